@@ -46,12 +46,15 @@ func (iter *CommitIterator) Next() (LogNode, bool) {
 	iter.branches = iter.branches.RemoveBranches(branchIndexes[1:])
 
 	// If this commit has parents, then a branch is splitting. Create a branch for each of the parents
-	// and splice that into the iterators list of branches.
+	// and splice that into the iterators list of branches. Parents that aren't present, because the
+	// database is shallow, end their branches.
 	branches := branchList{}
-	parents := commitRefsFromSet(br.commit.Get(datas.ParentsField).(types.Set))
-	for _, p := range parents {
-		b := branch{cr: p, commit: iter.db.ReadValue(p.TargetHash()).(types.Struct)}
-		branches = append(branches, b)
+	parents := []types.Ref{}
+	for _, p := range commitRefsFromSet(br.commit.Get(datas.ParentsField).(types.Set)) {
+		if v := iter.db.ReadValue(p.TargetHash()); v != nil {
+			parents = append(parents, p)
+			branches = append(branches, branch{cr: p, commit: v.(types.Struct)})
+		}
 	}
 	iter.branches = iter.branches.Splice(col, 1, branches...)
 
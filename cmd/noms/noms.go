@@ -12,13 +12,23 @@ import (
 )
 
 var commands = []*nomsCommand{
+	nomsBlame,
+	nomsCommit,
 	nomsDiff,
 	nomsDs,
+	nomsDu,
 	nomsLog,
+	nomsMigrate,
+	nomsQuery,
+	nomsRm,
 	nomsServe,
+	nomsSet,
 	nomsShow,
+	nomsSQL,
+	nomsStats,
 	nomsSync,
 	nomsVersion,
+	nomsWatch,
 }
 
 func main() {
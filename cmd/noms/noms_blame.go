@@ -0,0 +1,287 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var blameEach bool
+
+var nomsBlame = &nomsCommand{
+	Run:       runBlame,
+	UsageLine: "blame [options] <dataset><path>",
+	Short:     "Shows the commit that last changed a value in a dataset",
+	Long:      "Shows the most recent commit in the history of the dataset that changed the value at <path>, and its meta. <path> starts at the head commit, so it starts with .value; see #1399 for spelling the rest. A commit changed the value if it's different from the value at <path> in each of the commit's parents, so a merge commit only did if it's different from those in all the branches it merged.\n\nWith --each, the value must be a list, map, set or struct, and every element of it, entry of it, or field of it is shown with the commit that last changed it, followed by the meta of those commits. The elements of a list are followed through the insertions and removals of earlier ones.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupBlameFlags,
+	Nargs:     1,
+}
+
+func setupBlameFlags() *flag.FlagSet {
+	blameFlagSet := flag.NewFlagSet("blame", flag.ExitOnError)
+	blameFlagSet.BoolVar(&blameEach, "each", false, "show the commit that last changed each element, entry or field of the value")
+	outputpager.RegisterOutputpagerFlags(blameFlagSet)
+	spec.RegisterDatabaseFlags(blameFlagSet)
+	return blameFlagSet
+}
+
+func runBlame(args []string) int {
+	ds, path := getDatasetValuePath(args[0])
+	defer ds.Database().Close()
+
+	head, ok := ds.MaybeHead()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("No head value for dataset: %s", ds.ID()))
+	}
+	value := path.Resolve(head.Get(datas.ValueField))
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("No value at .%s%s in %s", datas.ValueField, path, ds.ID()))
+	}
+
+	var ids []types.Value
+	if blameEach {
+		ids = blameIDs(value)
+		if ids == nil {
+			d.CheckErrorNoUsage(fmt.Errorf("--each needs a List, Map, Set or Struct, but .%s%s is a %s", datas.ValueField, path, types.EncodedValue(value.Type())))
+		}
+	}
+	commits := blame(ds.Database(), head, path, ids)
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+
+	if !blameEach {
+		writeBlameCommit(pgr.Writer, commits[0])
+		return 0
+	}
+	tw := tabwriter.NewWriter(pgr.Writer, 0, 8, 2, ' ', 0)
+	shown := map[hash.Hash]bool{}
+	order := []types.Struct{}
+	for i, id := range ids {
+		c := commits[i]
+		fmt.Fprintf(tw, "%s\t%s\n", c.Hash().Abbrev(), blameLabel(value, id))
+		if !shown[c.Hash()] {
+			shown[c.Hash()] = true
+			order = append(order, c)
+		}
+	}
+	tw.Flush()
+	for _, c := range order {
+		fmt.Fprintln(pgr.Writer)
+		writeBlameCommit(pgr.Writer, c)
+	}
+	return 0
+}
+
+// blameItem is an element, entry or field of the value being blamed, or the value itself, that hasn't been blamed on a commit yet. id identifies it in the version of the value in the commit it's pending at, and n is its index in the results.
+type blameItem struct {
+	id types.Value
+	n  int
+}
+
+// blame walks the history of head, and returns the commits that last changed the elements, entries or fields that ids identify in the value at path, as blameIDs returns them. If ids is nil, it returns the one commit that last changed the value itself.
+//
+// Each id is pending at a commit whose version of it is the same as head's, starting at head. When the commit iterator reaches that commit, the id moves to the first of its parents whose version of it is the same too, if there is one; if there isn't, the commit is the one that changed it.
+func blame(db datas.Database, head types.Struct, path types.Path, ids []types.Value) []types.Struct {
+	whole := ids == nil
+	if whole {
+		ids = []types.Value{wholeValue}
+	}
+	res := make([]types.Struct, len(ids))
+	pending := map[hash.Hash][]blameItem{}
+	for i, id := range ids {
+		pending[head.Hash()] = append(pending[head.Hash()], blameItem{id, i})
+	}
+
+	iter := NewCommitIterator(db, head)
+	for node, ok := iter.Next(); ok && len(pending) > 0; node, ok = iter.Next() {
+		items := pending[node.cr.TargetHash()]
+		if len(items) == 0 {
+			continue
+		}
+		delete(pending, node.cr.TargetHash())
+
+		value := path.Resolve(node.commit.Get(datas.ValueField))
+		for _, p := range commitRefsFromSet(node.commit.Get(datas.ParentsField).(types.Set)) {
+			parent := db.ReadValue(p.TargetHash())
+			if parent == nil || len(items) == 0 {
+				continue
+			}
+			parentValue := path.Resolve(parent.(types.Struct).Get(datas.ValueField))
+			if parentValue == nil {
+				continue
+			}
+			parentIDs := []types.Value{nil}
+			if !whole {
+				parentIDs = blameParentIDs(value, parentValue, items)
+			} else if parentValue.Equals(value) {
+				parentIDs[0] = wholeValue
+			}
+			rest := []blameItem{}
+			for i, item := range items {
+				if parentIDs[i] != nil {
+					pending[p.TargetHash()] = append(pending[p.TargetHash()], blameItem{parentIDs[i], item.n})
+				} else {
+					rest = append(rest, item)
+				}
+			}
+			items = rest
+		}
+		for _, item := range items {
+			res[item.n] = node.commit
+		}
+	}
+	return res
+}
+
+// wholeValue is the id of the value being blamed itself, rather than of an element of it.
+var wholeValue = types.Bool(true)
+
+// blameIDs returns the ids of the elements, entries or fields of v: the indexes of a List, the keys of a Map, the elements of a Set, and the names of the fields of a Struct. It returns nil if v isn't one of those.
+func blameIDs(v types.Value) []types.Value {
+	ids := []types.Value{}
+	switch v := v.(type) {
+	case types.List:
+		for i := uint64(0); i < v.Len(); i++ {
+			ids = append(ids, types.Number(i))
+		}
+	case types.Map:
+		v.IterAll(func(k, _ types.Value) {
+			ids = append(ids, k)
+		})
+	case types.Set:
+		v.IterAll(func(e types.Value) {
+			ids = append(ids, e)
+		})
+	case types.Struct:
+		v.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			ids = append(ids, types.String(name))
+		})
+	default:
+		return nil
+	}
+	return ids
+}
+
+// blameParentIDs returns the ids in parent of the items in v, or nil for those that aren't the same in parent as they are in v.
+func blameParentIDs(v, parent types.Value, items []blameItem) []types.Value {
+	res := make([]types.Value, len(items))
+	switch v := v.(type) {
+	case types.List:
+		if parent, ok := parent.(types.List); ok {
+			indexes := listParentIndexes(v, parent)
+			for i, item := range items {
+				res[i] = indexes(uint64(item.id.(types.Number)))
+			}
+		}
+	case types.Map:
+		if parent, ok := parent.(types.Map); ok {
+			for i, item := range items {
+				if pv, ok := parent.MaybeGet(item.id); ok && pv.Equals(v.Get(item.id)) {
+					res[i] = item.id
+				}
+			}
+		}
+	case types.Set:
+		if parent, ok := parent.(types.Set); ok {
+			for i, item := range items {
+				if parent.Has(item.id) {
+					res[i] = item.id
+				}
+			}
+		}
+	case types.Struct:
+		if parent, ok := parent.(types.Struct); ok {
+			for i, item := range items {
+				name := string(item.id.(types.String))
+				if pv, ok := parent.MaybeGet(name); ok && pv.Equals(v.Get(name)) {
+					res[i] = item.id
+				}
+			}
+		}
+	}
+	return res
+}
+
+// listParentIndexes diffs l against parent, and returns a function that maps the index of an element of l to its index in parent, or to nil if it was inserted into l, or replaced, since parent.
+func listParentIndexes(l, parent types.List) func(i uint64) types.Value {
+	splices := []types.Splice{}
+	spliceChan := make(chan types.Splice)
+	go func() {
+		l.Diff(parent, spliceChan, nil)
+		close(spliceChan)
+	}()
+	for sp := range spliceChan {
+		splices = append(splices, sp)
+	}
+
+	return func(i uint64) types.Value {
+		// Find the first splice that doesn't end before i. Those before it moved i by the difference between where they end in l and in parent.
+		k := sort.Search(len(splices), func(k int) bool {
+			return splices[k].SpFrom+splices[k].SpAdded > i
+		})
+		if k < len(splices) && splices[k].SpFrom <= i && splices[k].SpAdded > 0 {
+			return nil
+		}
+		if k == 0 {
+			return types.Number(i)
+		}
+		prev := splices[k-1]
+		return types.Number(i - (prev.SpFrom + prev.SpAdded) + prev.SpAt + prev.SpRemoved)
+	}
+}
+
+// blameLabel names the element, entry or field of v that id identifies, as a path would where it can.
+func blameLabel(v, id types.Value) string {
+	switch v.(type) {
+	case types.Struct:
+		return types.NewPath().AddField(string(id.(types.String))).String()
+	case types.Set:
+		if isPrimitive(id) {
+			return types.EncodedValue(id)
+		}
+	default:
+		if isPrimitive(id) {
+			return types.NewPath().AddIndex(id).String()
+		}
+	}
+	return types.NewPath().AddHashIndex(id.Hash()).String()
+}
+
+func isPrimitive(v types.Value) bool {
+	k := v.Type().Kind()
+	return k == types.BoolKind || k == types.NumberKind || k == types.StringKind
+}
+
+// writeBlameCommit writes the hash of commit, and its meta, as noms log does.
+func writeBlameCommit(w io.Writer, commit types.Struct) {
+	fmt.Fprintf(w, "commit %s\n", commit.Hash())
+	m, ok := commit.MaybeGet(datas.MetaField)
+	if !ok {
+		return
+	}
+	meta := m.(types.Struct)
+	maxLen := 0
+	meta.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+		maxLen = max(maxLen, len(name))
+	})
+	meta.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+		fmt.Fprintf(w, "%-*s", maxLen+2, strings.Title(name)+":")
+		d.PanicIfError(types.WriteEncodedValue(w, meta.Get(name)))
+		fmt.Fprintln(w)
+	})
+}
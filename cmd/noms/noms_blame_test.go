@@ -0,0 +1,112 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBlame(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsBlameTestSuite{})
+}
+
+type nomsBlameTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+// commit commits v to ds with the message in its meta, on top of parents, or of the head of ds if there are none, and returns the new head.
+func (s *nomsBlameTestSuite) commit(ds *dataset.Dataset, v types.Value, message string, parents ...types.Struct) types.Struct {
+	opts := dataset.CommitOptions{Meta: types.NewStruct("Meta", types.StructData{"message": types.String(message)})}
+	if len(parents) > 0 {
+		opts.Parents = types.NewSet()
+		for _, p := range parents {
+			opts.Parents = opts.Parents.Insert(types.NewRef(p))
+		}
+	}
+	var err error
+	*ds, err = ds.Commit(v, opts)
+	s.NoError(err)
+	return ds.Head()
+}
+
+func blameCommitString(c types.Struct) string {
+	return fmt.Sprintf("commit %s\nMessage: %s\n", c.Hash(), types.EncodedValue(c.Get(datas.MetaField).(types.Struct).Get("message")))
+}
+
+func (s *nomsBlameTestSuite) TestBlameList() {
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "list")
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	nums := func(ns ...float64) types.List {
+		l := types.NewList()
+		for _, n := range ns {
+			l = l.Append(types.Number(n))
+		}
+		return l
+	}
+	c1 := s.commit(&ds, nums(10, 20, 30), "one")
+	c2 := s.commit(&ds, nums(5, 10, 20, 30), "two")
+	c3 := s.commit(&ds, nums(5, 10, 25, 30), "three")
+	ds.Database().Close()
+
+	out, _ := s.Run(main, []string{"blame", dsSpec + ".value"})
+	s.Equal(blameCommitString(c3), out)
+
+	// The path is resolved in each commit, so the element at index 3 was last changed when one was inserted before it.
+	out, _ = s.Run(main, []string{"blame", dsSpec + ".value[3]"})
+	s.Equal(blameCommitString(c2), out)
+
+	// With --each, elements are followed to their index in each commit.
+	out, _ = s.Run(main, []string{"blame", "--each", dsSpec + ".value"})
+	s.Equal(fmt.Sprintf("%s  [0]\n%s  [1]\n%s  [2]\n%s  [3]\n\n%s\n%s\n%s",
+		c2.Hash().Abbrev(), c1.Hash().Abbrev(), c3.Hash().Abbrev(), c1.Hash().Abbrev(),
+		blameCommitString(c2), blameCommitString(c1), blameCommitString(c3)), out)
+}
+
+func (s *nomsBlameTestSuite) TestBlameMerge() {
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "merged")
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	other := dataset.NewDataset(ds.Database(), "other")
+	base := s.commit(&ds, types.NewMap(types.String("a"), types.Number(1)), "base")
+	left := s.commit(&ds, types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2)), "left")
+	right := s.commit(&other, types.NewMap(types.String("a"), types.Number(1), types.String("c"), types.Number(3)), "right", base)
+	merge := s.commit(&ds, types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2), types.String("c"), types.Number(3)), "merge", left, right)
+	ds.Database().Close()
+
+	out, _ := s.Run(main, []string{"blame", dsSpec + ".value"})
+	s.Equal(blameCommitString(merge), out)
+
+	out, _ = s.Run(main, []string{"blame", dsSpec + `.value["c"]`})
+	s.Equal(blameCommitString(right), out)
+
+	out, _ = s.Run(main, []string{"blame", "--each", dsSpec + ".value"})
+	s.Equal(fmt.Sprintf("%s  [\"a\"]\n%s  [\"b\"]\n%s  [\"c\"]\n\n%s\n%s\n%s",
+		base.Hash().Abbrev(), left.Hash().Abbrev(), right.Hash().Abbrev(),
+		blameCommitString(base), blameCommitString(left), blameCommitString(right)), out)
+}
+
+func (s *nomsBlameTestSuite) TestBlameEachNotCollection() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "number")
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	s.commit(&ds, types.Number(1), "number")
+	ds.Database().Close()
+	s.Run(main, []string{"blame", "--each", dsSpec + ".value"})
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/jsontonoms"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var (
+	commitMessage string
+	commitMeta    metaFlag
+)
+
+var nomsCommit = &nomsCommand{
+	Run:       runCommit,
+	UsageLine: "commit [options] <value-source> <dataset>",
+	Short:     "Commits a value to a dataset",
+	Long:      "<value-source> is one of:\n  -          JSON read from stdin\n  <file>     a file, which is committed as a Blob\n  <object>   an object spec, naming a value in this or any other database\n\nThe new commit's meta has the current date, the message if one is given, and any fields given with --meta, all as strings.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
+	Flags:     setupCommitFlags,
+	Nargs:     2,
+}
+
+var metaFieldRe = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]*$")
+
+// metaFlag collects the key=value pairs given by repeated --meta flags.
+type metaFlag map[string]string
+
+func (m metaFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m metaFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("Invalid meta field, must be key=value: %s", s)
+	}
+	k := s[:i]
+	if !metaFieldRe.MatchString(k) {
+		return fmt.Errorf("Invalid meta field name: %s", k)
+	}
+	m[k] = s[i+1:]
+	return nil
+}
+
+func setupCommitFlags() *flag.FlagSet {
+	commitMeta = metaFlag{}
+	commitFlagSet := flag.NewFlagSet("commit", flag.ExitOnError)
+	commitFlagSet.StringVar(&commitMessage, "message", "", "message to record in the commit's meta")
+	commitFlagSet.StringVar(&commitMessage, "m", "", "message to record in the commit's meta")
+	commitFlagSet.Var(commitMeta, "meta", "key=value field to record in the commit's meta (may be given more than once)")
+	spec.RegisterDatabaseFlags(commitFlagSet)
+	return commitFlagSet
+}
+
+func runCommit(args []string) int {
+	ds, err := spec.GetDataset(args[1])
+	d.CheckError(err)
+	defer ds.Database().Close()
+
+	value := readCommitValue(args[0], ds.Database())
+	ds, err = commitToDataset(ds, value, commitMetaStruct(time.Now(), commitMessage, commitMeta))
+	d.CheckErrorNoUsage(err)
+
+	fmt.Printf("Committed %s to %s\n", ds.HeadRef().TargetHash().Abbrev(), ds.ID())
+	return 0
+}
+
+// readCommitValue reads the value that source names, as described by nomsCommit.Long, making sure that db has everything that it refers to.
+func readCommitValue(source string, db datas.Database) types.Value {
+	if source == "-" {
+		var decoded interface{}
+		d.CheckErrorNoUsage(json.NewDecoder(os.Stdin).Decode(&decoded))
+		value := jsontonoms.NomsValueFromDecodedJSON(decoded, false)
+		if value == nil {
+			d.CheckErrorNoUsage(fmt.Errorf("Can't commit null"))
+		}
+		return value
+	}
+
+	if f, err := os.Open(source); err == nil {
+		defer f.Close()
+		return types.NewStreamingBlob(f, db)
+	}
+
+	sourceDB, value, err := spec.GetPath(source)
+	d.CheckError(err)
+	defer sourceDB.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", source))
+	}
+	// value is written inline in the new commit, so only what it refers to needs to be copied. Reading each of those back lets db validate the commit, as in dataset.Commit.
+	for _, r := range value.Chunks() {
+		datas.Pull(sourceDB, db, r, types.Ref{}, 16, nil)
+		r.TargetValue(db)
+	}
+	return value
+}
+
+// commitMetaStruct builds the meta of a new commit. A "date" field in fields takes the place of date, so that commits can be backdated.
+func commitMetaStruct(date time.Time, message string, fields metaFlag) types.Struct {
+	data := types.StructData{"date": types.String(date.Format(time.RFC3339))}
+	for k, v := range fields {
+		data[k] = types.String(v)
+	}
+	if message != "" {
+		data["message"] = types.String(message)
+	}
+	return types.NewStruct("Meta", data)
+}
+
+// commitToDataset commits value to ds, on top of its head. If the head moves while it does, the error says so, rather than being datas.ErrMergeNeeded.
+func commitToDataset(ds dataset.Dataset, value types.Value, meta types.Struct) (dataset.Dataset, error) {
+	ds, err := ds.Commit(value, dataset.CommitOptions{Meta: meta})
+	if err == datas.ErrMergeNeeded {
+		return ds, fmt.Errorf("%s was changed by someone else while committing; try again", ds.ID())
+	}
+	return ds, err
+}
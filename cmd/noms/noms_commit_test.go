@@ -0,0 +1,130 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCommit(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsCommitTestSuite{})
+}
+
+type nomsCommitTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+// head returns the head of the dataset at dsSpec.
+func (s *nomsCommitTestSuite) head(dsSpec string) types.Struct {
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	defer ds.Database().Close()
+	return ds.Head()
+}
+
+func (s *nomsCommitTestSuite) TestCommitPath() {
+	sourceSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "source")
+	list := types.NewList(types.String("a"), types.String("b"))
+	writeTestData(sourceSpec, list)
+
+	// The value is committed to another database, along with what it refers to.
+	sinkDir := path.Join(s.TempDir, "commit-path")
+	sinkSpec := spec.CreateValueSpecString("ldb", sinkDir, "sink")
+	out, _ := s.Run(main, []string{"commit", "-m", "from a path", "--meta", "author=me", "--meta", "date=2016-08-01", sourceSpec + ".value", sinkSpec})
+	s.Contains(out, "Committed ")
+	s.Contains(out, " to sink")
+
+	ds, err := spec.GetDataset(sinkSpec)
+	s.NoError(err)
+	defer ds.Database().Close()
+	head := ds.Head()
+	r := head.Get(datas.ValueField).(types.Ref)
+	s.True(list.Equals(r.TargetValue(ds.Database())))
+
+	meta := head.Get(datas.MetaField).(types.Struct)
+	s.Equal(types.String("from a path"), meta.Get("message"))
+	s.Equal(types.String("me"), meta.Get("author"))
+	s.Equal(types.String("2016-08-01"), meta.Get("date"))
+}
+
+func (s *nomsCommitTestSuite) TestCommitJSON() {
+	stdin, err := ioutil.TempFile(s.TempDir, "")
+	s.NoError(err)
+	defer stdin.Close()
+	_, err = stdin.WriteString(`{"a": [1, "two"]}`)
+	s.NoError(err)
+	_, err = stdin.Seek(0, 0)
+	s.NoError(err)
+
+	origStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = origStdin }()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "json")
+	s.Run(main, []string{"commit", "-", dsSpec})
+
+	head := s.head(dsSpec)
+	expected := types.NewMap(types.String("a"), types.NewList(types.Number(1), types.String("two")))
+	s.True(expected.Equals(head.Get(datas.ValueField)))
+
+	meta := head.Get(datas.MetaField).(types.Struct)
+	_, ok := meta.MaybeGet("message")
+	s.False(ok)
+	date, err := time.Parse(time.RFC3339, string(meta.Get("date").(types.String)))
+	s.NoError(err)
+	s.WithinDuration(time.Now(), date, time.Minute)
+}
+
+func (s *nomsCommitTestSuite) TestCommitFile() {
+	file := path.Join(s.TempDir, "blob")
+	s.NoError(ioutil.WriteFile(file, []byte("some bytes"), 0644))
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "file")
+	s.Run(main, []string{"commit", file, dsSpec})
+	s.Run(main, []string{"commit", file, dsSpec})
+
+	head := s.head(dsSpec)
+	blob := head.Get(datas.ValueField).(types.Blob)
+	data, err := ioutil.ReadAll(blob.Reader())
+	s.NoError(err)
+	s.Equal("some bytes", string(data))
+	s.Equal(uint64(1), head.Get(datas.ParentsField).(types.Set).Len())
+}
+
+func (s *nomsCommitTestSuite) TestCommitMissingSource() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "missing")
+	s.Run(main, []string{"commit", spec.CreateValueSpecString("ldb", s.LdbDir, "nothing"), dsSpec})
+}
+
+func (s *nomsCommitTestSuite) TestCommitMergeNeeded() {
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	stale := dataset.NewDataset(db, "ds")
+	_, err := stale.CommitValue(types.Number(1))
+	s.NoError(err)
+
+	_, err = commitToDataset(stale, types.Number(2), commitMetaStruct(time.Now(), "", metaFlag{}))
+	s.Error(err)
+	s.NotEqual(datas.ErrMergeNeeded, err)
+	s.Contains(err.Error(), "ds was changed")
+}
@@ -72,7 +72,7 @@ func (s *nomsDsTestSuite) TestNomsDs() {
 
 	// delete one dataset, print message at delete
 	rtnVal, _ = s.Run(main, []string{"ds", "-d", datasetName})
-	s.Equal("Deleted dataset "+id+" (was 6ebc05f71q4sk2psi534fom9se228161)\n\n", rtnVal)
+	s.Equal("Deleted dataset "+id+" (was 6ebc05f71q)\n\n", rtnVal)
 
 	// print datasets, just one left
 	rtnVal, _ = s.Run(main, []string{"ds", dbSpec})
@@ -80,7 +80,7 @@ func (s *nomsDsTestSuite) TestNomsDs() {
 
 	// delete the second dataset
 	rtnVal, _ = s.Run(main, []string{"ds", "-d", dataset2Name})
-	s.Equal("Deleted dataset "+id2+" (was f5qtovr9mv7mjj2uoq7flcfpksgf0s2j)\n\n", rtnVal)
+	s.Equal("Deleted dataset "+id2+" (was f5qtovr9mv)\n\n", rtnVal)
 
 	// print datasets, none left
 	rtnVal, _ = s.Run(main, []string{"ds", dbSpec})
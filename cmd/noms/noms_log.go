@@ -10,10 +10,12 @@ import (
 	"io"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/noms/diff"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/orderedparallel"
@@ -28,8 +30,13 @@ var (
 	maxLines   int
 	maxCommits int
 	oneline    bool
+	fullHash   bool
 	showGraph  bool
 	showValue  bool
+	logPath    string
+	logSince   string
+	logUntil   string
+	logMeta    metaFlag
 )
 
 const parallelism = 16
@@ -38,7 +45,7 @@ var nomsLog = &nomsCommand{
 	Run:       runLog,
 	UsageLine: "log [options] <commitObject>",
 	Short:     "Displays the history of a Noms dataset",
-	Long:      "commitObject must be a dataset or object spec that refers to a commit. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details.",
+	Long:      "commitObject must be a dataset or object spec that refers to a commit. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details.\n\nThe commits shown can be limited to those that changed the value at a path in the commit's value, such as .config.limits, with --path; those whose meta date is in a range, with --since and --until; and those with meta fields of given values, with --meta. A commit changed the value at the path if it's different from the value at the path in each of the commit's parents. Dates are RFC 3339, e.g. 2016-10-19T12:00:00Z, or in the local time zone, e.g. 2016-10-19 12:00:00 or just 2016-10-19; --until a day includes all of it. The meta dates that csv-import and url-fetch write, e.g. 2016-10-19T12:00:00-0700, are understood too.",
 	Flags:     setupLogFlags,
 	Nargs:     1,
 }
@@ -49,8 +56,14 @@ func setupLogFlags() *flag.FlagSet {
 	logFlagSet.IntVar(&maxLines, "max-lines", 10, "max number of lines to show per commit (-1 for all lines)")
 	logFlagSet.IntVar(&maxCommits, "n", 0, "max number of commits to display (0 for all commits)")
 	logFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
+	logFlagSet.BoolVar(&fullHash, "full-hash", false, "show whole hashes, rather than abbreviated ones, with -oneline")
 	logFlagSet.BoolVar(&showGraph, "graph", false, "show ascii-based commit hierarcy on left side of output")
 	logFlagSet.BoolVar(&showValue, "show-value", false, "show commit value rather than diff information -- this is temporary")
+	logFlagSet.StringVar(&logPath, "path", "", "show only commits that changed the value at this path in the commit's value")
+	logFlagSet.StringVar(&logSince, "since", "", "show only commits whose meta date is at or after this date")
+	logFlagSet.StringVar(&logUntil, "until", "", "show only commits whose meta date is at or before this date")
+	logMeta = metaFlag{}
+	logFlagSet.Var(logMeta, "meta", "key=value field that shown commits must have in their meta (may be given more than once)")
 	outputpager.RegisterOutputpagerFlags(logFlagSet)
 	return logFlagSet
 }
@@ -73,6 +86,12 @@ func runLog(args []string) int {
 		d.CheckError(fmt.Errorf("%s does not reference a Commit object", args[0]))
 	}
 
+	filter, err := newLogFilter(logPath, logSince, logUntil, logMeta)
+	d.CheckErrorNoUsage(err)
+	if showGraph && filter.active() {
+		d.CheckErrorNoUsage(fmt.Errorf("--graph can't be used with --path, --since, --until or --meta"))
+	}
+
 	iter := NewCommitIterator(database, origCommit)
 	displayed := 0
 	if maxCommits <= 0 {
@@ -86,11 +105,28 @@ func runLog(args []string) int {
 		return buff.Bytes()
 	}, parallelism)
 
+	// Each commit is held back until the next one to be shown is found, so that the last one shown can be marked as the last, even if those after it were filtered out.
 	go func() {
-		for ln, ok := iter.Next(); ok && displayed < maxCommits; ln, ok = iter.Next() {
-			inChan <- ln
+		var prev LogNode
+		hasPrev := false
+		for displayed < maxCommits {
+			ln, ok := iter.Next()
+			if !ok {
+				prev.lastCommit = true
+				break
+			}
+			if !filter.matches(ln.commit, database) {
+				continue
+			}
+			if hasPrev {
+				inChan <- prev
+			}
+			prev, hasPrev = ln, true
 			displayed++
 		}
+		if hasPrev {
+			inChan <- prev
+		}
 		close(inChan)
 	}()
 
@@ -117,7 +153,7 @@ func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
 		return maxLen
 	}
 
-	hashStr := node.commit.Hash().String()
+	hashStr := commitHashString(node.commit.Hash())
 	if useColor {
 		hashStr = ansi.Color("commit "+hashStr, "red+h")
 	}
@@ -127,15 +163,22 @@ func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
 	parentLabel := "Parent"
 	parentValue := "None"
 	parents := commitRefsFromSet(node.commit.Get(datas.ParentsField).(types.Set))
+	// Parents that aren't present are beyond the history of a shallow database.
+	parentString := func(p types.Ref) string {
+		if db.ReadValue(p.TargetHash()) == nil {
+			return commitHashString(p.TargetHash()) + " (not present)"
+		}
+		return commitHashString(p.TargetHash())
+	}
 	if len(parents) > 1 {
 		pstrings := make([]string, len(parents))
 		for i, p := range parents {
-			pstrings[i] = p.TargetHash().String()
+			pstrings[i] = parentString(p)
 		}
 		parentLabel = "Merge"
 		parentValue = strings.Join(pstrings, " ")
 	} else if len(parents) == 1 {
-		parentValue = parents[0].TargetHash().String()
+		parentValue = parentString(parents[0])
 	}
 
 	if oneline {
@@ -166,6 +209,14 @@ func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
 	return
 }
 
+// commitHashString abbreviates h when commits are shown on a single line, unless -full-hash is given. The abbreviation can be used in place of h in a spec.
+func commitHashString(h hash.Hash) string {
+	if oneline && !fullHash {
+		return h.Abbrev()
+	}
+	return h.String()
+}
+
 // Generates ascii graph chars to display on the left side of the commit info if -graph arg is true.
 func genGraph(node LogNode, lineno int) string {
 	if !showGraph {
@@ -257,14 +308,15 @@ func writeDiffLines(node LogNode, db datas.Database, maxLines, lineno int, w io.
 	parents := node.commit.Get(datas.ParentsField).(types.Set)
 	var parent types.Value = nil
 	if parents.Len() > 0 {
-		parent = parents.First()
+		parent = parents.First().(types.Ref).TargetValue(db)
 	}
+	// parent is also nil if it isn't present, because the database is shallow.
 	if parent == nil {
 		_, err = fmt.Fprint(mlw, "\n")
 		return 1, err
 	}
 
-	parentCommit := parent.(types.Ref).TargetValue(db).(types.Struct)
+	parentCommit := parent.(types.Struct)
 	err = diff.Diff(mlw, parentCommit.Get(datas.ValueField), node.commit.Get(datas.ValueField))
 	d.PanicIfNotType(err, MaxLinesErr)
 	if err != nil {
@@ -278,6 +330,108 @@ func writeDiffLines(node LogNode, db datas.Database, maxLines, lineno int, w io.
 	return mlw.numLines, err
 }
 
+// logFilter selects the commits that noms log shows. Its zero value selects them all.
+type logFilter struct {
+	// path, if not nil, is the path in the value of each commit that it must have changed.
+	path types.Path
+	// since and until, if not zero, are the earliest and latest meta date a commit can have.
+	since, until time.Time
+	meta         metaFlag
+}
+
+func newLogFilter(path, since, until string, meta metaFlag) (f logFilter, err error) {
+	if path != "" {
+		if f.path, err = types.ParsePath(path); err != nil {
+			return
+		}
+	}
+	if since != "" {
+		if f.since, _, err = parseLogDate(since); err != nil {
+			return
+		}
+	}
+	if until != "" {
+		var day bool
+		if f.until, day, err = parseLogDate(until); err != nil {
+			return
+		}
+		if day {
+			f.until = f.until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	f.meta = meta
+	return
+}
+
+// parseLogDate parses s as spec.ParseCommitDate does, and reports whether it's just a day, which starts at midnight in the local time zone.
+func parseLogDate(s string) (t time.Time, day bool, err error) {
+	t, ok := spec.ParseCommitDate(s)
+	if !ok {
+		return t, false, fmt.Errorf("Invalid date, must be e.g. 2016-10-19T12:00:00Z, 2016-10-19 12:00:00 or 2016-10-19: %s", s)
+	}
+	return t, len(s) == len("2006-01-02"), nil
+}
+
+func (f logFilter) active() bool {
+	return f.path != nil || !f.since.IsZero() || !f.until.IsZero() || len(f.meta) > 0
+}
+
+// matches reports whether commit passes f. The meta is checked first, because checking the path means reading the commit's parents.
+func (f logFilter) matches(commit types.Struct, db datas.Database) bool {
+	var meta types.Struct
+	if m, ok := commit.MaybeGet(datas.MetaField); ok {
+		meta, _ = m.(types.Struct)
+	}
+	metaField := func(name string) (types.Value, bool) {
+		if meta.Type() == nil {
+			return nil, false
+		}
+		return meta.MaybeGet(name)
+	}
+
+	for k, v := range f.meta {
+		mv, ok := metaField(k)
+		if !ok {
+			return false
+		}
+		if s, isString := mv.(types.String); isString && string(s) != v || !isString && types.EncodedValue(mv) != v {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() || !f.until.IsZero() {
+		mv, ok := metaField("date")
+		s, isString := mv.(types.String)
+		if !ok || !isString {
+			return false
+		}
+		date, ok := spec.ParseCommitDate(string(s))
+		if !ok || !f.since.IsZero() && date.Before(f.since) || !f.until.IsZero() && date.After(f.until) {
+			return false
+		}
+	}
+
+	if f.path != nil {
+		value := f.path.Resolve(commit.Get(datas.ValueField))
+		hasParent := false
+		for _, p := range commitRefsFromSet(commit.Get(datas.ParentsField).(types.Set)) {
+			parent := db.ReadValue(p.TargetHash())
+			if parent == nil {
+				continue
+			}
+			hasParent = true
+			parentValue := f.path.Resolve(parent.(types.Struct).Get(datas.ValueField))
+			if value == nil && parentValue == nil || value != nil && parentValue != nil && value.Equals(parentValue) {
+				return false
+			}
+		}
+		if !hasParent && value == nil {
+			return false
+		}
+	}
+	return true
+}
+
 func shouldUseColor() bool {
 	if color != 1 && color != 0 {
 		return outputpager.IsStdoutTty()
@@ -6,6 +6,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/attic-labs/noms/go/d"
@@ -141,6 +142,13 @@ func (s *nomsLogTestSuite) TestEmptyCommit() {
 
 	res, _ = s.Run(main, []string{"log", "--show-value=false", "--oneline", dsSpec})
 	test.EqualsIgnoreHashes(s.T(), metaRes2, res)
+
+	res, _ = s.Run(main, []string{"log", "--show-value=false", "--oneline", "--full-hash", dsSpec})
+	test.EqualsIgnoreHashes(s.T(), metaRes3, res)
+
+	// The abbreviated hashes can be used to name commits.
+	res, _ = s.Run(main, []string{"log", "--show-value=false", "--oneline", spec.CreateValueSpecString("ldb", s.LdbDir, "#f8gjiv5974")})
+	test.EqualsIgnoreHashes(s.T(), "f8gjiv5974 (Parent: None)\n", res)
 }
 
 func (s *nomsLogTestSuite) TestNomsGraph1() {
@@ -309,6 +317,63 @@ func (s *nomsLogTestSuite) TestTruncation() {
 	test.EqualsIgnoreHashes(s.T(), diffTrunc3, res)
 }
 
+func (s *nomsLogTestSuite) TestFilters() {
+	str := spec.CreateDatabaseSpecString("ldb", s.LdbDir)
+	db, err := spec.GetDatabase(str)
+	s.NoError(err)
+
+	ds := dataset.NewDataset(db, "filters")
+	commit := func(name string, limit float64, date, author string) string {
+		value := types.NewStruct("", types.StructData{
+			"name":   types.String(name),
+			"config": types.NewStruct("", types.StructData{"limits": types.Number(limit)}),
+		})
+		meta := types.NewStruct("Meta", types.StructData{"date": types.String(date), "author": types.String(author)})
+		ds, err = ds.Commit(value, dataset.CommitOptions{Meta: meta})
+		s.NoError(err)
+		return ds.Head().Hash().Abbrev()
+	}
+	c1 := commit("a", 1, "2016-01-01T00:00:00Z", "x")
+	c2 := commit("b", 1, "2016-02-01T12:00:00Z", "y")
+	c3 := commit("b", 2, "2016-03-01T12:00:00Z", "x")
+	// csv-import and url-fetch write dates like this, which isn't RFC 3339.
+	c4 := commit("c", 2, "2016-03-15T10:00:00-0700", "y")
+	db.Close()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "filters")
+	shown := func(args ...string) []string {
+		res, _ := s.Run(main, append(append([]string{"log", "--oneline"}, args...), dsSpec))
+		hashes := []string{}
+		for _, c := range []string{c1, c2, c3, c4} {
+			if strings.Contains(res, c+" ") {
+				hashes = append(hashes, c)
+			}
+		}
+		return hashes
+	}
+
+	s.Equal([]string{c1, c3}, shown("--path", ".config.limits"))
+	s.Equal([]string{c1, c2, c4}, shown("--path", ".name"))
+	s.Equal([]string{c3}, shown("--path", ".config.limits", "-n", "1"))
+	s.Equal([]string{c2, c3}, shown("--since", "2016-02-01", "--until", "2016-03-02"))
+	s.Equal([]string{c1, c2, c3}, shown("--until", "2016-03-01T12:00:00Z"))
+	s.Equal([]string{c4}, shown("--since", "2016-03-01T12:00:01Z"))
+	s.Equal([]string{c4}, shown("--since", "2016-03-15T17:00:00Z"))
+	s.Empty(shown("--since", "2016-03-15T17:00:01Z"))
+	s.Equal([]string{c3, c4}, shown("--since", "2016-03-01", "--until", "2016-03-16"))
+	s.Equal([]string{c1, c3}, shown("--meta", "author=x"))
+	s.Equal([]string{c1}, shown("--meta", "author=x", "--path", ".name"))
+	s.Empty(shown("--meta", "author=x", "--meta", "nope=x"))
+
+	// The last commit shown isn't followed by a blank line, even when those after it are filtered out.
+	res, _ := s.Run(main, []string{"log", "--path", ".name", "--since", "2016-02-01", dsSpec})
+	s.True(strings.HasSuffix(res, "+   name: \"b\"\n  }\n"), res)
+
+	s.Panics(func() { s.Run(main, []string{"log", "--graph", "--path", ".name", dsSpec}) })
+	s.Panics(func() { s.Run(main, []string{"log", "--since", "yesterday", dsSpec}) })
+	s.Panics(func() { s.Run(main, []string{"log", "--path", "name", dsSpec}) })
+}
+
 func TestBranchlistSplice(t *testing.T) {
 	assert := assert.New(t)
 	bl := branchList{}
@@ -352,5 +417,6 @@ const (
 	diffTrunc3 = "* p1442asfqnhgv1ebg6rijhl3kb9n4vt3\n| Parent: 4tq9si4tk8n0pead7hovehcbuued45sa\n* 4tq9si4tk8n0pead7hovehcbuued45sa\n| Parent: None\n"
 
 	metaRes1 = "p7jmuh67vhfccnqk1bilnlovnms1m67o\nParent: f8gjiv5974ojir9tnrl2k393o4s1tf0r\n-   \"1\"\n+   \"2\"\n\nf8gjiv5974ojir9tnrl2k393o4s1tf0r\nParent:          None\nLongNameForTest: \"Yoo\"\nTest2:           \"Hoo\"\n\n"
-	metaRes2 = "p7jmuh67vhfccnqk1bilnlovnms1m67o (Parent: f8gjiv5974ojir9tnrl2k393o4s1tf0r)\nf8gjiv5974ojir9tnrl2k393o4s1tf0r (Parent: None)\n"
+	metaRes2 = "p7jmuh67vh (Parent: f8gjiv5974)\nf8gjiv5974 (Parent: None)\n"
+	metaRes3 = "p7jmuh67vhfccnqk1bilnlovnms1m67o (Parent: f8gjiv5974ojir9tnrl2k393o4s1tf0r)\nf8gjiv5974ojir9tnrl2k393o4s1tf0r (Parent: None)\n"
 )
@@ -0,0 +1,51 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/migration"
+	"github.com/attic-labs/noms/go/spec"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsMigrate = &nomsCommand{
+	Run:       runMigrate,
+	UsageLine: "migrate <source-database> <dest-database>",
+	Short:     "Converts a database written by another version of Noms to the current format",
+	Long:      "Every dataset in the source database is converted, along with its full commit history, and committed to the destination database under the same name. The old and new head of each dataset are printed once it has been migrated. The source database must be local, i.e. not http(s). See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database arguments.",
+	Flags:     setupMigrateFlags,
+	Nargs:     2,
+}
+
+func setupMigrateFlags() *flag.FlagSet {
+	migrateFlagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	spec.RegisterDatabaseFlags(migrateFlagSet)
+	return migrateFlagSet
+}
+
+func runMigrate(args []string) int {
+	src, err := spec.GetChunkStore(args[0])
+	d.CheckError(err)
+	defer src.Close()
+
+	sink, err := spec.GetDatabase(args[1])
+	d.CheckError(err)
+	defer sink.Close()
+
+	var heads []migration.Head
+	err = d.Try(func() {
+		var err error
+		sink, heads, err = migration.Migrate(src, sink)
+		d.PanicIfError(err)
+	})
+	for _, h := range heads {
+		fmt.Printf("%s: #%s => #%s\n", h.ID, h.Old, h.New)
+	}
+	d.CheckErrorNoUsage(d.Unwrap(err))
+	return 0
+}
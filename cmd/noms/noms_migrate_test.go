@@ -0,0 +1,74 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestMigrate(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsMigrateTestSuite{})
+}
+
+type nomsMigrateTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsMigrateTestSuite) TestMigrate() {
+	srcDir := path.Join(s.TempDir, "migrate-src")
+	db := datas.NewDatabase(chunks.NewLevelDBStore(srcDir, "", 24, false))
+	ds1 := dataset.NewDataset(db, "one")
+	ds1, err := ds1.CommitValue(types.Number(1))
+	s.NoError(err)
+	ds1, err = ds1.CommitValue(types.Number(2))
+	s.NoError(err)
+	ds2 := dataset.NewDataset(ds1.Database(), "two")
+	ds2, err = ds2.CommitValue(types.String("two"))
+	s.NoError(err)
+	h1, h2 := ds1.Head().Hash(), ds2.Head().Hash()
+	ds2.Database().Close()
+
+	sinkDir := path.Join(s.TempDir, "migrate-sink")
+	out, _ := s.Run(main, []string{"migrate", spec.CreateDatabaseSpecString("ldb", srcDir), spec.CreateDatabaseSpecString("ldb", sinkDir)})
+	s.Equal(fmt.Sprintf("one: #%s => #%s\ntwo: #%s => #%s\n", h1, h1, h2, h2), out)
+
+	sink := datas.NewDatabase(chunks.NewLevelDBStore(sinkDir, "", 24, false))
+	defer sink.Close()
+	s.True(types.Number(2).Equals(sink.Head("one").Get(datas.ValueField)))
+	parent := sink.ReadValue(sink.Head("one").Get(datas.ParentsField).(types.Set).First().(types.Ref).TargetHash())
+	s.True(types.Number(1).Equals(parent.(types.Struct).Get(datas.ValueField)))
+	s.True(types.String("two").Equals(sink.Head("two").Get(datas.ValueField)))
+}
+
+func (s *nomsMigrateTestSuite) TestMigrateExistingDataset() {
+	srcDir := path.Join(s.TempDir, "migrate-existing-src")
+	src := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(srcDir, "", 24, false)), "ds")
+	src, err := src.CommitValue(types.Number(1))
+	s.NoError(err)
+	src.Database().Close()
+
+	sinkDir := path.Join(s.TempDir, "migrate-existing-sink")
+	sink := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(sinkDir, "", 24, false)), "ds")
+	sink, err = sink.CommitValue(types.Number(2))
+	s.NoError(err)
+	sink.Database().Close()
+
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+	s.Run(main, []string{"migrate", spec.CreateDatabaseSpecString("ldb", srcDir), spec.CreateDatabaseSpecString("ldb", sinkDir)})
+}
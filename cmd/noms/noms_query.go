@@ -0,0 +1,56 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/jmespath"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsQuery = &nomsCommand{
+	Run:       runQuery,
+	UsageLine: "query [options] <expr> <object>",
+	Short:     "Evaluates a JMESPath expression against a Noms object",
+	Long:      "Shows the result of evaluating the JMESPath (http://jmespath.org) expression <expr> against <object>, as JSON. For example, if the value of the dataset people is a list of structs with name and age fields, the names of those over 30 are:\n\n  noms query 'value[?age > `30`].name' ldb:/tmp/db::people\n\nStructs, and maps with string keys, are objects. Lists and sets are arrays, as are other maps, of [key, value] pairs. Refs are followed as the expression steps through them; any in the result are shown as {\"@ref\": \"<hash>\"}. Only what the expression looks at is read.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
+	Flags:     setupQueryFlags,
+	Nargs:     2,
+}
+
+func setupQueryFlags() *flag.FlagSet {
+	queryFlagSet := flag.NewFlagSet("query", flag.ExitOnError)
+	outputpager.RegisterOutputpagerFlags(queryFlagSet)
+	spec.RegisterDatabaseFlags(queryFlagSet)
+	return queryFlagSet
+}
+
+func runQuery(args []string) int {
+	q, err := jmespath.Compile(args[0])
+	d.CheckErrorNoUsage(err)
+
+	database, value, err := spec.GetPath(args[1])
+	d.CheckErrorNoUsage(err)
+	defer database.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[1]))
+	}
+
+	res, err := q.Search(value, database)
+	d.CheckErrorNoUsage(err)
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+
+	enc := json.NewEncoder(pgr.Writer)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	d.CheckErrorNoUsage(enc.Encode(res))
+	return 0
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsQuery(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsQueryTestSuite{})
+}
+
+type nomsQueryTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsQueryTestSuite) TestQuery() {
+	people := types.NewList(
+		types.NewStruct("Person", types.StructData{"name": types.String("alice"), "age": types.Number(42)}),
+		types.NewStruct("Person", types.StructData{"name": types.String("bob"), "age": types.Number(25)}),
+	)
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "people")
+	writeTestData(dsSpec, people)
+
+	out, _ := s.Run(main, []string{"query", "value[?age > `30`].name", dsSpec})
+	s.Equal("[\n  \"alice\"\n]\n", out)
+
+	out, _ = s.Run(main, []string{"query", "length(value)", dsSpec})
+	s.Equal("2\n", out)
+
+	// The Ref at .value is followed.
+	out, _ = s.Run(main, []string{"query", "[1].{n: name}", dsSpec + ".value"})
+	s.Equal("{\n  \"n\": \"bob\"\n}\n", out)
+}
+
+func (s *nomsQueryTestSuite) TestQueryInvalidExpression() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "invalid")
+	writeTestData(dsSpec, types.Number(1))
+	s.Run(main, []string{"query", "value[?", dsSpec})
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsRm = &nomsCommand{
+	Run:       runRm,
+	UsageLine: "rm <dataset><path>",
+	Short:     "Removes a value from inside the head of a dataset",
+	Long:      "Commits a new head to the dataset, without the value at <path>, which is removed from the struct, list, map or set that holds it. <path> starts at the head commit, so it starts with .value; see #1399 for spelling the rest.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupRmFlags,
+	Nargs:     1,
+}
+
+func setupRmFlags() *flag.FlagSet {
+	rmFlagSet := flag.NewFlagSet("rm", flag.ExitOnError)
+	spec.RegisterDatabaseFlags(rmFlagSet)
+	return rmFlagSet
+}
+
+func runRm(args []string) int {
+	ds, path := getDatasetValuePath(args[0])
+	defer ds.Database().Close()
+
+	head, ok := ds.MaybeHeadValue()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("No head value for dataset: %s", ds.ID()))
+	}
+	value, err := path.Remove(head)
+	d.CheckErrorNoUsage(err)
+
+	commitEdit(ds, value, fmt.Sprintf("Removed .%s%s", datas.ValueField, path))
+	return 0
+}
@@ -5,26 +5,34 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/jsonapi"
 	"github.com/attic-labs/noms/go/util/profile"
 	flag "github.com/tsuru/gnuflag"
 )
 
 var (
-	port int
+	port        int
+	tokensFile  string
+	tlsCert     string
+	tlsKey      string
+	tlsClientCA string
 )
 
 var nomsServe = &nomsCommand{
 	Run:       runServe,
 	UsageLine: "serve [options] <database>",
 	Short:     "Serves a Noms database over HTTP",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.\n\nWith --tokens, clients must present an access token, either in an \"Authorization: Bearer <token>\" header or as the access_token query parameter of the database spec (e.g. http://localhost:8000?access_token=<token>). Each line of the tokens file has the form:\n\n  <token> read|write [<dataset-prefix>...]\n\nRead tokens may fetch any data. Write tokens may also write data and, if any prefixes are given, commit only to datasets whose IDs begin with one of them. Lines beginning with # are ignored.\n\nWith --tls-cert and --tls-key, the database is served over HTTPS. Adding --tls-client-ca also requires clients to present a certificate signed by one of its authorities.\n\nValues can also be read as JSON, without a Noms client, from /json/<path>, where <path> is a dataset or #hash followed by an optional value path (e.g. /json/ds.value.field). Collections are returned a page at a time; each response's \"next\" field, if present, is the URL of the following page. Similarly, /diff/?from=<path>&to=<path> streams the changes between two values as newline-delimited JSON.",
 	Flags:     setupServeFlags,
 	Nargs:     1,
 }
@@ -32,6 +40,10 @@ var nomsServe = &nomsCommand{
 func setupServeFlags() *flag.FlagSet {
 	serveFlagSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveFlagSet.IntVar(&port, "port", 8000, "port to listen on for HTTP requests")
+	serveFlagSet.StringVar(&tokensFile, "tokens", "", "file of access tokens that clients must present (see below)")
+	serveFlagSet.StringVar(&tlsCert, "tls-cert", "", "PEM file of the certificate to serve HTTPS with")
+	serveFlagSet.StringVar(&tlsKey, "tls-key", "", "PEM file of the private key for --tls-cert")
+	serveFlagSet.StringVar(&tlsClientCA, "tls-client-ca", "", "PEM file of certificate authorities that client certificates must be signed by")
 	spec.RegisterDatabaseFlags(serveFlagSet)
 	return serveFlagSet
 }
@@ -40,6 +52,19 @@ func runServe(args []string) int {
 	cs, err := spec.GetChunkStore(args[0])
 	d.CheckError(err)
 	server := datas.NewRemoteDatabaseServer(cs, port)
+	server.AddRoute("GET", constants.JSONPath+"*path", jsonapi.HandleGet)
+	server.AddRoute("GET", constants.DiffPath, jsonapi.HandleDiff)
+	if tokensFile != "" {
+		server.Tokens, err = readTokens(tokensFile)
+		d.CheckErrorNoUsage(err)
+	}
+	if tlsCert != "" || tlsKey != "" || tlsClientCA != "" {
+		if tlsCert == "" || tlsKey == "" {
+			d.CheckError(errors.New("--tls-cert and --tls-key must be given together"))
+		}
+		server.TLSConfig, err = datas.NewServerTLSConfig(tlsCert, tlsKey, tlsClientCA)
+		d.CheckErrorNoUsage(err)
+	}
 
 	// Shutdown server gracefully so that profile may be written
 	c := make(chan os.Signal, 1)
@@ -56,3 +81,16 @@ func runServe(args []string) int {
 	})
 	return 0
 }
+
+func readTokens(path string) (datas.TokenStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tokens, err := datas.ReadTokenStore(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return tokens, nil
+}
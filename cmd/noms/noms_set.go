@@ -0,0 +1,71 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsSet = &nomsCommand{
+	Run:       runSet,
+	UsageLine: "set <dataset><path> <value>",
+	Short:     "Sets a value inside the head of a dataset",
+	Long:      "Commits a new head to the dataset, in which the value at <path> is <value>. <path> starts at the head commit, so it starts with .value; see #1399 for spelling the rest. Its last part needn't exist yet: it can add a field to a struct, a key to a map, or an element to the end of a list.\n\n<value> is JSON, or a value written the way noms show writes it, e.g. 'Person {name: \"Bob\", tags: {\"a\", \"b\"}}'. JSON objects become maps.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupSetFlags,
+	Nargs:     2,
+}
+
+func setupSetFlags() *flag.FlagSet {
+	setFlagSet := flag.NewFlagSet("set", flag.ExitOnError)
+	spec.RegisterDatabaseFlags(setFlagSet)
+	return setFlagSet
+}
+
+func runSet(args []string) int {
+	ds, path := getDatasetValuePath(args[0])
+	defer ds.Database().Close()
+
+	newValue, err := types.ParseEncodedValue(args[1])
+	d.CheckErrorNoUsage(err)
+
+	var value types.Value
+	if head, ok := ds.MaybeHeadValue(); ok {
+		value = head
+	} else if len(path) > 0 {
+		d.CheckErrorNoUsage(fmt.Errorf("No head value for dataset: %s", ds.ID()))
+	}
+	value, err = path.Set(value, newValue)
+	d.CheckErrorNoUsage(err)
+
+	message := fmt.Sprintf("Set .%s%s to %s", datas.ValueField, path, types.EncodedValue(newValue))
+	commitEdit(ds, value, message)
+	return 0
+}
+
+// getDatasetValuePath parses str as a dataset followed by a path into the value of its head, which must start with ".value". It returns the dataset and the rest of the path.
+func getDatasetValuePath(str string) (dataset.Dataset, types.Path) {
+	ds, path, err := spec.GetDatasetPath(str)
+	d.CheckErrorNoUsage(err)
+	if len(path) == 0 || path[:1].String() != "."+datas.ValueField {
+		ds.Database().Close()
+		d.CheckErrorNoUsage(fmt.Errorf("Path must start with .%s: %s", datas.ValueField, str))
+	}
+	return ds, path[1:]
+}
+
+// commitEdit commits value to ds, on top of its head, with meta describing the edit in message.
+func commitEdit(ds dataset.Dataset, value types.Value, message string) {
+	ds, err := commitToDataset(ds, value, commitMetaStruct(time.Now(), message, metaFlag{}))
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Committed %s to %s\n", ds.HeadRef().TargetHash().Abbrev(), ds.ID())
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsSet(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsSetTestSuite{})
+}
+
+type nomsSetTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsSetTestSuite) commit(dsSpec string, value types.Value) {
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	defer ds.Database().Close()
+	_, err = ds.CommitValue(value)
+	s.NoError(err)
+}
+
+func (s *nomsSetTestSuite) head(dsSpec string) types.Struct {
+	ds, err := spec.GetDataset(dsSpec)
+	s.NoError(err)
+	defer ds.Database().Close()
+	return ds.Head()
+}
+
+func (s *nomsSetTestSuite) TestSetAndRm() {
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "people")
+	bob := types.NewStruct("Person", types.StructData{"name": types.String("bob")})
+	s.commit(dsSpec, types.NewMap(types.String("bob"), bob))
+	parent := s.head(dsSpec)
+
+	out, _ := s.Run(main, []string{"set", dsSpec + `.value["bob"].age`, "42"})
+	s.Contains(out, "Committed ")
+	s.Contains(out, " to people")
+	head := s.head(dsSpec)
+	s.True(types.NewMap(types.String("bob"), types.NewStruct("Person", types.StructData{"name": types.String("bob"), "age": types.Number(42)})).Equals(head.Get(datas.ValueField)))
+	s.True(types.NewSet(types.NewRef(parent)).Equals(head.Get(datas.ParentsField)))
+	s.Equal(types.String(`Set .value["bob"].age to 42`), head.Get(datas.MetaField).(types.Struct).Get("message"))
+
+	s.Run(main, []string{"set", dsSpec + `.value["carol"]`, `{"name": "carol", "tags": ["a"]}`})
+	carol := types.NewMap(types.String("name"), types.String("carol"), types.String("tags"), types.NewList(types.String("a")))
+	s.True(carol.Equals(s.head(dsSpec).Get(datas.ValueField).(types.Map).Get(types.String("carol"))))
+
+	s.Run(main, []string{"rm", dsSpec + `.value["bob"]`})
+	head = s.head(dsSpec)
+	s.True(types.NewMap(types.String("carol"), carol).Equals(head.Get(datas.ValueField)))
+	s.Equal(types.String(`Removed .value["bob"]`), head.Get(datas.MetaField).(types.Struct).Get("message"))
+
+	s.Run(main, []string{"set", dsSpec + ".value", `Person {name: "dave"}`})
+	s.True(types.NewStruct("Person", types.StructData{"name": types.String("dave")}).Equals(s.head(dsSpec).Get(datas.ValueField)))
+}
+
+func (s *nomsSetTestSuite) TestSetNewDataset() {
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "new")
+	s.Run(main, []string{"set", dsSpec + ".value", "[1, 2]"})
+	s.True(types.NewList(types.Number(1), types.Number(2)).Equals(s.head(dsSpec).Get(datas.ValueField)))
+}
+
+func (s *nomsSetTestSuite) TestSetBadPath() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "bad")
+	s.commit(dsSpec, types.Number(1))
+	s.Run(main, []string{"set", dsSpec + ".meta.message", `"hi"`})
+}
+
+func (s *nomsSetTestSuite) TestRmMissing() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "missing")
+	s.commit(dsSpec, types.NewList(types.Number(1)))
+	s.Run(main, []string{"rm", dsSpec + ".value[1]"})
+}
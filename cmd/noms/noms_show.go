@@ -11,26 +11,42 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/nomstojson"
 	"github.com/attic-labs/noms/go/util/outputpager"
 	flag "github.com/tsuru/gnuflag"
 )
 
+var (
+	showJSON        bool
+	showType        bool
+	showMaxDepth    int
+	showMaxElements uint64
+)
+
 var nomsShow = &nomsCommand{
 	Run:       runShow,
-	UsageLine: "show <object>",
+	UsageLine: "show [options] <object>",
 	Short:     "Shows a serialization of a Noms object",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
+	Long:      "--max-depth and --max-elements leave out the contents of collections and structs nested too deeply, and the elements of collections (or bytes of blobs) past the limit, writing \"... (N more)\" in their place. With --json, {\"@more\": N} takes the place of what's left out.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
 	Flags:     setupShowFlags,
 	Nargs:     1,
 }
 
 func setupShowFlags() *flag.FlagSet {
 	showFlagSet := flag.NewFlagSet("show", flag.ExitOnError)
+	showFlagSet.BoolVar(&showJSON, "json", false, "show the object as JSON")
+	showFlagSet.BoolVar(&showType, "type", false, "show only the type of the object")
+	showFlagSet.IntVar(&showMaxDepth, "max-depth", 0, "how many levels of nested collections and structs to show (0 for all)")
+	showFlagSet.Uint64Var(&showMaxElements, "max-elements", 0, "how many elements of each collection to show (0 for all)")
 	outputpager.RegisterOutputpagerFlags(showFlagSet)
 	return showFlagSet
 }
 
 func runShow(args []string) int {
+	if showJSON && showType {
+		d.CheckError(fmt.Errorf("--json can't be used with --type"))
+	}
+
 	database, value, err := spec.GetPath(args[0])
 	d.CheckErrorNoUsage(err)
 	defer database.Close()
@@ -43,7 +59,14 @@ func runShow(args []string) int {
 	pgr := outputpager.Start()
 	defer pgr.Stop()
 
-	types.WriteEncodedValueWithTags(pgr.Writer, value)
+	opts := types.ElisionOptions{MaxDepth: showMaxDepth, MaxElements: showMaxElements}
+	if showJSON {
+		d.CheckErrorNoUsage(nomstojson.WriteJSON(pgr.Writer, value, opts))
+	} else if showType {
+		types.WriteEncodedValue(pgr.Writer, value.Type())
+	} else {
+		types.WriteElidedEncodedValueWithTags(pgr.Writer, value, opts)
+	}
 	fmt.Fprintln(pgr.Writer)
 	return 0
 }
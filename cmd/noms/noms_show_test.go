@@ -70,3 +70,24 @@ func (s *nomsShowTestSuite) TestNomsShow() {
 	res, _ = s.Run(main, []string{"show", str})
 	test.EqualsIgnoreHashes(s.T(), res5, res)
 }
+
+func (s *nomsShowTestSuite) TestNomsShowOptions() {
+	list := types.NewList(types.NewList(types.Number(1), types.Number(2)), types.Number(3), types.Number(4))
+	r := writeTestData(spec.CreateValueSpecString("ldb", s.LdbDir, "options"), list)
+	str := spec.CreateValueSpecString("ldb", s.LdbDir, "#"+r.TargetHash().String())
+
+	res, _ := s.Run(main, []string{"show", "--max-depth", "1", "--max-elements", "2", str})
+	s.Equal("List<Number | List<Number>>([\n  [... (2 more)],\n  3,\n  ... (1 more)\n])\n", res)
+
+	res, _ = s.Run(main, []string{"show", "--type", str})
+	s.Equal("List<Number | List<Number>>\n", res)
+
+	res, _ = s.Run(main, []string{"show", "--json", str})
+	s.Equal("[\n  [\n    1,\n    2\n  ],\n  3,\n  4\n]\n", res)
+
+	res, _ = s.Run(main, []string{"show", "--json", "--max-elements", "2", str})
+	s.Equal("[\n  [\n    1,\n    2\n  ],\n  3,\n  {\n    \"@more\": 1\n  }\n]\n", res)
+
+	res, _ = s.Run(main, []string{"show", "--json", "--max-depth", "1", str})
+	s.Equal("[\n  [\n    {\n      \"@more\": 2\n    }\n  ],\n  3,\n  4\n]\n", res)
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/sql"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var sqlCommit string
+
+var nomsSQL = &nomsCommand{
+	Run:       runSQL,
+	UsageLine: "sql [options] <query> <object>",
+	Short:     "Runs a SQL query against a Noms list, map or set",
+	Long:      "Shows the result of running the SQL SELECT query <query> against <object>, which is a list, map or set, or a dataset whose head value is one. Each element or entry is a row, and the fields of the struct it holds are its columns. For example, if people was made by csv-import, the cities with the most people over 30 are:\n\n  noms sql 'SELECT city, count(*) AS n FROM people WHERE age > 30 GROUP BY city ORDER BY n DESC LIMIT 10' ldb:/tmp/db::people\n\nEach row also has the columns _key, the key of a map entry; _index, the index of a list element; and _value, the row's value itself. Conditions on _key, _value and _index, for maps, sets and lists, respectively, limit which rows are read. See https://godoc.org/github.com/attic-labs/noms/go/util/sql for the rest.\n\nWith --commit, the result is committed to a dataset, as a list of structs named Row, like those csv-import makes, instead of being shown.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
+	Flags:     setupSQLFlags,
+	Nargs:     2,
+}
+
+func setupSQLFlags() *flag.FlagSet {
+	sqlFlagSet := flag.NewFlagSet("sql", flag.ExitOnError)
+	sqlFlagSet.StringVar(&sqlCommit, "commit", "", "dataset to commit the result to, instead of showing it")
+	outputpager.RegisterOutputpagerFlags(sqlFlagSet)
+	spec.RegisterDatabaseFlags(sqlFlagSet)
+	return sqlFlagSet
+}
+
+func runSQL(args []string) int {
+	q, err := sql.Compile(args[0])
+	d.CheckErrorNoUsage(err)
+
+	database, value, err := spec.GetPath(args[1])
+	d.CheckErrorNoUsage(err)
+	defer database.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[1]))
+	}
+	if datas.IsCommitType(value.Type()) {
+		value = value.(types.Struct).Get(datas.ValueField)
+	}
+
+	res, err := q.Run(value, database)
+	d.CheckErrorNoUsage(err)
+
+	if sqlCommit != "" {
+		ds, err := spec.GetDataset(sqlCommit)
+		d.CheckErrorNoUsage(err)
+		defer ds.Database().Close()
+		commitEdit(ds, res.List(ds.Database()), args[0])
+		return 0
+	}
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+	writeSQLResult(pgr.Writer, res)
+	return 0
+}
+
+// writeSQLResult writes res as a table, with a header of the names of its columns. Strings are written as they are, NULL as NULL, and everything else as noms show writes it.
+func writeSQLResult(w io.Writer, res *sql.Result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(res.Columns, "\t"))
+	for _, r := range res.Rows {
+		cells := make([]string, len(r))
+		for i, v := range r {
+			switch v := v.(type) {
+			case nil:
+				cells[i] = "NULL"
+			case types.String:
+				cells[i] = string(v)
+			default:
+				cells[i] = types.EncodedValue(v)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
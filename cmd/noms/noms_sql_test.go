@@ -0,0 +1,71 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsSQL(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsSQLTestSuite{})
+}
+
+type nomsSQLTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsSQLTestSuite) writePeople(name string) string {
+	people := types.NewList(
+		types.NewStruct("Row", types.StructData{"name": types.String("alice"), "city": types.String("sf"), "age": types.Number(42)}),
+		types.NewStruct("Row", types.StructData{"name": types.String("bob"), "city": types.String("nyc"), "age": types.Number(25)}),
+		types.NewStruct("Row", types.StructData{"name": types.String("carol"), "city": types.String("sf"), "age": types.Number(31)}),
+	)
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, name)
+	writeTestData(dsSpec, people)
+	return dsSpec
+}
+
+func (s *nomsSQLTestSuite) TestSQL() {
+	dsSpec := s.writePeople("people")
+
+	out, _ := s.Run(main, []string{"sql", "SELECT city, count(*) AS n, max(age) FROM people GROUP BY city ORDER BY n DESC", dsSpec})
+	s.Equal("city  n  max(age)\nsf    2  42\nnyc   1  25\n", out)
+
+	out, _ = s.Run(main, []string{"sql", "SELECT _index, name, nope FROM people WHERE age < 40", dsSpec + ".value"})
+	s.Equal("_index  name   nope\n1       bob    NULL\n2       carol  NULL\n", out)
+}
+
+func (s *nomsSQLTestSuite) TestSQLCommit() {
+	dsSpec := s.writePeople("people")
+	outSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "old")
+
+	out, _ := s.Run(main, []string{"sql", "--commit", outSpec, "SELECT name FROM people WHERE age > 30", dsSpec})
+	s.Contains(out, "Committed ")
+
+	ds, err := spec.GetDataset(outSpec)
+	s.NoError(err)
+	defer ds.Database().Close()
+	s.True(types.NewList(
+		types.NewStruct("Row", types.StructData{"name": types.String("alice")}),
+		types.NewStruct("Row", types.StructData{"name": types.String("carol")}),
+	).Equals(ds.HeadValue()))
+	s.Equal(types.String("SELECT name FROM people WHERE age > 30"), ds.Head().Get("meta").(types.Struct).Get("message"))
+}
+
+func (s *nomsSQLTestSuite) TestSQLInvalidQuery() {
+	defer func() {
+		s.Equal(exitError{-1}, recover())
+	}()
+
+	dsSpec := s.writePeople("invalid")
+	s.Run(main, []string{"sql", "SELECT FROM people", dsSpec})
+}
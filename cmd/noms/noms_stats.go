@@ -0,0 +1,95 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/walk"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsStats = &nomsCommand{
+	Run:       runStats,
+	UsageLine: "stats [options] <database>",
+	Short:     "Shows how much storage each dataset in a database uses",
+	Long:      "For each dataset, shows the number and size of the chunks reachable from its head, including its history, and how many of them no other dataset shares; the depth of the deepest tree of chunks that a collection in it is split into; and how many chunks there are of each size. If only part of a dataset's history was synced, with noms sync --depth, the chunks it refers to that aren't there are counted as missing, and it's shown as shallow.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupStatsFlags,
+	Nargs:     1,
+}
+
+var nomsDu = &nomsCommand{
+	Run:       runDu,
+	UsageLine: "du [options] <database>",
+	Short:     "Shows the size of each dataset in a database",
+	Long:      "Shows the total size of the chunks reachable from the head of each dataset, including its history, then the size of those that no other dataset shares. Datasets with only part of their history, from noms sync --depth, are marked as shallow. See noms stats for more.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupStatsFlags,
+	Nargs:     1,
+}
+
+func setupStatsFlags() *flag.FlagSet {
+	statsFlagSet := flag.NewFlagSet("stats", flag.ExitOnError)
+	statsFlagSet.IntVar(&p, "p", 16, "parallelism")
+	spec.RegisterDatabaseFlags(statsFlagSet)
+	return statsFlagSet
+}
+
+// getDatasetsStats walks the datasets in the database named by str. It reads chunks straight from the database's ChunkStore, so it doesn't work with http databases.
+func getDatasetsStats(str string) []walk.DatasetStats {
+	cs, err := spec.GetChunkStore(str)
+	d.CheckErrorNoUsage(err)
+	db := datas.NewDatabase(cs)
+	defer db.Close()
+	return walk.DatasetsStats(db.Datasets(), types.NewBatchStoreAdaptor(cs), p)
+}
+
+func runStats(args []string) int {
+	writeDatasetsStats(os.Stdout, getDatasetsStats(args[0]))
+	return 0
+}
+
+func runDu(args []string) int {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, s := range getDatasetsStats(args[0]) {
+		shallow := ""
+		if s.MissingChunks > 0 {
+			shallow = " (shallow)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s%s\n", humanize.Bytes(s.Bytes), humanize.Bytes(s.UniqueBytes), s.ID, shallow)
+	}
+	tw.Flush()
+	return 0
+}
+
+func writeDatasetsStats(w io.Writer, stats []walk.DatasetStats) {
+	for _, s := range stats {
+		fmt.Fprintln(w, s.ID)
+		tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+		fmt.Fprintf(tw, "  chunks:\t%s (%s unique)\n", humanize.Comma(int64(s.Chunks)), humanize.Comma(int64(s.UniqueChunks)))
+		fmt.Fprintf(tw, "  bytes:\t%s (%s unique)\n", humanize.Bytes(s.Bytes), humanize.Bytes(s.UniqueBytes))
+		fmt.Fprintf(tw, "  collection depth:\t%d\n", s.CollectionDepth)
+		if s.MissingChunks > 0 {
+			fmt.Fprintf(tw, "  missing chunks:\t%s (shallow history)\n", humanize.Comma(int64(s.MissingChunks)))
+		}
+		tw.Flush()
+
+		fmt.Fprintln(w, "  chunk sizes:")
+		tw = tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+		for i, n := range s.SizeHistogram {
+			if n > 0 {
+				fmt.Fprintf(tw, "    %s - %s:\t%s\n", humanize.Bytes(1<<uint(i)), humanize.Bytes(1<<uint(i+1)-1), humanize.Comma(int64(n)))
+			}
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/noms/go/walk"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsStats(t *testing.T) {
+	suite.Run(t, &nomsStatsTestSuite{})
+}
+
+type nomsStatsTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsStatsTestSuite) TestStatsAndDu() {
+	list := types.NewList(types.String("a"), types.String("b"))
+	writeTestData(spec.CreateValueSpecString("ldb", s.LdbDir, "one"), list)
+	writeTestData(spec.CreateValueSpecString("ldb", s.LdbDir, "two"), list)
+	dbSpec := spec.CreateDatabaseSpecString("ldb", s.LdbDir)
+
+	out, _ := s.Run(main, []string{"stats", dbSpec})
+	s.Contains(out, "one\n  chunks:           2 (0 unique)\n")
+	s.Contains(out, "two\n  chunks:           2 (0 unique)\n")
+	s.Contains(out, "  collection depth: 1\n")
+
+	out, _ = s.Run(main, []string{"du", dbSpec})
+	s.Regexp("^[0-9]+ B  0 B  one\n[0-9]+ B  0 B  two\n$", out)
+}
+
+func (s *nomsStatsTestSuite) TestStatsShallow() {
+	sourceSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "source")
+	for i := 1; i <= 3; i++ {
+		writeTestData(sourceSpec, types.Number(i))
+	}
+	sinkDir := path.Join(s.TempDir, "shallow")
+	s.Run(main, []string{"sync", "--depth", "2", sourceSpec, spec.CreateValueSpecString("ldb", sinkDir, "sink")})
+	dbSpec := spec.CreateDatabaseSpecString("ldb", sinkDir)
+
+	out, _ := s.Run(main, []string{"stats", dbSpec})
+	s.Contains(out, "sink\n  chunks:           4 (4 unique)\n")
+	s.Contains(out, "  missing chunks:   1 (shallow history)\n")
+
+	out, _ = s.Run(main, []string{"du", dbSpec})
+	s.Regexp("^[0-9]+ B  [0-9]+ B  sink \\(shallow\\)\n$", out)
+}
+
+func (s *nomsStatsTestSuite) TestWriteDatasetsStats() {
+	buf := &bytes.Buffer{}
+	writeDatasetsStats(buf, []walk.DatasetStats{{
+		ID:              "ds",
+		Chunks:          1234,
+		Bytes:           5000,
+		UniqueChunks:    3,
+		UniqueBytes:     300,
+		CollectionDepth: 2,
+		SizeHistogram:   []uint64{0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 1231},
+	}})
+	s.Equal(`ds
+  chunks:           1,234 (3 unique)
+  bytes:            5.0 kB (300 B unique)
+  collection depth: 2
+  chunk sizes:
+    64 B - 127 B:    3
+    1.0 kB - 2.0 kB: 1,231
+`, buf.String())
+}
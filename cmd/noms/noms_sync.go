@@ -5,12 +5,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
@@ -20,14 +26,16 @@ import (
 )
 
 var (
-	p int
+	p         int
+	stateDir  string
+	syncDepth uint64
 )
 
 var nomsSync = &nomsCommand{
 	Run:       runSync,
 	UsageLine: "sync [options] <source-object> <dest-dataset>",
 	Short:     "Moves datasets between or within databases",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
+	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.\n\nThe progress of a sync is saved as it goes, and the head of the destination dataset is only moved once everything has been copied. If a sync is interrupted, by Ctrl-C or otherwise, running the same command again carries on from where it stopped.\n\nWith --depth, only that many of the most recent commits in the history of <source-object> are copied, and the destination database records that its history is shallow. A later sync without --depth fills in the rest.",
 	Flags:     setupSyncFlags,
 	Nargs:     2,
 }
@@ -35,6 +43,8 @@ var nomsSync = &nomsCommand{
 func setupSyncFlags() *flag.FlagSet {
 	syncFlagSet := flag.NewFlagSet("sync", flag.ExitOnError)
 	syncFlagSet.IntVar(&p, "p", 512, "parallelism")
+	syncFlagSet.Uint64Var(&syncDepth, "depth", 0, "number of commits of history to copy (0 for all of it)")
+	syncFlagSet.StringVar(&stateDir, "state-dir", "", "directory in which to save the progress of the sync (defaults to one under the system temporary directory, named for <dest-dataset>)")
 	spec.RegisterDatabaseFlags(syncFlagSet)
 	profile.RegisterProfileFlags(syncFlagSet)
 	return syncFlagSet
@@ -54,6 +64,24 @@ func runSync(args []string) int {
 	d.CheckError(err)
 	defer sinkDataset.Database().Close()
 
+	dir := stateDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "noms-sync", hash.FromData([]byte(args[1])).String())
+	}
+	cancel := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-sigCh:
+			close(cancel)
+		case <-done:
+		}
+	}()
+
 	start := time.Now()
 	progressCh := make(chan datas.PullProgress)
 	lastProgressCh := make(chan datas.PullProgress)
@@ -77,10 +105,15 @@ func runSync(args []string) int {
 		lastProgressCh <- last
 	}()
 
+	canceled := false
 	err = d.Try(func() {
 		defer profile.MaybeStartProfile().Stop()
 		var err error
-		sinkDataset, err = sinkDataset.Pull(sourceStore, types.NewRef(sourceObj), p, progressCh)
+		sinkDataset, err = sinkDataset.ResumablePull(sourceStore, types.NewRef(sourceObj), syncDepth, p, progressCh, dir, cancel)
+		if err == datas.ErrPullCanceled {
+			canceled = true
+			return
+		}
 		d.PanicIfError(err)
 	})
 
@@ -89,6 +122,10 @@ func runSync(args []string) int {
 	}
 
 	close(progressCh)
+	if canceled {
+		status.Done()
+		d.CheckErrorNoUsage(errors.New("Sync interrupted. Run the same command again to resume."))
+	}
 	if last := <-lastProgressCh; last.DoneCount > 0 {
 		status.Printf("Done - Synced %s in %s (%s/s)", humanize.Bytes(last.DoneBytes), since(start), bytesPerSec(last, start))
 		status.Done()
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"os"
 	"path"
 	"testing"
 
@@ -72,3 +73,64 @@ func (s *nomsSyncTestSuite) TestSync() {
 	dest.Database().Close()
 
 }
+
+func (s *nomsSyncTestSuite) TestSyncRemovesStateDir() {
+	source1 := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "foo")
+	source1, err := source1.CommitValue(types.NewList(types.Number(1), types.String("two")))
+	s.NoError(err)
+	source1.Database().Close()
+
+	stateDir := path.Join(s.TempDir, "state")
+	sourceDataset := spec.CreateValueSpecString("ldb", s.LdbDir, "foo")
+	ldb2dir := path.Join(s.TempDir, "ldb2")
+	sinkDatasetSpec := spec.CreateValueSpecString("ldb", ldb2dir, "bar")
+	s.Run(main, []string{"sync", "--state-dir", stateDir, sourceDataset, sinkDatasetSpec})
+
+	dest := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb2dir, "", 1, false)), "bar")
+	s.True(types.NewList(types.Number(1), types.String("two")).Equals(dest.HeadValue()))
+	dest.Database().Close()
+	_, err = os.Stat(stateDir)
+	s.True(os.IsNotExist(err))
+}
+
+func (s *nomsSyncTestSuite) TestSyncWithDepth() {
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "shallow")
+	source, err := source.CommitValue(types.Number(1))
+	s.NoError(err)
+	first := source.Head().Hash()
+	source, err = source.CommitValue(types.Number(2))
+	s.NoError(err)
+	source, err = source.CommitValue(types.Number(3))
+	s.NoError(err)
+	source.Database().Close()
+
+	sourceDataset := spec.CreateValueSpecString("ldb", s.LdbDir, "shallow")
+	ldb3dir := path.Join(s.TempDir, "ldb3")
+	sinkDatasetSpec := spec.CreateValueSpecString("ldb", ldb3dir, "baz")
+	s.Run(main, []string{"sync", "--depth", "2", sourceDataset, sinkDatasetSpec})
+
+	dest := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb3dir, "", 1, false)), "baz")
+	s.True(types.Number(3).Equals(dest.HeadValue()))
+	s.Nil(dest.Database().ReadValue(first))
+	s.Equal(uint64(1), dest.Database().ShallowCommits().Len())
+	dest.Database().Close()
+
+	// The log stops where the history does.
+	res, _ := s.Run(main, []string{"log", sinkDatasetSpec})
+	s.Contains(res, first.String()+" (not present)")
+
+	// Syncing out of the shallow copy leaves the new one just as shallow.
+	ldb4dir := path.Join(s.TempDir, "ldb4")
+	s.Run(main, []string{"sync", sinkDatasetSpec, spec.CreateValueSpecString("ldb", ldb4dir, "qux")})
+	copied := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb4dir, "", 1, false)), "qux")
+	s.True(types.Number(3).Equals(copied.HeadValue()))
+	s.Nil(copied.Database().ReadValue(first))
+	s.Equal(uint64(1), copied.Database().ShallowCommits().Len())
+	copied.Database().Close()
+
+	s.Run(main, []string{"sync", sourceDataset, sinkDatasetSpec})
+	dest = dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb3dir, "", 1, false)), "baz")
+	s.NotNil(dest.Database().ReadValue(first))
+	s.True(dest.Database().ShallowCommits().Empty())
+	dest.Database().Close()
+}
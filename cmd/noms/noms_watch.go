@@ -0,0 +1,56 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsWatch = &nomsCommand{
+	Run:       runWatch,
+	UsageLine: "watch [options] <dataset>",
+	Short:     "Prints new commits to a Noms dataset as they land",
+	Long:      "Waits for commits to the dataset and prints each new head as it's noticed, in the same format as noms log. A head that is replaced before it's noticed isn't printed. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupWatchFlags,
+	Nargs:     1,
+}
+
+func setupWatchFlags() *flag.FlagSet {
+	watchFlagSet := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchFlagSet.IntVar(&color, "color", -1, "value of 1 forces color on, 2 forces color off")
+	watchFlagSet.IntVar(&maxLines, "max-lines", 10, "max number of lines to show per commit (-1 for all lines)")
+	watchFlagSet.IntVar(&maxCommits, "n", 0, "exit after displaying this many commits (0 to watch until interrupted)")
+	watchFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
+	watchFlagSet.BoolVar(&fullHash, "full-hash", false, "show whole hashes, rather than abbreviated ones, with -oneline")
+	watchFlagSet.BoolVar(&showValue, "show-value", false, "show commit value rather than diff information")
+	spec.RegisterDatabaseFlags(watchFlagSet)
+	return watchFlagSet
+}
+
+func runWatch(args []string) int {
+	useColor = shouldUseColor()
+	showGraph = false
+
+	ds, err := spec.GetDataset(args[0])
+	d.CheckErrorNoUsage(err)
+	db := ds.Database()
+	defer db.Close()
+
+	displayed := 0
+	for r := range db.WatchHead(ds.ID()) {
+		commit := r.TargetValue(db).(types.Struct)
+		printCommit(LogNode{cr: r, commit: commit}, os.Stdout, db)
+		displayed++
+		if maxCommits > 0 && displayed >= maxCommits {
+			break
+		}
+	}
+	return 0
+}
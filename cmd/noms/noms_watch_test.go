@@ -0,0 +1,63 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsWatch(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsWatchTestSuite{})
+}
+
+type nomsWatchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsWatchTestSuite) TestWatch() {
+	str := spec.CreateValueSpecString("ldb", s.LdbDir, "watched")
+	ds, err := spec.GetDataset(str)
+	s.NoError(err)
+	ds, err = ds.CommitValue(types.Number(0))
+	s.NoError(err)
+
+	// Keep committing until watch has seen enough commits to exit, as commits made before it starts watching aren't printed.
+	done := make(chan struct{})
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		defer ds.Database().Close()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			var err error
+			ds, err = ds.CommitValue(types.Number(i))
+			s.NoError(err)
+		}
+	}()
+
+	out, _ := s.Run(main, []string{"watch", "--oneline", "-n", "2", str})
+	close(done)
+	<-committed
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	s.Len(lines, 2)
+	for _, l := range lines {
+		s.Contains(l, "(Parent: ")
+	}
+	s.NotEqual(lines[0], lines[1])
+}
@@ -84,6 +84,11 @@ func DeserializeToChan(reader io.Reader, chunkChan chan<- *Chunk) {
 	close(chunkChan)
 }
 
+// DeserializeOne reads a single Chunk, as written by Serialize, from |reader|. It returns false if |reader| is at EOF.
+func DeserializeOne(reader io.Reader) (Chunk, bool) {
+	return deserializeChunk(reader)
+}
+
 func deserializeChunk(reader io.Reader) (Chunk, bool) {
 	digest := hash.Digest{}
 	n, err := io.ReadFull(reader, digest[:])
@@ -32,6 +32,12 @@ type RootTracker interface {
 	UpdateRoot(current, last hash.Hash) bool
 }
 
+// ShallowTracker is implemented by ChunkStores that can keep a second hash alongside their root. Database uses it to name the Set of Commits whose parents aren't all present, because only part of their history was pulled. Keeping that apart from the root means that readers which don't know about shallow Databases, and take every entry in the map at the root to be a dataset, aren't confused by it.
+type ShallowTracker interface {
+	Shallow() hash.Hash
+	UpdateShallow(current, last hash.Hash) bool
+}
+
 // ChunkSource is a place to get chunks from.
 type ChunkSource interface {
 	// Get the Chunk for the value of the hash in the store. If the hash is absent from the store nil is returned.
@@ -44,6 +50,20 @@ type ChunkSource interface {
 	Version() string
 }
 
+// HashPrefixSource is implemented by ChunkSources that can find the Chunks they hold by the start of the String() of their hashes, which is what allows hashes to be abbreviated.
+type HashPrefixSource interface {
+	// HashesWithPrefix returns, in order, the hashes of up to limit of the Chunks in the source whose String() starts with prefix. ok is false if the source can't look Chunks up that way after all, e.g. because it wraps a ChunkSource that can't.
+	HashesWithPrefix(prefix string, limit int) (hashes hash.HashSlice, ok bool)
+}
+
+// HashesWithPrefix calls cs.HashesWithPrefix if cs is a HashPrefixSource, and returns false otherwise.
+func HashesWithPrefix(cs ChunkSource, prefix string, limit int) (hashes hash.HashSlice, ok bool) {
+	if hps, isHPS := cs.(HashPrefixSource); isHPS {
+		return hps.HashesWithPrefix(prefix, limit)
+	}
+	return nil, false
+}
+
 // ChunkSink is a place to put chunks.
 type ChunkSink interface {
 	// Put writes c into the ChunkSink, blocking until the operation is complete.
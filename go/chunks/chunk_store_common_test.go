@@ -5,6 +5,8 @@
 package chunks
 
 import (
+	"sort"
+
 	"github.com/attic-labs/testify/suite"
 
 	"github.com/attic-labs/noms/go/constants"
@@ -77,6 +79,25 @@ func (suite *ChunkStoreTestSuite) TestChunkStoreRoot() {
 	suite.True(result)
 }
 
+func (suite *ChunkStoreTestSuite) TestChunkStoreShallow() {
+	st, ok := suite.Store.(ShallowTracker)
+	if !ok {
+		return // Not every store can record shallow Commits.
+	}
+	suite.True(st.Shallow().IsEmpty())
+
+	bogus := hash.Parse("8habda5skfek1265pc5d5l1orptn5dr0")
+	shallow := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+	suite.False(st.UpdateShallow(shallow, bogus))
+	suite.True(st.UpdateShallow(shallow, hash.Hash{}))
+	suite.Equal(shallow, st.Shallow())
+
+	// The shallow hash is kept apart from the root.
+	suite.True(suite.Store.Root().IsEmpty())
+	suite.True(st.UpdateShallow(hash.Hash{}, shallow))
+	suite.True(st.Shallow().IsEmpty())
+}
+
 func (suite *ChunkStoreTestSuite) TestChunkStoreGetNonExisting() {
 	h := hash.Parse("11111111111111111111111111111111")
 	c := suite.Store.Get(h)
@@ -91,3 +112,33 @@ func (suite *ChunkStoreTestSuite) TestChunkStoreVersion() {
 
 	suite.Equal(constants.NomsVersion, suite.Store.Version())
 }
+
+func (suite *ChunkStoreTestSuite) TestChunkStoreHashesWithPrefix() {
+	chunks := []Chunk{}
+	for _, s := range []string{"abc", "def", "ghi", "jkl", "mno"} {
+		chunks = append(chunks, NewChunk([]byte(s)))
+	}
+	suite.Store.PutMany(chunks)
+	suite.Store.UpdateRoot(chunks[0].Hash(), suite.Store.Root()) // Commit writes
+
+	all, ok := HashesWithPrefix(suite.Store, "", 100)
+	if !ok {
+		return // Not every store can look chunks up by prefix.
+	}
+	suite.Len(all, len(chunks))
+	suite.True(sort.IsSorted(all))
+
+	h := chunks[2].Hash()
+	found, ok := HashesWithPrefix(suite.Store, h.String(), 100)
+	suite.True(ok)
+	suite.Equal(hash.HashSlice{h}, found)
+	found, _ = HashesWithPrefix(suite.Store, h.Abbrev(), 100)
+	suite.Equal(hash.HashSlice{h}, found)
+
+	found, _ = HashesWithPrefix(suite.Store, "", 2)
+	suite.Equal(all[:2], found)
+
+	found, ok = HashesWithPrefix(suite.Store, "not base32!", 100)
+	suite.True(ok)
+	suite.Empty(found)
+}
@@ -7,6 +7,7 @@ package chunks
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"sync"
@@ -29,6 +30,9 @@ const (
 	dynamoMaxPutSize    = 400 * 1024 // 400K
 	dynamoWriteUnitSize = 1024       // 1K
 
+	// Large chunks are split into parts of at most this many bytes, leaving room in each item for its key and attribute names.
+	dynamoMaxPartSize = dynamoMaxPutSize - dynamoWriteUnitSize
+
 	readBufferSize  = 1 << 12 // 4k
 	writeBufferSize = dynamoMaxPutCount
 
@@ -39,6 +43,7 @@ const (
 	numAttr         = "num"
 	noneValue       = "none"
 	gzipValue       = "gzip"
+	partsValue      = "parts"
 )
 
 var (
@@ -242,13 +247,13 @@ func (s *DynamoStore) processResponses(responses []map[string]*dynamodb.Attribut
 		p = item[chunkAttr]
 		d.Chk.True(p != nil)
 		b := p.B
-		if p = item[compAttr]; p != nil && *p.S == gzipValue {
-			gr, err := gzip.NewReader(bytes.NewReader(b))
-			d.Chk.NoError(err)
-			buf := &bytes.Buffer{}
-			_, err = io.Copy(buf, gr)
-			d.Chk.NoError(err)
-			b = buf.Bytes()
+		if p = item[compAttr]; p != nil {
+			switch *p.S {
+			case gzipValue:
+				b = gunzipBytes(b)
+			case partsValue:
+				b = gunzipBytes(s.readLargeChunk(r, b))
+			}
 		}
 		c := NewChunkWithHash(r, b)
 		for _, reqChan := range batch[r] {
@@ -286,6 +291,8 @@ func (s *DynamoStore) sendWriteRequests(first Chunk) {
 		size := chunkItemSize(c)
 		if size > dynamoMaxPutSize {
 			s.writeLargeChunk(c)
+			s.unwrittenPuts.Clear([]Chunk{c})
+			s.requestWg.Done()
 			return
 		}
 		chunks = append(chunks, c)
@@ -302,22 +309,28 @@ func (s *DynamoStore) sendWriteRequests(first Chunk) {
 		}
 	}
 
-	requestItems := s.buildWriteRequests(chunks)
+	if len(chunks) > 0 {
+		s.batchWrite(s.buildWriteRequests(chunks))
+	}
+
+	s.unwrittenPuts.Clear(chunks)
+	s.requestWg.Add(-len(chunks))
+}
+
+// batchWrite sends requestItems to DynamoDB, retrying until all of them have been processed.
+func (s *DynamoStore) batchWrite(requestItems map[string][]*dynamodb.WriteRequest) {
 	for hasUnprocessedItems := true; hasUnprocessedItems; {
 		out, err := s.ddbsvc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
 			RequestItems: requestItems,
 		})
 
 		if err != nil && err.(awserr.Error).Code() != "ProvisionedThroughputExceededException" {
-			d.Chk.NoError(err, "Errors from BatchGetItem() other than throughput exceeded are fatal")
+			d.Chk.NoError(err, "Errors from BatchWriteItem() other than throughput exceeded are fatal")
 		}
 
 		hasUnprocessedItems = len(out.UnprocessedItems) != 0
 		requestItems = out.UnprocessedItems
 	}
-
-	s.unwrittenPuts.Clear(chunks)
-	s.requestWg.Add(-len(chunks))
 }
 
 func chunkItemSize(c Chunk) int {
@@ -333,13 +346,8 @@ func (s *DynamoStore) buildWriteRequests(chunks []Chunk) map[string][]*dynamodb.
 		compression := noneValue
 		if chunkItemSize(c) > dynamoWriteUnitSize {
 			compression = gzipValue
-			buf := &bytes.Buffer{}
-			gw := gzip.NewWriter(buf)
-			_, err := io.Copy(gw, bytes.NewReader(chunkData))
-			d.Chk.NoError(err)
-			gw.Close()
-			chunkData = buf.Bytes()
-			compDataLen = uint64(buf.Len())
+			chunkData = gzipBytes(chunkData)
+			compDataLen = uint64(len(chunkData))
 		}
 		s.writeCount++
 		s.writeTotal += chunkDataLen
@@ -359,8 +367,108 @@ func (s *DynamoStore) buildWriteRequests(chunks []Chunk) map[string][]*dynamodb.
 	return map[string][]*dynamodb.WriteRequest{s.table: requests}
 }
 
+// writeLargeChunk handles chunks too big to fit in a single item. The gzipped chunk data is split into parts of at most dynamoMaxPartSize bytes, each stored under the chunk's key followed by the part's index. Once all parts are written, a manifest item holding the part count is stored under the chunk's key, so the chunk never appears to be present before it can be fully read back.
 func (s *DynamoStore) writeLargeChunk(c Chunk) {
-	d.Chk.Fail("Unsupported!")
+	h := c.Hash()
+	data := gzipBytes(c.Data())
+	s.writeCount++
+	s.writeTotal += uint64(len(c.Data()))
+	s.writeCompTotal += uint64(len(data))
+
+	var requests []*dynamodb.WriteRequest
+	flush := func() {
+		s.batchWrite(map[string][]*dynamodb.WriteRequest{s.table: requests})
+		requests = nil
+	}
+	numParts := uint32(0)
+	for ; len(data) > 0; numParts++ {
+		partLen := dynamoMaxPartSize
+		if len(data) < partLen {
+			partLen = len(data)
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{
+				refAttr:   {B: s.makePartKey(h, numParts)},
+				chunkAttr: {B: data[:partLen]},
+				compAttr:  {S: aws.String(noneValue)},
+			}},
+		})
+		data = data[partLen:]
+		if len(requests) == dynamoMaxPutCount {
+			flush()
+		}
+	}
+	if len(requests) > 0 {
+		flush()
+	}
+
+	manifest := make([]byte, 4)
+	binary.BigEndian.PutUint32(manifest, numParts)
+	requests = append(requests, &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{
+			refAttr:   {B: s.makeNamespacedKey(h)},
+			chunkAttr: {B: manifest},
+			compAttr:  {S: aws.String(partsValue)},
+		}},
+	})
+	flush()
+}
+
+// readLargeChunk fetches the parts listed in the manifest of the large chunk h and concatenates them in order.
+func (s *DynamoStore) readLargeChunk(h hash.Hash, manifest []byte) []byte {
+	d.Chk.True(len(manifest) == 4, "Manifest for %s should be 4 bytes, not %d", h, len(manifest))
+	numParts := binary.BigEndian.Uint32(manifest)
+	parts := make(map[string][]byte, numParts)
+
+	for start := uint32(0); start < numParts; start += dynamoMaxGetCount {
+		keys := &dynamodb.KeysAndAttributes{ConsistentRead: aws.Bool(true)}
+		for i := start; i < numParts && i < start+dynamoMaxGetCount; i++ {
+			keys.Keys = append(keys.Keys, map[string]*dynamodb.AttributeValue{refAttr: {B: s.makePartKey(h, i)}})
+		}
+		requestItems := map[string]*dynamodb.KeysAndAttributes{s.table: keys}
+		for hasUnprocessedKeys := true; hasUnprocessedKeys; {
+			out, err := s.ddbsvc.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+
+			if err == nil {
+				for _, item := range out.Responses[s.table] {
+					parts[string(item[refAttr].B)] = item[chunkAttr].B
+				}
+			} else if err.(awserr.Error).Code() != "ProvisionedThroughputExceededException" {
+				d.Chk.NoError(err, "Errors from BatchGetItem() other than throughput exceeded are fatal")
+			}
+
+			hasUnprocessedKeys = len(out.UnprocessedKeys) != 0
+			requestItems = out.UnprocessedKeys
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for i := uint32(0); i < numParts; i++ {
+		part, ok := parts[string(s.makePartKey(h, i))]
+		d.Chk.True(ok, "Part %d of %d missing for large chunk %s", i, numParts, h)
+		buf.Write(part)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	_, err := io.Copy(gw, bytes.NewReader(data))
+	d.Chk.NoError(err)
+	gw.Close()
+	return buf.Bytes()
+}
+
+func gunzipBytes(data []byte) []byte {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	d.Chk.NoError(err)
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, gr)
+	d.Chk.NoError(err)
+	return buf.Bytes()
 }
 
 func (s *DynamoStore) Close() error {
@@ -495,6 +603,14 @@ func (s *DynamoStore) makeNamespacedKey(h hash.Hash) []byte {
 	return key
 }
 
+// makePartKey returns the key of part i of the large chunk h. Part keys are longer than chunk keys, so the two can never collide.
+func (s *DynamoStore) makePartKey(h hash.Hash, i uint32) []byte {
+	key := s.makeNamespacedKey(h)
+	idx := make([]byte, 4)
+	binary.BigEndian.PutUint32(idx, i)
+	return append(key, idx...)
+}
+
 func (s *DynamoStore) removeNamespace(namespaced []byte) []byte {
 	return namespaced[len(s.namespace):]
 }
@@ -77,6 +77,7 @@ func (m *fakeDDB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb
 			m.assert.NotNil(value, "value should have been a blob: %+v", putReq.Item[chunkAttr])
 			m.assert.NotNil(comp, "comp should have been a string: %+v", putReq.Item[compAttr])
 			m.assert.False(bytes.Equal(key, dynamoRootKey), "Can't batch-write the root!")
+			m.assert.True(len(key)+len(value) <= dynamoMaxPutSize, "Item for %x exceeds the DynamoDB item size limit", key)
 
 			m.put(key, value, *comp)
 			if *comp != noneValue {
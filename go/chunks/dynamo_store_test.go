@@ -5,6 +5,7 @@
 package chunks
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/attic-labs/testify/assert"
@@ -54,3 +55,32 @@ func (suite *DynamoStoreTestSuite) TestChunkCompression() {
 	roundTrip := suite.Store.Get(c1.Hash())
 	suite.Equal(c1.Data(), roundTrip.Data())
 }
+
+func (suite *DynamoStoreTestSuite) TestLargeChunk() {
+	// Random data won't compress, so the chunk must be split across several items.
+	data := make([]byte, 3*dynamoMaxPutSize)
+	rand.New(rand.NewSource(42)).Read(data)
+	c1 := NewChunk(data)
+	suite.Store.Put(c1)
+	suite.Store.UpdateRoot(c1.Hash(), suite.Store.Root()) // Commit writes
+	suite.Equal(5, suite.ddb.numPuts)                     // 4 parts and a manifest
+	suite.True(suite.Store.Has(c1.Hash()))
+
+	store := newDynamoStoreFromDDBsvc("table", "namespace", suite.ddb, false)
+	defer store.Close()
+	roundTrip := store.Get(c1.Hash())
+	suite.Equal(c1.Hash(), roundTrip.Hash())
+	suite.Equal(c1.Data(), roundTrip.Data())
+}
+
+func (suite *DynamoStoreTestSuite) TestLargeChunkInBatch() {
+	small := NewChunk([]byte("abc"))
+	large := NewChunk(make([]byte, dynamoMaxPutSize+1))
+	suite.Nil(suite.Store.PutMany([]Chunk{small, large}))
+	suite.Store.UpdateRoot(large.Hash(), suite.Store.Root()) // Commit writes
+
+	store := newDynamoStoreFromDDBsvc("table", "namespace", suite.ddb, false)
+	defer store.Close()
+	suite.Equal(small.Data(), store.Get(small.Hash()).Data())
+	suite.Equal(large.Data(), store.Get(large.Hash()).Data())
+}
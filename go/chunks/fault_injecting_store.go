@@ -0,0 +1,226 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// FaultPolicy decides, one call at a time, whether a FaultInjectingStore should inject a fault into the operation it governs. Implementations must be goroutine-safe.
+type FaultPolicy interface {
+	Fail() bool
+}
+
+// FailOn returns a FaultPolicy that injects a fault on the given calls, counting from 0.
+func FailOn(calls ...int) FaultPolicy {
+	s := &scheduledFaults{calls: map[int]bool{}}
+	for _, c := range calls {
+		s.calls[c] = true
+	}
+	return s
+}
+
+// FailAfter returns a FaultPolicy that lets the first n calls through and injects a fault on every call after that.
+func FailAfter(n int) FaultPolicy {
+	return &thresholdFaults{threshold: n}
+}
+
+// FailRandomly returns a FaultPolicy that injects a fault with probability rate on each call. The sequence of decisions is fully determined by seed, so failing runs can be reproduced.
+func FailRandomly(rate float64, seed int64) FaultPolicy {
+	return &randomFaults{rate: rate, rnd: rand.New(rand.NewSource(seed))}
+}
+
+type scheduledFaults struct {
+	calls map[int]bool
+	count int
+	mu    sync.Mutex
+}
+
+func (s *scheduledFaults) Fail() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fail := s.calls[s.count]
+	s.count++
+	return fail
+}
+
+type thresholdFaults struct {
+	threshold int
+	count     int
+	mu        sync.Mutex
+}
+
+func (t *thresholdFaults) Fail() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count++
+	return t.count > t.threshold
+}
+
+type randomFaults struct {
+	rate float64
+	rnd  *rand.Rand
+	mu   sync.Mutex
+}
+
+func (r *randomFaults) Fail() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64() < r.rate
+}
+
+// FaultOptions configures the faults injected by a FaultInjectingStore. A nil FaultPolicy never injects a fault.
+type FaultOptions struct {
+	// Latency is added to every call that reaches the backing store.
+	Latency time.Duration
+
+	// Backpressure is consulted for each Chunk passed to PutMany. When it fails, that Chunk and all those after it are returned in a BackpressureError instead of being written.
+	Backpressure FaultPolicy
+
+	// Drop is consulted for each Chunk written via Put or PutMany. Dropped Chunks are acknowledged to the caller, but never reach the backing store.
+	Drop FaultPolicy
+
+	// Corrupt is consulted for each Chunk returned by Get. Corrupted Chunks keep their hash, but carry damaged data, as though the backing store had suffered bit rot.
+	Corrupt FaultPolicy
+
+	// RootConflict is consulted on each UpdateRoot. When it fails, UpdateRoot returns false without touching the backing store, as though another writer had moved the root.
+	RootConflict FaultPolicy
+}
+
+// FaultInjectingStore wraps a ChunkStore, injecting latency, backpressure, lost writes, damaged reads and root update conflicts according to its FaultOptions. Useful for testing how higher layers cope with misbehaving storage.
+type FaultInjectingStore struct {
+	backing ChunkStore
+	opts    FaultOptions
+
+	// Dropped holds the hashes of Chunks that never reached the backing store and Corrupted those of Chunks that were damaged on the way out of it. Backpressured and RootConflicts count injected PutMany and UpdateRoot failures.
+	Dropped       hash.HashSet
+	Corrupted     hash.HashSet
+	Backpressured int
+	RootConflicts int
+	mu            *sync.Mutex
+}
+
+func NewFaultInjectingStore(backing ChunkStore, opts FaultOptions) *FaultInjectingStore {
+	return &FaultInjectingStore{
+		backing:   backing,
+		opts:      opts,
+		Dropped:   hash.HashSet{},
+		Corrupted: hash.HashSet{},
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (s *FaultInjectingStore) Get(h hash.Hash) Chunk {
+	s.delay()
+	c := s.backing.Get(h)
+	if c.IsEmpty() || !fails(s.opts.Corrupt) {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Corrupted.Insert(h)
+	return NewChunkWithHash(h, corruptData(c.Data()))
+}
+
+func (s *FaultInjectingStore) Has(h hash.Hash) bool {
+	s.delay()
+	return s.backing.Has(h)
+}
+
+func (s *FaultInjectingStore) HashesWithPrefix(prefix string, limit int) (hash.HashSlice, bool) {
+	s.delay()
+	return HashesWithPrefix(s.backing, prefix, limit)
+}
+
+func (s *FaultInjectingStore) Version() string {
+	return s.backing.Version()
+}
+
+func (s *FaultInjectingStore) Put(c Chunk) {
+	s.delay()
+	if !s.drop(c) {
+		s.backing.Put(c)
+	}
+}
+
+func (s *FaultInjectingStore) PutMany(chunks []Chunk) BackpressureError {
+	s.delay()
+	toPut := make([]Chunk, 0, len(chunks))
+	var bpe BackpressureError
+	for i, c := range chunks {
+		if fails(s.opts.Backpressure) {
+			bpe = make(BackpressureError, len(chunks)-i)
+			for j, np := range chunks[i:] {
+				bpe[j] = np.Hash()
+			}
+			s.mu.Lock()
+			s.Backpressured++
+			s.mu.Unlock()
+			break
+		}
+		if !s.drop(c) {
+			toPut = append(toPut, c)
+		}
+	}
+
+	if backingBpe := s.backing.PutMany(toPut); backingBpe != nil {
+		bpe = append(backingBpe, bpe...)
+	}
+	return bpe
+}
+
+func (s *FaultInjectingStore) Root() hash.Hash {
+	s.delay()
+	return s.backing.Root()
+}
+
+func (s *FaultInjectingStore) UpdateRoot(current, last hash.Hash) bool {
+	s.delay()
+	if fails(s.opts.RootConflict) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.RootConflicts++
+		return false
+	}
+	return s.backing.UpdateRoot(current, last)
+}
+
+// Close closes the backing ChunkStore.
+func (s *FaultInjectingStore) Close() error {
+	return s.backing.Close()
+}
+
+func (s *FaultInjectingStore) delay() {
+	if s.opts.Latency > 0 {
+		time.Sleep(s.opts.Latency)
+	}
+}
+
+// drop applies the Drop policy to c, returning true if c should not be written.
+func (s *FaultInjectingStore) drop(c Chunk) bool {
+	if !fails(s.opts.Drop) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Dropped.Insert(c.Hash())
+	return true
+}
+
+// corruptData returns a copy of data with every bit of its last byte flipped.
+func corruptData(data []byte) []byte {
+	damaged := make([]byte, len(data))
+	copy(damaged, data)
+	damaged[len(damaged)-1] ^= 0xff
+	return damaged
+}
+
+func fails(p FaultPolicy) bool {
+	return p != nil && p.Fail()
+}
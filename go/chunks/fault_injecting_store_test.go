@@ -0,0 +1,110 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestFaultInjectingStoreTestSuite(t *testing.T) {
+	suite.Run(t, &FaultInjectingStoreTestSuite{})
+}
+
+type FaultInjectingStoreTestSuite struct {
+	ChunkStoreTestSuite
+}
+
+func (suite *FaultInjectingStoreTestSuite) SetupTest() {
+	suite.Store = NewFaultInjectingStore(NewMemoryStore(), FaultOptions{})
+}
+
+func (suite *FaultInjectingStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func TestFaultPolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	collect := func(p FaultPolicy, n int) (res []bool) {
+		for i := 0; i < n; i++ {
+			res = append(res, p.Fail())
+		}
+		return
+	}
+
+	assert.Equal([]bool{false, true, false, true, false}, collect(FailOn(1, 3), 5))
+	assert.Equal([]bool{false, false, true, true}, collect(FailAfter(2), 4))
+	assert.Equal(collect(FailRandomly(0.5, 42), 100), collect(FailRandomly(0.5, 42), 100))
+	assert.NotContains(collect(FailRandomly(0, 42), 100), true)
+	assert.NotContains(collect(FailRandomly(1, 42), 100), false)
+}
+
+func TestFaultInjectingStoreLatency(t *testing.T) {
+	assert := assert.New(t)
+	latency := 10 * time.Millisecond
+	s := NewFaultInjectingStore(NewMemoryStore(), FaultOptions{Latency: latency})
+
+	start := time.Now()
+	s.Put(NewChunk([]byte("abc")))
+	s.Get(hash.Hash{})
+	assert.True(time.Since(start) >= 2*latency)
+}
+
+func TestFaultInjectingStoreBackpressure(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	s := NewFaultInjectingStore(ms, FaultOptions{Backpressure: FailOn(1)})
+
+	c1, c2, c3 := NewChunk([]byte("abc")), NewChunk([]byte("def")), NewChunk([]byte("ghi"))
+	bpe := s.PutMany([]Chunk{c1, c2, c3})
+	assert.Equal(BackpressureError{c2.Hash(), c3.Hash()}, bpe)
+	assert.Equal(1, s.Backpressured)
+	assert.True(ms.Has(c1.Hash()))
+	assert.False(ms.Has(c2.Hash()))
+
+	// Retrying the rejected Chunks succeeds once the policy stops failing.
+	assert.Nil(s.PutMany([]Chunk{c2, c3}))
+	assert.Equal(3, ms.Len())
+}
+
+func TestFaultInjectingStoreDropAndCorrupt(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	s := NewFaultInjectingStore(ms, FaultOptions{Drop: FailOn(0, 2), Corrupt: FailOn(0)})
+
+	dropped, corrupted, intact := NewChunk([]byte("abc")), NewChunk([]byte("def")), NewChunk([]byte("ghi"))
+	s.Put(dropped)
+	s.PutMany([]Chunk{corrupted, dropped, intact})
+
+	assert.False(ms.Has(dropped.Hash()))
+	assert.True(s.Dropped.Has(dropped.Hash()))
+
+	damaged := s.Get(corrupted.Hash())
+	assert.Equal(corrupted.Hash(), damaged.Hash())
+	assert.NotEqual(corrupted.Data(), damaged.Data())
+	assert.NotEqual(corrupted.Hash(), hash.FromData(damaged.Data()))
+	assert.True(s.Corrupted.Has(corrupted.Hash()))
+
+	assert.Equal(intact.Data(), s.Get(intact.Hash()).Data())
+}
+
+func TestFaultInjectingStoreRootConflict(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	s := NewFaultInjectingStore(ms, FaultOptions{RootConflict: FailOn(0)})
+
+	newRoot := NewChunk([]byte("abc")).Hash()
+	assert.False(s.UpdateRoot(newRoot, hash.Hash{}))
+	assert.True(ms.Root().IsEmpty())
+	assert.Equal(1, s.RootConflicts)
+
+	assert.True(s.UpdateRoot(newRoot, hash.Hash{}))
+	assert.Equal(newRoot, ms.Root())
+}
@@ -5,9 +5,11 @@
 package chunks
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"sync"
+	"syscall"
 
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
@@ -22,6 +24,7 @@ import (
 
 const (
 	rootKeyConst     = "/root"
+	shallowKeyConst  = "/shallow"
 	versionKeyConst  = "/vers"
 	chunkPrefixConst = "/chunk/"
 )
@@ -61,6 +64,7 @@ func newLevelDBStore(store *internalLevelDBStore, ns []byte, closeBackingStore b
 	return &LevelDBStore{
 		internalLevelDBStore: store,
 		rootKey:              copyNsAndAppend(rootKeyConst),
+		shallowKey:           copyNsAndAppend(shallowKeyConst),
 		versionKey:           copyNsAndAppend(versionKeyConst),
 		chunkPrefix:          copyNsAndAppend(chunkPrefixConst),
 		closeBackingStore:    closeBackingStore,
@@ -70,6 +74,7 @@ func newLevelDBStore(store *internalLevelDBStore, ns []byte, closeBackingStore b
 type LevelDBStore struct {
 	*internalLevelDBStore
 	rootKey           []byte
+	shallowKey        []byte
 	versionKey        []byte
 	chunkPrefix       []byte
 	closeBackingStore bool
@@ -87,6 +92,17 @@ func (l *LevelDBStore) UpdateRoot(current, last hash.Hash) bool {
 	return l.updateRootByKey(l.rootKey, current, last)
 }
 
+func (l *LevelDBStore) Shallow() hash.Hash {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	return l.rootByKey(l.shallowKey)
+}
+
+func (l *LevelDBStore) UpdateShallow(current, last hash.Hash) bool {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	l.versionSetOnce.Do(l.setVersIfUnset)
+	return l.updateRootByKey(l.shallowKey, current, last)
+}
+
 func (l *LevelDBStore) Get(ref hash.Hash) Chunk {
 	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
 	return l.getByKey(l.toChunkKey(ref), ref)
@@ -97,6 +113,22 @@ func (l *LevelDBStore) Has(ref hash.Hash) bool {
 	return l.hasByKey(l.toChunkKey(ref))
 }
 
+func (l *LevelDBStore) HashesWithPrefix(prefix string, limit int) (hashes hash.HashSlice, ok bool) {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	first, last, valid := hash.PrefixRange(prefix)
+	if !valid {
+		return nil, true
+	}
+	end := l.toChunkKey(last)
+	iter := l.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for ok := iter.Seek(l.toChunkKey(first)); ok && len(hashes) < limit && bytes.Compare(iter.Key(), end) <= 0; ok = iter.Next() {
+		hashes = append(hashes, hash.FromSlice(iter.Key()[len(l.chunkPrefix):]))
+	}
+	d.Chk.NoError(iter.Error())
+	return hashes, true
+}
+
 func (l *LevelDBStore) Version() string {
 	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
 	return l.versByKey(l.versionKey)
@@ -145,6 +177,15 @@ func (l *LevelDBStore) setVersIfUnset() {
 	}
 }
 
+// LevelDBLockedError is the cause of the panic raised when opening a LevelDBStore in a directory that another process already has open.
+type LevelDBLockedError struct {
+	Dir string
+}
+
+func (e LevelDBLockedError) Error() string {
+	return fmt.Sprintf("LevelDB in %s is locked by another process", e.Dir)
+}
+
 type internalLevelDBStore struct {
 	db                                     *leveldb.DB
 	mu                                     *sync.Mutex
@@ -162,6 +203,9 @@ func newBackingStore(dir string, maxFileHandles int, dumpStats bool) *internalLe
 		OpenFilesCacheCapacity: maxFileHandles,
 		WriteBuffer:            1 << 24, // 16MiB,
 	})
+	if err == syscall.EWOULDBLOCK || err == syscall.EAGAIN {
+		d.PanicIfError(LevelDBLockedError{dir})
+	}
 	d.Chk.NoError(err, "opening internalLevelDBStore in %s", dir)
 	return &internalLevelDBStore{
 		db:                   db,
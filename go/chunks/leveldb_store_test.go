@@ -10,6 +10,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/testify/suite"
 )
 
@@ -56,3 +57,8 @@ func (suite *LevelDBStoreTestSuite) TestReservedKeys() {
 	suite.True(bytes.HasSuffix(ldb.versionKey, []byte(versionKeyConst)))
 	suite.True(bytes.HasSuffix(ldb.chunkPrefix, []byte(chunkPrefixConst)))
 }
+
+func (suite *LevelDBStoreTestSuite) TestLevelDBStoreLocked() {
+	err := d.Try(func() { NewLevelDBStore(suite.dir, "name", 24, false) }, LevelDBLockedError{})
+	suite.Equal(LevelDBLockedError{suite.dir}, err)
+}
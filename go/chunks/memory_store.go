@@ -5,6 +5,7 @@
 package chunks
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/attic-labs/noms/go/constants"
@@ -16,7 +17,8 @@ import (
 type MemoryStore struct {
 	data map[hash.Hash]Chunk
 	memoryRootTracker
-	mu sync.RWMutex
+	shallow memoryRootTracker
+	mu      sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -42,6 +44,37 @@ func (ms *MemoryStore) Has(r hash.Hash) bool {
 	return ok
 }
 
+func (ms *MemoryStore) HashesWithPrefix(prefix string, limit int) (hashes hash.HashSlice, ok bool) {
+	first, last, valid := hash.PrefixRange(prefix)
+	if !valid {
+		return nil, true
+	}
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for h := range ms.data {
+		if !h.Less(first) && !h.Greater(last) {
+			hashes = append(hashes, h)
+		}
+	}
+	sort.Sort(hashes)
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return hashes, true
+}
+
+func (ms *MemoryStore) Shallow() hash.Hash {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.shallow.Root()
+}
+
+func (ms *MemoryStore) UpdateShallow(current, last hash.Hash) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.shallow.UpdateRoot(current, last)
+}
+
 func (ms *MemoryStore) Version() string {
 	return constants.NomsVersion
 }
@@ -46,6 +46,11 @@ func (rts ReadThroughStore) Has(h hash.Hash) bool {
 	return rts.cachingStore.Has(h) || rts.backingStore.Has(h)
 }
 
+// HashesWithPrefix looks only in the backing store, which holds everything the caching store does.
+func (rts ReadThroughStore) HashesWithPrefix(prefix string, limit int) (hash.HashSlice, bool) {
+	return HashesWithPrefix(rts.backingStore, prefix, limit)
+}
+
 func (rts ReadThroughStore) Put(c Chunk) {
 	rts.backingStore.Put(c)
 	rts.cachingStore.Put(c)
@@ -6,7 +6,13 @@ package constants
 
 const (
 	RootPath       = "/root/"
+	ShallowPath    = "/shallow/"
 	GetRefsPath    = "/getRefs/"
+	RefPath        = "/ref/"
 	HasRefsPath    = "/hasRefs/"
 	WriteValuePath = "/writeValue/"
+	PullPath       = "/pull/"
+	HashesPath     = "/hashes/"
+	JSONPath       = "/json/"
+	DiffPath       = "/diff/"
 )
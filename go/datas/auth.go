@@ -0,0 +1,175 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+var authRegexp = regexp.MustCompile(`^Bearer\s+(\S*)$`)
+
+// Grant describes what the bearer of an access token may do with a served Database. Every Grant allows reading.
+type Grant struct {
+	// Write allows writing Values and updating the Database's root.
+	Write bool
+
+	// Prefixes, if non-empty, limits Write to datasets whose IDs begin with one of its elements. As Values are shared between datasets, reads can't be limited in this way.
+	Prefixes []string
+}
+
+// CanWrite returns true if g allows changes to the dataset with the given ID.
+func (g Grant) CanWrite(datasetID string) bool {
+	if !g.Write {
+		return false
+	}
+	if len(g.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range g.Prefixes {
+		if strings.HasPrefix(datasetID, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore maps access tokens to the Grants they confer. Clients present their token either in an "Authorization: Bearer <token>" header or in an access_token query parameter.
+type TokenStore map[string]Grant
+
+// ReadTokenStore parses a TokenStore from r. Each line has the form "<token> read|write [<prefix>...]", where any prefixes restrict the datasets the token may write. Blank lines and lines beginning with # are ignored.
+func ReadTokenStore(r io.Reader) (TokenStore, error) {
+	ts := TokenStore{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Line %d: expected a token followed by read or write", lineNum)
+		}
+		token, scope, prefixes := fields[0], fields[1], fields[2:]
+		if _, present := ts[token]; present {
+			return nil, fmt.Errorf("Line %d: duplicate token", lineNum)
+		}
+		switch scope {
+		case "read":
+			if len(prefixes) > 0 {
+				return nil, fmt.Errorf("Line %d: dataset prefixes can only restrict write tokens", lineNum)
+			}
+			ts[token] = Grant{}
+		case "write":
+			ts[token] = Grant{Write: true, Prefixes: prefixes}
+		default:
+			return nil, fmt.Errorf("Line %d: unknown scope %s, expected read or write", lineNum, scope)
+		}
+	}
+	return ts, scanner.Err()
+}
+
+// RequireRead wraps hndlr so that it's only invoked for requests bearing a token in ts.
+func (ts TokenStore) RequireRead(hndlr Handler) Handler {
+	return ts.require(hndlr, func(g Grant, req *http.Request, cs chunks.ChunkStore) error {
+		return nil
+	})
+}
+
+// RequireWrite wraps hndlr so that it's only invoked for requests bearing a token in ts that allows writing. Use RequireRootWrite to guard HandleRootPost.
+func (ts TokenStore) RequireWrite(hndlr Handler) Handler {
+	return ts.require(hndlr, func(g Grant, req *http.Request, cs chunks.ChunkStore) error {
+		if !g.Write {
+			return fmt.Errorf("Token does not allow writing")
+		}
+		return nil
+	})
+}
+
+// RequireRootWrite wraps a handler for root updates, such as HandleRootPost, so that it's only invoked for requests bearing a token in ts that allows writing every dataset that the update would add, remove or change.
+func (ts TokenStore) RequireRootWrite(hndlr Handler) Handler {
+	return ts.require(hndlr, func(g Grant, req *http.Request, cs chunks.ChunkStore) error {
+		if !g.Write {
+			return fmt.Errorf("Token does not allow writing")
+		}
+		if len(g.Prefixes) == 0 {
+			return nil
+		}
+
+		var changed []string
+		err := d.Try(func() {
+			params := req.URL.Query()
+			changed = changedDatasets(cs, hash.Parse(params.Get("last")), hash.Parse(params.Get("current")))
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range changed {
+			if !g.CanWrite(id) {
+				return fmt.Errorf("Token does not allow writing dataset %s", id)
+			}
+		}
+		return nil
+	})
+}
+
+func (ts TokenStore) require(hndlr Handler, allowed func(g Grant, req *http.Request, cs chunks.ChunkStore) error) Handler {
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		g, ok := ts[requestToken(req)]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Restricted", error="invalid_token"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if err := allowed(g, req, cs); err != nil {
+			http.Error(w, fmt.Sprintf("Error: %v", d.Unwrap(err)), http.StatusForbidden)
+			return
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+func requestToken(req *http.Request) string {
+	if res := authRegexp.FindStringSubmatch(req.Header.Get("Authorization")); res != nil && res[1] != "" {
+		return res[1]
+	}
+	return req.URL.Query().Get("access_token")
+}
+
+// changedDatasets returns the IDs of all datasets that differ between the root Maps stored in cs under last and current.
+func changedDatasets(cs chunks.ChunkStore, last, current hash.Hash) (changed []string) {
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	readRoot := func(h hash.Hash) types.Map {
+		if h.IsEmpty() {
+			return types.NewMap()
+		}
+		m, ok := vs.ReadValue(h).(types.Map)
+		d.PanicIfTrue(!ok, "Root %s is not a Map of datasets", h)
+		return m
+	}
+	lastMap, currentMap := readRoot(last), readRoot(current)
+
+	changeChan := make(chan types.ValueChanged)
+	stopChan := make(chan struct{}, 1) // buffer size of 1, so this won't block if diff already finished
+	defer func() { stopChan <- struct{}{} }()
+	go func() {
+		currentMap.Diff(lastMap, changeChan, stopChan)
+		close(changeChan)
+	}()
+	for change := range changeChan {
+		id, ok := change.V.(types.String)
+		d.PanicIfTrue(!ok, "Dataset ID is not a String")
+		changed = append(changed, string(id))
+	}
+	return
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+const testTokens = `
+# token  scope  prefixes
+reader   read
+admin    write
+alice    write  users/alice/ shared
+`
+
+func TestReadTokenStore(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, err := ReadTokenStore(strings.NewReader(testTokens))
+	assert.NoError(err)
+	assert.Equal(TokenStore{
+		"reader": Grant{},
+		"admin":  Grant{Write: true, Prefixes: []string{}},
+		"alice":  Grant{Write: true, Prefixes: []string{"users/alice/", "shared"}},
+	}, ts)
+
+	for _, bad := range []string{"lonely", "tok admin", "tok read users/", "tok read\ntok write"} {
+		_, err := ReadTokenStore(strings.NewReader(bad))
+		assert.Error(err, bad)
+	}
+}
+
+func TestGrantCanWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(Grant{}.CanWrite("foo"))
+	assert.True(Grant{Write: true}.CanWrite("foo"))
+
+	g := Grant{Write: true, Prefixes: []string{"users/alice/", "shared"}}
+	assert.True(g.CanWrite("users/alice/photos"))
+	assert.True(g.CanWrite("shared-stuff"))
+	assert.False(g.CanWrite("users/bob/photos"))
+	assert.False(g.CanWrite("users/alice"))
+}
+
+func TestTokenStoreRequireRead(t *testing.T) {
+	assert := assert.New(t)
+	ts, err := ReadTokenStore(strings.NewReader(testTokens))
+	assert.NoError(err)
+	hndlr := ts.RequireRead(HandleRootGet)
+	cs := chunks.NewTestStore()
+
+	w := httptest.NewRecorder()
+	hndlr(w, newRequest("GET", "", "", nil, nil), params{}, cs)
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(w.Header().Get("WWW-Authenticate"))
+
+	w = httptest.NewRecorder()
+	hndlr(w, newRequest("GET", "Bearer nobody", "", nil, nil), params{}, cs)
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	hndlr(w, newRequest("GET", "Bearer reader", "", nil, nil), params{}, cs)
+	assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes()))
+
+	w = httptest.NewRecorder()
+	hndlr(w, newRequest("GET", "", "?access_token=alice", nil, nil), params{}, cs)
+	assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes()))
+}
+
+func TestTokenStoreRequireWrite(t *testing.T) {
+	assert := assert.New(t)
+	ts, err := ReadTokenStore(strings.NewReader(testTokens))
+	assert.NoError(err)
+	called := false
+	hndlr := ts.RequireWrite(func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		called = true
+	})
+
+	w := httptest.NewRecorder()
+	hndlr(w, newRequest("POST", "Bearer reader", "", nil, nil), params{}, nil)
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.False(called)
+
+	w = httptest.NewRecorder()
+	hndlr(w, newRequest("POST", "Bearer alice", "", nil, nil), params{}, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.True(called)
+}
+
+func TestTokenStoreRequireRootWrite(t *testing.T) {
+	assert := assert.New(t)
+	ts, err := ReadTokenStore(strings.NewReader(testTokens))
+	assert.NoError(err)
+	hndlr := ts.RequireRootWrite(HandleRootPost)
+
+	cs := chunks.NewTestStore()
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	commit := vs.WriteValue(NewCommit(types.String("hi"), types.NewSet(), types.EmptyStruct))
+	writeRoot := func(ids ...string) hash.Hash {
+		kv := []types.Value{}
+		for _, id := range ids {
+			kv = append(kv, types.String(id), commit)
+		}
+		return vs.WriteValue(types.NewMap(kv...)).TargetHash()
+	}
+	postRoot := func(token string, last, current hash.Hash) int {
+		params := url.Values{}
+		params.Add("last", last.String())
+		params.Add("current", current.String())
+		w := httptest.NewRecorder()
+		hndlr(w, newRequest("POST", "Bearer "+token, "?"+params.Encode(), nil, nil), nil, cs)
+		return w.Code
+	}
+
+	root := writeRoot("users/alice/a", "users/bob/b")
+	assert.Equal(http.StatusForbidden, postRoot("reader", hash.Hash{}, root))
+	assert.Equal(http.StatusForbidden, postRoot("alice", hash.Hash{}, root))
+	assert.Equal(http.StatusOK, postRoot("admin", hash.Hash{}, root))
+
+	// Alice may change her own datasets, as long as bob's are left alone...
+	aliceRoot := writeRoot("users/alice/a", "users/alice/c", "users/bob/b")
+	assert.Equal(http.StatusOK, postRoot("alice", root, aliceRoot))
+
+	// ...and mustn't remove his, either.
+	assert.Equal(http.StatusForbidden, postRoot("alice", aliceRoot, writeRoot("users/alice/a")))
+
+	// A root that isn't a Map of datasets is rejected outright.
+	assert.Equal(http.StatusForbidden, postRoot("alice", aliceRoot, vs.WriteValue(types.String("nope")).TargetHash()))
+	assert.Equal(aliceRoot, cs.Root())
+}
@@ -34,14 +34,25 @@ type Database interface {
 	// Datasets returns the root of the database which is a MapOfStringToRefOfCommit where string is a datasetID.
 	Datasets() types.Map
 
+	// ShallowCommits returns the Set of Refs to the Commits in this Database whose parents aren't all present, because only part of their history was pulled. See PullWithDepth.
+	ShallowCommits() types.Set
+
 	// Commit updates the Commit that datasetID in this database points at. All Values that have been written to this Database are guaranteed to be persistent after Commit(). If the update cannot be performed, e.g., because of a conflict, error will non-nil. The newest snapshot of the database is always returned.
 	Commit(datasetID string, commit types.Struct) (Database, error)
 
 	// Delete removes the Dataset named datasetID from the map at the root of the Database. The Dataset data is not necessarily cleaned up at this time, but may be garbage collected in the future. If the update cannot be performed, e.g., because of a conflict, error will non-nil. The newest snapshot of the database is always returned.
 	Delete(datasetID string) (Database, error)
 
+	// HashesWithPrefix returns, in order, the hashes of up to limit of the Chunks in this Database whose String() starts with prefix. It returns ErrHashPrefixesUnsupported if the Database's storage can't look Chunks up that way.
+	HashesWithPrefix(prefix string, limit int) (hash.HashSlice, error)
+
+	// WatchHead returns a channel that receives the Ref of each new head of datasetID committed after this snapshot of the Database, by any writer. A head that is replaced before it's noticed may be skipped. The channel is closed when the Database is closed.
+	WatchHead(datasetID string) <-chan types.Ref
+
 	has(hash hash.Hash) bool
 	validatingBatchStore() types.BatchStore
+	// setShallowCommits replaces the Set returned by ShallowCommits. The Commits in it may be written with parents that aren't present.
+	setShallowCommits(commits types.Set) error
 }
 
 func NewDatabase(cs chunks.ChunkStore) Database {
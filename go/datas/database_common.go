@@ -19,15 +19,33 @@ type databaseCommon struct {
 	rt       chunks.RootTracker
 	rootRef  hash.Hash
 	datasets *types.Map
+	// st records the hash of the Set of shallow Commits, outside of the map at the root. It's nil if the Database's ChunkStore can't, in which case the Database can never be shallow.
+	st      chunks.ShallowTracker
+	shallow *types.Set
+	closed  closeNotifier
 }
 
 var (
-	ErrOptimisticLockFailed = errors.New("Optimistic lock failed on database Root update")
-	ErrMergeNeeded          = errors.New("Dataset head is not ancestor of commit")
+	ErrOptimisticLockFailed    = errors.New("Optimistic lock failed on database Root update")
+	ErrMergeNeeded             = errors.New("Dataset head is not ancestor of commit")
+	ErrPullCanceled            = errors.New("Pull canceled")
+	ErrShallowRemoteSink       = errors.New("Can't pull part of the history of a Commit into a remote Database")
+	ErrShallowUnsupported      = errors.New("Database can't record shallow Commits, so can't hold part of the history of a Commit")
+	ErrHashPrefixesUnsupported = errors.New("Database can't look up hashes by prefix")
 )
 
+// newDatabaseCommon returns a databaseCommon whose Root is tracked by rt. Shallow Commits are tracked by rt, too, if it's a chunks.ShallowTracker.
 func newDatabaseCommon(cch *cachingChunkHaver, vs *types.ValueStore, rt chunks.RootTracker) databaseCommon {
-	return databaseCommon{cch: cch, vs: vs, rt: rt, rootRef: rt.Root()}
+	st, _ := rt.(chunks.ShallowTracker)
+	return databaseCommon{cch: cch, vs: vs, rt: rt, rootRef: rt.Root(), st: st, closed: newCloseNotifier()}
+}
+
+// snapshot returns a databaseCommon that shares storage with ds, but reflects the current Root.
+func (ds *databaseCommon) snapshot() databaseCommon {
+	dc := newDatabaseCommon(ds.cch, ds.vs, ds.rt)
+	dc.st = ds.st
+	dc.closed = ds.closed
+	return dc
 }
 
 func (ds *databaseCommon) MaybeHead(datasetID string) (types.Struct, bool) {
@@ -69,6 +87,59 @@ func (ds *databaseCommon) Datasets() types.Map {
 	return *ds.datasets
 }
 
+func (ds *databaseCommon) ShallowCommits() types.Set {
+	if ds.shallow == nil {
+		commits := ds.shallowCommitsFromHash(ds.shallowHash())
+		ds.shallow = &commits
+	}
+	return *ds.shallow
+}
+
+func (ds *databaseCommon) shallowHash() hash.Hash {
+	if ds.st == nil {
+		return hash.Hash{}
+	}
+	return ds.st.Shallow()
+}
+
+func (ds *databaseCommon) shallowCommitsFromHash(h hash.Hash) types.Set {
+	if h.IsEmpty() {
+		return types.NewSet()
+	}
+	return ds.ReadValue(h).(types.Set)
+}
+
+// doSetShallowCommits replaces the Set of shallow Commits recorded in the Database, retrying if another writer updates it in the meantime. It returns ErrShallowUnsupported if commits isn't empty and the Database's ChunkStore can't record it.
+func (ds *databaseCommon) doSetShallowCommits(commits types.Set) error {
+	if ds.st == nil {
+		if commits.Empty() {
+			return nil
+		}
+		return ErrShallowUnsupported
+	}
+	for {
+		last := ds.st.Shallow()
+		if ds.shallowCommitsFromHash(last).Equals(commits) {
+			break
+		}
+		current := hash.Hash{}
+		if !commits.Empty() {
+			// Reading the Commits puts them in the cache that WriteValue checks Refs against.
+			commits.IterAll(func(v types.Value) {
+				ds.ReadValue(v.(types.Ref).TargetHash())
+			})
+			current = ds.WriteValue(commits).TargetHash()
+		}
+		// Unlike UpdateRoot, UpdateShallow doesn't flush, so the Set, and whatever was pulled along with it, has to be written out first.
+		ds.vs.Flush()
+		if ds.st.UpdateShallow(current, last) {
+			break
+		}
+	}
+	ds.shallow = &commits
+	return nil
+}
+
 func (ds *databaseCommon) has(h hash.Hash) bool {
 	return ds.cch.Has(h)
 }
@@ -82,6 +153,7 @@ func (ds *databaseCommon) WriteValue(v types.Value) types.Ref {
 }
 
 func (ds *databaseCommon) Close() error {
+	ds.closed.close()
 	return ds.vs.Close()
 }
 
@@ -161,11 +233,15 @@ func descendsFrom(commit types.Struct, currentHeadRef types.Ref, vr types.ValueR
 	return true
 }
 
+// getAncestors returns the parents of commits. Commits that aren't present, because they're beyond the history that a shallow Database holds, are taken to have none.
 func getAncestors(commits types.Set, vr types.ValueReader) types.Set {
 	ancestors := types.NewSet()
 	commits.IterAll(func(v types.Value) {
 		r := v.(types.Ref)
-		c := r.TargetValue(vr).(types.Struct)
+		c, ok := r.TargetValue(vr).(types.Struct)
+		if !ok {
+			return
+		}
 		next := []types.Value{}
 		c.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
 			next = append(next, v)
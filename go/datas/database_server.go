@@ -5,6 +5,7 @@
 package datas
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -29,13 +30,23 @@ type remoteDatabaseServer struct {
 	closing bool
 	// Called just before the server is started.
 	Ready func()
+	// If non-nil, every request must bear one of these tokens, and requests that modify the Database must bear a token that allows it. Set before calling Run().
+	Tokens TokenStore
+	// If non-nil, the server accepts only TLS connections, configured by TLSConfig. Set before calling Run().
+	TLSConfig *tls.Config
+	routes    []route
+}
+
+type route struct {
+	method, path string
+	hndlr        Handler
 }
 
 func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *remoteDatabaseServer {
 	dataVersion := cs.Version()
 	d.PanicIfTrue(constants.NomsVersion != dataVersion, "SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
 	return &remoteDatabaseServer{
-		cs, port, nil, make(chan *connectionState, 16), false, func() {},
+		cs, port, nil, make(chan *connectionState, 16), false, func() {}, nil, nil, nil,
 	}
 }
 
@@ -49,6 +60,9 @@ func (s *remoteDatabaseServer) Run() {
 
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
 	d.Chk.NoError(err)
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
 	s.l = &l
 	_, port, err := net.SplitHostPort(l.Addr().String())
 	d.Chk.NoError(err)
@@ -58,15 +72,29 @@ func (s *remoteDatabaseServer) Run() {
 
 	router := httprouter.New()
 
-	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
+	read, write, rootWrite := s.guards()
+	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(read(HandleGetRefs))))
+	router.GET(constants.GetRefsPath, s.corsHandle(s.makeHandle(read(HandleGetRefs))))
 	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
-	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
+	router.GET(constants.RefPath+":hash", s.corsHandle(s.makeHandle(read(HandleRefGet))))
+	router.OPTIONS(constants.RefPath+":hash", s.corsHandle(noopHandle))
+	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(read(HandleHasRefs))))
 	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
-	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootGet)))
-	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
+	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(read(HandleRootGet))))
+	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(rootWrite(HandleRootPost))))
 	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
-	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
+	router.GET(constants.ShallowPath, s.corsHandle(s.makeHandle(read(HandleShallowGet))))
+	router.POST(constants.ShallowPath, s.corsHandle(s.makeHandle(rootWrite(HandleShallowPost))))
+	router.OPTIONS(constants.ShallowPath, s.corsHandle(noopHandle))
+	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(write(HandleWriteValue))))
 	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
+	router.POST(constants.PullPath, s.corsHandle(s.makeHandle(read(HandlePull))))
+	router.OPTIONS(constants.PullPath, s.corsHandle(noopHandle))
+	router.GET(constants.HashesPath, s.corsHandle(s.makeHandle(read(HandleHashesGet))))
+	router.OPTIONS(constants.HashesPath, s.corsHandle(noopHandle))
+	for _, r := range s.routes {
+		router.Handle(r.method, r.path, s.corsHandle(s.makeHandle(read(r.hndlr))))
+	}
 
 	srv := &http.Server{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -94,6 +122,20 @@ func (s *remoteDatabaseServer) Run() {
 	srv.Serve(l)
 }
 
+// AddRoute makes the server pass requests for method and path, which may contain httprouter params, to hndlr. The request must bear a read token if s.Tokens is set. Call before Run().
+func (s *remoteDatabaseServer) AddRoute(method, path string, hndlr Handler) {
+	s.routes = append(s.routes, route{method, path, hndlr})
+}
+
+// guards returns the wrappers that enforce s.Tokens on read, write and root update handlers. Without Tokens, they leave handlers unchanged.
+func (s *remoteDatabaseServer) guards() (read, write, rootWrite func(Handler) Handler) {
+	if s.Tokens == nil {
+		allow := func(hndlr Handler) Handler { return hndlr }
+		return allow, allow, allow
+	}
+	return s.Tokens.RequireRead, s.Tokens.RequireWrite, s.Tokens.RequireRootWrite
+}
+
 func (s *remoteDatabaseServer) makeHandle(hndlr Handler) httprouter.Handle {
 	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		hndlr(w, req, ps, s.cs)
@@ -110,7 +152,7 @@ func (s *remoteDatabaseServer) corsHandle(f httprouter.Handle) httprouter.Handle
 		// Can't use * when clients are using cookies.
 		w.Header().Add("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 		w.Header().Add("Access-Control-Allow-Methods", "GET, POST")
-		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader)
+		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader+", Authorization")
 		w.Header().Add("Access-Control-Expose-Headers", NomsVersionHeader)
 		w.Header().Add(NomsVersionHeader, constants.NomsVersion)
 		f(w, r, ps)
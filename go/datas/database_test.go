@@ -5,11 +5,14 @@
 package datas
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
 )
 
@@ -318,3 +321,188 @@ func (suite *DatabaseSuite) TestDatabaseHeightOfCollections() {
 
 	suite.ds.WriteValue(types.NewList(andMore...))
 }
+
+func (suite *DatabaseSuite) TestDatabaseCommitRootConflict() {
+	fs := chunks.NewFaultInjectingStore(suite.cs, chunks.FaultOptions{RootConflict: chunks.FailOn(0)})
+	ds := suite.makeDs(fs)
+	defer ds.Close()
+
+	datasetID := "ds1"
+	a := types.String("a")
+	aCommit := NewCommit(a, types.NewSet(), types.EmptyStruct)
+
+	// Another writer appears to have moved the root, so the first attempt must fail without changing anything.
+	ds, err := ds.Commit(datasetID, aCommit)
+	suite.Equal(ErrOptimisticLockFailed, err)
+	suite.Equal(1, fs.RootConflicts)
+	_, ok := ds.MaybeHead(datasetID)
+	suite.False(ok)
+	suite.True(suite.cs.Root().IsEmpty())
+
+	// Retrying against the up-to-date Database succeeds.
+	ds, err = ds.Commit(datasetID, aCommit)
+	suite.NoError(err)
+	suite.True(ds.Head(datasetID).Get(ValueField).Equals(a))
+}
+
+func (suite *DatabaseSuite) TestDatabaseDroppedWrites() {
+	fs := chunks.NewFaultInjectingStore(suite.cs, chunks.FaultOptions{Drop: chunks.FailAfter(0)})
+	ds := suite.makeDs(fs)
+	defer ds.Close()
+
+	_, err := ds.Commit("ds1", NewCommit(types.String("a"), types.NewSet(), types.EmptyStruct))
+	suite.NoError(err)
+	suite.NotEmpty(fs.Dropped)
+
+	// The root moved, but the chunk it points at never landed, so a fresh Database can't read its datasets.
+	root := suite.cs.Root()
+	suite.False(root.IsEmpty())
+	suite.False(suite.cs.Has(root))
+	fresh := suite.makeDs(suite.cs)
+	defer fresh.Close()
+	suite.Panics(func() { fresh.Datasets() })
+}
+
+func (suite *DatabaseSuite) TestDatabaseCommitWithLatency() {
+	fs := chunks.NewFaultInjectingStore(suite.cs, chunks.FaultOptions{Latency: time.Millisecond})
+	ds := suite.makeDs(fs)
+	defer ds.Close()
+
+	datasetID := "ds1"
+	aCommit := NewCommit(types.String("a"), types.NewSet(), types.EmptyStruct)
+	ds, err := ds.Commit(datasetID, aCommit)
+	suite.NoError(err)
+	b := types.String("b")
+	ds, err = ds.Commit(datasetID, NewCommit(b, types.NewSet(types.NewRef(aCommit)), types.EmptyStruct))
+	suite.NoError(err)
+
+	fresh := suite.makeDs(suite.cs)
+	defer fresh.Close()
+	suite.True(fresh.Head(datasetID).Get(ValueField).Equals(b))
+}
+
+func (suite *DatabaseSuite) TestDatabaseWatchHead() {
+	defer func(d time.Duration) { rootPollInterval = d }(rootPollInterval)
+	rootPollInterval = time.Millisecond
+
+	datasetID := "ds1"
+	watcher := suite.makeDs(suite.cs)
+	heads := watcher.WatchHead(datasetID)
+	nextHead := func() (types.Ref, bool) {
+		select {
+		case r, ok := <-heads:
+			return r, ok
+		case <-time.After(5 * time.Second):
+			suite.Fail("Timed out waiting for head")
+			return types.Ref{}, false
+		}
+	}
+
+	// Commits made through another Database, and to other datasets, are seen.
+	aCommit := NewCommit(types.String("a"), types.NewSet(), types.EmptyStruct)
+	suite.ds, _ = suite.ds.Commit("other", aCommit)
+	ds, err := suite.ds.Commit(datasetID, aCommit)
+	suite.NoError(err)
+	suite.ds = ds
+	r, ok := nextHead()
+	suite.True(ok)
+	suite.True(suite.ds.HeadRef(datasetID).Equals(r))
+
+	bCommit := NewCommit(types.String("b"), types.NewSet(types.NewRef(aCommit)), types.EmptyStruct)
+	suite.ds, err = suite.ds.Commit(datasetID, bCommit)
+	suite.NoError(err)
+	r, ok = nextHead()
+	suite.True(ok)
+	suite.True(suite.ds.HeadRef(datasetID).Equals(r))
+
+	watcher.Close()
+	_, ok = nextHead()
+	suite.False(ok)
+}
+
+// closeOnRootChangeStore closes db the first time Root returns something other than last, just before returning it, and counts the reads made after it's closed.
+type closeOnRootChangeStore struct {
+	*chunks.TestStore
+	mu              sync.Mutex
+	db              Database
+	last            hash.Hash
+	closed          bool
+	readsAfterClose int
+}
+
+func (s *closeOnRootChangeStore) Root() hash.Hash {
+	root := s.TestStore.Root()
+	s.mu.Lock()
+	db := s.db
+	if root != s.last {
+		s.db = nil
+	}
+	s.mu.Unlock()
+	if root != s.last && db != nil {
+		db.Close()
+	}
+	return root
+}
+
+func (s *closeOnRootChangeStore) Get(h hash.Hash) chunks.Chunk {
+	s.mu.Lock()
+	if s.closed {
+		s.readsAfterClose++
+	}
+	s.mu.Unlock()
+	return s.TestStore.Get(h)
+}
+
+func (s *closeOnRootChangeStore) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.TestStore.Close()
+}
+
+func TestDatabaseWatchHeadClosedWhilePending(t *testing.T) {
+	assert := assert.New(t)
+	defer func(d time.Duration) { rootPollInterval = d }(rootPollInterval)
+	rootPollInterval = time.Millisecond
+
+	cs := chunks.NewTestStore()
+	store := &closeOnRootChangeStore{TestStore: cs, last: cs.Root()}
+	watcher := NewDatabase(store)
+	store.mu.Lock()
+	store.db = watcher
+	store.mu.Unlock()
+	heads := watcher.WatchHead("ds")
+
+	// The watch sees the new Root, but the Database is closed before it can read the new head.
+	writer := NewDatabase(cs)
+	_, err := writer.Commit("ds", NewCommit(types.String("a"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	writer.Close()
+
+	select {
+	case _, ok := <-heads:
+		assert.False(ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Timed out waiting for the watch to end")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.True(store.closed)
+	assert.Equal(0, store.readsAfterClose)
+}
+
+func (suite *DatabaseSuite) TestDatabaseHashesWithPrefix() {
+	a := NewCommit(types.String("a"), types.NewSet(), types.EmptyStruct)
+	ds, err := suite.ds.Commit("foo", a)
+	suite.NoError(err)
+	suite.ds = ds
+
+	h := a.Hash()
+	hashes, err := suite.ds.HashesWithPrefix(h.Abbrev(), 2)
+	suite.NoError(err)
+	suite.Equal(hash.HashSlice{h}, hashes)
+
+	hashes, err = suite.ds.HashesWithPrefix("", 1)
+	suite.NoError(err)
+	suite.Len(hashes, 1)
+}
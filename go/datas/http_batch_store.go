@@ -7,11 +7,14 @@ package datas
 import (
 	"bufio"
 	"compress/gzip"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +34,9 @@ const (
 	readBufferSize           = 1 << 12 // 4K
 
 	httpStatusTooManyRequests = 429 // This is new in Go 1.6. Once the builders have that, use it.
+
+	// maxCacheableGetRefs limits the number of hashes in each GET request for Chunks, keeping URLs short enough for caching proxies to accept.
+	maxCacheableGetRefs = 64
 )
 
 // httpBatchStore implements types.BatchStore
@@ -47,15 +53,17 @@ type httpBatchStore struct {
 	requestWg     *sync.WaitGroup
 	workerWg      *sync.WaitGroup
 	unwrittenPuts *orderedChunkCache
+	// If true, Chunks are fetched with GET requests, whose responses HTTP caches may store, rather than with POST requests.
+	cacheableReads bool
 }
 
-func newHTTPBatchStore(baseURL, auth string) *httpBatchStore {
+func newHTTPBatchStore(baseURL, auth string, tlsConfig *tls.Config) *httpBatchStore {
 	u, err := url.Parse(baseURL)
 	d.PanicIfError(err)
 	d.PanicIfTrue(u.Scheme != "http" && u.Scheme != "https", "Unrecognized scheme: %s", u.Scheme)
 	buffSink := &httpBatchStore{
 		host:          u,
-		httpClient:    makeHTTPClient(httpChunkSinkConcurrency),
+		httpClient:    makeHTTPClient(httpChunkSinkConcurrency, tlsConfig),
 		auth:          auth,
 		getQueue:      make(chan chunks.ReadRequest, readBufferSize),
 		hasQueue:      make(chan chunks.ReadRequest, readBufferSize),
@@ -83,10 +91,13 @@ type writeRequest struct {
 	justHints bool
 }
 
-// Use a custom http client rather than http.DefaultClient. We limit ourselves to a maximum of |requestLimit| concurrent http requests, the custom httpClient ups the maxIdleConnsPerHost value so that one connection stays open for each concurrent request.
-func makeHTTPClient(requestLimit int) *http.Client {
+// Use a custom http client rather than http.DefaultClient. We limit ourselves to a maximum of |requestLimit| concurrent http requests, the custom httpClient ups the maxIdleConnsPerHost value so that one connection stays open for each concurrent request. If tlsConfig is non-nil, it's used for https connections in place of the default configuration.
+func makeHTTPClient(requestLimit int, tlsConfig *tls.Config) *http.Client {
 	t := http.Transport(*http.DefaultTransport.(*http.Transport))
 	t.MaxIdleConnsPerHost = requestLimit
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
 	// This sets, essentially, an idle-timeout. The timer starts counting AFTER the client has finished sending the entire request to the server. As soon as the client receives the server's response headers, the timeout is canceled.
 	t.ResponseHeaderTimeout = time.Duration(2) * time.Minute
 
@@ -207,6 +218,11 @@ func (bhcs *httpBatchStore) sendReadRequests(req chunks.ReadRequest, queue <-cha
 }
 
 func (bhcs *httpBatchStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch) {
+	if bhcs.cacheableReads {
+		bhcs.getCacheableRefs(hashes, batch)
+		return
+	}
+
 	// POST http://<host>/getRefs/. Post body: ref=hash0&ref=hash1& Response will be chunk data if present, 404 if absent.
 	u := *bhcs.host
 	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.GetRefsPath)
@@ -228,6 +244,63 @@ func (bhcs *httpBatchStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 	chunks.Deserialize(reader, &readBatchChunkSink{&batch, &sync.RWMutex{}}, rl)
 }
 
+func (bhcs *httpBatchStore) getCacheableRefs(hashes hash.HashSet, batch chunks.ReadBatch) {
+	// GET http://<host>/ref/<hash> for a single Chunk, or GET http://<host>/getRefs/?ref=hash0&ref=hash1& for several. Hashes are sorted so that the same set of hashes always produces the same URL, and so the same cache entry.
+	sorted := make(hash.HashSlice, 0, len(hashes))
+	for h := range hashes {
+		sorted = append(sorted, h)
+	}
+	sort.Sort(sorted)
+
+	sink := &readBatchChunkSink{&batch, &sync.RWMutex{}}
+	for len(sorted) > 0 {
+		n := len(sorted)
+		if n > maxCacheableGetRefs {
+			n = maxCacheableGetRefs
+		}
+		group := sorted[:n]
+		sorted = sorted[n:]
+
+		u := *bhcs.host
+		if len(group) == 1 {
+			u.Path = httprouter.CleanPath(bhcs.host.Path + constants.RefPath + group[0].String())
+		} else {
+			u.Path = httprouter.CleanPath(bhcs.host.Path + constants.GetRefsPath)
+			values := u.Query()
+			for _, h := range group {
+				values.Add("ref", h.String())
+			}
+			u.RawQuery = values.Encode()
+		}
+
+		req := newRequest("GET", bhcs.auth, u.String(), nil, http.Header{
+			"Accept-Encoding": {"x-snappy-framed"},
+		})
+		res, err := bhcs.httpClient.Do(req)
+		d.Chk.NoError(err)
+		expectVersion(res)
+		reader := resBodyReader(res)
+
+		if len(group) == 1 {
+			if res.StatusCode == http.StatusNotFound {
+				closeResponse(reader)
+				continue
+			}
+			d.Chk.True(http.StatusOK == res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+			data, err := ioutil.ReadAll(reader)
+			d.Chk.NoError(err)
+			c := chunks.NewChunk(data)
+			d.Chk.True(c.Hash() == group[0], "Expected chunk %s, got %s", group[0], c.Hash())
+			sink.Put(c)
+		} else {
+			d.Chk.True(http.StatusOK == res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+			rl := make(chan struct{}, 16)
+			chunks.Deserialize(reader, sink, rl)
+		}
+		closeResponse(reader)
+	}
+}
+
 type readBatchChunkSink struct {
 	batch *chunks.ReadBatch
 	mu    *sync.RWMutex
@@ -422,6 +495,18 @@ func (bhcs *httpBatchStore) sendWriteRequests(hashes hash.HashSet, hints types.H
 	}()
 }
 
+// hashesWithPrefix implements chunks.HashPrefixSource for the server's ChunkStore.
+func (bhcs *httpBatchStore) hashesWithPrefix(prefix string, limit int) (hash.HashSlice, bool) {
+	// GET http://<host>/hashes/?prefix=<prefix>&limit=<limit>. Response will be one hash per line, or 404 from servers that predate the endpoint.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.HashesPath)
+	u.RawQuery = url.Values{"prefix": {prefix}, "limit": {strconv.Itoa(limit)}}.Encode()
+
+	res, err := bhcs.httpClient.Do(newRequest("GET", bhcs.auth, u.String(), nil, nil))
+	d.Chk.NoError(err)
+	return readHashesResponse(res)
+}
+
 func (bhcs *httpBatchStore) Root() hash.Hash {
 	// GET http://<host>/root. Response will be ref of root.
 	res := bhcs.requestRoot("GET", hash.Hash{}, hash.Hash{})
@@ -447,6 +532,54 @@ func (bhcs *httpBatchStore) UpdateRoot(current, last hash.Hash) bool {
 	return res.StatusCode == http.StatusOK
 }
 
+func (bhcs *httpBatchStore) Shallow() hash.Hash {
+	// GET http://<host>/shallow. Response will be the hash of the Set of shallow Commits, or 404 from servers that predate the endpoint.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.ShallowPath)
+	res, err := bhcs.httpClient.Do(newRequest("GET", bhcs.auth, u.String(), nil, nil))
+	d.PanicIfError(err)
+	return readShallowResponse(res)
+}
+
+// UpdateShallow flushes outstanding writes, as UpdateRoot does, since current is expected to name a recently-Put Set.
+func (bhcs *httpBatchStore) UpdateShallow(current, last hash.Hash) bool {
+	// POST http://<host>/shallow?current=<ref>&last=<ref>. Response will be 200 on success, 409 if current is outdated.
+	bhcs.Flush()
+
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.ShallowPath)
+	u.RawQuery = url.Values{"last": {last.String()}, "current": {current.String()}}.Encode()
+	res, err := bhcs.httpClient.Do(newRequest("POST", bhcs.auth, u.String(), nil, nil))
+	d.PanicIfError(err)
+	expectVersion(res)
+	defer closeResponse(res.Body)
+
+	d.PanicIfTrue(res.StatusCode != http.StatusOK && res.StatusCode != http.StatusConflict, "Unexpected response: %s", formatErrorResponse(res))
+	return res.StatusCode == http.StatusOK
+}
+
+// waitForRoot asks the server to hold the request until its Root differs from last. Servers that don't support waiting respond immediately.
+func (bhcs *httpBatchStore) waitForRoot(last hash.Hash, cancel <-chan struct{}) hash.Hash {
+	// GET http://<host>/root?last=<ref>. Response will be ref of root, once it differs from last or the server times out.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.RootPath)
+	params := u.Query()
+	params.Add("last", last.String())
+	u.RawQuery = params.Encode()
+
+	req := newRequest("GET", bhcs.auth, u.String(), nil, nil)
+	req.Cancel = cancel
+	res, err := bhcs.httpClient.Do(req)
+	d.PanicIfError(err)
+	expectVersion(res)
+	defer closeResponse(res.Body)
+
+	d.PanicIfTrue(http.StatusOK != res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	data, err := ioutil.ReadAll(res.Body)
+	d.PanicIfError(err)
+	return hash.Parse(string(data))
+}
+
 func (bhcs *httpBatchStore) requestRoot(method string, current, last hash.Hash) *http.Response {
 	u := *bhcs.host
 	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.RootPath)
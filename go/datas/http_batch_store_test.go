@@ -5,10 +5,13 @@
 package datas
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -53,6 +56,26 @@ func (suite *HTTPBatchStoreSuite) SetupTest() {
 }
 
 func newHTTPBatchStoreForTest(cs chunks.ChunkStore) *httpBatchStore {
+	serv := newInlineServerForTest(cs)
+	serv.POST(
+		constants.PullPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandlePull(w, req, ps, cs)
+		},
+	)
+	hcs := newHTTPBatchStore("http://localhost:9000", "", nil)
+	hcs.httpClient = serv
+	return hcs
+}
+
+// newLegacyHTTPBatchStoreForTest returns an httpBatchStore backed by a server that predates the pull/ endpoint.
+func newLegacyHTTPBatchStoreForTest(cs chunks.ChunkStore) *httpBatchStore {
+	hcs := newHTTPBatchStore("http://localhost:9000", "", nil)
+	hcs.httpClient = newInlineServerForTest(cs)
+	return hcs
+}
+
+func newInlineServerForTest(cs chunks.ChunkStore) inlineServer {
 	serv := inlineServer{httprouter.New()}
 	serv.POST(
 		constants.WriteValuePath,
@@ -66,6 +89,18 @@ func newHTTPBatchStoreForTest(cs chunks.ChunkStore) *httpBatchStore {
 			HandleGetRefs(w, req, ps, cs)
 		},
 	)
+	serv.GET(
+		constants.GetRefsPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandleGetRefs(w, req, ps, cs)
+		},
+	)
+	serv.GET(
+		constants.RefPath+":hash",
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandleRefGet(w, req, ps, cs)
+		},
+	)
 	serv.POST(
 		constants.HasRefsPath,
 		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -78,15 +113,87 @@ func newHTTPBatchStoreForTest(cs chunks.ChunkStore) *httpBatchStore {
 			HandleRootPost(w, req, ps, cs)
 		},
 	)
+	serv.GET(
+		constants.HashesPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandleHashesGet(w, req, ps, cs)
+		},
+	)
 	serv.GET(
 		constants.RootPath,
 		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 			HandleRootGet(w, req, ps, cs)
 		},
 	)
-	hcs := newHTTPBatchStore("http://localhost:9000", "")
-	hcs.httpClient = serv
-	return hcs
+	serv.GET(
+		constants.ShallowPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandleShallowGet(w, req, ps, cs)
+		},
+	)
+	serv.POST(
+		constants.ShallowPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			HandleShallowPost(w, req, ps, cs)
+		},
+	)
+	return serv
+}
+
+// cachingProxy stands in for an HTTP cache between an httpBatchStore and its server. It stores the responses to GET requests that are marked immutable.
+type cachingProxy struct {
+	backend httpDoer
+	mu      *sync.Mutex
+	cache   map[string]cachedResponse
+	// misses counts the GET requests that were passed to backend.
+	misses int
+}
+
+type cachedResponse struct {
+	code   int
+	header http.Header
+	body   []byte
+}
+
+func newCachingProxy(backend httpDoer) *cachingProxy {
+	return &cachingProxy{backend, &sync.Mutex{}, map[string]cachedResponse{}, 0}
+}
+
+func (p *cachingProxy) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return p.backend.Do(req)
+	}
+
+	key := req.URL.String() + " " + req.Header.Get("Accept-Encoding")
+	p.mu.Lock()
+	cached, ok := p.cache[key]
+	if !ok {
+		p.misses++
+	}
+	p.mu.Unlock()
+	if ok {
+		return &http.Response{
+			StatusCode: cached.code,
+			Status:     http.StatusText(cached.code),
+			Header:     cached.header,
+			Body:       ioutil.NopCloser(bytes.NewReader(cached.body)),
+		}, nil
+	}
+
+	res, err := p.backend.Do(req)
+	if err != nil || !strings.Contains(res.Header.Get("Cache-Control"), "immutable") {
+		return res, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	p.mu.Lock()
+	p.cache[key] = cachedResponse{res.StatusCode, res.Header, body}
+	p.mu.Unlock()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
 }
 
 func newAuthenticatingHTTPBatchStoreForTest(suite *HTTPBatchStoreSuite, hostUrl string) *httpBatchStore {
@@ -102,7 +209,7 @@ func newAuthenticatingHTTPBatchStoreForTest(suite *HTTPBatchStoreSuite, hostUrl
 			HandleRootPost(w, req, ps, suite.cs)
 		},
 	)
-	hcs := newHTTPBatchStore(hostUrl, "")
+	hcs := newHTTPBatchStore(hostUrl, "", nil)
 	hcs.httpClient = serv
 	return hcs
 }
@@ -116,7 +223,7 @@ func newBadVersionHTTPBatchStoreForTest(suite *HTTPBatchStoreSuite) *httpBatchSt
 			w.Header().Set(NomsVersionHeader, "BAD")
 		},
 	)
-	hcs := newHTTPBatchStore("http://localhost", "")
+	hcs := newHTTPBatchStore("http://localhost", "", nil)
 	hcs.httpClient = serv
 	return hcs
 }
@@ -217,6 +324,56 @@ func (suite *HTTPBatchStoreSuite) TestPutChunksBackpressure() {
 	suite.Equal(6, suite.cs.Writes)
 }
 
+func (suite *HTTPBatchStoreSuite) TestGetChunksThroughCache() {
+	chnx := []chunks.Chunk{
+		types.EncodeValue(types.String("abc"), nil),
+		types.EncodeValue(types.String("def"), nil),
+		types.EncodeValue(types.String("ghi"), nil),
+	}
+	suite.NoError(suite.cs.PutMany(chnx))
+	absent := hash.Parse("00000000000000000000000000000002")
+
+	proxy := newCachingProxy(suite.store.httpClient)
+	newStore := func() *httpBatchStore {
+		store := newHTTPBatchStoreForTest(suite.cs)
+		store.httpClient = proxy
+		store.cacheableReads = true
+		return store
+	}
+	getAll := func(store *httpBatchStore) {
+		for _, c := range chnx {
+			suite.Equal(c.Hash(), store.Get(c.Hash()).Hash())
+		}
+		suite.True(store.Get(absent).IsEmpty())
+
+		// Several hashes at once are fetched in one request.
+		hashes := hash.HashSet{}
+		batch := chunks.ReadBatch{}
+		got := map[hash.Hash]chan chunks.Chunk{}
+		for _, h := range []hash.Hash{chnx[0].Hash(), chnx[2].Hash()} {
+			hashes.Insert(h)
+			got[h] = make(chan chunks.Chunk, 1)
+			batch[h] = append(batch[h], chunks.NewGetRequest(h, got[h]).Outstanding())
+		}
+		store.getRefs(hashes, batch)
+		batch.Close()
+		for h, ch := range got {
+			suite.Equal(h, (<-ch).Hash())
+		}
+	}
+
+	store := newStore()
+	getAll(store)
+	store.Close()
+	suite.Equal(5, proxy.misses)
+
+	// Chunks are immutable, so a second client is served entirely from the cache, except for the missing Chunk.
+	store = newStore()
+	getAll(store)
+	store.Close()
+	suite.Equal(6, proxy.misses)
+}
+
 func (suite *HTTPBatchStoreSuite) TestRoot() {
 	c := chunks.NewChunk([]byte("abc"))
 	suite.True(suite.cs.UpdateRoot(c.Hash(), hash.Hash{}))
@@ -255,6 +412,34 @@ func (suite *HTTPBatchStoreSuite) TestGet() {
 	suite.Equal(chnx[1].Hash(), got.Hash())
 }
 
+func (suite *HTTPBatchStoreSuite) TestHashesWithPrefix() {
+	chnx := []chunks.Chunk{
+		chunks.NewChunk([]byte("abc")),
+		chunks.NewChunk([]byte("def")),
+	}
+	suite.NoError(suite.cs.PutMany(chnx))
+	h := chnx[1].Hash()
+	hashes, ok := suite.store.hashesWithPrefix(h.Abbrev(), 10)
+	suite.True(ok)
+	suite.Equal(hash.HashSlice{h}, hashes)
+	hashes, ok = suite.store.hashesWithPrefix("", 10)
+	suite.True(ok)
+	suite.Len(hashes, 2)
+
+	// The server can't look up hashes by prefix if its ChunkStore can't.
+	bs := newHTTPBatchStoreForTest(&backpressureCS{ChunkStore: suite.cs})
+	defer bs.Close()
+	_, ok = bs.hashesWithPrefix(h.Abbrev(), 10)
+	suite.False(ok)
+
+	// Nor if it predates the hashes/ endpoint.
+	bs = newHTTPBatchStore("http://localhost:9000", "", nil)
+	bs.httpClient = inlineServer{httprouter.New()}
+	defer bs.Close()
+	_, ok = bs.hashesWithPrefix(h.Abbrev(), 10)
+	suite.False(ok)
+}
+
 func (suite *HTTPBatchStoreSuite) TestGetSame() {
 	chnx := []chunks.Chunk{
 		chunks.NewChunk([]byte("def")),
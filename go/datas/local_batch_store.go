@@ -91,6 +91,11 @@ func (lbs *localBatchStore) AddHints(hints types.Hints) {
 	}
 }
 
+// allowMissingRefs lets the Chunk with hash h refer to Chunks that aren't present when it's flushed, as a shallow Commit does.
+func (lbs *localBatchStore) allowMissingRefs(h hash.Hash) {
+	lbs.vbs.AllowMissingRefs(h)
+}
+
 func (lbs *localBatchStore) Flush() {
 	lbs.once.Do(lbs.expectVersion)
 
@@ -7,6 +7,7 @@ package datas
 import (
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -18,20 +19,27 @@ type LocalDatabase struct {
 
 func newLocalDatabase(cs chunks.ChunkStore) *LocalDatabase {
 	bs := types.NewBatchStoreAdaptor(cs)
-	return &LocalDatabase{
-		newDatabaseCommon(newCachingChunkHaver(cs), types.NewValueStore(bs), bs),
-		cs,
-	}
+	dc := newDatabaseCommon(newCachingChunkHaver(cs), types.NewValueStore(bs), bs)
+	dc.st, _ = cs.(chunks.ShallowTracker)
+	return &LocalDatabase{dc, cs}
 }
 
 func (lds *LocalDatabase) Commit(datasetID string, commit types.Struct) (Database, error) {
 	err := lds.commit(datasetID, commit)
-	return &LocalDatabase{newDatabaseCommon(lds.cch, lds.vs, lds.rt), lds.cs}, err
+	return &LocalDatabase{lds.snapshot(), lds.cs}, err
 }
 
 func (lds *LocalDatabase) Delete(datasetID string) (Database, error) {
 	err := lds.doDelete(datasetID)
-	return &LocalDatabase{newDatabaseCommon(lds.cch, lds.vs, lds.rt), lds.cs}, err
+	return &LocalDatabase{lds.snapshot(), lds.cs}, err
+}
+
+func (lds *LocalDatabase) HashesWithPrefix(prefix string, limit int) (hash.HashSlice, error) {
+	hashes, ok := chunks.HashesWithPrefix(lds.cs, prefix, limit)
+	if !ok {
+		return nil, ErrHashPrefixesUnsupported
+	}
+	return hashes, nil
 }
 
 func (lds *LocalDatabase) validatingBatchStore() (bs types.BatchStore) {
@@ -40,7 +48,17 @@ func (lds *LocalDatabase) validatingBatchStore() (bs types.BatchStore) {
 		bs = newLocalBatchStore(lds.cs)
 		lds.vs = types.NewValueStore(bs)
 		lds.rt = bs
+		// The new ValueStore only knows about the Refs in the root map if it reads it itself.
+		lds.datasets = nil
 	}
 	d.Chk.True(bs.IsValidating())
 	return bs
 }
+
+func (lds *LocalDatabase) setShallowCommits(commits types.Set) error {
+	lbs := lds.validatingBatchStore().(*localBatchStore)
+	commits.IterAll(func(v types.Value) {
+		lbs.allowMissingRefs(v.(types.Ref).TargetHash())
+	})
+	return lds.doSetShallowCommits(commits)
+}
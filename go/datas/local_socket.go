@@ -0,0 +1,252 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// localSocketHost is used to build request URLs for the local socket protocol. Requests are always dialed to the socket, so it's never resolved.
+const localSocketHost = "http://localsocket"
+
+var hashesRequestHeader = http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+
+// localSocketDrainTimeout bounds how long Stop waits for requests in flight to finish on their own, before cutting their connections, e.g. those of clients waiting for the Root to change.
+var localSocketDrainTimeout = 10 * time.Second
+
+// LocalSocketServer shares a ChunkStore with other processes on the same machine by serving it over a Unix domain socket. It speaks the same HTTP protocol as remoteDatabaseServer, so all writes and root updates are applied by the owning process and UpdateRoot keeps its compare-and-swap semantics for every client.
+type LocalSocketServer struct {
+	cs   chunks.ChunkStore
+	path string
+	srv  *http.Server
+	// handlers tracks the requests being handled, which Stop waits for, so that cs can be closed once it returns.
+	handlers sync.WaitGroup
+	// mu guards stopped, which is set once Stop begins waiting for handlers, after which no more are started.
+	mu      sync.Mutex
+	stopped bool
+}
+
+// NewLocalSocketServer starts serving cs on a Unix domain socket at path, replacing any socket left behind at path by a previous owner. The caller must ensure that it's the only process serving path. Stop() shuts the server down, but leaves cs open.
+func NewLocalSocketServer(cs chunks.ChunkStore, path string) (*LocalSocketServer, error) {
+	if dataVersion := cs.Version(); constants.NomsVersion != dataVersion {
+		return nil, fmt.Errorf("SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &LocalSocketServer{cs: cs, path: path}
+	handle := func(hndlr Handler) httprouter.Handle {
+		return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			s.mu.Lock()
+			if s.stopped {
+				s.mu.Unlock()
+				http.Error(w, "Server is stopping", http.StatusServiceUnavailable)
+				return
+			}
+			s.handlers.Add(1)
+			s.mu.Unlock()
+			defer s.handlers.Done()
+			hndlr(w, req, ps, cs)
+		}
+	}
+	router := httprouter.New()
+	router.POST(constants.GetRefsPath, handle(HandleGetRefs))
+	router.POST(constants.HasRefsPath, handle(HandleHasRefs))
+	router.GET(constants.RootPath, handle(HandleRootGet))
+	router.POST(constants.RootPath, handle(HandleRootPost))
+	router.GET(constants.ShallowPath, handle(HandleShallowGet))
+	router.POST(constants.ShallowPath, handle(HandleShallowPost))
+	router.POST(constants.WriteValuePath, handle(HandleWriteValue))
+	router.GET(constants.HashesPath, handle(HandleHashesGet))
+
+	s.srv = &http.Server{Handler: router}
+	go s.srv.Serve(l)
+	return s, nil
+}
+
+// Stop closes the socket, so that no new clients can connect, and returns once the requests in flight have been handled, so that the served ChunkStore can then be closed. Requests still in flight after localSocketDrainTimeout have their connections cut, and Stop waits for their handlers to notice.
+func (s *LocalSocketServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), localSocketDrainTimeout)
+	defer cancel()
+	if s.srv.Shutdown(ctx) != nil {
+		s.srv.Close()
+	}
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.handlers.Wait()
+	os.Remove(s.path)
+}
+
+// localSocketChunkStore implements chunks.ChunkStore by forwarding every call to a LocalSocketServer. Unlike httpBatchStore, it does no batching or caching of its own, so that it can stand in for the ChunkStore being served.
+type localSocketChunkStore struct {
+	host       *url.URL
+	httpClient *http.Client
+}
+
+// NewLocalSocketChunkStore returns a ChunkStore backed by the LocalSocketServer listening at path. It returns an error if nothing is listening there. The ChunkStore can't outlive the server: once it stops, e.g. because the process serving it exits, every call panics with the error from connecting to it, and the store has to be opened afresh, such as by reopening the database it belongs to.
+func NewLocalSocketChunkStore(path string) (chunks.ChunkStore, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	u, err := url.Parse(localSocketHost)
+	d.PanicIfError(err)
+	t := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}
+	return &localSocketChunkStore{u, &http.Client{Transport: t}}, nil
+}
+
+func (s *localSocketChunkStore) Get(h hash.Hash) chunks.Chunk {
+	res := s.do("POST", constants.GetRefsPath, hashesRequestHeader, buildHashesRequest(hash.HashSet{h: struct{}{}}))
+	d.PanicIfTrue(http.StatusOK != res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+
+	found := chunks.EmptyChunk
+	chunkChan := make(chan *chunks.Chunk, 1)
+	go chunks.DeserializeToChan(reader, chunkChan)
+	for c := range chunkChan {
+		found = *c
+	}
+	return found
+}
+
+func (s *localSocketChunkStore) Has(h hash.Hash) bool {
+	res := s.do("POST", constants.HasRefsPath, hashesRequestHeader, buildHashesRequest(hash.HashSet{h: struct{}{}}))
+	d.PanicIfTrue(http.StatusOK != res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanWords)
+	d.Chk.True(scanner.Scan() && hash.Parse(scanner.Text()) == h)
+	d.Chk.True(scanner.Scan())
+	return scanner.Text() == "true"
+}
+
+// Version returns the version of the running SDK, as the server refuses to serve data of any other version.
+func (s *localSocketChunkStore) Version() string {
+	return constants.NomsVersion
+}
+
+func (s *localSocketChunkStore) Put(c chunks.Chunk) {
+	d.PanicIfTrue(s.PutMany([]chunks.Chunk{c}) != nil, "Unexpected backpressure writing %s", c.Hash())
+}
+
+// PutMany sends chunks to the server, which validates them before writing them to its ChunkStore. Chunks must therefore be provided in ref-height order, after any Chunks they reference.
+func (s *localSocketChunkStore) PutMany(chnx []chunks.Chunk) chunks.BackpressureError {
+	if len(chnx) == 0 {
+		return nil
+	}
+	chunkChan := make(chan *chunks.Chunk, len(chnx))
+	for i := range chnx {
+		chunkChan <- &chnx[i]
+	}
+	close(chunkChan)
+
+	res := s.do("POST", constants.WriteValuePath, http.Header{
+		"Content-Encoding": {"x-snappy-framed"},
+		"Content-Type":     {"application/octet-stream"},
+	}, buildWriteValueRequest(chunkChan, types.Hints{}))
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+
+	if res.StatusCode == httpStatusTooManyRequests {
+		return chunks.BackpressureError(deserializeHashes(reader))
+	}
+	d.PanicIfTrue(http.StatusCreated != res.StatusCode, "Unexpected response: %s", formatErrorResponse(res))
+	return nil
+}
+
+func (s *localSocketChunkStore) Root() hash.Hash {
+	res := s.do("GET", constants.RootPath, nil, nil)
+	defer closeResponse(res.Body)
+
+	d.PanicIfTrue(http.StatusOK != res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	data, err := ioutil.ReadAll(res.Body)
+	d.PanicIfError(err)
+	return hash.Parse(string(data))
+}
+
+func (s *localSocketChunkStore) UpdateRoot(current, last hash.Hash) bool {
+	params := url.Values{}
+	params.Add("last", last.String())
+	params.Add("current", current.String())
+	res := s.do("POST", constants.RootPath+"?"+params.Encode(), nil, nil)
+	defer closeResponse(res.Body)
+
+	d.PanicIfTrue(res.StatusCode != http.StatusOK && res.StatusCode != http.StatusConflict, "Unexpected response: %s", formatErrorResponse(res))
+	return res.StatusCode == http.StatusOK
+}
+
+func (s *localSocketChunkStore) Shallow() hash.Hash {
+	req := newRequest("GET", "", s.host.String()+constants.ShallowPath, nil, nil)
+	res, err := s.httpClient.Do(req)
+	d.PanicIfError(err)
+	return readShallowResponse(res)
+}
+
+func (s *localSocketChunkStore) UpdateShallow(current, last hash.Hash) bool {
+	params := url.Values{}
+	params.Add("last", last.String())
+	params.Add("current", current.String())
+	res := s.do("POST", constants.ShallowPath+"?"+params.Encode(), nil, nil)
+	defer closeResponse(res.Body)
+
+	d.PanicIfTrue(res.StatusCode != http.StatusOK && res.StatusCode != http.StatusConflict, "Unexpected response: %s", formatErrorResponse(res))
+	return res.StatusCode == http.StatusOK
+}
+
+func (s *localSocketChunkStore) HashesWithPrefix(prefix string, limit int) (hash.HashSlice, bool) {
+	query := url.Values{"prefix": {prefix}, "limit": {strconv.Itoa(limit)}}.Encode()
+	req := newRequest("GET", "", s.host.String()+constants.HashesPath+"?"+query, nil, nil)
+	res, err := s.httpClient.Do(req)
+	d.PanicIfError(err)
+	return readHashesResponse(res)
+}
+
+// Close drops any idle connections to the server. The served ChunkStore stays open.
+func (s *localSocketChunkStore) Close() error {
+	s.httpClient.Transport.(*http.Transport).CloseIdleConnections()
+	return nil
+}
+
+func (s *localSocketChunkStore) do(method, path string, header http.Header, body io.Reader) *http.Response {
+	req := newRequest(method, "", s.host.String()+path, body, header)
+	res, err := s.httpClient.Do(req)
+	d.PanicIfError(err)
+	expectVersion(res)
+	return res
+}
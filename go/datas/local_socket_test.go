@@ -0,0 +1,146 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestLocalSocketChunkStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sock")
+
+	_, err = NewLocalSocketChunkStore(path)
+	assert.Error(err, "Nothing is listening yet")
+
+	ms := chunks.NewMemoryStore()
+	server, err := NewLocalSocketServer(ms, path)
+	assert.NoError(err)
+
+	cs, err := NewLocalSocketChunkStore(path)
+	assert.NoError(err)
+
+	c1, c2 := chunks.NewChunk([]byte("abc")), chunks.NewChunk([]byte("def"))
+	assert.True(cs.Get(c1.Hash()).IsEmpty())
+	assert.False(cs.Has(c1.Hash()))
+
+	// Chunks have to decode as Values, as the server validates them.
+	v := types.String("abc")
+	c1 = types.EncodeValue(v, nil)
+	cs.Put(c1)
+	assert.True(ms.Has(c1.Hash()))
+	assert.True(cs.Has(c1.Hash()))
+	assert.Equal(c1.Data(), cs.Get(c1.Hash()).Data())
+
+	hashes, ok := chunks.HashesWithPrefix(cs, c1.Hash().Abbrev(), 10)
+	assert.True(ok)
+	assert.Equal(hash.HashSlice{c1.Hash()}, hashes)
+
+	// UpdateRoot is a compare-and-swap against the served store's root.
+	assert.True(cs.Root().IsEmpty())
+	assert.True(cs.UpdateRoot(c1.Hash(), hash.Hash{}))
+	assert.Equal(c1.Hash(), ms.Root())
+	assert.True(ms.UpdateRoot(c2.Hash(), c1.Hash()))
+	assert.False(cs.UpdateRoot(c1.Hash(), c1.Hash()))
+	assert.Equal(c2.Hash(), cs.Root())
+
+	assert.NoError(cs.Close())
+	server.Stop()
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestLocalSocketDatabase(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sock")
+
+	ms := chunks.NewMemoryStore()
+	server, err := NewLocalSocketServer(ms, path)
+	assert.NoError(err)
+	defer server.Stop()
+	cs, err := NewLocalSocketChunkStore(path)
+	assert.NoError(err)
+
+	db := NewDatabase(cs)
+	defer db.Close()
+	l := types.NewList(types.String("a"), types.NewList(types.Number(1)))
+	db, err = db.Commit("ds", NewCommit(l, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+
+	owner := NewDatabase(ms)
+	assert.True(owner.Head("ds").Get(ValueField).Equals(l))
+
+	// A commit by the owner makes the client's next attempt fail, rather than overwrite it.
+	_, err = owner.Commit("ds", NewCommit(types.String("b"), types.NewSet(owner.HeadRef("ds")), types.EmptyStruct))
+	assert.NoError(err)
+	_, err = db.Commit("ds", NewCommit(types.String("c"), types.NewSet(db.HeadRef("ds")), types.EmptyStruct))
+	assert.Equal(ErrMergeNeeded, err)
+}
+
+// blockingGetStore blocks Get until release is closed, having first sent on entered.
+type blockingGetStore struct {
+	*chunks.MemoryStore
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s blockingGetStore) Get(h hash.Hash) chunks.Chunk {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.MemoryStore.Get(h)
+}
+
+func TestLocalSocketServerStopWaitsForRequests(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sock")
+
+	ms := chunks.NewMemoryStore()
+	c := types.EncodeValue(types.String("abc"), nil)
+	ms.Put(c)
+	bs := blockingGetStore{ms, make(chan struct{}), make(chan struct{})}
+	server, err := NewLocalSocketServer(bs, path)
+	assert.NoError(err)
+	cs, err := NewLocalSocketChunkStore(path)
+	assert.NoError(err)
+
+	got := make(chan chunks.Chunk)
+	go func() { got <- cs.Get(c.Hash()) }()
+	<-bs.entered
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		assert.Fail("Stop returned while a request was being handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(bs.release)
+	assert.Equal(c.Data(), (<-got).Data())
+	<-stopped
+	_, err = NewLocalSocketChunkStore(path)
+	assert.Error(err)
+}
@@ -5,9 +5,9 @@
 package datas
 
 import (
-	"sort"
 	"sync"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
@@ -17,20 +17,100 @@ type PullProgress struct {
 	DoneCount, KnownCount, DoneBytes uint64
 }
 
+// pullSink is where pull sends the Chunks that it copies.
+type pullSink interface {
+	// has returns true if the sink is known to already have the Chunk with hash h, and everything it refers to. Returning false is always safe, but causes the Chunk to be copied.
+	has(h hash.Hash) bool
+	// get returns a copy of the Chunk with hash h that the sink already holds, without it counting towards has, or the empty Chunk if there isn't one. This lets pull avoid reading such Chunks from the source again.
+	get(h hash.Hash) chunks.Chunk
+	// schedulePut copies c, whose Ref has the given height, to the sink. It must be goroutine-safe.
+	schedulePut(c chunks.Chunk, height uint64)
+	// addHints is called once, after all Chunks have been copied, with the hashes of Chunks in the sink that refer to Chunks the copied ones depend on.
+	addHints(hints types.Hints)
+}
+
+// databaseSink copies Chunks into a Database.
+type databaseSink struct {
+	db Database
+}
+
+func (s databaseSink) has(h hash.Hash) bool {
+	return s.db.has(h)
+}
+
+func (s databaseSink) get(h hash.Hash) chunks.Chunk {
+	return chunks.EmptyChunk
+}
+
+func (s databaseSink) schedulePut(c chunks.Chunk, height uint64) {
+	s.db.validatingBatchStore().SchedulePut(c, height, types.Hints{})
+}
+
+func (s databaseSink) addHints(hints types.Hints) {
+	s.db.validatingBatchStore().AddHints(hints)
+}
+
 // Pull objects that descends from sourceRef from srcDB to sinkDB. sinkHeadRef should point to a Commit (in sinkDB) that's an ancestor of sourceRef. This allows the algorithm to figure out which portions of data are already present in sinkDB and skip copying them.
+// If srcDB is a remote Database whose server supports it, the server works out which Chunks sinkDB is missing and streams them all in a single response. Otherwise, the two Databases are walked in tandem from here. If sinkDB is shallow, its history is deepened. See PullWithDepth.
 func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) {
-	srcQ, sinkQ := &types.RefByHeight{sourceRef}, &types.RefByHeight{sinkHeadRef}
+	d.PanicIfError(PullWithDepth(srcDB, sinkDB, sourceRef, sinkHeadRef, 0, concurrency, progressCh))
+}
+
+// mostLocalDatabase returns whichever of srcDB and sinkDB is a LocalDatabase, preferring sinkDB. Since we expect sourceRef to descend from sinkHeadRef, we assume srcDB has a superset of the data in sinkDB. There are some cases where, logically, the code wants to read data it knows to be in sinkDB. In this case, it doesn't actually matter which Database the data comes from, so as an optimization we use whichever is a LocalDatabase -- if either is.
+func mostLocalDatabase(srcDB, sinkDB Database) Database {
+	if _, ok := sinkDB.(*LocalDatabase); ok {
+		return sinkDB
+	}
+	return srcDB
+}
+
+// pullState is everything pull needs to carry on walking srcDB and sinkDB from where it left off.
+type pullState struct {
+	sourceRef, sinkHeadRef types.Ref
+	srcQ, sinkQ            *refQueue
+	// hc and reachableChunks are used to compute the hints that are handed to the sink once srcQ is empty. hc maps the hash of each Chunk found under sinkHeadRef to the hash of the Chunk that refers to it, and reachableChunks holds, with empty values, the hashes of Chunks found under sourceRef that haven't been copied.
+	hc              *hashMap
+	reachableChunks *hashMap
+	progress        PullProgress
+	// exclude holds the hashes of Commits that are too far back in history to be copied, and shallow is what the sink's ShallowCommits will be once the pull is done. See PullWithDepth.
+	exclude hash.HashSet
+	shallow types.Set
+}
 
-	// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just clear out sinkQ right now and don't bother.
-	if !srcDB.has(sinkHeadRef.TargetHash()) {
-		sinkQ.PopBack()
+func newPullState(srcDB Database, sourceRef, sinkHeadRef types.Ref) *pullState {
+	srcQ, sinkQ := newRefQueue(sourceRef), newRefQueue()
+
+	// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just leave sinkQ empty and don't bother.
+	if srcDB.has(sinkHeadRef.TargetHash()) {
+		sinkQ.PushBack(sinkHeadRef)
 	}
+	return &pullState{sourceRef, sinkHeadRef, srcQ, sinkQ, newHashMap(), newHashMap(), PullProgress{}, hash.HashSet{}, types.NewSet()}
+}
 
-	// Since we expect sourceRef to descend from sinkHeadRef, we assume srcDB has a superset of the data in sinkDB. There are some cases where, logically, the code wants to read data it knows to be in sinkDB. In this case, it doesn't actually matter which Database the data comes from, so as an optimization we use whichever is a LocalDatabase -- if either is.
-	mostLocalDB := srcDB
-	if _, ok := sinkDB.(*LocalDatabase); ok {
-		mostLocalDB = sinkDB
+// destroy removes any temporary storage that state's queues and hint bookkeeping have spilled to. state can't be used afterwards.
+func (state *pullState) destroy() {
+	for _, q := range []*refQueue{state.srcQ, state.sinkQ} {
+		if q != nil {
+			q.Destroy()
+		}
 	}
+	for _, m := range []*hashMap{state.hc, state.reachableChunks} {
+		if m != nil {
+			m.Destroy()
+		}
+	}
+}
+
+// streamable returns true if the pull that state describes copies everything under sourceRef that isn't under sinkHeadRef, which is what a server streams.
+func (state *pullState) streamable() bool {
+	return len(state.exclude) == 0 && state.srcQ.Len() == 1
+}
+
+// pull copies the Chunks reachable from state.sourceRef in srcDB, and not reachable from state.sinkHeadRef, to sink. Chunks under sinkHeadRef are read from mostLocalDB, which must have them. state is updated as pull goes, and checkpoint, if non-nil, is called with it each time the walk descends a level.
+// If cancel is closed before the walk is finished, pull abandons any work in flight, leaves state describing what remains to be done and returns false. Otherwise, it hands the hints the copied Chunks need to sink and returns true.
+func pull(srcDB, mostLocalDB Database, sink pullSink, state *pullState, concurrency int, progressCh chan PullProgress, cancel <-chan struct{}, checkpoint func(*pullState)) bool {
+	srcQ, sinkQ, sinkHeadRef := state.srcQ, state.sinkQ, state.sinkHeadRef
+
 	// traverseWorker below takes refs off of {src,sink,com}Chan, processes them to figure out what reachable refs should be traversed, and then sends the results to {srcRes,sinkRes,comRes}Chan.
 	// sending to (or closing) the 'done' channel causes traverseWorkers to exit.
 	srcChan := make(chan types.Ref)
@@ -41,6 +121,7 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 	comResChan := make(chan traverseResult)
 	done := make(chan struct{})
 
+	// workerWg tracks the goroutines that send work, too, as they can only be sure to exit once done is closed.
 	workerWg := &sync.WaitGroup{}
 	defer func() {
 		close(done)
@@ -59,11 +140,11 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 			for {
 				select {
 				case srcRef := <-srcChan:
-					srcResChan <- traverseSource(srcRef, srcDB, sinkDB)
+					sendResult(srcResChan, traverseSource(srcRef, srcDB, sink), done)
 				case sinkRef := <-sinkChan:
-					sinkResChan <- traverseSink(sinkRef, mostLocalDB)
+					sendResult(sinkResChan, traverseSink(sinkRef, mostLocalDB), done)
 				case comRef := <-comChan:
-					comResChan <- traverseCommon(comRef, sinkHeadRef, mostLocalDB)
+					sendResult(comResChan, traverseCommon(comRef, sinkHeadRef, mostLocalDB), done)
 				case <-done:
 					workerWg.Done()
 					return
@@ -75,18 +156,32 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 		traverseWorker()
 	}
 
-	var doneCount, knownCount, doneBytes uint64
+	// progress.KnownCount doesn't include the Refs in srcQ, which are added whenever progress is reported.
+	progress := &state.progress
 	updateProgress := func(moreDone, moreKnown, moreBytes uint64) {
+		progress.DoneCount, progress.KnownCount, progress.DoneBytes = progress.DoneCount+moreDone, progress.KnownCount+moreKnown, progress.DoneBytes+moreBytes
 		if progressCh == nil {
 			return
 		}
-		doneCount, knownCount, doneBytes = doneCount+moreDone, knownCount+moreKnown, doneBytes+moreBytes
-		progressCh <- PullProgress{doneCount, knownCount + uint64(srcQ.Len()), doneBytes}
+		progressCh <- PullProgress{progress.DoneCount, progress.KnownCount + uint64(srcQ.Len()), progress.DoneBytes}
+	}
+	sendWork := func(ch chan<- types.Ref, refs types.RefSlice) {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for _, r := range refs {
+				select {
+				case ch <- r:
+				case <-done:
+					return
+				}
+			}
+		}()
 	}
 
 	// hc and reachableChunks aren't goroutine-safe, so only write them here.
-	hc := hintCache{}
-	reachableChunks := hash.HashSet{}
+	hc := state.hc
+	reachableChunks := state.reachableChunks
 	for !srcQ.Empty() {
 		srcRefs, sinkRefs, comRefs := planWork(srcQ, sinkQ)
 		srcWork, sinkWork, comWork := len(srcRefs), len(sinkRefs), len(comRefs)
@@ -94,18 +189,29 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 			updateProgress(0, uint64(srcWork+comWork), 0)
 		}
 
-		// These goroutines send work to traverseWorkers, blocking when all are busy. They self-terminate when they've sent all they have.
-		go sendWork(srcChan, srcRefs)
-		go sendWork(sinkChan, sinkRefs)
-		go sendWork(comChan, comRefs)
+		// If the pull is canceled, the Refs that haven't been traversed yet need to go back on their queues.
+		pending := newPendingRefs(srcRefs, sinkRefs, comRefs)
+
+		// These goroutines send work to traverseWorkers, blocking when all are busy. They self-terminate when they've sent all they have, or done is closed.
+		sendWork(srcChan, srcRefs)
+		sendWork(sinkChan, sinkRefs)
+		sendWork(comChan, comRefs)
 		//  Don't use srcRefs, sinkRefs, or comRefs after this point. The goroutines above own them.
 
 		for srcWork+sinkWork+comWork > 0 {
 			select {
+			case <-cancel:
+				pending.requeue(srcQ, sinkQ)
+				progress.KnownCount -= uint64(srcWork + comWork)
+				return false
 			case res := <-srcResChan:
+				delete(pending.src, res.ref.TargetHash())
 				for _, reachable := range res.reachables {
+					if state.exclude.Has(reachable.TargetHash()) {
+						continue
+					}
 					srcQ.PushBack(reachable)
-					reachableChunks.Insert(reachable.TargetHash())
+					reachableChunks.Set(reachable.TargetHash(), hash.Hash{})
 				}
 				if !res.readHash.IsEmpty() {
 					reachableChunks.Remove(res.readHash)
@@ -113,40 +219,76 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				srcWork--
 				updateProgress(1, 0, uint64(res.readBytes))
 			case res := <-sinkResChan:
+				delete(pending.sink, res.ref.TargetHash())
 				for _, reachable := range res.reachables {
 					sinkQ.PushBack(reachable)
-					hc[reachable.TargetHash()] = res.readHash
+					hc.Set(reachable.TargetHash(), res.readHash)
 				}
 				sinkWork--
 			case res := <-comResChan:
+				delete(pending.com, res.ref.TargetHash())
 				isHeadOfSink := res.readHash == sinkHeadRef.TargetHash()
 				for _, reachable := range res.reachables {
+					if state.exclude.Has(reachable.TargetHash()) {
+						continue
+					}
 					sinkQ.PushBack(reachable)
 					if !isHeadOfSink {
 						srcQ.PushBack(reachable)
 					}
-					hc[reachable.TargetHash()] = res.readHash
+					hc.Set(reachable.TargetHash(), res.readHash)
 				}
 				comWork--
 				updateProgress(1, 0, uint64(res.readBytes))
 			}
 		}
-		sort.Sort(sinkQ)
-		sort.Sort(srcQ)
-		sinkQ.Unique()
-		srcQ.Unique()
+		if checkpoint != nil {
+			checkpoint(state)
+		}
 	}
 
 	hints := types.Hints{}
-	for hash := range reachableChunks {
-		if hint, present := hc[hash]; present {
+	reachableChunks.IterAll(func(h, _ hash.Hash) {
+		if hint, present := hc.Get(h); present {
 			hints[hint] = struct{}{}
 		}
+	})
+	sink.addHints(hints)
+	return true
+}
+
+// pendingRefs holds the Refs, by target hash, that have been taken off of srcQ and sinkQ to be traversed but haven't been yet.
+type pendingRefs struct {
+	src, sink, com map[hash.Hash]types.Ref
+}
+
+func newPendingRefs(srcRefs, sinkRefs, comRefs types.RefSlice) pendingRefs {
+	index := func(refs types.RefSlice) map[hash.Hash]types.Ref {
+		m := make(map[hash.Hash]types.Ref, len(refs))
+		for _, r := range refs {
+			m[r.TargetHash()] = r
+		}
+		return m
+	}
+	return pendingRefs{index(srcRefs), index(sinkRefs), index(comRefs)}
+}
+
+// requeue puts the pending Refs back on the queues that planWork took them from. Common Refs were taken from both.
+func (p pendingRefs) requeue(srcQ, sinkQ *refQueue) {
+	for _, r := range p.src {
+		srcQ.PushBack(r)
+	}
+	for _, r := range p.sink {
+		sinkQ.PushBack(r)
+	}
+	for _, r := range p.com {
+		srcQ.PushBack(r)
+		sinkQ.PushBack(r)
 	}
-	sinkDB.validatingBatchStore().AddHints(hints)
 }
 
 type traverseResult struct {
+	ref        types.Ref
 	readHash   hash.Hash
 	reachables types.RefSlice
 	readBytes  int
@@ -159,8 +301,9 @@ type traverseResult struct {
 //
 // As we build up lists of refs to be processed in parallel, we need to avoid blowing past potential common refs. When processing a given Ref, we enumerate Refs of all Chunks that are directly reachable, which must _by definition_ be shorter than the given Ref. This means that, for example, if the queues are the same height we know that nothing can happen that will put more Refs of that height on either queue. In general, if you look at the height of the Ref at the head of a queue, you know that all Refs of that height in the current graph under consideration are already in the queue. Conversely, for any height less than that of the head of the queue, it's possible that Refs of that height remain to be discovered. Given this, we can figure out which Refs are safe to pull off the 'taller' queue in the cases where the heights of the two queues are not equal.
 // If one queue is 'taller' than the other, it's clear that we can process all refs from the taller queue with height greater than the height of the 'shorter' queue. We should also be able to process refs from the taller queue that are of the same height as the shorter queue, as long as we also check to see if they're common to both queues. It is not safe, however, to pull unique items off the shorter queue at this point. It's possible that, in processing some of the Refs from the taller queue, that these Refs will be discovered to be common after all.
+// So that the work in flight doesn't grow with the size of the graph, at most pullSpillThreshold Refs are taken at a time. That's safe for the same reason: processing some of the Refs of a given height can only uncover shorter ones, so the rest can wait for the next round.
 // TODO: Bug 2203
-func planWork(srcQ, sinkQ *types.RefByHeight) (srcRefs, sinkRefs, comRefs types.RefSlice) {
+func planWork(srcQ, sinkQ *refQueue) (srcRefs, sinkRefs, comRefs types.RefSlice) {
 	srcHt, sinkHt := tallestHeight(srcQ), tallestHeight(sinkQ)
 	if srcHt > sinkHt {
 		srcRefs = popRefsOfHeight(srcQ, srcHt)
@@ -171,71 +314,72 @@ func planWork(srcQ, sinkQ *types.RefByHeight) (srcRefs, sinkRefs, comRefs types.
 		return
 	}
 	d.Chk.True(srcHt == sinkHt)
-	srcRefs, comRefs = findCommon(srcQ, sinkQ, srcHt)
-	sinkRefs = popRefsOfHeight(sinkQ, sinkHt)
-	return
+	return findCommon(srcQ, sinkQ, srcHt)
 }
 
-func popRefsOfHeight(q *types.RefByHeight, height uint64) (refs types.RefSlice) {
-	for tallestHeight(q) == height {
-		r := q.PopBack()
-		refs = append(refs, r)
+func popRefsOfHeight(q *refQueue, height uint64) (refs types.RefSlice) {
+	for len(refs) < pullSpillThreshold && !q.Empty() && tallestHeight(q) == height {
+		refs = append(refs, q.PopBack())
 	}
 	return
 }
 
-func findCommon(taller, shorter *types.RefByHeight, height uint64) (tallRefs, comRefs types.RefSlice) {
-	d.Chk.True(tallestHeight(taller) == height)
-	d.Chk.True(tallestHeight(shorter) == height)
-	comIndices := []int{}
-	// Walk through shorter and taller in tandem from the back (where the tallest Refs are). Refs from taller that go into a work queue are popped off directly, but doing so to shorter would mess up shortIdx. So, instead just keep track of the indices of common refs and drop them from shorter at the end.
-	for shortIdx := shorter.Len() - 1; !taller.Empty() && tallestHeight(taller) == height; {
-		tallPeek := taller.PeekEnd()
-		shortPeek := shorter.PeekAt(shortIdx)
-		if types.HeightOrder(tallPeek, shortPeek) {
-			tallRefs = append(tallRefs, taller.PopBack())
-			continue
-		}
-		if shortPeek.Equals(tallPeek) {
-			comIndices = append(comIndices, shortIdx)
-			comRefs = append(comRefs, taller.PopBack())
+// findCommon takes up to pullSpillThreshold Refs of the given height off of srcQ and sinkQ, and sorts them into those that were only in srcQ, those that were only in sinkQ and those that were in both. The queues yield Refs in the same order, so this is a merge.
+func findCommon(srcQ, sinkQ *refQueue, height uint64) (srcRefs, sinkRefs, comRefs types.RefSlice) {
+	d.Chk.True(tallestHeight(srcQ) == height)
+	d.Chk.True(tallestHeight(sinkQ) == height)
+	for n := 0; n < pullSpillThreshold; n++ {
+		inSrc := !srcQ.Empty() && tallestHeight(srcQ) == height
+		inSink := !sinkQ.Empty() && tallestHeight(sinkQ) == height
+		switch {
+		case inSrc && inSink && srcQ.PeekEnd().Equals(sinkQ.PeekEnd()):
+			comRefs = append(comRefs, srcQ.PopBack())
+			sinkQ.PopBack()
+		case inSrc && (!inSink || types.HeightOrder(srcQ.PeekEnd(), sinkQ.PeekEnd())):
+			srcRefs = append(srcRefs, srcQ.PopBack())
+		case inSink:
+			sinkRefs = append(sinkRefs, sinkQ.PopBack())
+		default:
+			return
 		}
-		shortIdx--
 	}
-	shorter.DropIndices(comIndices)
 	return
 }
 
-func tallestHeight(h *types.RefByHeight) uint64 {
+func tallestHeight(h *refQueue) uint64 {
 	return h.PeekEnd().Height()
 }
 
-func sendWork(ch chan<- types.Ref, refs types.RefSlice) {
-	for _, r := range refs {
-		ch <- r
+func sendResult(ch chan<- traverseResult, res traverseResult, done <-chan struct{}) {
+	select {
+	case ch <- res:
+	case <-done:
 	}
 }
 
-type hintCache map[hash.Hash]hash.Hash
-
-func traverseSource(srcRef types.Ref, srcDB, sinkDB Database) traverseResult {
+func traverseSource(srcRef types.Ref, srcDB Database, sink pullSink) traverseResult {
 	h := srcRef.TargetHash()
-	if !sinkDB.has(h) {
-		srcBS := srcDB.validatingBatchStore()
-		c := srcBS.Get(h)
+	if !sink.has(h) {
+		c := sink.get(h)
+		if c.IsEmpty() {
+			c = srcDB.validatingBatchStore().Get(h)
+		}
 		v := types.DecodeValue(c, srcDB)
 		d.Chk.True(v != nil, "Expected decoded chunk to be non-nil.")
-		sinkDB.validatingBatchStore().SchedulePut(c, srcRef.Height(), types.Hints{})
-		return traverseResult{h, v.Chunks(), len(c.Data())}
+		sink.schedulePut(c, srcRef.Height())
+		return traverseResult{srcRef, h, v.Chunks(), len(c.Data())}
 	}
-	return traverseResult{}
+	return traverseResult{ref: srcRef}
 }
 
+// traverseSink skips Chunks that db doesn't have, which are beyond the history of a shallow Database.
 func traverseSink(sinkRef types.Ref, db Database) traverseResult {
 	if sinkRef.Height() > 1 {
-		return traverseResult{sinkRef.TargetHash(), sinkRef.TargetValue(db).Chunks(), 0}
+		if v := sinkRef.TargetValue(db); v != nil {
+			return traverseResult{sinkRef, sinkRef.TargetHash(), v.Chunks(), 0}
+		}
 	}
-	return traverseResult{}
+	return traverseResult{ref: sinkRef}
 }
 
 func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
@@ -256,7 +400,7 @@ func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
 			}
 			i++
 		}
-		return traverseResult{comRef.TargetHash(), chunks, 0}
+		return traverseResult{comRef, comRef.TargetHash(), chunks, 0}
 	}
-	return traverseResult{}
+	return traverseResult{ref: comRef}
 }
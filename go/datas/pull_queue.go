@@ -0,0 +1,302 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// pullSpillThreshold is how many entries a refQueue or hashMap holds in memory before it starts moving them to temporary LevelDB storage, and how many Refs pull takes off of its queues at a time. Together, these bound how much memory a pull needs, however big the graph being pulled is. It's a var so that tests can make it small.
+var pullSpillThreshold = 1 << 18
+
+// openSpillDB creates a LevelDB in a new temporary directory, for a refQueue or hashMap to move entries to. Like orderedChunkCache, it doesn't need to be durable.
+func openSpillDB() (*leveldb.DB, string) {
+	dir, err := ioutil.TempDir("", "")
+	d.PanicIfError(err)
+	db, err := leveldb.OpenFile(dir, &opt.Options{
+		Compression:            opt.NoCompression,
+		Filter:                 filter.NewBloomFilter(10), // 10 bits/key
+		OpenFilesCacheCapacity: 24,
+		NoSync:                 true,
+		WriteBuffer:            1 << 24, // 16MiB
+	})
+	d.Chk.NoError(err, "opening pull spill space in %s", dir)
+	return db, dir
+}
+
+func destroySpillDB(db *leveldb.DB, dir string) {
+	if db == nil {
+		return
+	}
+	d.PanicIfError(db.Close())
+	d.PanicIfError(os.RemoveAll(dir))
+}
+
+// refQueue holds Refs, each target at most once, and gives them back tallest first, in the order of types.HeightOrder. Once it holds more than pullSpillThreshold Refs, the shortest half of them are moved to disk. pull only ever pushes Refs shorter than the ones it pops, so those are the ones it will need last; they're brought back, the tallest first, when nothing in memory is taller.
+type refQueue struct {
+	mem    types.RefByHeight
+	inMem  hash.HashSet
+	sorted bool
+
+	disk    *leveldb.DB
+	diskDir string
+	diskLen int
+	// diskTop is the key of the tallest Ref on disk, or nil if there aren't any.
+	diskTop []byte
+}
+
+func newRefQueue(refs ...types.Ref) *refQueue {
+	q := &refQueue{inMem: hash.HashSet{}, sorted: true}
+	for _, r := range refs {
+		q.PushBack(r)
+	}
+	return q
+}
+
+// refQueueKey returns the key under which r is kept on disk. LevelDB orders these keys as types.RefByHeight orders Refs: by increasing height, and then by decreasing target hash.
+func refQueueKey(r types.Ref) []byte {
+	key := make([]byte, uint64Size+hash.ByteLen)
+	binary.BigEndian.PutUint64(key, r.Height())
+	digest := r.TargetHash().Digest()
+	for i, b := range digest {
+		key[uint64Size+i] = ^b
+	}
+	return key
+}
+
+func (q *refQueue) Len() int {
+	return len(q.mem) + q.diskLen
+}
+
+func (q *refQueue) Empty() bool {
+	return q.Len() == 0
+}
+
+// PushBack adds r to q, unless q already holds a Ref to the same target.
+func (q *refQueue) PushBack(r types.Ref) {
+	target := r.TargetHash()
+	if q.inMem.Has(target) {
+		return
+	}
+	if q.diskLen > 0 {
+		onDisk, err := q.disk.Has(refQueueKey(r), nil)
+		d.PanicIfError(err)
+		if onDisk {
+			return
+		}
+	}
+	q.mem = append(q.mem, r)
+	q.inMem.Insert(target)
+	q.sorted = false
+	if len(q.mem) > pullSpillThreshold {
+		q.spill()
+	}
+}
+
+// PeekEnd returns, but doesn't remove, the tallest Ref in q. If q is empty, it returns the empty Ref.
+func (q *refQueue) PeekEnd() types.Ref {
+	q.settle()
+	return q.mem.PeekEnd()
+}
+
+// PopBack removes and returns the tallest Ref in q.
+func (q *refQueue) PopBack() types.Ref {
+	q.settle()
+	r := q.mem.PopBack()
+	q.inMem.Remove(r.TargetHash())
+	return r
+}
+
+// IterAll calls cb with every Ref in q, in no particular order.
+func (q *refQueue) IterAll(cb func(r types.Ref)) {
+	for _, r := range q.mem {
+		cb(r)
+	}
+	if q.diskLen == 0 {
+		return
+	}
+	iter := q.disk.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		cb(decodeSpilledRef(iter.Value()))
+	}
+	d.PanicIfError(iter.Error())
+}
+
+// Destroy removes anything q has moved to disk. q can't be used afterwards.
+func (q *refQueue) Destroy() {
+	destroySpillDB(q.disk, q.diskDir)
+	q.disk = nil
+}
+
+// settle makes the end of q.mem the tallest Ref in q, by sorting it and, if need be, bringing back Refs from disk.
+func (q *refQueue) settle() {
+	if !q.sorted {
+		sort.Sort(q.mem)
+		q.sorted = true
+	}
+	if q.diskTop == nil || (!q.mem.Empty() && bytes.Compare(q.diskTop, refQueueKey(q.mem.PeekEnd())) < 0) {
+		return
+	}
+	q.unspill()
+}
+
+// spill moves the shortest half of the Refs in memory to disk.
+func (q *refQueue) spill() {
+	if q.disk == nil {
+		q.disk, q.diskDir = openSpillDB()
+	}
+	sort.Sort(q.mem)
+	n := len(q.mem) / 2
+	batch := &leveldb.Batch{}
+	for _, r := range q.mem[:n] {
+		batch.Put(refQueueKey(r), types.EncodeValue(r, nil).Data())
+		q.inMem.Remove(r.TargetHash())
+	}
+	d.PanicIfError(q.disk.Write(batch, nil))
+	q.diskLen += n
+	if top := refQueueKey(q.mem[n-1]); bytes.Compare(top, q.diskTop) > 0 {
+		q.diskTop = top
+	}
+	// Copy what's left, so that the memory holding the spilled Refs can be reclaimed.
+	q.mem = append(make(types.RefByHeight, 0, len(q.mem)-n), q.mem[n:]...)
+	q.sorted = true
+}
+
+// unspill moves up to half of pullSpillThreshold of the tallest Refs on disk back into memory.
+func (q *refQueue) unspill() {
+	limit := pullSpillThreshold / 2
+	if limit == 0 {
+		limit = 1
+	}
+	iter := q.disk.NewIterator(nil, nil)
+	defer iter.Release()
+	batch := &leveldb.Batch{}
+	n := 0
+	q.diskTop = nil
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		if n == limit {
+			q.diskTop = append([]byte{}, iter.Key()...)
+			break
+		}
+		r := decodeSpilledRef(iter.Value())
+		batch.Delete(iter.Key())
+		q.mem = append(q.mem, r)
+		q.inMem.Insert(r.TargetHash())
+		n++
+	}
+	d.PanicIfError(iter.Error())
+	d.PanicIfError(q.disk.Write(batch, nil))
+	q.diskLen -= n
+	sort.Sort(q.mem)
+}
+
+func decodeSpilledRef(data []byte) types.Ref {
+	return types.DecodeValue(chunks.NewChunk(append([]byte{}, data...)), nil).(types.Ref)
+}
+
+// hashMap maps hashes to hashes. It's an ordinary map until it holds more than pullSpillThreshold entries, at which point all of them are moved to disk, where any more are added as well. A hashMap whose values are all the empty hash serves as a set.
+type hashMap struct {
+	mem     map[hash.Hash]hash.Hash
+	disk    *leveldb.DB
+	diskDir string
+	diskLen int
+}
+
+func newHashMap() *hashMap {
+	return &hashMap{mem: map[hash.Hash]hash.Hash{}}
+}
+
+func (m *hashMap) Len() int {
+	return len(m.mem) + m.diskLen
+}
+
+func (m *hashMap) Set(k, v hash.Hash) {
+	if m.disk == nil {
+		m.mem[k] = v
+		if len(m.mem) > pullSpillThreshold {
+			m.spill()
+		}
+		return
+	}
+	if !m.Has(k) {
+		m.diskLen++
+	}
+	d.PanicIfError(m.disk.Put(k.DigestSlice(), v.DigestSlice(), nil))
+}
+
+func (m *hashMap) Get(k hash.Hash) (v hash.Hash, ok bool) {
+	if m.disk == nil {
+		v, ok = m.mem[k]
+		return
+	}
+	data, err := m.disk.Get(k.DigestSlice(), nil)
+	if err == leveldb.ErrNotFound {
+		return
+	}
+	d.PanicIfError(err)
+	return hash.FromSlice(data), true
+}
+
+func (m *hashMap) Has(k hash.Hash) bool {
+	_, ok := m.Get(k)
+	return ok
+}
+
+func (m *hashMap) Remove(k hash.Hash) {
+	if m.disk == nil {
+		delete(m.mem, k)
+		return
+	}
+	if m.Has(k) {
+		d.PanicIfError(m.disk.Delete(k.DigestSlice(), nil))
+		m.diskLen--
+	}
+}
+
+// IterAll calls cb with every entry in m, in no particular order.
+func (m *hashMap) IterAll(cb func(k, v hash.Hash)) {
+	if m.disk == nil {
+		for k, v := range m.mem {
+			cb(k, v)
+		}
+		return
+	}
+	iter := m.disk.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		cb(hash.FromSlice(iter.Key()), hash.FromSlice(iter.Value()))
+	}
+	d.PanicIfError(iter.Error())
+}
+
+// Destroy removes anything m has moved to disk. m can't be used afterwards.
+func (m *hashMap) Destroy() {
+	destroySpillDB(m.disk, m.diskDir)
+	m.disk = nil
+}
+
+func (m *hashMap) spill() {
+	m.disk, m.diskDir = openSpillDB()
+	batch := &leveldb.Batch{}
+	for k, v := range m.mem {
+		batch.Put(k.DigestSlice(), v.DigestSlice())
+	}
+	d.PanicIfError(m.disk.Write(batch, nil))
+	m.diskLen = len(m.mem)
+	m.mem = map[hash.Hash]hash.Hash{}
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+// withSpillThreshold runs f with pullSpillThreshold set to n.
+func withSpillThreshold(n int, f func()) {
+	old := pullSpillThreshold
+	pullSpillThreshold = n
+	defer func() { pullSpillThreshold = old }()
+	f()
+}
+
+// refsOfHeights returns a Ref of each of the heights from 1 to maxHeight for every one of n unique values.
+func refsOfHeights(n int, maxHeight int) types.RefByHeight {
+	refs := types.RefByHeight{}
+	for i := 0; i < n; i++ {
+		v := types.Value(types.Number(i))
+		for h := 0; h < maxHeight; h++ {
+			r := types.NewRef(v)
+			refs = append(refs, r)
+			v = types.NewList(r)
+		}
+	}
+	return refs
+}
+
+func TestRefQueueSpills(t *testing.T) {
+	assert := assert.New(t)
+	withSpillThreshold(4, func() {
+		refs := refsOfHeights(10, 3)
+		q := newRefQueue()
+		for _, r := range refs {
+			q.PushBack(r)
+		}
+		// Pushing Refs again, whether they're on disk or not, doesn't add them twice.
+		for _, r := range refs {
+			q.PushBack(r)
+		}
+		assert.Equal(len(refs), q.Len())
+		assert.NotNil(q.disk)
+		dir := q.diskDir
+
+		seen := 0
+		q.IterAll(func(r types.Ref) { seen++ })
+		assert.Equal(len(refs), seen)
+
+		sort.Sort(refs)
+		for i := len(refs) - 1; i >= 0; i-- {
+			assert.True(refs[i].Equals(q.PeekEnd()))
+			assert.True(refs[i].Equals(q.PopBack()))
+		}
+		assert.True(q.Empty())
+		assert.Equal(types.Ref{}, q.PeekEnd())
+
+		q.Destroy()
+		_, err := os.Stat(dir)
+		assert.True(os.IsNotExist(err))
+	})
+}
+
+func TestRefQueueInterleaved(t *testing.T) {
+	assert := assert.New(t)
+	withSpillThreshold(2, func() {
+		refs := refsOfHeights(8, 4)
+		sort.Sort(refs)
+		q := newRefQueue()
+		defer q.Destroy()
+
+		// Push the tallest Refs first, and each time one is popped, push some shorter ones, the way pull does.
+		next := len(refs) - 1
+		for i := 0; i < 4; i++ {
+			q.PushBack(refs[next])
+			next--
+		}
+		for !q.Empty() {
+			r := q.PopBack()
+			for _, shorter := range refs[:next+1] {
+				assert.True(types.HeightOrder(r, shorter))
+			}
+			for i := 0; i < 3 && next >= 0; i++ {
+				q.PushBack(refs[next])
+				next--
+			}
+		}
+		assert.Equal(-1, next)
+	})
+}
+
+func TestHashMapSpills(t *testing.T) {
+	assert := assert.New(t)
+	withSpillThreshold(4, func() {
+		m := newHashMap()
+		keys := hash.HashSlice{}
+		for i := 0; i < 10; i++ {
+			k := types.Number(i).Hash()
+			keys = append(keys, k)
+			m.Set(k, types.String("first").Hash())
+		}
+		assert.NotNil(m.disk)
+		dir := m.diskDir
+
+		m.Set(keys[0], types.String("second").Hash())
+		m.Remove(keys[1])
+		m.Remove(types.Number(100).Hash())
+		assert.Equal(9, m.Len())
+
+		v, ok := m.Get(keys[0])
+		assert.True(ok)
+		assert.Equal(types.String("second").Hash(), v)
+		assert.False(m.Has(keys[1]))
+		assert.True(m.Has(keys[2]))
+
+		seen := map[hash.Hash]hash.Hash{}
+		m.IterAll(func(k, v hash.Hash) { seen[k] = v })
+		assert.Len(seen, 9)
+		assert.Equal(types.String("first").Hash(), seen[keys[9]])
+
+		m.Destroy()
+		_, err := os.Stat(dir)
+		assert.True(os.IsNotExist(err))
+	})
+}
+
+func TestSpillingLocalToLocalPulls(t *testing.T) {
+	suite.Run(t, &SpillingLocalToLocalSuite{})
+}
+
+func TestSpillingLegacyRemoteToLocalPulls(t *testing.T) {
+	suite.Run(t, &SpillingLegacyRemoteToLocalSuite{})
+}
+
+// SpillingLocalToLocalSuite pulls as LocalToLocalSuite does, but with a pullSpillThreshold small enough that nearly everything pull keeps track of is moved to disk, and that it takes one Ref at a time off of its queues.
+type SpillingLocalToLocalSuite struct {
+	LocalToLocalSuite
+	oldThreshold int
+}
+
+func (suite *SpillingLocalToLocalSuite) SetupTest() {
+	suite.oldThreshold, pullSpillThreshold = pullSpillThreshold, 1
+	suite.LocalToLocalSuite.SetupTest()
+}
+
+func (suite *SpillingLocalToLocalSuite) TearDownTest() {
+	pullSpillThreshold = suite.oldThreshold
+	suite.LocalToLocalSuite.TearDownTest()
+}
+
+type SpillingLegacyRemoteToLocalSuite struct {
+	LegacyRemoteToLocalSuite
+	oldThreshold int
+}
+
+func (suite *SpillingLegacyRemoteToLocalSuite) SetupTest() {
+	suite.oldThreshold, pullSpillThreshold = pullSpillThreshold, 1
+	suite.LegacyRemoteToLocalSuite.SetupTest()
+}
+
+func (suite *SpillingLegacyRemoteToLocalSuite) TearDownTest() {
+	pullSpillThreshold = suite.oldThreshold
+	suite.LegacyRemoteToLocalSuite.TearDownTest()
+}
+
+func TestResumePullSpills(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	withSpillThreshold(1, func() {
+		source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(chunks.NewTestStore())
+		defer source.Close()
+		defer sink.Close()
+		source, sourceRef := commitWideList(source, dsID, 20)
+
+		// Cancel part way through the walk, so that state which has been moved to disk is saved and read back.
+		cancel := make(chan struct{})
+		progressCh := make(chan PullProgress)
+		go func() {
+			canceled := false
+			for p := range progressCh {
+				if p.DoneCount >= 10 && !canceled {
+					close(cancel)
+					canceled = true
+				}
+			}
+		}()
+		err := ResumePull(source, sink, sourceRef, types.Ref{}, 0, 1, progressCh, dir, cancel)
+		close(progressCh)
+		assert.Equal(ErrPullCanceled, err)
+
+		assert.NoError(ResumePull(source, sink, sourceRef, types.Ref{}, 0, 1, nil, dir, nil))
+		assert.True(sourceRef.TargetValue(source).Equals(sourceRef.TargetValue(sink)))
+	})
+}
@@ -0,0 +1,263 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+/*
+  Pull State Serialization:
+    SourceHash   // 20-byte hash of the sourceRef target
+    SinkHeadHash // 20-byte hash of the sinkHeadRef target, all zeroes if there is none
+    Depth        // 8-byte uint64
+    Complete     // 1 byte, 1 if every Chunk has been copied into the spool
+    Progress     // 3 8-byte uint64s: DoneCount, KnownCount and DoneBytes
+    Exclude      // as serialized by serializeHashes
+    Shallow      // Refs
+    if Complete:
+      Hints      // as serialized by serializeHints
+    else:
+      SrcQ       // Refs
+      SinkQ      // Refs
+      HintCache  // 4-byte uint32 count, followed by that many pairs of 20-byte hashes
+      Reachable  // as serialized by serializeHashes
+
+  Refs:
+    4-byte uint32 count, followed by that many Refs, each encoded by types.EncodeValue and serialized by chunks.Serialize
+*/
+
+const (
+	pullStateFile  = "state"
+	pullSpoolDir   = "chunks"
+	pullStateMagic = uint32(0x6e6d7032) // "nmp2"
+
+	// pullCheckpointInterval is how often ResumePull saves its state while walking.
+	pullCheckpointInterval = 5 * time.Second
+)
+
+// spoolSink collects the Chunks that pull copies in an orderedChunkCache that survives the process exiting, so that nothing is handed to db until all of them have been copied.
+type spoolSink struct {
+	db    Database
+	spool *orderedChunkCache
+	hints types.Hints
+}
+
+// has doesn't consult the spool, because a Chunk being there doesn't mean that the Chunks it refers to are. Those are found, without reading the source again, by way of get.
+func (s *spoolSink) has(h hash.Hash) bool {
+	return s.db.has(h)
+}
+
+func (s *spoolSink) get(h hash.Hash) chunks.Chunk {
+	return s.spool.Get(h)
+}
+
+func (s *spoolSink) schedulePut(c chunks.Chunk, height uint64) {
+	s.spool.Insert(c, height)
+}
+
+func (s *spoolSink) addHints(hints types.Hints) {
+	s.hints = hints
+}
+
+// ResumePull pulls as PullWithDepth does, but keeps track of its progress in dir, so that if it's canceled, or the process exits, calling it again with the same dir, sourceRef, sinkHeadRef and depth carries on from where it stopped. Copied Chunks are kept in dir until all of them have been, and only then handed to sinkDB, so that sinkDB never holds part of the graph under sourceRef.
+// If cancel is closed before everything has been copied, ResumePull saves its state and returns ErrPullCanceled. Otherwise, dir is removed once sinkDB has everything. If dir holds the state of a pull of some other sourceRef, sinkHeadRef or depth, that state is discarded.
+func ResumePull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, depth uint64, concurrency int, progressCh chan PullProgress, dir string, cancel <-chan struct{}) error {
+	d.PanicIfError(os.MkdirAll(dir, 0777))
+	srcShallow := srcDB.ShallowCommits()
+	state, hints, complete, ok := readPullState(dir, sourceRef, sinkHeadRef, depth)
+	if !ok {
+		d.PanicIfError(os.RemoveAll(filepath.Join(dir, pullSpoolDir)))
+		state = newShallowPullState(srcDB, sinkDB, sinkDB.ShallowCommits(), srcShallow, sourceRef, sinkHeadRef, depth)
+		if err := checkShallowSink(sinkDB, state.shallow); err != nil {
+			state.destroy()
+			return err
+		}
+		// Written right away, so that if this pull is interrupted the spool is known to belong to it.
+		writePullState(dir, state, depth, nil)
+	}
+	defer state.destroy()
+	sink := &spoolSink{db: sinkDB, spool: openOrderedChunkCache(filepath.Join(dir, pullSpoolDir), false), hints: hints}
+
+	if !complete {
+		// Only a fresh pull is streamed, as the server can't know what's already in the spool, and only from a srcDB that isn't shallow, as PullWithDepth explains. If streaming is interrupted, the walk below finds what was received in the spool.
+		streamed := !ok && state.streamable() && srcShallow.Empty() && pullNegotiated(srcDB, sinkDB, sink, sourceRef, sinkHeadRef, progressCh, cancel)
+		if !streamed || sink.hints == nil {
+			last := time.Now()
+			checkpoint := func(state *pullState) {
+				if time.Since(last) >= pullCheckpointInterval {
+					writePullState(dir, state, depth, nil)
+					last = time.Now()
+				}
+			}
+			if streamed || !pull(srcDB, mostLocalDatabase(srcDB, sinkDB), sink, state, concurrency, progressCh, cancel, checkpoint) {
+				writePullState(dir, state, depth, nil)
+				d.PanicIfError(sink.spool.Close())
+				return ErrPullCanceled
+			}
+		}
+		writePullState(dir, state, depth, sink.hints)
+	}
+
+	// Handing the spool to sinkDB isn't interruptible, but it's safe to redo if the process exits part way through.
+	bs := sinkDB.validatingBatchStore()
+	sink.spool.IterAll(func(c chunks.Chunk, height uint64) {
+		bs.SchedulePut(c, height, types.Hints{})
+	})
+	bs.AddHints(sink.hints)
+	// Recording the shallow Commits flushes bs, so it has to come first if there are any, in order for them to be allowed to have missing parents.
+	if !state.shallow.Equals(sinkDB.ShallowCommits()) {
+		if err := sinkDB.setShallowCommits(state.shallow); err != nil {
+			return err
+		}
+	}
+	bs.Flush()
+	d.PanicIfError(sink.spool.Destroy())
+	return os.RemoveAll(dir)
+}
+
+// readPullState reads the state saved in dir by writePullState. ok is false if there isn't any, or it's for a different pull.
+func readPullState(dir string, sourceRef, sinkHeadRef types.Ref, depth uint64) (state *pullState, hints types.Hints, complete, ok bool) {
+	f, err := os.Open(filepath.Join(dir, pullStateFile))
+	if os.IsNotExist(err) {
+		return
+	}
+	d.PanicIfError(err)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var magic uint32
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &magic))
+	if magic != pullStateMagic || deserializeHash(r) != sourceRef.TargetHash() || deserializeHash(r) != sinkHeadRef.TargetHash() {
+		return
+	}
+	var savedDepth uint64
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &savedDepth))
+	if savedDepth != depth {
+		return
+	}
+
+	var completeByte byte
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &completeByte))
+	state = &pullState{sourceRef: sourceRef, sinkHeadRef: sinkHeadRef, exclude: hash.HashSet{}}
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &state.progress))
+	for _, h := range deserializeHashes(r) {
+		state.exclude.Insert(h)
+	}
+	shallow := []types.Value{}
+	deserializeRefs(r, func(ref types.Ref) {
+		shallow = append(shallow, ref)
+	})
+	state.shallow = types.NewSet(shallow...)
+	if completeByte == 1 {
+		return state, deserializeHints(r), true, true
+	}
+
+	state.srcQ, state.sinkQ = newRefQueue(), newRefQueue()
+	deserializeRefs(r, state.srcQ.PushBack)
+	deserializeRefs(r, state.sinkQ.PushBack)
+	state.hc, state.reachableChunks = deserializeHashMap(r), newHashMap()
+	var numReachable uint32
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &numReachable))
+	for i := uint32(0); i < numReachable; i++ {
+		state.reachableChunks.Set(deserializeHash(r), hash.Hash{})
+	}
+	return state, nil, false, true
+}
+
+// writePullState replaces the state saved in dir. If hints is non-nil, every Chunk has been copied and hints are what they need to be validated.
+func writePullState(dir string, state *pullState, depth uint64, hints types.Hints) {
+	// Write to a temporary file and rename it, so that the saved state is never half written.
+	tmp := filepath.Join(dir, pullStateFile+".tmp")
+	f, err := os.Create(tmp)
+	d.PanicIfError(err)
+	w := bufio.NewWriter(f)
+	d.PanicIfError(binary.Write(w, binary.BigEndian, pullStateMagic))
+	serializeHash(w, state.sourceRef.TargetHash())
+	serializeHash(w, state.sinkHeadRef.TargetHash())
+	d.PanicIfError(binary.Write(w, binary.BigEndian, depth))
+	complete := byte(0)
+	if hints != nil {
+		complete = 1
+	}
+	d.PanicIfError(binary.Write(w, binary.BigEndian, complete))
+	d.PanicIfError(binary.Write(w, binary.BigEndian, state.progress))
+	exclude := make(hash.HashSlice, 0, len(state.exclude))
+	for h := range state.exclude {
+		exclude = append(exclude, h)
+	}
+	serializeHashes(w, exclude)
+	shallow := make([]types.Ref, 0, state.shallow.Len())
+	state.shallow.IterAll(func(v types.Value) {
+		shallow = append(shallow, v.(types.Ref))
+	})
+	serializeRefs(w, uint32(len(shallow)), func(cb func(types.Ref)) {
+		for _, r := range shallow {
+			cb(r)
+		}
+	})
+	if hints != nil {
+		serializeHints(w, hints)
+	} else {
+		serializeRefs(w, uint32(state.srcQ.Len()), state.srcQ.IterAll)
+		serializeRefs(w, uint32(state.sinkQ.Len()), state.sinkQ.IterAll)
+		serializeHashMap(w, state.hc)
+		d.PanicIfError(binary.Write(w, binary.BigEndian, uint32(state.reachableChunks.Len())))
+		state.reachableChunks.IterAll(func(h, _ hash.Hash) {
+			serializeHash(w, h)
+		})
+	}
+	d.PanicIfError(w.Flush())
+	d.PanicIfError(f.Close())
+	d.PanicIfError(os.Rename(tmp, filepath.Join(dir, pullStateFile)))
+}
+
+// serializeRefs writes count, followed by each of the Refs that iter calls its callback with, of which there must be count.
+func serializeRefs(w io.Writer, count uint32, iter func(cb func(types.Ref))) {
+	d.PanicIfError(binary.Write(w, binary.BigEndian, count))
+	iter(func(r types.Ref) {
+		chunks.Serialize(types.EncodeValue(r, nil), w)
+	})
+}
+
+// deserializeRefs reads Refs written by serializeRefs, calling cb with each one.
+func deserializeRefs(r io.Reader, cb func(types.Ref)) {
+	var numRefs uint32
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &numRefs))
+	for i := uint32(0); i < numRefs; i++ {
+		c, ok := chunks.DeserializeOne(r)
+		d.Chk.True(ok, "Unexpected end of pull state")
+		cb(types.DecodeValue(c, nil).(types.Ref))
+	}
+}
+
+func serializeHashMap(w io.Writer, m *hashMap) {
+	d.PanicIfError(binary.Write(w, binary.BigEndian, uint32(m.Len())))
+	m.IterAll(func(k, v hash.Hash) {
+		serializeHash(w, k)
+		serializeHash(w, v)
+	})
+}
+
+func deserializeHashMap(r io.Reader) *hashMap {
+	m := newHashMap()
+	var numEntries uint32
+	d.PanicIfError(binary.Read(r, binary.BigEndian, &numEntries))
+	for i := uint32(0); i < numEntries; i++ {
+		k := deserializeHash(r)
+		m.Set(k, deserializeHash(r))
+	}
+	return m
+}
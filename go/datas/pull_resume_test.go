@@ -0,0 +1,103 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// commitWideList commits a List of n Refs, each to a List of height 2, to datasetID in db, and returns the Ref of the new head.
+func commitWideList(db Database, datasetID string, n int) (Database, types.Ref) {
+	refs := []types.Value{}
+	for i := 0; i < n; i++ {
+		l := buildListOfHeight(2, db)
+		refs = append(refs, db.WriteValue(l.Append(types.Number(i))))
+	}
+	db, err := db.Commit(datasetID, NewCommit(types.NewList(refs...), types.NewSet(), types.EmptyStruct))
+	if err != nil {
+		panic(err)
+	}
+	return db, db.HeadRef(datasetID)
+}
+
+func TestResumePullCanceledThenResumed(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sourceCS, sinkCS := chunks.NewTestStore(), chunks.NewTestStore()
+	source, sink := NewDatabase(sourceCS), NewDatabase(sinkCS)
+	defer source.Close()
+	defer sink.Close()
+	source, sourceRef := commitWideList(source, dsID, 20)
+	stateDir := filepath.Join(dir, "pull")
+
+	// Cancel part way through the walk.
+	cancel := make(chan struct{})
+	progressCh := make(chan PullProgress)
+	go func() {
+		canceled := false
+		for p := range progressCh {
+			if p.DoneCount >= 10 && !canceled {
+				close(cancel)
+				canceled = true
+			}
+		}
+	}()
+	setupReads := sourceCS.Reads
+	err = ResumePull(source, sink, sourceRef, types.Ref{}, 0, 1, progressCh, stateDir, cancel)
+	close(progressCh)
+	assert.Equal(ErrPullCanceled, err)
+	assert.Nil(sink.ReadValue(sourceRef.TargetHash()))
+	assert.Equal(0, sinkCS.Writes)
+	_, err = os.Stat(filepath.Join(stateDir, pullStateFile))
+	assert.NoError(err)
+	firstReads := sourceCS.Reads - setupReads
+	assert.True(firstReads > 0)
+
+	// Resuming reads only what the first attempt didn't from source.
+	assert.NoError(ResumePull(source, sink, sourceRef, types.Ref{}, 0, 2, nil, stateDir, nil))
+	resumedReads := sourceCS.Reads - setupReads - firstReads
+	assert.True(sourceRef.TargetValue(sink).Equals(sourceRef.TargetValue(source)))
+	_, err = os.Stat(stateDir)
+	assert.True(os.IsNotExist(err))
+
+	fullCS := chunks.NewTestStore()
+	full := NewDatabase(fullCS)
+	defer full.Close()
+	preReads := sourceCS.Reads
+	assert.NoError(ResumePull(source, full, sourceRef, types.Ref{}, 0, 2, nil, filepath.Join(dir, "full"), nil))
+	assert.Equal(fullCS.Writes, sinkCS.Writes)
+	assert.Equal(sourceCS.Reads-preReads, firstReads+resumedReads)
+}
+
+func TestResumePullDiscardsOtherState(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(chunks.NewTestStore())
+	defer source.Close()
+	defer sink.Close()
+	source, firstRef := commitWideList(source, "first", 2)
+	source, secondRef := commitWideList(source, "second", 3)
+
+	cancel := make(chan struct{})
+	close(cancel)
+	assert.Equal(ErrPullCanceled, ResumePull(source, sink, firstRef, types.Ref{}, 0, 1, nil, dir, cancel))
+
+	assert.NoError(ResumePull(source, sink, secondRef, types.Ref{}, 0, 1, nil, dir, nil))
+	assert.True(secondRef.TargetValue(sink).Equals(secondRef.TargetValue(source)))
+	assert.Nil(sink.ReadValue(firstRef.TargetHash()))
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// PullWithDepth is like Pull, but if depth is greater than zero, it copies only the depth most recent Commits in the history of sourceRef, along with their Values. Older parents are left out, and the Commits that refer to them are recorded in sinkDB's ShallowCommits, so that the history can be deepened later. If sinkHeadRef is in srcDB, every Commit that might lead back to it is copied whatever depth is, so that it can be fast-forwarded to sourceRef.
+// A depth of zero copies the complete history, which includes deepening it if sinkDB is shallow. Either way, if srcDB is itself shallow, history stops where it does in srcDB, and its shallow Commits become shallow in sinkDB.
+// Only a LocalDatabase whose ChunkStore is a chunks.ShallowTracker can be made shallow. If that's what would be needed and sinkDB can't be, ErrShallowRemoteSink or ErrShallowUnsupported is returned before anything is copied.
+func PullWithDepth(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, depth uint64, concurrency int, progressCh chan PullProgress) error {
+	oldShallow, srcShallow := sinkDB.ShallowCommits(), srcDB.ShallowCommits()
+	state := newShallowPullState(srcDB, sinkDB, oldShallow, srcShallow, sourceRef, sinkHeadRef, depth)
+	defer state.destroy()
+	if err := checkShallowSink(sinkDB, state.shallow); err != nil {
+		return err
+	}
+	// A server holding a shallow Database can't stream a pull, as it would have to know where sinkDB's history stops as well as its own.
+	if !state.streamable() || !srcShallow.Empty() || !pullNegotiated(srcDB, sinkDB, databaseSink{sinkDB}, sourceRef, sinkHeadRef, progressCh, nil) {
+		pull(srcDB, mostLocalDatabase(srcDB, sinkDB), databaseSink{sinkDB}, state, concurrency, progressCh, nil, nil)
+	}
+	if state.shallow.Equals(oldShallow) {
+		return nil
+	}
+	return sinkDB.setShallowCommits(state.shallow)
+}
+
+// checkShallowSink returns the error that recording shallow as sinkDB's ShallowCommits would, so that a pull can fail before it copies anything.
+func checkShallowSink(sinkDB Database, shallow types.Set) error {
+	if shallow.Empty() {
+		return nil
+	}
+	lds, ok := sinkDB.(*LocalDatabase)
+	if !ok {
+		return ErrShallowRemoteSink
+	}
+	if lds.st == nil {
+		return ErrShallowUnsupported
+	}
+	return nil
+}
+
+// newShallowPullState returns the state of a pull of sourceRef into sinkDB limited to depth, as described by PullWithDepth. oldShallow is sinkDB's ShallowCommits, and srcShallow is srcDB's.
+func newShallowPullState(srcDB, sinkDB Database, oldShallow, srcShallow types.Set, sourceRef, sinkHeadRef types.Ref, depth uint64) *pullState {
+	state := newPullState(srcDB, sourceRef, sinkHeadRef)
+	if depth > 0 || !oldShallow.Empty() || !srcShallow.Empty() {
+		var roots types.RefSlice
+		state.exclude, roots, state.shallow = planShallowPull(srcDB, sinkDB, oldShallow, srcShallow, sourceRef, sinkHeadRef, depth)
+		for _, r := range roots {
+			state.srcQ.PushBack(r)
+		}
+	}
+	return state
+}
+
+// planShallowPull works out which Commits a pull of sourceRef limited to depth leaves out, by walking the history of sourceRef breadth first. It returns the hashes of the parents that are too far back to be copied, or that can't be because they're beyond the history of srcDB, the Refs of parents that are missing from sinkDB but now need copying because the Commits that refer to them were in oldShallow, and what sinkDB's ShallowCommits will be once the pull is done.
+func planShallowPull(srcDB, sinkDB Database, oldShallow, srcShallow types.Set, sourceRef, sinkHeadRef types.Ref, depth uint64) (exclude hash.HashSet, roots types.RefSlice, shallow types.Set) {
+	isSrcShallow := hash.HashSet{}
+	srcShallow.IterAll(func(v types.Value) {
+		isSrcShallow.Insert(v.(types.Ref).TargetHash())
+	})
+	isShallow := hash.HashSet{}
+	// A Commit can only lead back to a shallow one if it's taller, so the walk needn't look at any Commit that sinkDB has, and isn't shallow, below that.
+	shallowHeight := uint64(0)
+	oldShallow.IterAll(func(v types.Value) {
+		r := v.(types.Ref)
+		isShallow.Insert(r.TargetHash())
+		if shallowHeight == 0 || r.Height() < shallowHeight {
+			shallowHeight = r.Height()
+		}
+	})
+	// Likewise, only Commits taller than sinkHeadRef can lead back to it.
+	headHeight := uint64(0)
+	if srcDB.has(sinkHeadRef.TargetHash()) {
+		headHeight = sinkHeadRef.Height()
+	}
+
+	// sinkDB.has may remember a Chunk as missing from before it was written, so look for Chunks directly.
+	sinkBS := sinkDB.validatingBatchStore()
+	inSinkDB := func(h hash.Hash) bool {
+		return !sinkBS.Get(h).IsEmpty()
+	}
+
+	exclude = hash.HashSet{}
+	missing := hash.HashSet{}
+	kept := hash.HashSet{}
+	walked := map[hash.Hash]types.Ref{}
+	parentsOf := map[hash.Hash]types.RefSlice{}
+	level := types.RefSlice{sourceRef}
+	kept.Insert(sourceRef.TargetHash())
+	for n := uint64(1); len(level) > 0; n++ {
+		next := types.RefSlice{}
+		for _, r := range level {
+			h := r.TargetHash()
+			if _, ok := walked[h]; ok {
+				continue
+			}
+			inSink := inSinkDB(h)
+			if inSink && !isShallow.Has(h) && (shallowHeight == 0 || r.Height() <= shallowHeight) {
+				continue
+			}
+			walked[h] = r
+
+			db := srcDB
+			if inSink {
+				db = sinkDB
+			}
+			commit := r.TargetValue(db).(types.Struct)
+			commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+				p := v.(types.Ref)
+				parentsOf[h] = append(parentsOf[h], p)
+				pInSink := inSinkDB(p.TargetHash())
+				if !inSink && !pInSink && isSrcShallow.Has(h) && !srcDB.has(p.TargetHash()) {
+					missing.Insert(p.TargetHash())
+				} else if depth == 0 || n < depth || (headHeight > 0 && p.Height() > headHeight) {
+					kept.Insert(p.TargetHash())
+					next = append(next, p)
+					if inSink && !pInSink {
+						roots = append(roots, p)
+					}
+				} else if !pInSink {
+					exclude.Insert(p.TargetHash())
+				}
+			})
+		}
+		level = next
+	}
+	for h := range kept {
+		exclude.Remove(h)
+	}
+	for h := range missing {
+		exclude.Insert(h)
+	}
+
+	// Shallow Commits that weren't walked stay that way. Those that were, along with the rest of the walked Commits, are shallow if they have an excluded parent.
+	markers := []types.Value{}
+	oldShallow.IterAll(func(v types.Value) {
+		if _, ok := walked[v.(types.Ref).TargetHash()]; !ok {
+			markers = append(markers, v)
+		}
+	})
+	for h, r := range walked {
+		for _, p := range parentsOf[h] {
+			if exclude.Has(p.TargetHash()) {
+				markers = append(markers, r)
+				break
+			}
+		}
+	}
+	return exclude, roots, types.NewSet(markers...)
+}
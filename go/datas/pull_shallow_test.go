@@ -0,0 +1,209 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// commitChain commits n Commits to dsID in db, each the parent of the next and holding a List of a Ref to a String unique to it, and returns their Refs, oldest first.
+func commitChain(db Database, n int) (Database, []types.Ref) {
+	refs := []types.Ref{}
+	parents := types.NewSet()
+	for i := 0; i < n; i++ {
+		v := types.NewList(db.WriteValue(types.String(fmt.Sprintf("value %d", i))))
+		var err error
+		db, err = db.Commit(dsID, NewCommit(v, parents, types.EmptyStruct))
+		if err != nil {
+			panic(err)
+		}
+		r := db.HeadRef(dsID)
+		refs = append(refs, r)
+		parents = types.NewSet(r)
+	}
+	return db, refs
+}
+
+func TestPullWithDepth(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS := chunks.NewTestStore()
+	source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(sinkCS)
+	defer source.Close()
+	source, refs := commitChain(source, 5)
+	head := refs[4]
+
+	assert.NoError(PullWithDepth(source, sink, head, types.Ref{}, 2, 1, nil))
+	sink, err := sink.Commit(dsID, head.TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+	assert.True(sinkCS.Has(refs[3].TargetHash()))
+	assert.True(sinkCS.Has(types.String("value 3").Hash()))
+	assert.False(sinkCS.Has(refs[2].TargetHash()))
+	assert.False(sinkCS.Has(types.String("value 2").Hash()))
+	assert.True(types.NewSet(refs[3]).Equals(sink.ShallowCommits()))
+	assert.Equal(uint64(1), sink.Datasets().Len())
+
+	// Walking the history stops where it does, rather than failing.
+	assert.False(descendsFrom(head.TargetValue(sink).(types.Struct), refs[0], sink))
+	sink.Close()
+
+	// Pulling without a depth fills in the rest.
+	sink = NewDatabase(sinkCS)
+	assert.NoError(PullWithDepth(source, sink, head, head, 0, 1, nil))
+	sink.Close()
+	sink = NewDatabase(sinkCS)
+	defer sink.Close()
+	for _, r := range refs {
+		assert.True(r.TargetValue(source).Equals(r.TargetValue(sink)))
+	}
+	assert.True(sink.ShallowCommits().Empty())
+	assert.True(head.Equals(sink.HeadRef(dsID)))
+}
+
+func TestPullWithDepthDeepens(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS := chunks.NewTestStore()
+	source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(sinkCS)
+	defer source.Close()
+	defer sink.Close()
+	source, refs := commitChain(source, 5)
+	head := refs[4]
+
+	assert.NoError(PullWithDepth(source, sink, head, types.Ref{}, 1, 1, nil))
+	assert.True(types.NewSet(head).Equals(sink.ShallowCommits()))
+
+	assert.NoError(PullWithDepth(source, sink, head, types.Ref{}, 3, 1, nil))
+	assert.True(types.NewSet(refs[2]).Equals(sink.ShallowCommits()))
+	assert.True(sinkCS.Has(refs[2].TargetHash()))
+	assert.False(sinkCS.Has(refs[1].TargetHash()))
+}
+
+func TestPullWithDepthFastForwards(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS := chunks.NewTestStore()
+	source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(sinkCS)
+	defer source.Close()
+	source, refs := commitChain(source, 5)
+
+	Pull(source, sink, refs[1], types.Ref{}, 1, nil)
+	sink, err := sink.Commit(dsID, refs[1].TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+
+	// Every Commit back to the head of the sink is copied, however shallow the pull, so that the head can be moved forward.
+	assert.NoError(PullWithDepth(source, sink, refs[4], refs[1], 1, 1, nil))
+	sink, err = sink.Commit(dsID, refs[4].TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+	defer sink.Close()
+	assert.True(sink.ShallowCommits().Empty())
+	assert.True(sinkCS.Has(refs[2].TargetHash()))
+}
+
+func TestPullWithDepthToRemote(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS := chunks.NewTestStore()
+	source, sink := NewDatabase(chunks.NewTestStore()), makeRemoteDb(sinkCS)
+	defer source.Close()
+	defer sink.Close()
+	source, refs := commitChain(source, 3)
+
+	assert.Equal(ErrShallowRemoteSink, PullWithDepth(source, sink, refs[2], types.Ref{}, 1, 1, nil))
+	assert.Equal(0, sinkCS.Writes)
+	assert.NoError(PullWithDepth(source, sink, refs[2], types.Ref{}, 5, 1, nil))
+	assert.True(sink.ShallowCommits().Empty())
+}
+
+func TestResumePullWithDepth(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sinkCS := chunks.NewTestStore()
+	source, sink := NewDatabase(chunks.NewTestStore()), NewDatabase(sinkCS)
+	defer source.Close()
+	defer sink.Close()
+	source, refs := commitChain(source, 4)
+	head := refs[3]
+
+	cancel := make(chan struct{})
+	close(cancel)
+	assert.Equal(ErrPullCanceled, ResumePull(source, sink, head, types.Ref{}, 2, 1, nil, dir, cancel))
+	assert.NoError(ResumePull(source, sink, head, types.Ref{}, 2, 1, nil, dir, nil))
+	assert.True(types.NewSet(refs[2]).Equals(sink.ShallowCommits()))
+	assert.True(sinkCS.Has(refs[2].TargetHash()))
+	assert.False(sinkCS.Has(refs[1].TargetHash()))
+}
+
+func TestPullFromShallow(t *testing.T) {
+	assert := assert.New(t)
+	source := NewDatabase(chunks.NewTestStore())
+	defer source.Close()
+	source, refs := commitChain(source, 5)
+	head := refs[4]
+
+	shallowCS := chunks.NewTestStore()
+	shallow := NewDatabase(shallowCS)
+	assert.NoError(PullWithDepth(source, shallow, head, types.Ref{}, 2, 1, nil))
+	shallow, err := shallow.Commit(dsID, head.TargetValue(shallow).(types.Struct))
+	assert.NoError(err)
+	defer shallow.Close()
+
+	test := func(src Database) {
+		sinkCS := chunks.NewTestStore()
+		sink := NewDatabase(sinkCS)
+		defer sink.Close()
+
+		// The Commits the source doesn't have the parents of can't be copied in full, so the sink is left just as shallow.
+		Pull(src, sink, head, types.Ref{}, 1, nil)
+		assert.True(types.NewSet(refs[3]).Equals(sink.ShallowCommits()))
+		assert.True(sinkCS.Has(refs[3].TargetHash()))
+		assert.False(sinkCS.Has(refs[2].TargetHash()))
+
+		sink, err := sink.Commit(dsID, head.TargetValue(sink).(types.Struct))
+		assert.NoError(err)
+		assert.True(head.Equals(sink.HeadRef(dsID)))
+
+		// Nothing but datasets is kept in the Map at the root.
+		root := sink.ReadValue(sinkCS.Root()).(types.Map)
+		assert.Equal(uint64(1), root.Len())
+		assert.True(root.Has(types.String(dsID)))
+
+		// Pulling from the original source fills in the rest.
+		assert.NoError(PullWithDepth(source, sink, head, head, 0, 1, nil))
+		assert.True(sink.ShallowCommits().Empty())
+		assert.True(sinkCS.Has(refs[0].TargetHash()))
+	}
+	test(shallow)
+	test(makeRemoteDb(shallowCS))
+}
+
+func TestPullFromShallowIntoStoreWithoutShallowSupport(t *testing.T) {
+	assert := assert.New(t)
+	source := NewDatabase(chunks.NewTestStore())
+	defer source.Close()
+	source, refs := commitChain(source, 3)
+	head := refs[2]
+
+	shallow := NewDatabase(chunks.NewTestStore())
+	defer shallow.Close()
+	assert.NoError(PullWithDepth(source, shallow, head, types.Ref{}, 1, 1, nil))
+
+	sinkCS := chunks.NewTestStore()
+	sink := NewDatabase(noShallowStore{sinkCS})
+	defer sink.Close()
+	assert.Equal(ErrShallowUnsupported, PullWithDepth(shallow, sink, head, types.Ref{}, 0, 1, nil))
+	assert.Equal(0, sinkCS.Writes)
+}
+
+// noShallowStore hides the ShallowTracker methods of the ChunkStore it wraps.
+type noShallowStore struct {
+	chunks.ChunkStore
+}
@@ -0,0 +1,315 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+/*
+  Pull Request:
+    want=<hash>         // the Chunk to pull everything under
+    have=<hash>         // optional, the head the client already has everything under
+    has=<height>:<hash>,<hash>,...
+                        // optional, and repeated once per height: hashes of Chunks, whose Refs have that height, that the client has along with everything under them
+
+  Pull Stream Serialization:
+    Entry 0
+    Entry 1
+     ..
+    Entry N
+    0      // 8-byte uint64, marking the end of the Entries
+    Hints  // as serialized by serializeHints
+
+  Entry:
+    Height // 8-byte uint64 height of the Chunk's Ref, never 0
+    Known  // 8-byte uint64 count of Chunks the server knows it will send, including those already sent
+    Chunk  // as serialized by chunks.Serialize
+*/
+
+// pullStreamConcurrency is the number of goroutines the server uses to walk the source and sink graphs in handlePull.
+const pullStreamConcurrency = 16
+
+// pullSummaryLimit is the most hashes a client sends in the has summaries of a pull request.
+var pullSummaryLimit = 1 << 16
+
+// streamingPullSink writes the Chunks that pull copies to w, in the pull stream format above, for the client to store.
+type streamingPullSink struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	known uint64
+	// clientHas holds the hashes from the client's has summaries.
+	clientHas hash.HashSet
+}
+
+func newStreamingPullSink(w io.Writer, clientHas hash.HashSet) *streamingPullSink {
+	return &streamingPullSink{w: w, mu: &sync.Mutex{}, clientHas: clientHas}
+}
+
+// has returns true for the Chunks in the client's has summaries. Beyond those, the server only knows what the client has by way of the sink head it sent.
+func (s *streamingPullSink) has(h hash.Hash) bool {
+	return s.clientHas.Has(h)
+}
+
+func (s *streamingPullSink) get(h hash.Hash) chunks.Chunk {
+	return chunks.EmptyChunk
+}
+
+func (s *streamingPullSink) schedulePut(c chunks.Chunk, height uint64) {
+	d.Chk.True(height > 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.PanicIfError(binary.Write(s.w, binary.BigEndian, height))
+	d.PanicIfError(binary.Write(s.w, binary.BigEndian, s.known))
+	chunks.Serialize(c, s.w)
+}
+
+func (s *streamingPullSink) addHints(hints types.Hints) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.PanicIfError(binary.Write(s.w, binary.BigEndian, uint64(0)))
+	serializeHints(s.w, hints)
+}
+
+// trackProgress records the KnownCount of each PullProgress from ch, so that it can be sent along with each Chunk.
+func (s *streamingPullSink) trackProgress(ch <-chan PullProgress) {
+	for p := range ch {
+		s.mu.Lock()
+		s.known = p.KnownCount
+		s.mu.Unlock()
+	}
+}
+
+func handlePull(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "POST", "Expected post method.")
+
+	err := req.ParseForm()
+	d.PanicIfError(err)
+	want, ok := hash.MaybeParse(req.PostForm.Get("want"))
+	d.PanicIfTrue(!ok, "Invalid want: %s", req.PostForm.Get("want"))
+
+	db := newLocalDatabase(cs)
+	// The walk below would reach the missing parents of a shallow Commit. Clients pull from a shallow Database without streaming instead.
+	if !db.ShallowCommits().Empty() {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	wantVal := db.ReadValue(want)
+	d.PanicIfTrue(wantVal == nil, "Chunk %s not found", want)
+	sourceRef := types.NewRef(wantVal)
+
+	// If the server doesn't have the client's head, the client's data can't be accounted for and the whole graph under want is sent.
+	sinkHeadRef := types.Ref{}
+	if haveStr := req.PostForm.Get("have"); haveStr != "" {
+		have, ok := hash.MaybeParse(haveStr)
+		d.PanicIfTrue(!ok, "Invalid have: %s", haveStr)
+		if haveVal := db.ReadValue(have); haveVal != nil {
+			sinkHeadRef = types.NewRef(haveVal)
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	writer := respWriter(req, w)
+	defer writer.Close()
+
+	sink := newStreamingPullSink(writer, parsePullSummaries(req.PostForm["has"]))
+	progressCh := make(chan PullProgress)
+	progressDone := make(chan struct{})
+	go func() {
+		sink.trackProgress(progressCh)
+		close(progressDone)
+	}()
+	defer func() {
+		close(progressCh)
+		<-progressDone
+	}()
+	state := newPullState(db, sourceRef, sinkHeadRef)
+	defer state.destroy()
+	pull(db, db, sink, state, pullStreamConcurrency, progressCh, nil, nil)
+}
+
+// parsePullSummaries returns the hashes in the has values of a pull request, whatever their heights.
+func parsePullSummaries(values []string) hash.HashSet {
+	hashes := hash.HashSet{}
+	for _, v := range values {
+		sep := strings.IndexByte(v, ':')
+		d.PanicIfTrue(sep < 0, "Invalid has: %s", v)
+		_, err := strconv.ParseUint(v[:sep], 10, 64)
+		d.PanicIfTrue(err != nil, "Invalid has height: %s", v[:sep])
+		for _, str := range strings.Split(v[sep+1:], ",") {
+			h, ok := hash.MaybeParse(str)
+			d.PanicIfTrue(!ok, "Invalid has hash: %s", str)
+			hashes.Insert(h)
+		}
+	}
+	return hashes
+}
+
+// formatPullSummaries returns the has values of a pull request for summaries, in order of height.
+func formatPullSummaries(summaries map[uint64]hash.HashSet) []string {
+	heights := make([]int, 0, len(summaries))
+	for height := range summaries {
+		heights = append(heights, int(height))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	values := make([]string, 0, len(heights))
+	for _, height := range heights {
+		strs := make([]string, 0, len(summaries[uint64(height)]))
+		for h := range summaries[uint64(height)] {
+			strs = append(strs, h.String())
+		}
+		values = append(values, fmt.Sprintf("%d:%s", height, strings.Join(strs, ",")))
+	}
+	return values
+}
+
+// pullSummaries returns the hashes of the Chunks under head in db, grouped by the heights of their Refs, starting with the tallest, up to pullSummaryLimit of them. db must have everything under head, so that the server can leave out everything under each of them too.
+func pullSummaries(db Database, head types.Ref) map[uint64]hash.HashSet {
+	summaries := map[uint64]hash.HashSet{}
+	q := newRefQueue(head)
+	defer q.Destroy()
+	seen := hash.HashSet{}
+	for len(seen) < pullSummaryLimit && !q.Empty() {
+		r := q.PopBack()
+		if seen.Has(r.TargetHash()) {
+			continue
+		}
+		seen.Insert(r.TargetHash())
+		if summaries[r.Height()] == nil {
+			summaries[r.Height()] = hash.HashSet{}
+		}
+		summaries[r.Height()].Insert(r.TargetHash())
+		if r.Height() > 1 {
+			for _, child := range r.TargetValue(db).Chunks() {
+				if !seen.Has(child.TargetHash()) {
+					q.PushBack(child)
+				}
+			}
+		}
+	}
+	return summaries
+}
+
+// streamPull asks the server for every Chunk reachable from want but not from have, which may be empty, or from the Chunks in summaries, which may be nil, and calls put with each one as it arrives, followed by addHints with the hints that the Chunks need to be validated. If put returns false, the rest of the response is abandoned and addHints isn't called. streamPull returns false, having called neither, if the server doesn't support the request.
+func (bhcs *httpBatchStore) streamPull(want, have hash.Hash, summaries map[uint64]hash.HashSet, put func(c chunks.Chunk, height, known uint64) bool, addHints func(types.Hints)) bool {
+	// POST http://<host>/pull/. Post body: want=hash&have=hash&has=height:hash,hash. Response will be the pull stream described above, 404 from servers that predate it, or 501 from servers holding a shallow Database.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.PullPath)
+	values := url.Values{"want": {want.String()}}
+	if !have.IsEmpty() {
+		values.Set("have", have.String())
+	}
+	if len(summaries) > 0 {
+		values["has"] = formatPullSummaries(summaries)
+	}
+
+	req := newRequest("POST", bhcs.auth, u.String(), strings.NewReader(values.Encode()), http.Header{
+		"Accept-Encoding": {"x-snappy-framed"},
+		"Content-Type":    {"application/x-www-form-urlencoded"},
+	})
+
+	res, err := bhcs.httpClient.Do(req)
+	d.Chk.NoError(err)
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+		closeResponse(res.Body)
+		return false
+	}
+	expectVersion(res)
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+
+	d.Chk.True(http.StatusOK == res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+
+	for {
+		var height, known uint64
+		d.Chk.NoError(binary.Read(reader, binary.BigEndian, &height))
+		if height == 0 {
+			break
+		}
+		d.Chk.NoError(binary.Read(reader, binary.BigEndian, &known))
+		c, ok := chunks.DeserializeOne(reader)
+		d.Chk.True(ok, "Unexpected end of pull stream")
+		if !put(c, height, known) {
+			return true
+		}
+	}
+	addHints(deserializeHints(reader))
+	return true
+}
+
+// pullNegotiated pulls as described by Pull, but by having the server of srcDB stream everything sink needs in one response. It returns false, having done nothing, if srcDB isn't remote or its server is too old to do this. If cancel is closed before the response is finished, the rest of it is abandoned and sink.addHints isn't called.
+// If the server doesn't have sinkHeadRef, e.g. because sinkDB got it from elsewhere, the client summarizes what's under it instead, as long as sinkDB is local and has all of it, so that the server can still leave out what sinkDB has.
+func pullNegotiated(srcDB, sinkDB Database, sink pullSink, sourceRef, sinkHeadRef types.Ref, progressCh chan PullProgress, cancel <-chan struct{}) bool {
+	rds, ok := srcDB.(*RemoteDatabaseClient)
+	if !ok {
+		return false
+	}
+	bhcs, ok := rds.validatingBatchStore().(*httpBatchStore)
+	if !ok {
+		return false
+	}
+
+	var summaries map[uint64]hash.HashSet
+	if _, local := sinkDB.(*LocalDatabase); local && !sinkHeadRef.TargetHash().IsEmpty() && sinkDB.ShallowCommits().Empty() && !srcDB.has(sinkHeadRef.TargetHash()) {
+		summaries = pullSummaries(sinkDB, sinkHeadRef)
+	}
+
+	var doneCount, knownCount, doneBytes uint64
+	updateProgress := func() {
+		if progressCh != nil {
+			progressCh <- PullProgress{doneCount, knownCount, doneBytes}
+		}
+	}
+
+	started := false
+	put := func(c chunks.Chunk, height, known uint64) bool {
+		select {
+		case <-cancel:
+			return false
+		default:
+		}
+		if !started {
+			started = true
+			knownCount = known + 1
+			updateProgress()
+		}
+		// The server only knows which Chunks sink has by way of sinkHeadRef and summaries, so it may send some that are already present.
+		if !sink.has(c.Hash()) {
+			sink.schedulePut(c, height)
+		}
+		doneCount, doneBytes = doneCount+1, doneBytes+uint64(len(c.Data()))
+		if known > knownCount {
+			knownCount = known
+		}
+		if knownCount <= doneCount {
+			knownCount = doneCount + 1
+		}
+		updateProgress()
+		return true
+	}
+	addHints := func(hints types.Hints) {
+		sink.addHints(hints)
+		if started {
+			knownCount = doneCount
+			updateProgress()
+		}
+	}
+	return bhcs.streamPull(sourceRef.TargetHash(), sinkHeadRef.TargetHash(), summaries, put, addHints)
+}
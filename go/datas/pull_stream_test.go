@@ -0,0 +1,159 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestStreamPull(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+
+	l1 := types.NewList(db.WriteValue(types.String("a")))
+	db, err := db.Commit("ds", NewCommit(l1, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	head1 := db.Head("ds")
+	l2 := l1.Append(db.WriteValue(types.String("b")))
+	db, err = db.Commit("ds", NewCommit(l2, types.NewSet(types.NewRef(head1)), types.EmptyStruct))
+	assert.NoError(err)
+	head2 := db.Head("ds")
+
+	pulled := func(want, have hash.Hash) hash.HashSet {
+		store := newHTTPBatchStoreForTest(cs)
+		defer store.Close()
+		got := hash.HashSet{}
+		hintsSeen := false
+		ok := store.streamPull(want, have, nil, func(c chunks.Chunk, height, known uint64) bool {
+			assert.True(height > 0)
+			assert.False(hintsSeen)
+			got.Insert(c.Hash())
+			return true
+		}, func(hints types.Hints) {
+			hintsSeen = true
+		})
+		assert.True(ok)
+		assert.True(hintsSeen)
+		return got
+	}
+
+	// With no have, everything reachable from head2 is sent.
+	all := pulled(head2.Hash(), hash.Hash{})
+	assert.True(all.Has(head2.Hash()))
+	assert.True(all.Has(head1.Hash()))
+	assert.True(all.Has(types.String("a").Hash()))
+
+	// With have, only what isn't reachable from head1 is sent.
+	delta := pulled(head2.Hash(), head1.Hash())
+	assert.True(delta.Has(head2.Hash()))
+	assert.True(delta.Has(types.String("b").Hash()))
+	assert.False(delta.Has(head1.Hash()))
+	assert.False(delta.Has(types.String("a").Hash()))
+	assert.True(len(delta) < len(all))
+
+	// A have the server doesn't know is ignored.
+	assert.Equal(all, pulled(head2.Hash(), hash.FromData([]byte("nope"))))
+}
+
+func TestStreamPullFromLegacyServer(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	store := newLegacyHTTPBatchStoreForTest(cs)
+	defer store.Close()
+
+	called := false
+	ok := store.streamPull(hash.FromData([]byte("x")), hash.Hash{}, nil, func(c chunks.Chunk, height, known uint64) bool {
+		called = true
+		return true
+	}, func(hints types.Hints) {
+		called = true
+	})
+	assert.False(ok)
+	assert.False(called)
+}
+
+// recordingPullSink records the Chunks that it's sent, and has none of its own.
+type recordingPullSink struct {
+	mu   sync.Mutex
+	puts hash.HashSet
+}
+
+func (s *recordingPullSink) has(h hash.Hash) bool {
+	return false
+}
+
+func (s *recordingPullSink) get(h hash.Hash) chunks.Chunk {
+	return chunks.EmptyChunk
+}
+
+func (s *recordingPullSink) schedulePut(c chunks.Chunk, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puts.Insert(c.Hash())
+}
+
+func (s *recordingPullSink) addHints(hints types.Hints) {}
+
+func TestPullNegotiatedSummaries(t *testing.T) {
+	assert := assert.New(t)
+	nums := make([]types.Value, 10000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	big := types.NewList(nums...)
+	assert.True(types.IsMetaCollection(big))
+
+	sourceCS := chunks.NewTestStore()
+	source := NewDatabase(sourceCS)
+	source, err := source.Commit("ds", NewCommit(source.WriteValue(big), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	sourceRef := source.HeadRef("ds")
+
+	// The sink got the same List some other way, so the server doesn't have the sink's head.
+	sink := NewDatabase(chunks.NewTestStore())
+	defer sink.Close()
+	meta := types.NewStruct("Meta", types.StructData{"from": types.String("elsewhere")})
+	sink, err = sink.Commit("ds", NewCommit(sink.WriteValue(big), types.NewSet(), meta))
+	assert.NoError(err)
+	sinkHeadRef := sink.HeadRef("ds")
+	assert.False(source.has(sinkHeadRef.TargetHash()))
+
+	pulled := func() hash.HashSet {
+		rs := &recordingPullSink{puts: hash.HashSet{}}
+		assert.True(pullNegotiated(makeRemoteDb(sourceCS), sink, rs, sourceRef, sinkHeadRef, nil, nil))
+		return rs.puts
+	}
+
+	// The List is in the sink's summaries, so only the Commit is sent.
+	assert.Equal(hash.HashSet{sourceRef.TargetHash(): struct{}{}}, pulled())
+
+	// With only the sink's head summarized, the server has to send everything.
+	defer func(l int) { pullSummaryLimit = l }(pullSummaryLimit)
+	pullSummaryLimit = 1
+	all := pulled()
+	assert.True(all.Has(sourceRef.TargetHash()))
+	assert.True(all.Has(big.Hash()))
+	assert.True(len(all) > 2)
+}
+
+func TestPullSummariesRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	a, b, c := hash.FromData([]byte("a")), hash.FromData([]byte("b")), hash.FromData([]byte("c"))
+	summaries := map[uint64]hash.HashSet{
+		1: {a: struct{}{}, b: struct{}{}},
+		3: {c: struct{}{}},
+	}
+	values := formatPullSummaries(summaries)
+	assert.Equal("3:"+c.String(), values[0])
+	assert.Equal(hash.HashSet{a: struct{}{}, b: struct{}{}, c: struct{}{}}, parsePullSummaries(values))
+	assert.Panics(func() { parsePullSummaries([]string{a.String()}) })
+}
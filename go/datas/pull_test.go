@@ -5,8 +5,8 @@
 package datas
 
 import (
-	"sort"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/types"
@@ -24,6 +24,10 @@ func TestRemoteToLocalPulls(t *testing.T) {
 	suite.Run(t, &RemoteToLocalSuite{})
 }
 
+func TestLegacyRemoteToLocalPulls(t *testing.T) {
+	suite.Run(t, &LegacyRemoteToLocalSuite{})
+}
+
 func TestLocalToRemotePulls(t *testing.T) {
 	suite.Run(t, &LocalToRemoteSuite{})
 }
@@ -38,6 +42,8 @@ type PullSuite struct {
 	sourceCS *chunks.TestStore
 	sink     Database
 	source   Database
+	// sourceStreams is true if source is served by a server that supports the pull/ endpoint.
+	sourceStreams bool
 }
 
 type LocalToLocalSuite struct {
@@ -56,12 +62,26 @@ type RemoteToLocalSuite struct {
 }
 
 func (suite *RemoteToLocalSuite) SetupTest() {
+	suite.sourceStreams = true
 	suite.sinkCS = chunks.NewTestStore()
 	suite.sourceCS = chunks.NewTestStore()
 	suite.sink = NewDatabase(suite.sinkCS)
 	suite.source = makeRemoteDb(suite.sourceCS)
 }
 
+// LegacyRemoteToLocalSuite pulls from a server that doesn't support the pull/ endpoint, so Pull falls back to walking the source and sink itself.
+type LegacyRemoteToLocalSuite struct {
+	PullSuite
+}
+
+func (suite *LegacyRemoteToLocalSuite) SetupTest() {
+	suite.sinkCS = chunks.NewTestStore()
+	suite.sourceCS = chunks.NewTestStore()
+	suite.sink = NewDatabase(suite.sinkCS)
+	hbs := newLegacyHTTPBatchStoreForTest(suite.sourceCS)
+	suite.source = &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(hbs), types.NewValueStore(hbs), hbs)}
+}
+
 type LocalToRemoteSuite struct {
 	PullSuite
 }
@@ -78,6 +98,7 @@ type RemoteToRemoteSuite struct {
 }
 
 func (suite *RemoteToRemoteSuite) SetupTest() {
+	suite.sourceStreams = true
 	suite.sinkCS = chunks.NewTestStore()
 	suite.sourceCS = chunks.NewTestStore()
 	suite.sink = makeRemoteDb(suite.sinkCS)
@@ -94,6 +115,11 @@ func (suite *PullSuite) sinkIsLocal() bool {
 	return isLocal
 }
 
+// sinkIsWalked returns true if Pull reads chunks from the sink's ChunkStore to find what to copy, rather than having the source's server work that out.
+func (suite *PullSuite) sinkIsWalked() bool {
+	return suite.sinkIsLocal() && !suite.sourceStreams
+}
+
 func (suite *PullSuite) TearDownTest() {
 	suite.sink.Close()
 	suite.source.Close()
@@ -199,7 +225,7 @@ func (suite *PullSuite) TestPullMultiGeneration() {
 	pt := startProgressTracker()
 
 	Pull(suite.source, suite.sink, sourceRef, sinkRef, 2, pt.Ch)
-	if suite.sinkIsLocal() {
+	if suite.sinkIsWalked() {
 		// C1 gets read from most-local DB
 		expectedReads++
 	}
@@ -250,8 +276,10 @@ func (suite *PullSuite) TestPullDivergentHistory() {
 
 	Pull(suite.source, suite.sink, sourceRef, sinkRef, 2, pt.Ch)
 
-	// No objects read from sink, since sink Head is not an ancestor of source HEAD.
-	suite.Equal(preReads, suite.sinkCS.Reads)
+	// No objects read from sink, since sink Head is not an ancestor of source HEAD, unless they're summarized for a remote source, which doesn't have sink Head.
+	if _, remote := suite.source.(*RemoteDatabaseClient); !remote || !suite.sinkIsLocal() {
+		suite.Equal(preReads, suite.sinkCS.Reads)
+	}
 	pt.Validate(suite)
 
 	suite.sink.validatingBatchStore().Flush()
@@ -294,7 +322,7 @@ func (suite *PullSuite) TestPullUpdates() {
 
 	Pull(suite.source, suite.sink, sourceRef, sinkRef, 2, pt.Ch)
 
-	if suite.sinkIsLocal() {
+	if suite.sinkIsWalked() {
 		// 3 objects read from sink: L3, L2 and C1 (when considering the shared commit).
 		expectedReads += 3
 	}
@@ -334,30 +362,69 @@ func buildListOfHeight(height int, vw types.ValueWriter) types.List {
 	return l
 }
 
-// Note: This test is asserting that findCommon correctly separates refs which are exclusive to |taller| from those which are |common|.
+// Note: This test is asserting that findCommon correctly separates refs which are exclusive to |srcQ| or |sinkQ| from those which are |common|.
 func TestFindCommon(t *testing.T) {
-	taller := &types.RefByHeight{}
-	shorter := &types.RefByHeight{}
+	srcQ := newRefQueue()
+	sinkQ := newRefQueue()
 
 	for i := 0; i < 50; i++ {
-		shorter.PushBack(types.NewRef(types.Number(i)))
+		sinkQ.PushBack(types.NewRef(types.Number(i)))
 	}
 
 	for i := 50; i < 250; i++ {
-		shorter.PushBack(types.NewRef(types.Number(i)))
-		taller.PushBack(types.NewRef(types.Number(i)))
+		sinkQ.PushBack(types.NewRef(types.Number(i)))
+		srcQ.PushBack(types.NewRef(types.Number(i)))
 	}
 
 	for i := 250; i < 275; i++ {
-		taller.PushBack(types.NewRef(types.Number(i)))
+		srcQ.PushBack(types.NewRef(types.Number(i)))
 	}
 
-	sort.Sort(shorter)
-	sort.Sort(taller)
-
-	tallRefs, comRefs := findCommon(taller, shorter, 1)
-	assert.Equal(t, 25, len(tallRefs))
+	srcRefs, sinkRefs, comRefs := findCommon(srcQ, sinkQ, 1)
+	assert.Equal(t, 25, len(srcRefs))
+	assert.Equal(t, 50, len(sinkRefs))
 	assert.Equal(t, 200, len(comRefs))
-	assert.Equal(t, 0, len(*taller))
-	assert.Equal(t, 50, len(*shorter))
+	assert.True(t, srcQ.Empty())
+	assert.True(t, sinkQ.Empty())
+}
+
+func TestPullWithBackpressure(t *testing.T) {
+	assert := assert.New(t)
+	source := NewDatabase(chunks.NewTestStore())
+	sinkCS := chunks.NewFaultInjectingStore(chunks.NewTestStore(), chunks.FaultOptions{Backpressure: chunks.FailOn(0)})
+	sink := makeRemoteDb(sinkCS)
+	defer sink.Close()
+	defer source.Close()
+
+	l := buildListOfHeight(4, source)
+	source, err := source.Commit(dsID, NewCommit(l, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	sourceRef := source.HeadRef(dsID)
+
+	Pull(source, sink, sourceRef, types.Ref{}, 2, nil)
+	sink.validatingBatchStore().Flush()
+
+	// The server rejected the first batch, so the client must have retried it.
+	assert.Equal(1, sinkCS.Backpressured)
+	v := sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	assert.True(l.Equals(v.Get(ValueField)))
+}
+
+func TestPullWithLatency(t *testing.T) {
+	assert := assert.New(t)
+	opts := chunks.FaultOptions{Latency: time.Millisecond}
+	source := makeRemoteDb(chunks.NewFaultInjectingStore(chunks.NewTestStore(), opts))
+	sink := NewDatabase(chunks.NewFaultInjectingStore(chunks.NewTestStore(), opts))
+	defer sink.Close()
+	defer source.Close()
+
+	l := buildListOfHeight(3, source)
+	source, err := source.Commit(dsID, NewCommit(l, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	sourceRef := source.HeadRef(dsID)
+
+	Pull(source, sink, sourceRef, types.Ref{}, 4, nil)
+	sink.validatingBatchStore().Flush()
+	v := sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	assert.True(l.Equals(v.Get(ValueField)))
 }
@@ -23,17 +23,31 @@ import (
 func newOrderedChunkCache() *orderedChunkCache {
 	dir, err := ioutil.TempDir("", "")
 	d.PanicIfError(err)
+	// We dont need this data to be durable. LDB is acting as sorting temporary storage that can be larger than main memory.
+	return openOrderedChunkCache(dir, true)
+}
+
+// openOrderedChunkCache opens the cache stored in dir, creating it if need be, and indexes any Chunks it already holds. Unless noSync is true, Chunks that have been Inserted survive the process exiting before Destroy is called.
+func openOrderedChunkCache(dir string, noSync bool) *orderedChunkCache {
 	db, err := leveldb.OpenFile(dir, &opt.Options{
 		Compression:            opt.NoCompression,
 		Filter:                 filter.NewBloomFilter(10), // 10 bits/key
 		OpenFilesCacheCapacity: 24,
-		NoSync:                 true,    // We dont need this data to be durable. LDB is acting as sorting temporary storage that can be larger than main memory.
+		NoSync:                 noSync,
 		WriteBuffer:            1 << 27, // 128MiB
 	})
 	d.Chk.NoError(err, "opening put cache in %s", dir)
+	chunkIndex := map[hash.Hash][]byte{}
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		_, h := fromDbKey(iter.Key())
+		chunkIndex[h] = append([]byte{}, iter.Key()...)
+	}
+	iter.Release()
+	d.Chk.NoError(iter.Error())
 	return &orderedChunkCache{
 		orderedChunks: db,
-		chunkIndex:    map[hash.Hash][]byte{},
+		chunkIndex:    chunkIndex,
 		dbDir:         dir,
 		mu:            &sync.RWMutex{},
 	}
@@ -147,6 +161,24 @@ func (p *orderedChunkCache) ExtractChunks(hashes hash.HashSet, chunkChan chan *c
 	return nil
 }
 
+// IterAll can be called from any goroutine to call cb with every Chunk in the cache, along with the height it was Inserted with, in ref-height order.
+func (p *orderedChunkCache) IterAll(cb func(c chunks.Chunk, refHeight uint64)) {
+	iter := p.orderedChunks.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		refHeight, hash := fromDbKey(iter.Key())
+		data, err := snappy.Decode(nil, iter.Value())
+		d.Chk.NoError(err)
+		cb(chunks.NewChunkWithHash(hash, data), refHeight)
+	}
+	d.Chk.NoError(iter.Error())
+}
+
+// Close closes the cache, leaving its contents on disk to be reopened by openOrderedChunkCache.
+func (p *orderedChunkCache) Close() error {
+	return p.orderedChunks.Close()
+}
+
 func (p *orderedChunkCache) Destroy() error {
 	d.Chk.NoError(p.orderedChunks.Close())
 	return os.RemoveAll(p.dbDir)
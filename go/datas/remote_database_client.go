@@ -5,7 +5,10 @@
 package datas
 
 import (
+	"crypto/tls"
+
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/julienschmidt/httprouter"
 )
@@ -16,24 +19,59 @@ type RemoteDatabaseClient struct {
 }
 
 func NewRemoteDatabase(baseURL, auth string) *RemoteDatabaseClient {
-	httpBS := newHTTPBatchStore(baseURL, auth)
+	return NewRemoteDatabaseTLS(baseURL, auth, nil)
+}
+
+// NewRemoteDatabaseTLS is like NewRemoteDatabase, but uses tlsConfig to connect to https servers. This allows servers to use certificates signed by a private authority, or to require a client certificate. A nil tlsConfig uses the system defaults.
+func NewRemoteDatabaseTLS(baseURL, auth string, tlsConfig *tls.Config) *RemoteDatabaseClient {
+	return NewRemoteDatabaseWithOptions(baseURL, auth, RemoteOptions{TLSConfig: tlsConfig})
+}
+
+// RemoteOptions configures how a RemoteDatabaseClient talks to its server.
+type RemoteOptions struct {
+	// TLSConfig is used to connect to https servers, as described by NewRemoteDatabaseTLS. If nil, the system defaults are used.
+	TLSConfig *tls.Config
+	// CacheableReads makes the client fetch Chunks with GET requests, whose responses HTTP caches may store, rather than with POST requests. It's worthwhile when the server sits behind a caching proxy or CDN.
+	CacheableReads bool
+}
+
+// NewRemoteDatabaseWithOptions is like NewRemoteDatabase, but configured by opts.
+func NewRemoteDatabaseWithOptions(baseURL, auth string, opts RemoteOptions) *RemoteDatabaseClient {
+	httpBS := newHTTPBatchStore(baseURL, auth, opts.TLSConfig)
+	httpBS.cacheableReads = opts.CacheableReads
 	return &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(httpBS), types.NewValueStore(httpBS), httpBS)}
 }
 
+func (rds *RemoteDatabaseClient) HashesWithPrefix(prefix string, limit int) (hash.HashSlice, error) {
+	hashes, ok := rds.validatingBatchStore().(*httpBatchStore).hashesWithPrefix(prefix, limit)
+	if !ok {
+		return nil, ErrHashPrefixesUnsupported
+	}
+	return hashes, nil
+}
+
 func (rds *RemoteDatabaseClient) validatingBatchStore() (bs types.BatchStore) {
 	bs = rds.vs.BatchStore()
 	d.Chk.True(bs.IsValidating())
 	return
 }
 
+// setShallowCommits fails unless commits is empty, because the server validates every Chunk it's sent, and so won't accept a Commit whose parents are missing.
+func (rds *RemoteDatabaseClient) setShallowCommits(commits types.Set) error {
+	if !commits.Empty() {
+		return ErrShallowRemoteSink
+	}
+	return rds.doSetShallowCommits(commits)
+}
+
 func (rds *RemoteDatabaseClient) Commit(datasetID string, commit types.Struct) (Database, error) {
 	err := rds.commit(datasetID, commit)
-	return &RemoteDatabaseClient{newDatabaseCommon(rds.cch, rds.vs, rds.rt)}, err
+	return &RemoteDatabaseClient{rds.snapshot()}, err
 }
 
 func (rds *RemoteDatabaseClient) Delete(datasetID string) (Database, error) {
 	err := rds.doDelete(datasetID)
-	return &RemoteDatabaseClient{newDatabaseCommon(rds.cch, rds.vs, rds.rt)}, err
+	return &RemoteDatabaseClient{rds.snapshot()}, err
 }
 
 func (f RemoteStoreFactory) CreateStore(ns string) Database {
@@ -5,12 +5,15 @@
 package datas
 
 import (
+	"bufio"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -35,21 +38,36 @@ var (
 	// TODO: Nice comment about what headers it expects/honors, payload format, and error responses.
 	HandleWriteValue = versionCheck(handleWriteValue)
 
-	// HandleGetRefs is meant to handle HTTP POST requests to the getRefs/ server endpoint. Given a sequence of Chunk hashes, the server will fetch and return them.
+	// HandleGetRefs is meant to handle HTTP POST requests to the getRefs/ server endpoint. Given a sequence of Chunk hashes, the server will fetch and return them. It also handles GET requests that give the hashes as "ref" query params; if every Chunk is present, the response may be stored by HTTP caches, as Chunks never change.
 	// TODO: Nice comment about what headers it expects/honors, payload format, and responses.
 	HandleGetRefs = versionCheck(handleGetRefs)
 
+	// HandleRefGet is meant to handle HTTP GET requests to the ref/:hash server endpoint. The server returns the data of the Chunk with the given hash, or 404 if it's absent. As Chunks never change, the response may be stored by HTTP caches.
+	HandleRefGet = versionCheck(handleRefGet)
+
 	// HandleWriteValue is meant to handle HTTP POST requests to the hasRefs/ server endpoint. Given a sequence of Chunk hashes, the server check for their presence and return a list of true/false responses.
 	// TODO: Nice comment about what headers it expects/honors, payload format, and responses.
 	HandleHasRefs = versionCheck(handleHasRefs)
 
-	// HandleRootGet is meant to handle HTTP GET requests to the root/ server endpoint. The server returns the hash of the Root as a string.
+	// HandleRootGet is meant to handle HTTP GET requests to the root/ server endpoint. The server returns the hash of the Root as a string. If the request has a "last" query param, the server holds the request until the Root differs from it, or until a timeout elapses, before responding.
 	// TODO: Nice comment about what headers it expects/honors, payload format, and responses.
 	HandleRootGet = versionCheck(handleRootGet)
 
+	// HandlePull is meant to handle HTTP POST requests to the pull/ server endpoint. Given the hash of the value a client wants and, optionally, the hash of the Commit at the head of the client's dataset and summaries, by height, of Chunks the client has, the server streams back every Chunk the client needs to have the wanted value, along with the hints needed to validate them, so that a whole pull takes a single round trip. It responds 501 if the server's Database is shallow.
+	HandlePull = versionCheck(handlePull)
+
+	// HandleHashesGet is meant to handle HTTP GET requests to the hashes/ server endpoint. Given "prefix" and "limit" query params, the server returns, one per line and in order, the hashes of up to limit of the Chunks it has whose String() starts with prefix, or 501 if its ChunkStore can't look Chunks up that way.
+	HandleHashesGet = versionCheck(handleHashesGet)
+
 	// HandleWriteValue is meant to handle HTTP POST requests to the root/ server endpoint. This is used to update the Root to point to a new Chunk.
 	// TODO: Nice comment about what headers it expects/honors, payload format, and error responses.
 	HandleRootPost = versionCheck(handleRootPost)
+
+	// HandleShallowGet is meant to handle HTTP GET requests to the shallow/ server endpoint. The server returns, as a string, the hash of the Set of Commits in its Database whose parents aren't all present. It's the empty hash if there are none, including when the server's ChunkStore can't record them. See chunks.ShallowTracker.
+	HandleShallowGet = versionCheck(handleShallowGet)
+
+	// HandleShallowPost is meant to handle HTTP POST requests to the shallow/ server endpoint. Given "current" and "last" query params, it's a compare-and-swap of the hash returned by HandleShallowGet, responding 409 if last is out of date, or 501 if the server's ChunkStore can't record shallow Commits.
+	HandleShallowPost = versionCheck(handleShallowPost)
 )
 
 func versionCheck(hndlr Handler) Handler {
@@ -164,9 +182,34 @@ func (wc wc) Close() error {
 }
 
 func handleGetRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
-	d.PanicIfTrue(req.Method != "POST", "Expected post method.")
+	d.PanicIfTrue(req.Method != "POST" && req.Method != "GET", "Expected post or get method.")
 
 	hashes := extractHashes(req)
+	if req.Method == "GET" {
+		// The response depends only on the set of hashes and the encoding, so it can be cached unless a Chunk is missing and might appear later.
+		strs := make([]string, len(hashes))
+		for i, h := range hashes {
+			strs[i] = h.String()
+		}
+		sort.Strings(strs)
+		etag := `"` + hash.FromData([]byte(strings.Join(strs, ","))).String() + `"`
+		w.Header().Add("Vary", "Accept-Encoding")
+		if notModified(w, req, etag) {
+			return
+		}
+		all := true
+		for _, h := range hashes {
+			if !cs.Has(h) {
+				all = false
+				break
+			}
+		}
+		if all {
+			setImmutable(w, etag)
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+	}
 
 	w.Header().Add("Content-Type", "application/octet-stream")
 	writer := respWriter(req, w)
@@ -184,6 +227,9 @@ func extractHashes(req *http.Request) hash.HashSlice {
 	err := req.ParseForm()
 	d.PanicIfError(err)
 	hashStrs := req.PostForm["ref"]
+	if req.Method == "GET" {
+		hashStrs = req.Form["ref"]
+	}
 	d.PanicIfTrue(len(hashStrs) <= 0, "PostForm is empty")
 
 	hashes := make(hash.HashSlice, len(hashStrs))
@@ -193,6 +239,85 @@ func extractHashes(req *http.Request) hash.HashSlice {
 	return hashes
 }
 
+func handleRefGet(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	h, ok := hash.MaybeParse(ps.ByName("hash"))
+	d.PanicIfTrue(!ok, "Invalid hash: %s", ps.ByName("hash"))
+	etag := `"` + h.String() + `"`
+	if notModified(w, req, etag) {
+		return
+	}
+
+	c := cs.Get(h)
+	if c.IsEmpty() {
+		// The Chunk might be written later, so caches mustn't remember that it's absent.
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	setImmutable(w, etag)
+	w.Header().Add("Content-Type", "application/octet-stream")
+	_, err := w.Write(c.Data())
+	d.PanicIfError(err)
+}
+
+func handleHashesGet(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	params := req.URL.Query()
+	limit, err := strconv.Atoi(params.Get("limit"))
+	d.PanicIfTrue(err != nil || limit <= 0, "Invalid limit: %s", params.Get("limit"))
+
+	hashes, ok := chunks.HashesWithPrefix(cs, params.Get("prefix"), limit)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	// Chunks may be written later, so caches mustn't remember which ones match.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/plain")
+	for _, h := range hashes {
+		_, err := fmt.Fprintln(w, h.String())
+		d.PanicIfError(err)
+	}
+}
+
+// readHashesResponse reads the hashes in a response from the hashes/ server endpoint. ok is false if the server doesn't support the request, either because it predates the endpoint or because its ChunkStore can't look Chunks up by prefix.
+func readHashesResponse(res *http.Response) (hashes hash.HashSlice, ok bool) {
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusNotImplemented {
+		closeResponse(res.Body)
+		return nil, false
+	}
+	expectVersion(res)
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+	d.Chk.True(http.StatusOK == res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		hashes = append(hashes, hash.Parse(scanner.Text()))
+	}
+	d.Chk.NoError(scanner.Err())
+	return hashes, true
+}
+
+// setImmutable marks the response as one that HTTP caches may keep indefinitely. It isn't marked public, so shared caches won't store responses to requests that carry an Authorization header.
+func setImmutable(w http.ResponseWriter, etag string) {
+	w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+}
+
+// notModified responds 304 and returns true if the request's If-None-Match header matches etag, which must identify an immutable response.
+func notModified(w http.ResponseWriter, req *http.Request, etag string) bool {
+	if req.Header.Get("If-None-Match") != etag {
+		return false
+	}
+	setImmutable(w, etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
 func buildHashesRequest(hashes map[hash.Hash]struct{}) io.Reader {
 	values := &url.Values{}
 	for r := range hashes {
@@ -218,7 +343,16 @@ func handleHasRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 func handleRootGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt chunks.ChunkStore) {
 	d.PanicIfTrue(req.Method != "GET", "Expected post method.")
 
-	rootRef := rt.Root()
+	var rootRef hash.Hash
+	if last := req.URL.Query().Get("last"); last != "" {
+		var closed <-chan bool
+		if cn, ok := w.(http.CloseNotifier); ok {
+			closed = cn.CloseNotify()
+		}
+		rootRef = waitForRootChange(rt, hash.Parse(last), closed)
+	} else {
+		rootRef = rt.Root()
+	}
 	fmt.Fprintf(w, "%v", rootRef.String())
 	w.Header().Add("content-type", "text/plain")
 }
@@ -239,3 +373,49 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, rt c
 		return
 	}
 }
+
+func handleShallowGet(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	h := hash.Hash{}
+	if st, ok := cs.(chunks.ShallowTracker); ok {
+		h = st.Shallow()
+	}
+	w.Header().Add("content-type", "text/plain")
+	fmt.Fprintf(w, "%v", h.String())
+}
+
+func handleShallowPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "POST", "Expected post method.")
+
+	params := req.URL.Query()
+	tokens := params["last"]
+	d.PanicIfTrue(len(tokens) != 1, `Expected "last" query param value`)
+	last := hash.Parse(tokens[0])
+	tokens = params["current"]
+	d.PanicIfTrue(len(tokens) != 1, `Expected "current" query param value`)
+	current := hash.Parse(tokens[0])
+
+	st, ok := cs.(chunks.ShallowTracker)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	if !st.UpdateShallow(current, last) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+}
+
+// readShallowResponse reads the hash in a response from the shallow/ server endpoint. Servers that predate the endpoint can't hold shallow Databases, so a 404 is taken to mean there are no shallow Commits.
+func readShallowResponse(res *http.Response) hash.Hash {
+	defer closeResponse(res.Body)
+	if res.StatusCode == http.StatusNotFound {
+		return hash.Hash{}
+	}
+	expectVersion(res)
+	d.PanicIfTrue(http.StatusOK != res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	data, err := ioutil.ReadAll(res.Body)
+	d.PanicIfError(err)
+	return hash.Parse(string(data))
+}
@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/hash"
@@ -192,6 +193,77 @@ func TestHandleGetRefs(t *testing.T) {
 	}
 }
 
+func TestHandleGetRefsWithGet(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	chnx := []chunks.Chunk{
+		chunks.NewChunk([]byte("abc")),
+		chunks.NewChunk([]byte("def")),
+	}
+	err := cs.PutMany(chnx)
+	assert.NoError(err)
+
+	get := func(query string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HandleGetRefs(w, newRequest("GET", "", "?"+query, nil, header), params{}, cs)
+		return w
+	}
+
+	// The ETag doesn't depend on the order of the hashes.
+	w := get(fmt.Sprintf("ref=%s&ref=%s", chnx[0].Hash(), chnx[1].Hash()), nil)
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		assert.Contains(w.Header().Get("Cache-Control"), "immutable")
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(etag)
+
+		chunkChan := make(chan *chunks.Chunk)
+		go chunks.DeserializeToChan(w.Body, chunkChan)
+		got := map[hash.Hash]bool{}
+		for c := range chunkChan {
+			got[c.Hash()] = true
+		}
+		assert.Equal(map[hash.Hash]bool{chnx[0].Hash(): true, chnx[1].Hash(): true}, got)
+
+		w = get(fmt.Sprintf("ref=%s&ref=%s", chnx[1].Hash(), chnx[0].Hash()), http.Header{"If-None-Match": {etag}})
+		assert.Equal(http.StatusNotModified, w.Code)
+	}
+
+	// A missing Chunk might be written later, so the response mustn't be cached.
+	absent := hash.Parse("00000000000000000000000000000002")
+	w = get(fmt.Sprintf("ref=%s&ref=%s", chnx[0].Hash(), absent), nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("no-store", w.Header().Get("Cache-Control"))
+}
+
+func TestHandleRefGet(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	c := chunks.NewChunk([]byte("abc"))
+	cs.Put(c)
+
+	w := httptest.NewRecorder()
+	HandleRefGet(w, newRequest("GET", "", "", nil, nil), params{"hash": c.Hash().String()}, cs)
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		assert.Equal(c.Data(), w.Body.Bytes())
+		assert.Contains(w.Header().Get("Cache-Control"), "immutable")
+		assert.Equal(`"`+c.Hash().String()+`"`, w.Header().Get("ETag"))
+	}
+
+	w = httptest.NewRecorder()
+	HandleRefGet(w, newRequest("GET", "", "", nil, http.Header{"If-None-Match": {`"` + c.Hash().String() + `"`}}), params{"hash": c.Hash().String()}, cs)
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Empty(w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	HandleRefGet(w, newRequest("GET", "", "", nil, nil), params{"hash": "00000000000000000000000000000002"}, cs)
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Equal("no-store", w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	HandleRefGet(w, newRequest("GET", "", "", nil, nil), params{"hash": "nope"}, cs)
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
 func TestHandleHasRefs(t *testing.T) {
 	assert := assert.New(t)
 	cs := chunks.NewTestStore()
@@ -249,6 +321,35 @@ func TestHandleGetRoot(t *testing.T) {
 	}
 }
 
+func TestHandleGetRootWaitsForChange(t *testing.T) {
+	assert := assert.New(t)
+	defer func(i, t time.Duration) { rootPollInterval, rootWatchTimeout = i, t }(rootPollInterval, rootWatchTimeout)
+	rootPollInterval, rootWatchTimeout = time.Millisecond, 50*time.Millisecond
+
+	cs := chunks.NewTestStore()
+	c1, c2 := chunks.NewChunk([]byte("abc")), chunks.NewChunk([]byte("def"))
+	cs.PutMany([]chunks.Chunk{c1, c2})
+	assert.True(cs.UpdateRoot(c1.Hash(), hash.Hash{}))
+	getRoot := func() hash.Hash {
+		w := httptest.NewRecorder()
+		HandleRootGet(w, newRequest("GET", "", "?last="+c1.Hash().String(), nil, nil), params{}, cs)
+		assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes()))
+		return hash.Parse(string(w.Body.Bytes()))
+	}
+
+	// With no change, the server gives up after rootWatchTimeout.
+	start := time.Now()
+	assert.Equal(c1.Hash(), getRoot())
+	assert.True(time.Since(start) >= rootWatchTimeout)
+
+	rootWatchTimeout = time.Minute
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cs.UpdateRoot(c2.Hash(), c1.Hash())
+	}()
+	assert.Equal(c2.Hash(), getRoot())
+}
+
 func TestHandlePostRoot(t *testing.T) {
 	assert := assert.New(t)
 	cs := chunks.NewTestStore()
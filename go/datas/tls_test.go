@@ -0,0 +1,133 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// writeTestCert writes a self-signed certificate for localhost, and its private key, to PEM files in dir. The certificate may also act as its own authority.
+func writeTestCert(assert *assert.Assertions, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(err)
+
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	assert.NoError(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return
+}
+
+// serveTLS runs a remoteDatabaseServer over cs using config, calling f with its URL once it's ready.
+func serveTLS(cs chunks.ChunkStore, config *tls.Config, f func(url string)) {
+	server := NewRemoteDatabaseServer(cs, 0)
+	server.TLSConfig = config
+	server.Ready = func() {
+		defer server.Stop()
+		f(fmt.Sprintf("https://localhost:%d", server.Port()))
+	}
+	server.Run()
+}
+
+func TestRemoteDatabaseTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	serverCert, serverKey := writeTestCert(assert, dir, "server", x509.ExtKeyUsageServerAuth)
+	serverConfig, err := NewServerTLSConfig(serverCert, serverKey, "")
+	assert.NoError(err)
+	clientConfig, err := NewClientTLSConfig(serverCert, "", "")
+	assert.NoError(err)
+
+	serveTLS(chunks.NewTestStore(), serverConfig, func(url string) {
+		var db Database = NewRemoteDatabaseTLS(url, "", clientConfig)
+		db, err := db.Commit("ds", NewCommit(types.String("hello"), types.NewSet(), types.EmptyStruct))
+		assert.NoError(err)
+		assert.NoError(db.Close())
+
+		db = NewRemoteDatabaseTLS(url, "", clientConfig)
+		assert.True(types.String("hello").Equals(db.Head("ds").Get(ValueField)))
+		assert.NoError(db.Close())
+
+		// The server's certificate isn't signed by any authority the system trusts.
+		assert.Error(d.Try(func() { NewRemoteDatabase(url, "") }))
+	})
+}
+
+func TestRemoteDatabaseMutualTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	serverCert, serverKey := writeTestCert(assert, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := writeTestCert(assert, dir, "client", x509.ExtKeyUsageClientAuth)
+	serverConfig, err := NewServerTLSConfig(serverCert, serverKey, clientCert)
+	assert.NoError(err)
+
+	serveTLS(chunks.NewTestStore(), serverConfig, func(url string) {
+		withCert, err := NewClientTLSConfig(serverCert, clientCert, clientKey)
+		assert.NoError(err)
+		db := NewRemoteDatabaseTLS(url, "", withCert)
+		assert.Equal(0, int(db.Datasets().Len()))
+		assert.NoError(db.Close())
+
+		withoutCert, err := NewClientTLSConfig(serverCert, "", "")
+		assert.NoError(err)
+		assert.Error(d.Try(func() { NewRemoteDatabaseTLS(url, "", withoutCert) }))
+	})
+}
+
+func TestLoadCertPool(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cert, key := writeTestCert(assert, dir, "test", x509.ExtKeyUsageServerAuth)
+	_, err = LoadCertPool(cert)
+	assert.NoError(err)
+
+	_, err = LoadCertPool(key)
+	assert.Error(err)
+	_, err = LoadCertPool(filepath.Join(dir, "missing"))
+	assert.Error(err)
+}
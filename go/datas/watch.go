@@ -0,0 +1,173 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+var (
+	// rootPollInterval is how often the Root of a ChunkStore is checked when waiting for it to change.
+	rootPollInterval = 100 * time.Millisecond
+
+	// rootWatchTimeout bounds how long the server holds a root/ request waiting for the Root to change, so that idle connections are periodically refreshed.
+	rootWatchTimeout = 30 * time.Second
+)
+
+// rootWaiter is implemented by RootTrackers that can block until their Root changes, rather than having to be polled.
+type rootWaiter interface {
+	// waitForRoot returns the current Root once it differs from last, or once some implementation-defined timeout has elapsed. Closing cancel abandons the wait.
+	waitForRoot(last hash.Hash, cancel <-chan struct{}) hash.Hash
+}
+
+// closeNotifier is shared by every snapshot of a Database, so that watches started on any of them end when one of them is closed.
+type closeNotifier struct {
+	c    chan struct{}
+	once *sync.Once
+	// mu is held for reading by watches while they read from the Database, and for writing while it's being marked closed, so that the ChunkStore isn't closed under them.
+	mu *sync.RWMutex
+}
+
+func newCloseNotifier() closeNotifier {
+	return closeNotifier{make(chan struct{}), &sync.Once{}, &sync.RWMutex{}}
+}
+
+// close marks the Database closed, once any reads begun by whileOpen have finished.
+func (cn closeNotifier) close() {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.once.Do(func() { close(cn.c) })
+}
+
+// whileOpen calls f and returns true if the Database hasn't been closed, or returns false if it has. The Database isn't closed until f returns.
+func (cn closeNotifier) whileOpen(f func()) bool {
+	cn.mu.RLock()
+	defer cn.mu.RUnlock()
+	select {
+	case <-cn.c:
+		return false
+	default:
+	}
+	f()
+	return true
+}
+
+// WatchHead waits for the Root to change, and sends the head of datasetID in each new Root if it differs from the last one sent.
+func (ds *databaseCommon) WatchHead(datasetID string) <-chan types.Ref {
+	heads := make(chan types.Ref)
+	go func() {
+		defer close(heads)
+		root := ds.rootRef
+		var head hash.Hash
+		// The Database may be closed at any time, so it's only read from within whileOpen.
+		if !ds.closed.whileOpen(func() {
+			if r, ok := ds.MaybeHeadRef(datasetID); ok {
+				head = r.TargetHash()
+			}
+		}) {
+			return
+		}
+		for {
+			next, ok := ds.waitForRoot(root)
+			if !ok {
+				return
+			}
+			if next == root {
+				continue
+			}
+			root = next
+
+			if root.IsEmpty() {
+				continue
+			}
+			var r types.Value
+			var present bool
+			if !ds.closed.whileOpen(func() {
+				r, present = ds.datasetsFromRef(root).MaybeGet(types.String(datasetID))
+			}) {
+				return
+			}
+			if !present || r.(types.Ref).TargetHash() == head {
+				continue
+			}
+			head = r.(types.Ref).TargetHash()
+			select {
+			case heads <- r.(types.Ref):
+			case <-ds.closed.c:
+				return
+			}
+		}
+	}()
+	return heads
+}
+
+// waitForRoot returns the current Root once it differs from last, or after a while if it doesn't. It returns false if the Database is closed in the meantime.
+func (ds *databaseCommon) waitForRoot(last hash.Hash) (hash.Hash, bool) {
+	if rw, ok := ds.rt.(rootWaiter); ok {
+		rootChan := make(chan hash.Hash, 1)
+		go func() {
+			root := last
+			// Errors, e.g. from a server that's restarting, are treated like a timeout, so that the watch carries on.
+			d.Try(func() { root = rw.waitForRoot(last, ds.closed.c) })
+			rootChan <- root
+		}()
+		select {
+		case <-ds.closed.c:
+			return hash.Hash{}, false
+		case root := <-rootChan:
+			if root == last {
+				// Older servers respond without waiting, so don't spin.
+				time.Sleep(rootPollInterval)
+			}
+			return root, true
+		}
+	}
+	return pollRoot(ds.rt, last, ds.closed.c)
+}
+
+// pollRoot checks rt for a Root different from last every rootPollInterval, until it finds one or until either rootWatchTimeout elapses or done is closed. It returns false if done was closed.
+func pollRoot(rt chunks.RootTracker, last hash.Hash, done <-chan struct{}) (hash.Hash, bool) {
+	timeout := time.After(rootWatchTimeout)
+	for {
+		select {
+		case <-done:
+			return hash.Hash{}, false
+		default:
+		}
+		if root := rt.Root(); root != last {
+			return root, true
+		}
+		select {
+		case <-done:
+			return hash.Hash{}, false
+		case <-timeout:
+			return last, true
+		case <-time.After(rootPollInterval):
+		}
+	}
+}
+
+// waitForRootChange holds a root/ request until the Root of rt differs from last, or until rootWatchTimeout elapses, and returns the current Root. A value on closed, which may be nil, means the client has gone away, so waiting is abandoned.
+func waitForRootChange(rt chunks.RootTracker, last hash.Hash, closed <-chan bool) hash.Hash {
+	stop, finished := make(chan struct{}), make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-closed:
+			close(stop)
+		case <-finished:
+		}
+	}()
+	if root, ok := pollRoot(rt, last, stop); ok {
+		return root
+	}
+	return last
+}
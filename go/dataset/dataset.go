@@ -103,7 +103,13 @@ func (ds *Dataset) Commit(v types.Value, opts CommitOptions) (Dataset, error) {
 	return Dataset{store, ds.id}, err
 }
 
+// Pull copies everything under sourceRef in sourceStore into the Database of ds and makes it the head of ds. If that Database is shallow, its history is deepened. See datas.PullWithDepth.
 func (ds *Dataset) Pull(sourceStore datas.Database, sourceRef types.Ref, concurrency int, progressCh chan datas.PullProgress) (Dataset, error) {
+	return ds.PullWithDepth(sourceStore, sourceRef, 0, concurrency, progressCh)
+}
+
+// PullWithDepth is like Pull, but if depth is greater than zero, only the depth most recent Commits in the history of sourceRef are copied, as described by datas.PullWithDepth.
+func (ds *Dataset) PullWithDepth(sourceStore datas.Database, sourceRef types.Ref, depth uint64, concurrency int, progressCh chan datas.PullProgress) (Dataset, error) {
 	sink := *ds
 
 	sinkHeadRef := types.Ref{}
@@ -111,11 +117,37 @@ func (ds *Dataset) Pull(sourceStore datas.Database, sourceRef types.Ref, concurr
 		sinkHeadRef = currentHeadRef
 	}
 
-	if sourceRef == sinkHeadRef {
+	// If the Database is shallow, there may be history to fill in even though the head is where it should be.
+	if sourceRef == sinkHeadRef && sink.Database().ShallowCommits().Empty() {
 		return sink, nil
 	}
 
-	datas.Pull(sourceStore, sink.Database(), sourceRef, sinkHeadRef, concurrency, progressCh)
+	if err := datas.PullWithDepth(sourceStore, sink.Database(), sourceRef, sinkHeadRef, depth, concurrency, progressCh); err != nil {
+		return sink, err
+	}
+	err := datas.ErrOptimisticLockFailed
+	for ; err == datas.ErrOptimisticLockFailed; sink, err = sink.setNewHead(sourceRef) {
+	}
+
+	return sink, err
+}
+
+// ResumablePull is like PullWithDepth, but uses datas.ResumePull to keep track of its progress in dir. If cancel is closed before everything under sourceRef has been copied, it returns datas.ErrPullCanceled and leaves the head of ds where it was. Calling it again with the same dir carries on from where it stopped.
+func (ds *Dataset) ResumablePull(sourceStore datas.Database, sourceRef types.Ref, depth uint64, concurrency int, progressCh chan datas.PullProgress, dir string, cancel <-chan struct{}) (Dataset, error) {
+	sink := *ds
+
+	sinkHeadRef := types.Ref{}
+	if currentHeadRef, ok := sink.MaybeHeadRef(); ok {
+		sinkHeadRef = currentHeadRef
+	}
+
+	if sourceRef == sinkHeadRef && sink.Database().ShallowCommits().Empty() {
+		return sink, nil
+	}
+
+	if err := datas.ResumePull(sourceStore, sink.Database(), sourceRef, sinkHeadRef, depth, concurrency, progressCh, dir, cancel); err != nil {
+		return sink, err
+	}
 	err := datas.ErrOptimisticLockFailed
 	for ; err == datas.ErrOptimisticLockFailed; sink, err = sink.setNewHead(sourceRef) {
 	}
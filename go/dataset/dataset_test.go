@@ -194,3 +194,20 @@ func TestHeadValueFunctions(t *testing.T) {
 	_, ok = ds2.MaybeHeadValue()
 	assert.False(ok)
 }
+
+func TestCommitRootConflict(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewFaultInjectingStore(chunks.NewTestStore(), chunks.FaultOptions{RootConflict: chunks.FailOn(0)})
+	ds := NewDataset(datas.NewDatabase(cs), "ds1")
+
+	a := types.String("a")
+	ds, err := ds.CommitValue(a)
+	assert.Equal(datas.ErrOptimisticLockFailed, err)
+	_, ok := ds.MaybeHead()
+	assert.False(ok)
+
+	ds, err = ds.CommitValue(a)
+	assert.NoError(err)
+	assert.True(ds.HeadValue().Equals(a))
+	assert.Equal(1, cs.RootConflicts)
+}
@@ -5,6 +5,8 @@
 package dataset
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -161,3 +163,87 @@ func TestPullWithMeta(t *testing.T) {
 	assert.NoError(err)
 	assert.True(source.Head().Equals(sink.Head()))
 }
+
+func TestPullRetriesRootConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	sinkCS := chunks.NewFaultInjectingStore(chunks.NewTestStore(), chunks.FaultOptions{RootConflict: chunks.FailOn(0, 1)})
+	sink := NewDataset(datas.NewDatabase(sinkCS), "sink")
+	source := createTestDataset("source")
+
+	source, err := source.CommitValue(types.NewMap(types.String("first"), NewList(source, types.Number(1))))
+	assert.NoError(err)
+
+	// Pull keeps retrying until the head update lands.
+	sink, err = sink.Pull(source.Database(), types.NewRef(source.Head()), 1, nil)
+	assert.NoError(err)
+	assert.True(source.Head().Equals(sink.Head()))
+	assert.Equal(2, sinkCS.RootConflicts)
+}
+
+func TestPullCorruptedSource(t *testing.T) {
+	assert := assert.New(t)
+
+	sourceCS := chunks.NewTestStore()
+	source := NewDataset(datas.NewDatabase(sourceCS), "source")
+	source, err := source.CommitValue(types.NewList(types.String("a"), types.String("b")))
+	assert.NoError(err)
+	sourceRef := types.NewRef(source.Head())
+
+	// Reopen the source over a store whose reads come back damaged; the sink must refuse to take on the bad data.
+	damaged := datas.NewDatabase(chunks.NewFaultInjectingStore(sourceCS, chunks.FaultOptions{Corrupt: chunks.FailAfter(0)}))
+	sink := createTestDataset("sink")
+	assert.Panics(func() { sink.Pull(damaged, sourceRef, 1, nil) })
+	_, ok := sink.MaybeHead()
+	assert.False(ok)
+}
+
+func TestResumablePullMovesHeadOnlyWhenDone(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sink := createTestDataset("sink")
+	source := createTestDataset("source")
+	source, err = source.CommitValue(types.NewMap(
+		types.String("first"), NewList(source),
+		types.String("second"), NewList(source, types.Number(2))))
+	assert.NoError(err)
+
+	cancel := make(chan struct{})
+	close(cancel)
+	sink, err = sink.ResumablePull(source.Database(), types.NewRef(source.Head()), 0, 1, nil, dir, cancel)
+	assert.Equal(datas.ErrPullCanceled, err)
+	_, ok := sink.MaybeHead()
+	assert.False(ok)
+
+	sink, err = sink.ResumablePull(source.Database(), types.NewRef(source.Head()), 0, 1, nil, dir, nil)
+	assert.NoError(err)
+	assert.True(source.Head().Equals(sink.Head()))
+}
+
+func TestPullWithDepthThenDeepen(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS := chunks.NewTestStore()
+	sink := NewDataset(datas.NewDatabase(sinkCS), "sink")
+	source := createTestDataset("source")
+	source, err := source.CommitValue(types.Number(1))
+	assert.NoError(err)
+	first := source.Head()
+	source, err = source.CommitValue(types.Number(2))
+	assert.NoError(err)
+	sourceRef := types.NewRef(source.Head())
+
+	sink, err = sink.PullWithDepth(source.Database(), sourceRef, 1, 1, nil)
+	assert.NoError(err)
+	assert.True(source.Head().Equals(sink.Head()))
+	assert.False(sinkCS.Has(first.Hash()))
+	assert.True(types.NewSet(sourceRef).Equals(sink.Database().ShallowCommits()))
+
+	// The head is already where it should be, but the history still has to be filled in.
+	sink, err = sink.Pull(source.Database(), sourceRef, 1, nil)
+	assert.NoError(err)
+	assert.True(sinkCS.Has(first.Hash()))
+	assert.True(sink.Database().ShallowCommits().Empty())
+}
@@ -34,6 +34,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/attic-labs/noms/go/d"
 )
@@ -41,11 +42,15 @@ import (
 const (
 	ByteLen   = 20
 	StringLen = 32 // 20 * 8 / log2(32)
+
+	// AbbrevLen is the length of the strings returned by Abbrev.
+	AbbrevLen = 10
 )
 
 var (
-	pattern   = regexp.MustCompile("^([0-9a-v]{" + strconv.Itoa(StringLen) + "})$")
-	emptyHash = Hash{}
+	pattern       = regexp.MustCompile("^([0-9a-v]{" + strconv.Itoa(StringLen) + "})$")
+	prefixPattern = regexp.MustCompile("^[0-9a-v]{0," + strconv.Itoa(StringLen) + "}$")
+	emptyHash     = Hash{}
 )
 
 type Hash struct {
@@ -107,6 +112,22 @@ func Parse(s string) Hash {
 	return r
 }
 
+// PrefixRange returns the first and last Hashes whose String() starts with prefix. Since sorted hashes are sorted textually, every Hash between them does too. ok is false if prefix can't be the start of a Hash's String().
+func PrefixRange(prefix string) (first, last Hash, ok bool) {
+	if !prefixPattern.MatchString(prefix) {
+		return emptyHash, emptyHash, false
+	}
+	pad := StringLen - len(prefix)
+	first = FromSlice(decode(prefix + strings.Repeat("0", pad)))
+	last = FromSlice(decode(prefix + strings.Repeat("v", pad)))
+	return first, last, true
+}
+
+// Abbrev returns the first AbbrevLen characters of r.String(), which are usually enough to pick r out from the other hashes in a Database.
+func (r Hash) Abbrev() string {
+	return r.String()[:AbbrevLen]
+}
+
 func (r Hash) Less(other Hash) bool {
 	return bytes.Compare(r.digest[:], other.digest[:]) < 0
 }
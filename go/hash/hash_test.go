@@ -5,6 +5,7 @@
 package hash
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/attic-labs/noms/go/d"
@@ -146,3 +147,33 @@ func TestGreater(t *testing.T) {
 	assert.False(r0.Greater(r2))
 	assert.True(r2.Greater(r0))
 }
+
+func TestPrefixRange(t *testing.T) {
+	assert := assert.New(t)
+
+	h := FromData([]byte("abc"))
+	for _, n := range []int{0, 1, 4, 13, StringLen} {
+		prefix := h.String()[:n]
+		first, last, ok := PrefixRange(prefix)
+		assert.True(ok)
+		assert.True(strings.HasPrefix(first.String(), prefix))
+		assert.True(strings.HasPrefix(last.String(), prefix))
+		assert.False(h.Less(first))
+		assert.False(h.Greater(last))
+	}
+
+	first, last, ok := PrefixRange("a")
+	assert.True(ok)
+	assert.Equal("a0000000000000000000000000000000", first.String())
+	assert.Equal("avvvvvvvvvvvvvvvvvvvvvvvvvvvvvvv", last.String())
+
+	_, _, ok = PrefixRange("w")
+	assert.False(ok)
+	_, _, ok = PrefixRange(h.String() + "0")
+	assert.False(ok)
+}
+
+func TestAbbrev(t *testing.T) {
+	h := FromData([]byte("abc"))
+	assert.Equal(t, h.String()[:AbbrevLen], h.Abbrev())
+}
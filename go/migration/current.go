@@ -0,0 +1,67 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const currentMigratorConcurrency = 64
+
+func init() {
+	Register(constants.NomsVersion, func() Migrator { return currentMigrator{} })
+}
+
+// currentMigrator handles data that's already in the current format, so migrating simply copies it. This makes it possible to consolidate databases, or move data between storage backends, with the same tool used to upgrade them.
+type currentMigrator struct{}
+
+func (currentMigrator) Datasets(src chunks.ChunkStore) map[string]hash.Hash {
+	srcDB := datas.NewDatabase(noCloseStore{src})
+	defer srcDB.Close()
+	heads := map[string]hash.Hash{}
+	srcDB.Datasets().IterAll(func(k, v types.Value) {
+		heads[string(k.(types.String))] = v.(types.Ref).TargetHash()
+	})
+	return heads
+}
+
+func (currentMigrator) MigrateValue(h hash.Hash, src chunks.ChunkStore, sink datas.Database) types.Value {
+	srcDB := datas.NewDatabase(noCloseStore{src})
+	defer srcDB.Close()
+	v := srcDB.ReadValue(h)
+	if v == nil {
+		return nil
+	}
+	datas.Pull(srcDB, sink, types.NewRef(v), types.Ref{}, currentMigratorConcurrency, nil)
+	return sink.ReadValue(h)
+}
+
+// noCloseStore keeps src open when the Database wrapped around it is closed, as src belongs to the caller of Migrate.
+type noCloseStore struct {
+	chunks.ChunkStore
+}
+
+func (noCloseStore) Close() error {
+	return nil
+}
+
+// Shallow and UpdateShallow pass through to src, if it can record shallow Commits, so that a shallow source is migrated into an equally shallow sink.
+func (s noCloseStore) Shallow() hash.Hash {
+	if st, ok := s.ChunkStore.(chunks.ShallowTracker); ok {
+		return st.Shallow()
+	}
+	return hash.Hash{}
+}
+
+func (s noCloseStore) UpdateShallow(current, last hash.Hash) bool {
+	if st, ok := s.ChunkStore.(chunks.ShallowTracker); ok {
+		return st.UpdateShallow(current, last)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package migration moves data written in one version of the Noms format into a Database using the current one.
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Migrator converts data written in one particular version of the Noms format into the current format.
+type Migrator interface {
+	// Datasets returns the hash of the head Commit of every dataset in src, keyed by dataset ID.
+	Datasets(src chunks.ChunkStore) map[string]hash.Hash
+
+	// MigrateValue converts the Value stored in src under h, along with everything it references, and writes the results to sink. It returns the converted Value. As Commits reference their parents, migrating a Commit migrates its entire history, though history shared with a Value migrated earlier in the same run needn't be migrated again.
+	MigrateValue(h hash.Hash, src chunks.ChunkStore, sink datas.Database) types.Value
+}
+
+var (
+	migrators   = map[string]func() Migrator{}
+	migratorsMu = &sync.Mutex{}
+)
+
+// Register makes the Migrators returned by newMigrator responsible for migrating data of the given version, as reported by ChunkStore.Version(). Each call to Migrate gets a Migrator of its own, so it may remember what it has already migrated for the length of the run. Packages able to decode older versions of the format register themselves at init time.
+func Register(version string, newMigrator func() Migrator) {
+	migratorsMu.Lock()
+	defer migratorsMu.Unlock()
+	_, present := migrators[version]
+	d.PanicIfTrue(present, "A Migrator is already registered for version %s", version)
+	migrators[version] = newMigrator
+}
+
+func lookup(version string) (Migrator, bool) {
+	migratorsMu.Lock()
+	defer migratorsMu.Unlock()
+	newMigrator, ok := migrators[version]
+	if !ok {
+		return nil, false
+	}
+	return newMigrator(), true
+}
+
+// Head records the head of a migrated dataset before and after migration.
+type Head struct {
+	ID       string
+	Old, New hash.Hash
+}
+
+// Migrate converts every dataset in src to the current format and commits it to sink, preserving each dataset's full commit graph. It returns the updated sink along with the old and new heads of each dataset, in dataset ID order. Datasets that already exist in sink are not overwritten; the first one encountered causes ErrMergeNeeded to be returned.
+func Migrate(src chunks.ChunkStore, sink datas.Database) (datas.Database, []Head, error) {
+	version := src.Version()
+	m, ok := lookup(version)
+	if !ok {
+		return sink, nil, fmt.Errorf("Don't know how to migrate data of version %s", version)
+	}
+
+	datasets := m.Datasets(src)
+	ids := make([]string, 0, len(datasets))
+	for id := range datasets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	heads := make([]Head, 0, len(ids))
+	for _, id := range ids {
+		oldHead := datasets[id]
+		commit, ok := m.MigrateValue(oldHead, src, sink).(types.Struct)
+		if !ok || !datas.IsCommitType(commit.Type()) {
+			return sink, heads, fmt.Errorf("Head of dataset %s is not a Commit", id)
+		}
+
+		var err error
+		if sink, err = sink.Commit(id, commit); err != nil {
+			return sink, heads, err
+		}
+		heads = append(heads, Head{id, oldHead, commit.Hash()})
+	}
+	return sink, heads, nil
+}
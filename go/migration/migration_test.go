@@ -0,0 +1,134 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type versionedStore struct {
+	chunks.ChunkStore
+	version string
+}
+
+func (s versionedStore) Version() string {
+	return s.version
+}
+
+// unwrappingMigrator migrates the data hidden behind a versionedStore.
+type unwrappingMigrator struct{}
+
+func (unwrappingMigrator) Datasets(src chunks.ChunkStore) map[string]hash.Hash {
+	return currentMigrator{}.Datasets(src.(versionedStore).ChunkStore)
+}
+
+func (unwrappingMigrator) MigrateValue(h hash.Hash, src chunks.ChunkStore, sink datas.Database) types.Value {
+	return currentMigrator{}.MigrateValue(h, src.(versionedStore).ChunkStore, sink)
+}
+
+func commit(db datas.Database, id string, v types.Value, parents ...types.Struct) (datas.Database, types.Struct) {
+	parentRefs := types.NewSet()
+	for _, p := range parents {
+		parentRefs = parentRefs.Insert(types.NewRef(p))
+	}
+	c := datas.NewCommit(v, parentRefs, types.EmptyStruct)
+	db, err := db.Commit(id, c)
+	if err != nil {
+		panic(err)
+	}
+	return db, db.Head(id)
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	src := chunks.NewMemoryStore()
+	db := datas.NewDatabase(src)
+	db, c1 := commit(db, "a", types.Number(1))
+	db, c2 := commit(db, "a", types.Number(2), c1)
+	db, _ = db.Delete("a")
+	db, c3 := commit(db, "a", types.Number(3), c1)
+	db, c4 := commit(db, "a", types.NewList(types.String("merged")), c2, c3)
+	db, b1 := commit(db, "b", types.String("b"))
+
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	sink, heads, err := Migrate(src, sink)
+	assert.NoError(err)
+	assert.Equal([]Head{{"a", c4.Hash(), c4.Hash()}, {"b", b1.Hash(), b1.Hash()}}, heads)
+
+	// The whole commit graph, including both sides of the merge, must have come along.
+	for _, c := range []types.Struct{c1, c2, c3, c4, b1} {
+		assert.True(c.Equals(sink.ReadValue(c.Hash())))
+	}
+	assert.True(sink.Head("a").Equals(c4))
+	assert.True(sink.Head("b").Equals(b1))
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	assert := assert.New(t)
+	src := versionedStore{chunks.NewMemoryStore(), "0"}
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	_, heads, err := Migrate(src, sink)
+	assert.Error(err)
+	assert.Empty(heads)
+}
+
+func TestMigrateRegisteredVersion(t *testing.T) {
+	assert := assert.New(t)
+	Register("test", func() Migrator { return unwrappingMigrator{} })
+
+	ms := chunks.NewMemoryStore()
+	_, c1 := commit(datas.NewDatabase(ms), "a", types.Number(1))
+
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	sink, heads, err := Migrate(versionedStore{ms, "test"}, sink)
+	assert.NoError(err)
+	assert.Equal([]Head{{"a", c1.Hash(), c1.Hash()}}, heads)
+
+	newCurrent := func() Migrator { return currentMigrator{} }
+	assert.Panics(func() { Register("test", newCurrent) })
+	assert.Panics(func() { Register(constants.NomsVersion, newCurrent) })
+}
+
+func TestMigrateExistingDataset(t *testing.T) {
+	assert := assert.New(t)
+
+	src := chunks.NewMemoryStore()
+	commit(datas.NewDatabase(src), "a", types.Number(1))
+
+	sink, _ := commit(datas.NewDatabase(chunks.NewMemoryStore()), "a", types.Number(2))
+	_, _, err := Migrate(src, sink)
+	assert.Equal(datas.ErrMergeNeeded, err)
+}
+
+func TestMigrateShallow(t *testing.T) {
+	assert := assert.New(t)
+
+	full := datas.NewDatabase(chunks.NewMemoryStore())
+	full, c1 := commit(full, "a", types.Number(1))
+	full, c2 := commit(full, "a", types.Number(2), c1)
+	full, c3 := commit(full, "a", types.Number(3), c2)
+
+	src := chunks.NewMemoryStore()
+	srcDB := datas.NewDatabase(src)
+	assert.NoError(datas.PullWithDepth(full, srcDB, types.NewRef(c3), types.Ref{}, 1, 1, nil))
+	_, err := srcDB.Commit("a", c3)
+	assert.NoError(err)
+
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	sink, heads, err := Migrate(src, sink)
+	assert.NoError(err)
+	assert.Equal([]Head{{"a", c3.Hash(), c3.Hash()}}, heads)
+	assert.True(sink.Head("a").Equals(c3))
+	assert.True(types.NewSet(types.NewRef(c3)).Equals(sink.ShallowCommits()))
+	assert.Nil(sink.ReadValue(c2.Hash()))
+}
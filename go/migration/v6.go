@@ -0,0 +1,350 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+func init() {
+	Register("6", func() Migrator { return &v6Migrator{migrated: map[hash.Hash]types.Ref{}} })
+}
+
+// v6Migrator handles data written in version 6 of the format. Version 6 lays out chunks exactly as the current version does, except that Numbers are encoded as the 8 big-endian bytes of their IEEE 754 representation rather than as a varint mantissa and exponent. Since that changes the hash of every chunk containing a Number, along with every chunk which references one, values can't simply be copied: each one is decoded, rebuilt in the current format and rechunked, with every Ref rewritten to point at the migrated copy of its target.
+type v6Migrator struct {
+	// migrated maps the hash of each chunk migrated so far in this run to a Ref to its migrated copy, so that history shared between datasets is only migrated once.
+	migrated map[hash.Hash]types.Ref
+}
+
+func (m *v6Migrator) Datasets(src chunks.ChunkStore) map[string]hash.Hash {
+	heads := map[string]hash.Hash{}
+	root := src.Root()
+	if root.IsEmpty() {
+		return heads
+	}
+
+	// The root is a Map<String, Ref<Commit>>. Rather than migrating every head just to find out its old hash, Refs are decoded as Strings holding the hash of their target.
+	dec := &v6Decoder{src: src, ref: func(h hash.Hash) types.Value { return types.String(h.String()) }}
+	dec.readChunk(root).(types.Map).IterAll(func(k, v types.Value) {
+		heads[string(k.(types.String))] = hash.Parse(string(v.(types.String)))
+	})
+	return heads
+}
+
+func (m *v6Migrator) MigrateValue(h hash.Hash, src chunks.ChunkStore, sink datas.Database) types.Value {
+	if !src.Has(h) {
+		return nil
+	}
+
+	// A chunk can only be rebuilt once everything it references has been, so chunks are migrated bottom-up from an explicit stack rather than by recursing through Refs, which would nest once per ancestor Commit. A chunk is expanded, pushing its unmigrated targets above it, the first time it reaches the top of the stack, and written the second.
+	dec := &v6Decoder{src: src, ref: func(h hash.Hash) types.Value {
+		r, ok := m.migrated[h]
+		d.PanicIfTrue(!ok, "Chunk %s referenced before it was migrated", h)
+		return r
+	}}
+	expanded := map[hash.Hash]bool{}
+	stack := hash.HashSlice{h}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if _, ok := m.migrated[top]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if !expanded[top] {
+			expanded[top] = true
+			scanRefs(src, top, func(target hash.Hash) {
+				if _, ok := m.migrated[target]; !ok && !expanded[target] {
+					stack = append(stack, target)
+				}
+			})
+			continue
+		}
+		m.migrated[top] = sink.WriteValue(dec.readChunk(top))
+		stack = stack[:len(stack)-1]
+	}
+	return sink.ReadValue(m.migrated[h].TargetHash())
+}
+
+// v6Decoder decodes version 6 chunks from src into current Values, reassembling chunked collections as it goes. Every Ref encountered is passed to ref, which decides what should take its place.
+type v6Decoder struct {
+	src chunks.ChunkStore
+	ref func(h hash.Hash) types.Value
+}
+
+func (dec *v6Decoder) readChunk(h hash.Hash) types.Value {
+	c := dec.src.Get(h)
+	d.PanicIfTrue(c.IsEmpty(), "Missing chunk %s", h)
+	r := &v6Reader{c.Data(), 0}
+	v := dec.readValue(r)
+	d.PanicIfTrue(r.offset != len(r.buff), "Trailing data in chunk %s", h)
+	return v
+}
+
+func (dec *v6Decoder) readValue(r *v6Reader) types.Value {
+	t := r.readType()
+	switch t.Kind() {
+	case types.BlobKind:
+		var data []byte
+		if r.readBool() {
+			dec.readMetaSequence(r, func(child types.Value) {
+				data = append(data, readAllBlob(child.(types.Blob))...)
+			})
+		} else {
+			data = r.readBytes()
+		}
+		return types.NewBlob(bytes.NewReader(data))
+	case types.BoolKind:
+		return types.Bool(r.readBool())
+	case types.NumberKind:
+		return r.readNumber()
+	case types.StringKind:
+		return types.String(r.readString())
+	case types.ListKind:
+		values := types.ValueSlice{}
+		if r.readBool() {
+			dec.readMetaSequence(r, func(child types.Value) {
+				child.(types.List).IterAll(func(v types.Value, i uint64) {
+					values = append(values, v)
+				})
+			})
+		} else {
+			values = dec.readValueSequence(r, 1)
+		}
+		return types.NewList(values...)
+	case types.MapKind:
+		kvs := types.ValueSlice{}
+		if r.readBool() {
+			dec.readMetaSequence(r, func(child types.Value) {
+				child.(types.Map).IterAll(func(k, v types.Value) {
+					kvs = append(kvs, k, v)
+				})
+			})
+		} else {
+			kvs = dec.readValueSequence(r, 2)
+		}
+		return types.NewMap(kvs...)
+	case types.RefKind:
+		h := r.readHash()
+		r.readUint64() // height, which is recomputed when the target is written
+		return dec.ref(h)
+	case types.SetKind:
+		values := types.ValueSlice{}
+		if r.readBool() {
+			dec.readMetaSequence(r, func(child types.Value) {
+				child.(types.Set).IterAll(func(v types.Value) {
+					values = append(values, v)
+				})
+			})
+		} else {
+			values = dec.readValueSequence(r, 1)
+		}
+		return types.NewSet(values...)
+	case types.StructKind:
+		desc := t.Desc.(types.StructDesc)
+		values := make(types.ValueSlice, desc.Len())
+		for i := range values {
+			values[i] = dec.readValue(r)
+		}
+		return types.NewStructWithType(t, values)
+	case types.TypeKind:
+		return r.readType()
+	}
+
+	panic(fmt.Sprintf("A value instance can never have type %s", types.KindToString[t.Kind()]))
+}
+
+// readValueSequence reads a leaf sequence of count items, each made up of width Values.
+func (dec *v6Decoder) readValueSequence(r *v6Reader, width int) types.ValueSlice {
+	count := int(r.readUint32()) * width
+	values := make(types.ValueSlice, count)
+	for i := range values {
+		values[i] = dec.readValue(r)
+	}
+	return values
+}
+
+// readMetaSequence decodes the child sequence referenced by each tuple of a meta sequence, in order, and passes it to cb. The tuples' keys and leaf counts only describe the old chunking, so they're discarded.
+func (dec *v6Decoder) readMetaSequence(r *v6Reader, cb func(child types.Value)) {
+	// Keys may be Refs to values which don't exist, as non-primitive keys are stored as Refs to their hash.
+	keyDec := &v6Decoder{dec.src, func(h hash.Hash) types.Value { return types.Bool(false) }}
+
+	count := r.readUint32()
+	for i := uint32(0); i < count; i++ {
+		t := r.readType()
+		d.PanicIfTrue(t.Kind() != types.RefKind, "Meta tuple must start with a Ref, not %s", t.Describe())
+		h := r.readHash()
+		r.readUint64()
+		keyDec.readValue(r)
+		r.readUint64()
+		cb(dec.readChunk(h))
+	}
+}
+
+// scanRefs calls cb with the target of every Ref in the chunk stored under h, in the order in which they're encoded. These are the Refs a v6Decoder passes to its ref callback, so the chunks of a chunked collection are scanned as part of the collection, and the keys in their meta tuples are skipped.
+func scanRefs(src chunks.ChunkStore, h hash.Hash, cb func(target hash.Hash)) {
+	c := src.Get(h)
+	d.PanicIfTrue(c.IsEmpty(), "Missing chunk %s", h)
+	r := &v6Reader{c.Data(), 0}
+	scanValue(src, r, cb)
+	d.PanicIfTrue(r.offset != len(r.buff), "Trailing data in chunk %s", h)
+}
+
+func scanValue(src chunks.ChunkStore, r *v6Reader, cb func(target hash.Hash)) {
+	t := r.readType()
+	switch t.Kind() {
+	case types.BlobKind:
+		if r.readBool() {
+			scanMetaSequence(src, r, cb)
+		} else {
+			r.readBytes()
+		}
+	case types.BoolKind:
+		r.readBool()
+	case types.NumberKind:
+		r.readNumber()
+	case types.StringKind:
+		r.readString()
+	case types.ListKind, types.SetKind, types.MapKind:
+		if r.readBool() {
+			scanMetaSequence(src, r, cb)
+			return
+		}
+		count := int(r.readUint32())
+		if t.Kind() == types.MapKind {
+			count *= 2
+		}
+		for i := 0; i < count; i++ {
+			scanValue(src, r, cb)
+		}
+	case types.RefKind:
+		cb(r.readHash())
+		r.readUint64()
+	case types.StructKind:
+		for i := 0; i < t.Desc.(types.StructDesc).Len(); i++ {
+			scanValue(src, r, cb)
+		}
+	case types.TypeKind:
+		r.readType()
+	default:
+		panic(fmt.Sprintf("A value instance can never have type %s", types.KindToString[t.Kind()]))
+	}
+}
+
+func scanMetaSequence(src chunks.ChunkStore, r *v6Reader, cb func(target hash.Hash)) {
+	count := r.readUint32()
+	for i := uint32(0); i < count; i++ {
+		t := r.readType()
+		d.PanicIfTrue(t.Kind() != types.RefKind, "Meta tuple must start with a Ref, not %s", t.Describe())
+		h := r.readHash()
+		r.readUint64()
+		scanValue(src, r, func(hash.Hash) {})
+		r.readUint64()
+		scanRefs(src, h, cb)
+	}
+}
+
+func readAllBlob(b types.Blob) []byte {
+	buff := &bytes.Buffer{}
+	_, err := buff.ReadFrom(b.Reader())
+	d.Chk.NoError(err)
+	return buff.Bytes()
+}
+
+// v6Reader reads the primitive encodings of version 6, all of which are shared with the current version apart from Numbers.
+type v6Reader struct {
+	buff   []byte
+	offset int
+}
+
+func (r *v6Reader) readUint8() uint8 {
+	v := r.buff[r.offset]
+	r.offset++
+	return v
+}
+
+func (r *v6Reader) readUint32() uint32 {
+	v := binary.BigEndian.Uint32(r.buff[r.offset:])
+	r.offset += 4
+	return v
+}
+
+func (r *v6Reader) readUint64() uint64 {
+	v := binary.BigEndian.Uint64(r.buff[r.offset:])
+	r.offset += 8
+	return v
+}
+
+func (r *v6Reader) readNumber() types.Number {
+	return types.Number(math.Float64frombits(r.readUint64()))
+}
+
+func (r *v6Reader) readBool() bool {
+	return r.readUint8() == 1
+}
+
+func (r *v6Reader) readBytes() []byte {
+	size := int(r.readUint32())
+	v := make([]byte, size)
+	copy(v, r.buff[r.offset:r.offset+size])
+	r.offset += size
+	return v
+}
+
+func (r *v6Reader) readString() string {
+	return string(r.readBytes())
+}
+
+func (r *v6Reader) readHash() hash.Hash {
+	digest := hash.Digest{}
+	copy(digest[:], r.buff[r.offset:r.offset+hash.ByteLen])
+	r.offset += hash.ByteLen
+	return hash.New(digest)
+}
+
+func (r *v6Reader) readType() *types.Type {
+	k := types.NomsKind(r.readUint8())
+	switch k {
+	case types.ListKind:
+		return types.MakeListType(r.readType())
+	case types.MapKind:
+		kt := r.readType()
+		return types.MakeMapType(kt, r.readType())
+	case types.RefKind:
+		return types.MakeRefType(r.readType())
+	case types.SetKind:
+		return types.MakeSetType(r.readType())
+	case types.StructKind:
+		name := r.readString()
+		count := r.readUint32()
+		fieldNames := make([]string, count)
+		fieldTypes := make([]*types.Type, count)
+		for i := uint32(0); i < count; i++ {
+			fieldNames[i] = r.readString()
+			fieldTypes[i] = r.readType()
+		}
+		return types.MakeStructType(name, fieldNames, fieldTypes)
+	case types.UnionKind:
+		count := r.readUint32()
+		elemTypes := make([]*types.Type, count)
+		for i := range elemTypes {
+			elemTypes[i] = r.readType()
+		}
+		return types.MakeUnionType(elemTypes...)
+	case types.CycleKind:
+		return types.MakeCycleType(r.readUint32())
+	}
+
+	d.PanicIfTrue(!types.IsPrimitiveKind(k), "Unknown kind %d", k)
+	return types.MakePrimitiveType(k)
+}
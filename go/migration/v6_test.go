@@ -0,0 +1,294 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type v6Ref struct {
+	h      hash.Hash
+	height uint64
+}
+
+// v6Writer copies values read from vr into cs, encoded as version 6 would have written them. Lists whose hash is a key of chunked are written as a meta sequence over the given leaves.
+type v6Writer struct {
+	vr      types.ValueReader
+	cs      chunks.ChunkStore
+	chunked map[hash.Hash][][]types.Value
+	written map[hash.Hash]v6Ref
+}
+
+func newV6Writer(vr types.ValueReader, cs chunks.ChunkStore) *v6Writer {
+	return &v6Writer{vr, cs, map[hash.Hash][][]types.Value{}, map[hash.Hash]v6Ref{}}
+}
+
+func (w *v6Writer) writeChunk(v types.Value) v6Ref {
+	if r, ok := w.written[v.Hash()]; ok {
+		return r
+	}
+	b := &v6Buffer{}
+	w.writeValue(b, v)
+	c := chunks.NewChunk(b.Bytes())
+	w.cs.Put(c)
+	r := v6Ref{c.Hash(), b.height + 1}
+	w.written[v.Hash()] = r
+	return r
+}
+
+func (w *v6Writer) writeRef(b *v6Buffer, t *types.Type, r v6Ref) {
+	b.writeType(t, nil)
+	b.Write(r.h.DigestSlice())
+	b.writeUint64(r.height)
+	if r.height > b.height {
+		b.height = r.height
+	}
+}
+
+func (w *v6Writer) writeValue(b *v6Buffer, v types.Value) {
+	t := v.Type()
+	switch v := v.(type) {
+	case types.Bool:
+		b.writeType(t, nil)
+		b.writeBool(bool(v))
+	case types.Number:
+		b.writeType(t, nil)
+		b.writeUint64(math.Float64bits(float64(v)))
+	case types.String:
+		b.writeType(t, nil)
+		b.writeString(string(v))
+	case types.Blob:
+		b.writeType(t, nil)
+		b.writeBool(false)
+		data := &bytes.Buffer{}
+		data.ReadFrom(v.Reader())
+		b.writeUint32(uint32(data.Len()))
+		b.Write(data.Bytes())
+	case types.Ref:
+		w.writeRef(b, t, w.writeChunk(v.TargetValue(w.vr)))
+	case types.List:
+		if leaves, ok := w.chunked[v.Hash()]; ok {
+			b.writeType(t, nil)
+			b.writeBool(true)
+			b.writeUint32(uint32(len(leaves)))
+			for _, leaf := range leaves {
+				w.writeRef(b, types.MakeRefType(t), w.writeChunk(types.NewList(leaf...)))
+				w.writeValue(b, types.Number(len(leaf)))
+				b.writeUint64(uint64(len(leaf)))
+			}
+			return
+		}
+		values := []types.Value{}
+		v.IterAll(func(v types.Value, i uint64) {
+			values = append(values, v)
+		})
+		w.writeLeaf(b, t, values)
+	case types.Set:
+		values := []types.Value{}
+		v.IterAll(func(v types.Value) {
+			values = append(values, v)
+		})
+		w.writeLeaf(b, t, values)
+	case types.Map:
+		b.writeType(t, nil)
+		b.writeBool(false)
+		b.writeUint32(uint32(v.Len()))
+		v.IterAll(func(k, v types.Value) {
+			w.writeValue(b, k)
+			w.writeValue(b, v)
+		})
+	case types.Struct:
+		b.writeType(t, nil)
+		t.Desc.(types.StructDesc).IterFields(func(name string, _ *types.Type) {
+			w.writeValue(b, v.Get(name))
+		})
+	default:
+		panic("unsupported value")
+	}
+}
+
+func (w *v6Writer) writeLeaf(b *v6Buffer, t *types.Type, values []types.Value) {
+	encoded := make([][]byte, len(values))
+	for i, v := range values {
+		vb := &v6Buffer{}
+		w.writeValue(vb, v)
+		encoded[i] = vb.Bytes()
+		if vb.height > b.height {
+			b.height = vb.height
+		}
+	}
+	if t.Kind() == types.SetKind {
+		// Sets of Refs are ordered by target hash, and the targets' hashes have changed. An encoded Ref is its type followed by its target hash, so sorting the encodings restores the old order.
+		sort.Sort(byteSlices(encoded))
+	}
+
+	b.writeType(t, nil)
+	b.writeBool(false)
+	b.writeUint32(uint32(len(values)))
+	for _, e := range encoded {
+		b.Write(e)
+	}
+}
+
+type byteSlices [][]byte
+
+func (s byteSlices) Len() int           { return len(s) }
+func (s byteSlices) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byteSlices) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
+
+// v6Buffer accumulates an encoded chunk, along with the tallest Ref written to it.
+type v6Buffer struct {
+	bytes.Buffer
+	height uint64
+}
+
+func (b *v6Buffer) writeUint32(v uint32) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func (b *v6Buffer) writeUint64(v uint64) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func (b *v6Buffer) writeBool(v bool) {
+	if v {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+}
+
+func (b *v6Buffer) writeString(v string) {
+	b.writeUint32(uint32(len(v)))
+	b.WriteString(v)
+}
+
+func (b *v6Buffer) writeType(t *types.Type, parentStructTypes []*types.Type) {
+	switch k := t.Kind(); k {
+	case types.ListKind, types.MapKind, types.RefKind, types.SetKind:
+		b.WriteByte(byte(k))
+		for _, et := range t.Desc.(types.CompoundDesc).ElemTypes {
+			b.writeType(et, parentStructTypes)
+		}
+	case types.UnionKind:
+		b.WriteByte(byte(k))
+		elemTypes := t.Desc.(types.CompoundDesc).ElemTypes
+		b.writeUint32(uint32(len(elemTypes)))
+		for _, et := range elemTypes {
+			b.writeType(et, parentStructTypes)
+		}
+	case types.StructKind:
+		for i, pt := range parentStructTypes {
+			if pt == t {
+				b.WriteByte(byte(types.CycleKind))
+				b.writeUint32(uint32(len(parentStructTypes) - i - 1))
+				return
+			}
+		}
+		parentStructTypes = append(parentStructTypes, t)
+		desc := t.Desc.(types.StructDesc)
+		b.WriteByte(byte(k))
+		b.writeString(desc.Name)
+		b.writeUint32(uint32(desc.Len()))
+		desc.IterFields(func(name string, ft *types.Type) {
+			b.writeString(name)
+			b.writeType(ft, parentStructTypes)
+		})
+	case types.CycleKind:
+		b.WriteByte(byte(k))
+		b.writeUint32(uint32(t.Desc.(types.CycleDesc)))
+	default:
+		b.WriteByte(byte(k))
+	}
+}
+
+func TestMigrateV6(t *testing.T) {
+	assert := assert.New(t)
+
+	// The expected results are built directly in the current format.
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	db, c1 := commit(db, "a", types.Number(1.5))
+	db, c2 := commit(db, "a", types.NewMap(types.String("n"), types.Number(-0.25), types.String("big"), types.Number(1e20)), c1)
+	db, _ = db.Delete("a")
+	db, c3 := commit(db, "a", types.NewMap(types.String("n"), types.Number(3.5)), c1)
+	chunkedList := types.NewList(types.Number(1), types.Number(2), types.Number(3.25), types.Number(4), types.Number(5))
+	db, c4 := commit(db, "a", chunkedList, c2, c3)
+	db, b1 := commit(db, "b", types.NewStruct("S", types.StructData{"x": types.Number(math.Pi), "y": types.NewBlob(bytes.NewBufferString("blob")), "z": types.NewSet(types.Number(3), types.String("three"))}))
+
+	// Copy them into a version 6 store. The List is split in two, as though it had been chunked.
+	ms := chunks.NewMemoryStore()
+	w := newV6Writer(db, ms)
+	w.chunked[chunkedList.Hash()] = [][]types.Value{
+		{types.Number(1), types.Number(2)},
+		{types.Number(3.25), types.Number(4), types.Number(5)},
+	}
+	root := w.writeChunk(types.NewMap(types.String("a"), types.NewRef(c4), types.String("b"), types.NewRef(b1)))
+	assert.True(ms.UpdateRoot(root.h, hash.Hash{}))
+
+	oldA, oldB := w.written[c4.Hash()].h, w.written[b1.Hash()].h
+	assert.NotEqual(c4.Hash(), oldA)
+	assert.NotEqual(b1.Hash(), oldB)
+
+	src := versionedStore{ms, "6"}
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	sink, heads, err := Migrate(src, sink)
+	assert.NoError(err)
+	assert.Equal([]Head{{"a", oldA, c4.Hash()}, {"b", oldB, b1.Hash()}}, heads)
+
+	for _, c := range []types.Struct{c1, c2, c3, c4, b1} {
+		assert.True(c.Equals(sink.ReadValue(c.Hash())))
+	}
+	assert.True(sink.Head("a").Get(datas.ValueField).Equals(chunkedList))
+	assert.True(sink.Head("b").Equals(b1))
+}
+
+// countingStore counts the reads of each chunk in the ChunkStore it wraps.
+type countingStore struct {
+	chunks.ChunkStore
+	gets map[hash.Hash]int
+}
+
+func (s countingStore) Get(h hash.Hash) chunks.Chunk {
+	s.gets[h]++
+	return s.ChunkStore.Get(h)
+}
+
+func TestMigrateV6SharedHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	db, c1 := commit(db, "a", types.Number(1))
+	parent := c1
+	for i := 2; i <= 1000; i++ {
+		db, parent = commit(db, "a", types.Number(i), parent)
+	}
+	db, b2 := commit(db, "b", types.Number(-1), c1)
+
+	ms := chunks.NewMemoryStore()
+	w := newV6Writer(db, ms)
+	root := w.writeChunk(types.NewMap(types.String("a"), types.NewRef(parent), types.String("b"), types.NewRef(b2)))
+	assert.True(ms.UpdateRoot(root.h, hash.Hash{}))
+
+	src := countingStore{versionedStore{ms, "6"}, map[hash.Hash]int{}}
+	sink := datas.NewDatabase(chunks.NewMemoryStore())
+	sink, heads, err := Migrate(src, sink)
+	assert.NoError(err)
+	assert.Equal([]Head{{"a", w.written[parent.Hash()].h, parent.Hash()}, {"b", w.written[b2.Hash()].h, b2.Hash()}}, heads)
+	assert.True(sink.Head("b").Equals(b2))
+	assert.True(c1.Equals(sink.ReadValue(c1.Hash())))
+
+	// Each chunk is read once to find what it references and once to decode it, however many datasets share it.
+	assert.Equal(2, src.gets[w.written[c1.Hash()].h])
+}
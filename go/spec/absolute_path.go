@@ -8,6 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
@@ -18,10 +21,133 @@ import (
 
 var datasetCapturePrefixRe = regexp.MustCompile("^(" + dataset.DatasetRe.String() + ")")
 
+// AbsolutePath names a value in a Database: the head of a dataset, or the value with a given hash, or with the only hash in the Database that starts with a given prefix, then optionally some steps back through the history of that Commit, and then optionally a path into the resulting value.
+// The steps follow git's spelling. "^" is the first parent and "^n" the nth, in the order of the Commit's parents Set; "^0" is the Commit itself. "~n" goes back n generations by way of first parents, and "~" is "~1". "@{date}" goes back by way of first parents to the first Commit whose meta has a "date" field at or before date. See commitDateLayouts for the accepted forms of date.
 type AbsolutePath struct {
-	dataset string
-	hash    hash.Hash
-	path    types.Path
+	dataset    string
+	hash       hash.Hash
+	hashPrefix string
+	steps      []commitStep
+	path       types.Path
+}
+
+// minHashPrefixLen is the shortest prefix of a hash that an AbsolutePath accepts in place of the whole hash.
+const minHashPrefixLen = 4
+
+// commitDateLayouts are the forms of date accepted in an "@{date}" step, and in the "date" field of commit meta. Those without a time zone are in the local one.
+var commitDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseCommitDate parses str in one of the forms of commitDateLayouts, as the "date" field of commit meta is written by csv-import and url-fetch, or as a person would write a date; those without a time zone are in the local one. It's how the dates of commits are read everywhere, so that they're all read alike.
+func ParseCommitDate(str string) (time.Time, bool) {
+	for _, layout := range commitDateLayouts {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// commitStep is one of the steps back through history that can follow the dataset or hash of an AbsolutePath.
+type commitStep struct {
+	// op is '^', '~' or '@'.
+	op   byte
+	n    int
+	date time.Time
+	// str is how the step was spelled.
+	str string
+}
+
+// parseCommitSteps parses the steps at the start of str, returning them and the rest of str.
+func parseCommitSteps(str string) (steps []commitStep, rest string, err error) {
+	for len(str) > 0 {
+		step := commitStep{op: str[0]}
+		switch step.op {
+		case '^', '~':
+			digits := len(str) - len(strings.TrimLeft(str[1:], "0123456789")) - 1
+			step.n = 1
+			if digits > 0 {
+				if step.n, err = strconv.Atoi(str[1 : 1+digits]); err != nil {
+					return nil, "", fmt.Errorf("Invalid commit step: %s", str[:1+digits])
+				}
+			}
+			step.str = str[:1+digits]
+		case '@':
+			end := strings.IndexByte(str, '}')
+			if !strings.HasPrefix(str, "@{") || end < 0 {
+				return nil, "", fmt.Errorf("Invalid date: %s", str[1:])
+			}
+			var ok bool
+			if step.date, ok = ParseCommitDate(str[2:end]); !ok {
+				return nil, "", fmt.Errorf("Invalid date: %s", str[2:end])
+			}
+			step.str = str[:end+1]
+		default:
+			return steps, str, nil
+		}
+		steps = append(steps, step)
+		str = str[len(step.str):]
+	}
+	return steps, str, nil
+}
+
+// resolve takes this step back from commit, returning nil if commit isn't a Commit or there's nowhere to go.
+func (step commitStep) resolve(commit types.Value, vr types.ValueReader) types.Value {
+	parent := func(c types.Value, n int) types.Value {
+		s, ok := c.(types.Struct)
+		if !ok || !datas.IsCommitType(s.Type()) {
+			return nil
+		}
+		if n == 0 {
+			return c
+		}
+		parents := s.Get(datas.ParentsField).(types.Set)
+		if uint64(n) > parents.Len() {
+			return nil
+		}
+		i := 0
+		var p types.Value
+		parents.IterAll(func(v types.Value) {
+			if i++; i == n {
+				p = v.(types.Ref).TargetValue(vr)
+			}
+		})
+		return p
+	}
+
+	switch step.op {
+	case '^':
+		return parent(commit, step.n)
+	case '~':
+		for i := 0; i < step.n && commit != nil; i++ {
+			commit = parent(commit, 1)
+		}
+		return commit
+	case '@':
+		for ; commit != nil; commit = parent(commit, 1) {
+			if t, ok := commitDate(commit); ok && !t.After(step.date) {
+				return commit
+			}
+		}
+		return nil
+	}
+	panic("unreachable")
+}
+
+// commitDate returns the time in the "date" field of commit's meta, if there is one.
+func commitDate(commit types.Value) (time.Time, bool) {
+	s, ok := commit.(types.Struct)
+	if !ok || !datas.IsCommitType(s.Type()) {
+		return time.Time{}, false
+	}
+	meta, ok := s.Get(datas.MetaField).(types.Struct)
+	if !ok {
+		return time.Time{}, false
+	}
+	date, ok := meta.MaybeGet("date")
+	if !ok || date.Type().Kind() != types.StringKind {
+		return time.Time{}, false
+	}
+	return ParseCommitDate(string(date.(types.String)))
 }
 
 func NewAbsolutePath(str string) (AbsolutePath, error) {
@@ -30,23 +156,22 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 	}
 
 	var h hash.Hash
+	var hashPrefix string
 	var dataset string
 	var pathStr string
 
 	if str[0] == '#' {
 		tail := str[1:]
-		if len(tail) < hash.StringLen {
-			return AbsolutePath{}, errors.New("Invalid hash: " + tail)
-		}
-
-		hashStr := tail[:hash.StringLen]
-		if h2, ok := hash.MaybeParse(hashStr); ok {
-			h = h2
+		n := len(tail) - len(strings.TrimLeft(tail, "0123456789abcdefghijklmnopqrstuv"))
+		if n >= hash.StringLen {
+			h = hash.Parse(tail[:hash.StringLen])
+			pathStr = tail[hash.StringLen:]
+		} else if n >= minHashPrefixLen {
+			hashPrefix = tail[:n]
+			pathStr = tail[n:]
 		} else {
-			return AbsolutePath{}, errors.New("Invalid hash: " + hashStr)
+			return AbsolutePath{}, errors.New("Invalid hash: " + tail)
 		}
-
-		pathStr = tail[hash.StringLen:]
 	} else {
 		datasetParts := datasetCapturePrefixRe.FindStringSubmatch(str)
 		if datasetParts == nil {
@@ -57,8 +182,13 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 		pathStr = str[len(dataset):]
 	}
 
+	steps, pathStr, err := parseCommitSteps(pathStr)
+	if err != nil {
+		return AbsolutePath{}, err
+	}
+
 	if len(pathStr) == 0 {
-		return AbsolutePath{hash: h, dataset: dataset}, nil
+		return AbsolutePath{hash: h, hashPrefix: hashPrefix, dataset: dataset, steps: steps}, nil
 	}
 
 	path, err := types.ParsePath(pathStr)
@@ -66,10 +196,11 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 		return AbsolutePath{}, err
 	}
 
-	return AbsolutePath{hash: h, dataset: dataset, path: path}, nil
+	return AbsolutePath{hash: h, hashPrefix: hashPrefix, dataset: dataset, steps: steps, path: path}, nil
 }
 
-func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
+// Resolve returns the value that p names in db, or nil if there isn't one. It returns an error if p names a value by a prefix of its hash which more than one hash in db starts with, or db can't look hashes up by prefix.
+func (p AbsolutePath) Resolve(db datas.Database) (val types.Value, err error) {
 	if len(p.dataset) > 0 {
 		var ok bool
 		if val, ok = db.MaybeHead(p.dataset); !ok {
@@ -77,14 +208,32 @@ func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
 		}
 	} else if !p.hash.IsEmpty() {
 		val = db.ReadValue(p.hash)
+	} else if len(p.hashPrefix) > 0 {
+		hashes, err := db.HashesWithPrefix(p.hashPrefix, 2)
+		if err != nil {
+			return nil, err
+		}
+		if len(hashes) > 1 {
+			return nil, fmt.Errorf("Ambiguous hash: %s could be %s or %s, at least", p.hashPrefix, hashes[0], hashes[1])
+		}
+		if len(hashes) == 1 {
+			val = db.ReadValue(hashes[0])
+		}
 	} else {
 		d.Chk.Fail("Unreachable")
 	}
 
+	for _, step := range p.steps {
+		if val == nil {
+			break
+		}
+		val = step.resolve(val, db)
+	}
+
 	if val != nil && p.path != nil {
 		val = p.path.Resolve(val)
 	}
-	return
+	return val, nil
 }
 
 func (p AbsolutePath) String() (str string) {
@@ -92,9 +241,14 @@ func (p AbsolutePath) String() (str string) {
 		str = p.dataset
 	} else if !p.hash.IsEmpty() {
 		str = "#" + p.hash.String()
+	} else if len(p.hashPrefix) > 0 {
+		str = "#" + p.hashPrefix
 	} else {
 		d.Chk.Fail("Unreachable")
 	}
 
+	for _, step := range p.steps {
+		str += step.str
+	}
 	return str + p.path.String()
 }
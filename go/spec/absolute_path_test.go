@@ -28,6 +28,79 @@ func TestAbsolutePathToAndFromString(t *testing.T) {
 	h := types.Number(42).Hash() // arbitrary hash
 	test(fmt.Sprintf("foo.bar[#%s]", h.String()))
 	test(fmt.Sprintf("#%s.bar[42]", h.String()))
+	test("foo^")
+	test("foo^2~3.value")
+	test("foo~@{2016-08-01}^0")
+	test(fmt.Sprintf("#%s~~2[1]", h.String()))
+	test("#" + h.Abbrev())
+	test(fmt.Sprintf("#%s^.value[0]", h.String()[:minHashPrefixLen]))
+}
+
+func TestAbsolutePathCommitSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	meta := func(date string) types.Struct {
+		return types.NewStruct("Meta", types.StructData{"date": types.String(date)})
+	}
+	commit := func(v types.Value, date string, parents ...types.Value) types.Ref {
+		var err error
+		db, err = db.Commit("ds", datas.NewCommit(v, types.NewSet(parents...), meta(date)))
+		assert.NoError(err)
+		return db.HeadRef("ds")
+	}
+
+	c1 := commit(types.Number(1), "2016-07-01T12:00:00Z")
+	c2 := commit(types.Number(2), "2016-07-20T12:00:00Z", c1)
+	c3 := commit(types.Number(3), "2016-08-10T12:00:00Z", c2)
+
+	// A Commit on another dataset, which c4 merges in.
+	db, err := db.Commit("other", datas.NewCommit(types.Number(10), types.NewSet(), meta("2016-07-05T12:00:00Z")))
+	assert.NoError(err)
+	other := db.HeadRef("other")
+	c4 := commit(types.Number(4), "2016-08-20T12:00:00Z", c3, other)
+
+	// The parents of c4 are numbered in the order of its parents Set.
+	first, second := c3, other
+	if !types.NewSet(c3, other).First().Equals(c3) {
+		first, second = other, c3
+	}
+
+	resolvesTo := func(exp types.Value, str string) {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		act, err := p.Resolve(db)
+		assert.NoError(err)
+		if exp == nil {
+			assert.Nil(act, str)
+		} else {
+			assert.True(exp.Equals(act), "%s Expected %s Actual %s", str, types.EncodedValue(exp), types.EncodedValue(act))
+		}
+	}
+	value := func(r types.Ref) types.Value {
+		return r.TargetValue(db)
+	}
+
+	resolvesTo(value(c4), "ds^0")
+	resolvesTo(value(first), "ds^")
+	resolvesTo(value(first), "ds^1")
+	resolvesTo(value(second), "ds^2")
+	resolvesTo(nil, "ds^3")
+	resolvesTo(value(c1), "ds~3")
+	resolvesTo(value(c1), "#"+c3.TargetHash().String()+"~2")
+	resolvesTo(value(c2), "#"+c3.TargetHash().String()+"~")
+	resolvesTo(types.Number(1), "#"+c3.TargetHash().String()+"^^.value")
+	resolvesTo(nil, "#"+c3.TargetHash().String()+"~3")
+
+	if first.Equals(c3) {
+		resolvesTo(value(c1), "ds~2^")
+		resolvesTo(value(c2), "ds@{2016-08-01}")
+		resolvesTo(value(c3), "ds@{2016-08-15 00:00:00}")
+	}
+	resolvesTo(value(c2), "#"+c3.TargetHash().String()+"@{2016-08-01}")
+	resolvesTo(value(c1), "#"+c3.TargetHash().String()+"@{2016-07-20T11:00:00Z}")
+	resolvesTo(types.Number(2), "#"+c3.TargetHash().String()+"@{2016-07-20T12:00:00Z}.value")
+	resolvesTo(nil, "#"+c3.TargetHash().String()+"@{2016-06-01}")
 }
 
 func TestAbsolutePaths(t *testing.T) {
@@ -51,7 +124,8 @@ func TestAbsolutePaths(t *testing.T) {
 	resolvesTo := func(exp types.Value, str string) {
 		p, err := NewAbsolutePath(str)
 		assert.NoError(err)
-		act := p.Resolve(db)
+		act, err := p.Resolve(db)
+		assert.NoError(err)
 		if exp == nil {
 			assert.Nil(act)
 		} else {
@@ -79,6 +153,54 @@ func TestAbsolutePaths(t *testing.T) {
 	resolvesTo(nil, "#"+types.String("baz").Hash().String()+"[0]")
 }
 
+func TestAbsolutePathHashPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	// Find two values whose hashes start the same way.
+	seen := map[string]types.Value{}
+	var a, b types.Value
+	for i := 0; a == nil; i++ {
+		v := types.Number(i)
+		prefix := v.Hash().String()[:minHashPrefixLen]
+		if other, ok := seen[prefix]; ok {
+			a, b = other, v
+		}
+		seen[prefix] = v
+	}
+	list := types.NewList(a)
+
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	db.WriteValue(a)
+	db.WriteValue(b)
+	db.WriteValue(list)
+
+	resolve := func(str string) (types.Value, error) {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		return p.Resolve(db)
+	}
+
+	v, err := resolve("#" + list.Hash().Abbrev())
+	assert.NoError(err)
+	assert.True(list.Equals(v))
+	v, err = resolve("#" + list.Hash().Abbrev() + "[0]")
+	assert.NoError(err)
+	assert.True(a.Equals(v))
+	v, err = resolve("#" + a.Hash().Abbrev())
+	assert.NoError(err)
+	assert.True(a.Equals(v))
+
+	v, err = resolve("#" + a.Hash().String()[:minHashPrefixLen])
+	assert.Nil(v)
+	assert.Error(err)
+	assert.Contains(err.Error(), "Ambiguous hash")
+
+	v, err = resolve("#" + types.String("absent").Hash().Abbrev())
+	assert.NoError(err)
+	assert.Nil(v)
+}
+
 func TestAbsolutePathParseErrors(t *testing.T) {
 	assert := assert.New(t)
 
@@ -96,4 +218,8 @@ func TestAbsolutePathParseErrors(t *testing.T) {
 	test("#abc", "Invalid hash: abc")
 	invHash := strings.Repeat("z", hash.StringLen)
 	test("#"+invHash, "Invalid hash: "+invHash)
+	test("foo@{yesterday}", "Invalid date: yesterday")
+	test("foo@{2016-08-01", "Invalid date: {2016-08-01")
+	test("foo@2016", "Invalid date: 2016")
+	test("foo.value^", "Invalid operator: ^")
 }
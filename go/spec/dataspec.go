@@ -5,9 +5,11 @@
 package spec
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -38,14 +40,11 @@ func GetChunkStore(str string) (chunks.ChunkStore, error) {
 		return nil, err
 	}
 
-	switch sp.Protocol {
-	case "ldb":
-		return getLDBStore(sp.Path), nil
-	case "mem":
-		return chunks.NewMemoryStore(), nil
-	default:
+	f, ok := lookupProtocol(sp.Protocol)
+	if !ok {
 		return nil, fmt.Errorf("Unable to create chunkstore for protocol: %s", str)
 	}
+	return f(sp.Path)
 }
 
 func GetDataset(str string) (dataset.Dataset, error) {
@@ -64,10 +63,27 @@ func GetPath(str string) (datas.Database, types.Value, error) {
 	return sp.Value()
 }
 
+// GetDatasetPath parses str as a dataset followed by a path, e.g. "ldb:/tmp/db::ds.value.name", and returns the dataset and the path, which is relative to its head Commit. Unlike GetPath, str can't name a hash or step back through history, because only the head of a dataset can be built upon.
+func GetDatasetPath(str string) (dataset.Dataset, types.Path, error) {
+	sp, err := parsePathSpec(str)
+	if err != nil {
+		return dataset.Dataset{}, nil, err
+	}
+	if sp.Path.dataset == "" || len(sp.Path.steps) > 0 {
+		return dataset.Dataset{}, nil, fmt.Errorf("Not a dataset followed by a path: %s", str)
+	}
+	ds, err := datasetSpec{sp.DbSpec, sp.Path.dataset}.Dataset()
+	if err != nil {
+		return dataset.Dataset{}, nil, err
+	}
+	return ds, sp.Path.path, nil
+}
+
 type databaseSpec struct {
-	Protocol    string
-	Path        string
-	accessToken string
+	Protocol       string
+	Path           string
+	accessToken    string
+	cacheableReads bool
 }
 
 type datasetSpec struct {
@@ -109,7 +125,13 @@ func parseDatabaseSpec(spec string) (databaseSpec, error) {
 			return databaseSpec{}, fmt.Errorf("Invalid URL: %s", spec)
 		}
 		token := u.Query().Get("access_token")
-		return databaseSpec{Protocol: protocol, Path: path, accessToken: token}, nil
+		cacheableReads := false
+		if str := u.Query().Get("cacheable_reads"); str != "" {
+			if cacheableReads, err = strconv.ParseBool(str); err != nil {
+				return databaseSpec{}, fmt.Errorf("Invalid cacheable_reads: %s", str)
+			}
+		}
+		return databaseSpec{Protocol: protocol, Path: path, accessToken: token, cacheableReads: cacheableReads}, nil
 
 	case "ldb":
 		return ldbDatabaseSpec(path)
@@ -118,7 +140,13 @@ func parseDatabaseSpec(spec string) (databaseSpec, error) {
 		return databaseSpec{}, fmt.Errorf(`In-memory database must be specified as "mem", not "mem:%s"`, path)
 
 	default:
-		return databaseSpec{}, fmt.Errorf("Invalid database protocol: %s", spec)
+		if _, ok := lookupProtocol(protocol); !ok {
+			return databaseSpec{}, fmt.Errorf("Invalid database protocol: %s", spec)
+		}
+		if len(path) == 0 {
+			return databaseSpec{}, fmt.Errorf("Empty path for database protocol: %s", spec)
+		}
+		return databaseSpec{Protocol: protocol, Path: path}, nil
 	}
 }
 
@@ -170,17 +198,26 @@ func (s databaseSpec) String() string {
 func (spec databaseSpec) Database() (ds datas.Database, err error) {
 	switch spec.Protocol {
 	case "http", "https":
+		var tlsConfig *tls.Config
+		if tlsConfig, err = getTLSConfig(); err != nil {
+			return
+		}
+		auth := ""
+		if spec.accessToken != "" {
+			auth = "Bearer " + spec.accessToken
+		}
 		err = d.Unwrap(d.Try(func() {
-			ds = datas.NewRemoteDatabase(spec.String(), "Bearer "+spec.accessToken)
-		}))
-	case "ldb":
-		err = d.Unwrap(d.Try(func() {
-			ds = datas.NewDatabase(getLDBStore(spec.Path))
+			ds = datas.NewRemoteDatabaseWithOptions(spec.String(), auth, datas.RemoteOptions{TLSConfig: tlsConfig, CacheableReads: spec.cacheableReads})
 		}))
-	case "mem":
-		ds = datas.NewDatabase(chunks.NewMemoryStore())
 	default:
-		err = fmt.Errorf("Invalid path prototocol: %s", spec.Protocol)
+		f, ok := lookupProtocol(spec.Protocol)
+		if !ok {
+			return nil, fmt.Errorf("Invalid path prototocol: %s", spec.Protocol)
+		}
+		var cs chunks.ChunkStore
+		if cs, err = f(spec.Path); err == nil {
+			ds = datas.NewDatabase(cs)
+		}
 	}
 	return
 }
@@ -219,12 +256,16 @@ func (spec pathSpec) Value() (db datas.Database, val types.Value, err error) {
 		return
 	}
 
-	val = spec.Path.Resolve(db)
+	if val, err = spec.Path.Resolve(db); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
 	return
 }
 
 func RegisterDatabaseFlags(flags *flag.FlagSet) {
 	chunks.RegisterLevelDBFlags(flags)
+	registerTLSFlags(flags)
 }
 
 func CreateDatabaseSpecString(protocol, path string) string {
@@ -239,15 +280,23 @@ func CreateHashSpecString(protocol, path string, h hash.Hash) string {
 	return fmt.Sprintf("%s:%s::#%s", protocol, path, h.String())
 }
 
+// getLDBStore opens the LevelDB database at path, sharing a single store between all callers in this process. If another process already has the database open, the returned ChunkStore talks to it over the socket it serves instead. Such a ChunkStore only works for as long as that process keeps the database open; after that, it fails every call, and the database has to be opened again, which then opens the LevelDB itself.
 func getLDBStore(path string) chunks.ChunkStore {
 	if store, ok := ldbStores[path]; ok {
 		store.AddRef()
 		return store
 	}
 
-	store := newRefCountingLdbStore(path, func() {
-		delete(ldbStores, path)
-	})
+	var store *refCountingLdbStore
+	if err := d.Try(func() {
+		store = newRefCountingLdbStore(path, func() {
+			delete(ldbStores, path)
+		})
+	}, chunks.LevelDBLockedError{}); err != nil {
+		cs, sockErr := datas.NewLocalSocketChunkStore(ldbSocketPath(path))
+		d.PanicIfTrue(sockErr != nil, "%s, and it can't be reached via %s: %s", err, ldbSocketPath(path), sockErr)
+		return cs
+	}
 	ldbStores[path] = store
 	return store
 }
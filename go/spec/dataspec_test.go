@@ -44,6 +44,45 @@ func TestLDBDatabase(t *testing.T) {
 	os.Remove(dir)
 }
 
+func TestLDBDatabaseSharedBetweenProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	spec := fmt.Sprintf("ldb:%s::ds", dir)
+
+	owner, err := GetDataset(spec)
+	assert.NoError(err)
+	owner, err = owner.CommitValue(types.String("from owner"))
+	assert.NoError(err)
+
+	// Hide the owner's store, so that the next open behaves as though it were in a different process.
+	ownerStore := ldbStores[dir]
+	delete(ldbStores, dir)
+	other, err := GetDataset(spec)
+	ldbStores[dir] = ownerStore
+	assert.NoError(err)
+	assert.Equal(1, ldbStores[dir].refCount, "The other Dataset must not share the owner's store")
+
+	assert.True(other.HeadValue().Equals(types.String("from owner")))
+	other, err = other.CommitValue(types.String("from other"))
+	assert.NoError(err)
+	db, err := GetDatabase("ldb:" + dir)
+	assert.NoError(err)
+	assert.True(db.Head("ds").Get(datas.ValueField).Equals(types.String("from other")))
+	db.Close()
+
+	// The owner's Dataset is now out of date, so its commit must not clobber the other's.
+	_, err = owner.CommitValue(types.String("clobber"))
+	assert.Equal(datas.ErrMergeNeeded, err)
+
+	other.Database().Close()
+	owner.Database().Close()
+	_, err = os.Stat(ldbSocketPath(dir))
+	assert.True(os.IsNotExist(err))
+}
+
 func TestMemDatabase(t *testing.T) {
 	assert := assert.New(t)
 
@@ -192,6 +231,12 @@ func TestDatabaseSpecs(t *testing.T) {
 		assert.NoError(err)
 		assert.Equal(databaseSpec{Protocol: tc.scheme, Path: tc.path, accessToken: tc.accessToken}, dbSpec)
 	}
+
+	dbSpec, err := parseDatabaseSpec("http://server.com/john/doe?cacheable_reads=true")
+	assert.NoError(err)
+	assert.True(dbSpec.cacheableReads)
+	_, err = parseDatabaseSpec("http://server.com/john/doe?cacheable_reads=maybe")
+	assert.Error(err)
 }
 
 func TestDatasetSpecs(t *testing.T) {
@@ -268,3 +313,25 @@ func TestPathSpec(t *testing.T) {
 		assert.Equal(expected, actual)
 	}
 }
+
+func TestGetDatasetPath(t *testing.T) {
+	assert := assert.New(t)
+
+	ds, p, err := GetDatasetPath("mem::ds.value[1]")
+	assert.NoError(err)
+	assert.Equal("ds", ds.ID())
+	assert.Equal(".value[1]", p.String())
+	ds.Database().Close()
+
+	ds, p, err = GetDatasetPath("mem::ds")
+	assert.NoError(err)
+	assert.Equal("ds", ds.ID())
+	assert.Empty(p)
+	ds.Database().Close()
+
+	badSpecs := []string{"mem", "mem::", "mem::#0123456789abcdefghijklmnopqrstuv.value", "mem::ds^.value", "mem::ds.value["}
+	for _, bs := range badSpecs {
+		_, _, err := GetDatasetPath(bs)
+		assert.Error(err, bs)
+	}
+}
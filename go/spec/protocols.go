@@ -0,0 +1,70 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ProtocolFunc creates a ChunkStore from the path portion of a database spec, i.e. everything following "<protocol>:".
+type ProtocolFunc func(path string) (chunks.ChunkStore, error)
+
+var (
+	protocols   = map[string]ProtocolFunc{}
+	protocolsMu = &sync.RWMutex{}
+)
+
+func init() {
+	RegisterProtocol("ldb", func(path string) (cs chunks.ChunkStore, err error) {
+		err = d.Unwrap(d.Try(func() { cs = getLDBStore(path) }))
+		return
+	})
+	RegisterProtocol("mem", func(path string) (chunks.ChunkStore, error) {
+		return chunks.NewMemoryStore(), nil
+	})
+	RegisterProtocol("dynamo", getDynamoStore)
+}
+
+// RegisterProtocol makes the ChunkStores created by f addressable from database, dataset and path specs of the form "<name>:<path>". It panics if name is "http" or "https", which are served by remote databases, or if name is already registered.
+func RegisterProtocol(name string, f ProtocolFunc) {
+	d.PanicIfTrue(name == "" || strings.ContainsAny(name, ":/"), "Invalid protocol name: %s", name)
+	d.PanicIfTrue(name == "http" || name == "https", "Protocol %s is reserved for remote databases", name)
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+	_, present := protocols[name]
+	d.PanicIfTrue(present, "Protocol %s is already registered", name)
+	protocols[name] = f
+}
+
+func lookupProtocol(name string) (ProtocolFunc, bool) {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	f, ok := protocols[name]
+	return f, ok
+}
+
+// getDynamoStore creates a DynamoStore from a path of the form "table/namespace", where namespace may be omitted. The AWS region and credentials are taken from the environment, as with the AWS command line tools; it's an error if AWS_REGION isn't set, rather than guessing a region.
+func getDynamoStore(path string) (chunks.ChunkStore, error) {
+	parts := strings.SplitN(path, "/", 2)
+	table, namespace := parts[0], ""
+	if len(parts) == 2 {
+		namespace = parts[1]
+	}
+	if table == "" {
+		return nil, fmt.Errorf("Missing DynamoDB table name: %s", path)
+	}
+
+	if os.Getenv("AWS_REGION") == "" {
+		return nil, fmt.Errorf("AWS_REGION must be set to the region of DynamoDB table %s", table)
+	}
+	return chunks.NewDynamoStore(table, namespace, aws.NewConfig(), false), nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestRegisterProtocol(t *testing.T) {
+	assert := assert.New(t)
+
+	stores := map[string]*chunks.MemoryStore{}
+	RegisterProtocol("testproto", func(path string) (chunks.ChunkStore, error) {
+		if path == "broken" {
+			return nil, errors.New("broken store")
+		}
+		if _, ok := stores[path]; !ok {
+			stores[path] = chunks.NewMemoryStore()
+		}
+		return stores[path], nil
+	})
+
+	ds, err := GetDataset("testproto:a/b::ds")
+	assert.NoError(err)
+	ds, err = ds.CommitValue(types.String("hi"))
+	assert.NoError(err)
+	assert.False(stores["a/b"].Root().IsEmpty())
+
+	db, err := GetDatabase("testproto:a/b")
+	assert.NoError(err)
+	assert.True(db.Head("ds").Get(datas.ValueField).Equals(types.String("hi")))
+
+	_, v, err := GetPath("testproto:a/b::ds.value")
+	assert.NoError(err)
+	assert.True(types.String("hi").Equals(v))
+
+	cs, err := GetChunkStore("testproto:a/b")
+	assert.NoError(err)
+	assert.Equal(stores["a/b"].Root(), cs.Root())
+
+	_, err = GetDatabase("testproto:broken")
+	assert.Error(err)
+	_, err = GetDatabase("testproto:")
+	assert.Error(err)
+
+	assert.Panics(func() { RegisterProtocol("testproto", nil) })
+	assert.Panics(func() { RegisterProtocol("ldb", nil) })
+	assert.Panics(func() { RegisterProtocol("https", nil) })
+	assert.Panics(func() { RegisterProtocol("a:b", nil) })
+}
+
+func TestDynamoSpecs(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, path := range []string{"table/ns", "table/ns/nested", "table"} {
+		sp, err := parseDatabaseSpec("dynamo:" + path)
+		assert.NoError(err)
+		assert.Equal(databaseSpec{Protocol: "dynamo", Path: path}, sp)
+
+		sp2, err := parseDatasetSpec("dynamo:" + path + "::ds")
+		assert.NoError(err)
+		assert.Equal(datasetSpec{sp, "ds"}, sp2)
+	}
+
+	_, err := parseDatabaseSpec("dynamo:")
+	assert.Error(err)
+	_, err = GetChunkStore("dynamo:/ns")
+	assert.Error(err)
+
+	region, ok := os.LookupEnv("AWS_REGION")
+	os.Unsetenv("AWS_REGION")
+	if ok {
+		defer os.Setenv("AWS_REGION", region)
+	}
+	_, err = GetChunkStore("dynamo:table/ns")
+	assert.Error(err)
+	assert.Contains(err.Error(), "AWS_REGION")
+}
@@ -5,18 +5,28 @@
 package spec
 
 import (
+	"path/filepath"
+
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 )
 
+// ldbSocketName is the name of the socket, within a LevelDB directory, on which the process holding the database open shares it with other processes.
+const ldbSocketName = "noms.sock"
+
 type refCountingLdbStore struct {
 	*chunks.LevelDBStore
 	refCount int
 	closeFn  func()
+	server   *datas.LocalSocketServer
 }
 
 func newRefCountingLdbStore(path string, closeFn func()) *refCountingLdbStore {
-	return &refCountingLdbStore{chunks.NewLevelDBStoreUseFlags(path, ""), 1, closeFn}
+	store := chunks.NewLevelDBStoreUseFlags(path, "")
+	// Sharing is best effort. If the socket can't be created, e.g. because the path is too long, other processes simply can't open the database until this one closes it.
+	server, _ := datas.NewLocalSocketServer(store, ldbSocketPath(path))
+	return &refCountingLdbStore{store, 1, closeFn, server}
 }
 
 func (r *refCountingLdbStore) AddRef() {
@@ -27,8 +37,16 @@ func (r *refCountingLdbStore) Close() (err error) {
 	d.Chk.True(r.refCount > 0)
 	r.refCount--
 	if r.refCount == 0 {
+		// Stop waits for the requests of other processes to be handled, so they don't find the store closed.
+		if r.server != nil {
+			r.server.Stop()
+		}
 		err = r.LevelDBStore.Close()
 		r.closeFn()
 	}
 	return
 }
+
+func ldbSocketPath(path string) string {
+	return filepath.Join(path, ldbSocketName)
+}
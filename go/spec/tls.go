@@ -0,0 +1,55 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/attic-labs/noms/go/datas"
+	flag "github.com/tsuru/gnuflag"
+)
+
+type tlsOptions struct {
+	caBundle   string
+	clientCert string
+	clientKey  string
+}
+
+var (
+	tlsFlags           = tlsOptions{}
+	tlsFlagsRegistered = false
+	tlsOverride        *tls.Config
+	tlsMu              = &sync.Mutex{}
+)
+
+func registerTLSFlags(flags *flag.FlagSet) {
+	if !tlsFlagsRegistered {
+		tlsFlagsRegistered = true
+		flags.StringVar(&tlsFlags.caBundle, "tls-ca-bundle", "", "PEM file of certificate authorities to trust when connecting to https databases, in place of the system's")
+		flags.StringVar(&tlsFlags.clientCert, "tls-client-cert", "", "PEM file of the certificate to present to https databases that require one")
+		flags.StringVar(&tlsFlags.clientKey, "tls-client-key", "", "PEM file of the private key for --tls-client-cert")
+	}
+}
+
+// SetTLSConfig sets the TLS configuration used to connect to https database specs, overriding any set by command line flags. Passing nil reverts to the flags, or to the system defaults if none were given.
+func SetTLSConfig(config *tls.Config) {
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	tlsOverride = config
+}
+
+// getTLSConfig returns the TLS configuration for https database specs, or nil if the system defaults should be used.
+func getTLSConfig() (*tls.Config, error) {
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	if tlsOverride != nil {
+		return tlsOverride, nil
+	}
+	if tlsFlags == (tlsOptions{}) {
+		return nil, nil
+	}
+	return datas.NewClientTLSConfig(tlsFlags.caBundle, tlsFlags.clientCert, tlsFlags.clientKey)
+}
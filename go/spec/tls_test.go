@@ -0,0 +1,37 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGetTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+	defer func() { tlsFlags = tlsOptions{} }()
+
+	config, err := getTLSConfig()
+	assert.NoError(err)
+	assert.Nil(config)
+
+	tlsFlags.caBundle = "/does/not/exist.pem"
+	_, err = getTLSConfig()
+	assert.Error(err)
+	_, err = GetDatabase("https://localhost:8000")
+	assert.Error(err)
+
+	override := &tls.Config{}
+	SetTLSConfig(override)
+	config, err = getTLSConfig()
+	assert.NoError(err)
+	assert.True(override == config)
+
+	SetTLSConfig(nil)
+	_, err = getTLSConfig()
+	assert.Error(err)
+}
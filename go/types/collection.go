@@ -10,3 +10,8 @@ type Collection interface {
 	Empty() bool
 	sequence() sequence
 }
+
+// IsMetaCollection reports whether c is an internal node of the tree of chunks that a large collection is split into, whose Chunks are the nodes below it, rather than a leaf that holds elements.
+func IsMetaCollection(c Collection) bool {
+	return isMetaSequence(c.sequence())
+}
@@ -21,6 +21,19 @@ type hrsWriter struct {
 	lineLength  int
 	floatFormat byte
 	err         error
+	// depth is how many collections and structs enclose the value being written. Those at maxDepth, if it's non-zero, are elided.
+	depth    int
+	maxDepth int
+	// maxElements, if non-zero, is how many elements of each collection, or bytes of each blob, are written before the rest are elided.
+	maxElements uint64
+}
+
+// ElisionOptions limits how much of a value its human readable serialization shows, so that huge values can be looked at. What's left out is replaced by a "... (N more)" marker.
+type ElisionOptions struct {
+	// MaxDepth, if non-zero, is how deeply collections and structs are written. The contents of those nested any deeper are elided.
+	MaxDepth int
+	// MaxElements, if non-zero, is how many elements of each collection, or bytes of each blob, are written.
+	MaxElements uint64
 }
 
 func (w *hrsWriter) maybeWriteIndentation() {
@@ -55,6 +68,40 @@ func (w *hrsWriter) newLine() {
 	w.lineLength = 0
 }
 
+func (w *hrsWriter) writeElision(n uint64) {
+	w.write(fmt.Sprintf("... (%s more)", humanize.Comma(int64(n))))
+}
+
+// elideContents writes a marker in place of the n elements or fields of a collection or struct, if it's nested too deeply for them to be written, and reports whether it did.
+func (w *hrsWriter) elideContents(n uint64) bool {
+	if w.maxDepth == 0 || w.depth < w.maxDepth || n == 0 {
+		return false
+	}
+	w.writeElision(n)
+	return true
+}
+
+// elideElements writes a marker in place of the ith and later of the n elements of a collection, if i is maxElements, and reports whether it did.
+func (w *hrsWriter) elideElements(i, n uint64) bool {
+	if w.maxElements == 0 || i < w.maxElements {
+		return false
+	}
+	w.writeElision(n - i)
+	w.newLine()
+	return true
+}
+
+// enter and leave bracket the writing of the contents of a collection or struct.
+func (w *hrsWriter) enter() {
+	w.indent()
+	w.depth++
+}
+
+func (w *hrsWriter) leave() {
+	w.depth--
+	w.outdent()
+}
+
 // hexWriter is used to write blob byte data as "00 01 ... 0f\n10 11 .."
 // hexWriter is an io.Writer that writes to an underlying hrsWriter.
 type hexWriter struct {
@@ -105,34 +152,57 @@ func (w *hrsWriter) Write(v Value) {
 		w.maybeWriteIndentation()
 		blob := v.(Blob)
 		encoder := &hexWriter{hrs: w, size: blob.Len()}
-		_, w.err = io.Copy(encoder, blob.Reader())
+		if w.maxElements == 0 || blob.Len() <= w.maxElements {
+			_, w.err = io.Copy(encoder, blob.Reader())
+			break
+		}
+		if _, w.err = io.CopyN(encoder, blob.Reader(), int64(w.maxElements)); w.err == nil {
+			w.newLine()
+			w.writeElision(blob.Len() - w.maxElements)
+		}
 
 	case ListKind:
+		l := v.(List)
 		w.write("[")
+		if w.elideContents(l.Len()) {
+			w.write("]")
+			break
+		}
 		w.writeSize(v)
-		w.indent()
-		v.(List).Iter(func(v Value, i uint64) bool {
+		w.enter()
+		l.Iter(func(v Value, i uint64) bool {
 			if i == 0 {
 				w.newLine()
 			}
+			if w.elideElements(i, l.Len()) {
+				return true
+			}
 			w.Write(v)
 			w.write(",")
 			w.newLine()
 			return w.err != nil
 		})
-		w.outdent()
+		w.leave()
 		w.write("]")
 
 	case MapKind:
+		m := v.(Map)
 		w.write("{")
+		if w.elideContents(m.Len()) {
+			w.write("}")
+			break
+		}
 		w.writeSize(v)
-		w.indent()
-		first := true
-		v.(Map).Iter(func(key, val Value) bool {
-			if first {
+		w.enter()
+		i := uint64(0)
+		m.Iter(func(key, val Value) bool {
+			if i == 0 {
 				w.newLine()
-				first = false
 			}
+			if w.elideElements(i, m.Len()) {
+				return true
+			}
+			i++
 			w.Write(key)
 			w.write(": ")
 			w.Write(val)
@@ -140,28 +210,36 @@ func (w *hrsWriter) Write(v Value) {
 			w.newLine()
 			return w.err != nil
 		})
-		w.outdent()
+		w.leave()
 		w.write("}")
 
 	case RefKind:
 		w.write(v.(Ref).TargetHash().String())
 
 	case SetKind:
+		set := v.(Set)
 		w.write("{")
+		if w.elideContents(set.Len()) {
+			w.write("}")
+			break
+		}
 		w.writeSize(v)
-		w.indent()
-		first := true
-		v.(Set).Iter(func(v Value) bool {
-			if first {
+		w.enter()
+		i := uint64(0)
+		set.Iter(func(v Value) bool {
+			if i == 0 {
 				w.newLine()
-				first = false
 			}
+			if w.elideElements(i, set.Len()) {
+				return true
+			}
+			i++
 			w.Write(v)
 			w.write(",")
 			w.newLine()
 			return w.err != nil
 		})
-		w.outdent()
+		w.leave()
 		w.write("}")
 
 	case TypeKind:
@@ -184,7 +262,11 @@ func (w *hrsWriter) writeStruct(v Struct, printStructName bool) {
 		w.write(" ")
 	}
 	w.write("{")
-	w.indent()
+	if w.elideContents(uint64(desc.Len())) {
+		w.write("}")
+		return
+	}
+	w.enter()
 
 	first := true
 	desc.IterFields(func(name string, t *Type) {
@@ -200,7 +282,7 @@ func (w *hrsWriter) writeStruct(v Struct, printStructName bool) {
 		w.newLine()
 	})
 
-	w.outdent()
+	w.leave()
 	w.write("}")
 }
 
@@ -356,3 +438,17 @@ func WriteEncodedValueWithTags(w io.Writer, v Value) error {
 	hrs.WriteTagged(v)
 	return hrs.err
 }
+
+// WriteElidedEncodedValue writes the serialization of a value, leaving out what opts says to.
+func WriteElidedEncodedValue(w io.Writer, v Value, opts ElisionOptions) error {
+	hrs := &hrsWriter{w: w, floatFormat: 'g', maxDepth: opts.MaxDepth, maxElements: opts.MaxElements}
+	hrs.Write(v)
+	return hrs.err
+}
+
+// WriteElidedEncodedValueWithTags writes the serialization of a value prefixed by its type, leaving out what opts says to. The type is written in full.
+func WriteElidedEncodedValueWithTags(w io.Writer, v Value, opts ElisionOptions) error {
+	hrs := &hrsWriter{w: w, floatFormat: 'g', maxDepth: opts.MaxDepth, maxElements: opts.MaxElements}
+	hrs.WriteTagged(v)
+	return hrs.err
+}
@@ -397,3 +397,42 @@ func TestWriteHumanReadableWriterError(t *testing.T) {
 	w := &errorWriter{err}
 	assert.Equal(err, WriteEncodedValueWithTags(w, Number(42)))
 }
+
+func TestWriteHumanReadableElided(t *testing.T) {
+	assert := assert.New(t)
+
+	writes := func(expected string, v Value, opts ElisionOptions) {
+		var buf bytes.Buffer
+		assert.NoError(WriteElidedEncodedValue(&buf, v, opts))
+		assert.Equal(expected, buf.String())
+	}
+
+	l := NewList(Number(0), Number(1), Number(2), Number(3), Number(4))
+	writes(`[  // 5 items
+  0,
+  1,
+  ... (3 more)
+]`, l, ElisionOptions{MaxElements: 2})
+	writes(EncodedValue(l), l, ElisionOptions{MaxElements: 5})
+
+	m := NewMap(String("a"), NewList(Number(1)), String("b"), NewMap(), String("c"), Number(3))
+	writes(`{
+  "a": [... (1 more)],
+  "b": {},
+  "c": 3,
+}`, m, ElisionOptions{MaxDepth: 1})
+	writes("[\n  {... (3 more)},\n]", NewList(NewSet(Number(1), Number(2), Number(3))), ElisionOptions{MaxDepth: 1})
+
+	s := NewStruct("S", StructData{"x": NewStruct("T", StructData{"y": Number(1)}), "z": NewSet(Number(1), Number(2))})
+	writes(`S {
+  x: T {... (1 more)},
+  z: {... (2 more)},
+}`, s, ElisionOptions{MaxDepth: 1})
+
+	b := NewBlob(bytes.NewBuffer([]byte{0x01, 0x02, 0x03, 0x04}))
+	writes("01 02\n... (2 more)", b, ElisionOptions{MaxElements: 2})
+
+	var buf bytes.Buffer
+	assert.NoError(WriteElidedEncodedValueWithTags(&buf, l, ElisionOptions{MaxDepth: 1, MaxElements: 1}))
+	assert.Equal("List<Number>([  // 5 items\n  0,\n  ... (4 more)\n])", buf.String())
+}
@@ -162,7 +162,12 @@ func (l List) RemoveAt(idx uint64) List {
 type listIterFunc func(v Value, index uint64) (stop bool)
 
 func (l List) Iter(f listIterFunc) {
-	idx := uint64(0)
+	l.IterFrom(0, f)
+}
+
+// IterFrom is like Iter, but starts at index start.
+func (l List) IterFrom(start uint64, f listIterFunc) {
+	idx := start
 	cur := newCursorAtIndex(l.seq, idx)
 	cur.iter(func(v interface{}) bool {
 		if f(v.(Value), uint64(idx)) {
@@ -1039,3 +1039,29 @@ func TestListTypeAfterMutations(t *testing.T) {
 	test(15, listLeafSequence{})
 	test(1500, indexedMetaSequence{})
 }
+
+func TestListIterFrom(t *testing.T) {
+	assert := assert.New(t)
+	tl := getTestList()
+	l := tl.toList()
+
+	collect := func(start uint64, max int) (vals testList, indices []uint64) {
+		l.IterFrom(start, func(v Value, idx uint64) bool {
+			vals = append(vals, v)
+			indices = append(indices, idx)
+			return len(vals) == max
+		})
+		return
+	}
+
+	vals, indices := collect(1000, 100)
+	assert.Equal(tl[1000:1100], vals)
+	assert.Equal(uint64(1000), indices[0])
+	assert.Equal(uint64(1099), indices[99])
+
+	vals, _ = collect(uint64(len(tl)-3), 100)
+	assert.Equal(tl[len(tl)-3:], vals)
+
+	vals, _ = collect(uint64(len(tl)), 100)
+	assert.Empty(vals)
+}
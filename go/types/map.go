@@ -207,6 +207,15 @@ func (m Map) Iter(cb mapIterCallback) {
 	})
 }
 
+// IterFrom is like Iter, but starts at the first entry whose key is not less than start.
+func (m Map) IterFrom(start Value, cb mapIterCallback) {
+	cur := newCursorAtValue(m.seq, start, true, false)
+	cur.iter(func(v interface{}) bool {
+		entry := v.(mapEntry)
+		return cb(entry.key, entry.value)
+	})
+}
+
 type mapIterAllCallback func(key, value Value)
 
 func (m Map) IterAll(cb mapIterAllCallback) {
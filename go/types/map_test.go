@@ -1131,3 +1131,27 @@ func TestCompoundMapWithValuesOfEveryType(t *testing.T) {
 		assert.Equal(len(kvs)/2, int(m.Len()))
 	}
 }
+
+func TestMapIterFrom(t *testing.T) {
+	assert := assert.New(t)
+	kv := []Value{}
+	for i := 0; i < 5000; i++ {
+		kv = append(kv, Number(i*2), Number(i))
+	}
+	m := NewMap(kv...)
+
+	collect := func(start Value, max int) (keys []Value) {
+		m.IterFrom(start, func(k, v Value) bool {
+			assert.True(m.Get(k).Equals(v))
+			keys = append(keys, k)
+			return len(keys) == max
+		})
+		return
+	}
+
+	assert.Equal([]Value{Number(3000), Number(3002), Number(3004)}, collect(Number(3000), 3))
+	assert.Equal([]Value{Number(3002), Number(3004)}, collect(Number(3001), 2))
+	assert.Equal([]Value{Number(0)}, collect(Number(-10), 1))
+	assert.Equal([]Value{Number(9998)}, collect(Number(9998), 10))
+	assert.Empty(collect(Number(10000), 10))
+}
@@ -0,0 +1,229 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// ParseEncodedValue parses str as a Value written the way EncodedValue writes it, e.g. `Person {name: "Bob", tags: {"a", "b"}}`. JSON is accepted too, with objects becoming Maps. Where a Map and a Set look the same, in "{}", the result is a Map. Blobs and Refs can't be parsed.
+func ParseEncodedValue(str string) (v Value, err error) {
+	p := &valueParser{}
+	p.s.Init(strings.NewReader(str))
+	p.s.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		p.fail("%s", msg)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(valueParseError)
+			if !ok {
+				panic(r)
+			}
+			v, err = nil, pe.error
+		}
+	}()
+	p.next()
+	v = p.parseValue()
+	p.expect(scanner.EOF)
+	return v, nil
+}
+
+type valueParseError struct {
+	error
+}
+
+type valueParser struct {
+	s   scanner.Scanner
+	tok rune
+}
+
+func (p *valueParser) next() {
+	p.tok = p.s.Scan()
+}
+
+func (p *valueParser) fail(format string, args ...interface{}) {
+	pos := p.s.Position
+	if !pos.IsValid() {
+		pos = p.s.Pos()
+	}
+	panic(valueParseError{fmt.Errorf("Invalid value at %d:%d: %s", pos.Line, pos.Column, fmt.Sprintf(format, args...))})
+}
+
+func (p *valueParser) expect(tok rune) {
+	if p.tok != tok {
+		p.fail("expected %s, found %s", scanner.TokenString(tok), scanner.TokenString(p.tok))
+	}
+}
+
+func (p *valueParser) parseValue() Value {
+	switch p.tok {
+	case scanner.String:
+		s, err := strconv.Unquote(p.s.TokenText())
+		if err != nil {
+			p.fail("%s", err)
+		}
+		p.next()
+		return String(s)
+	case scanner.Int, scanner.Float:
+		return p.parseNumber(1)
+	case '-':
+		p.next()
+		if p.tok != scanner.Int && p.tok != scanner.Float {
+			p.fail("expected number, found %s", scanner.TokenString(p.tok))
+		}
+		return p.parseNumber(-1)
+	case '[':
+		return p.parseList()
+	case '{':
+		return p.parseBraces()
+	case scanner.Ident:
+		switch name := p.s.TokenText(); name {
+		case "true", "false":
+			p.next()
+			return Bool(name == "true")
+		case "struct":
+			p.next()
+			name = ""
+			if p.tok == scanner.Ident {
+				name = p.s.TokenText()
+				p.next()
+			}
+			return p.parseStruct(name)
+		default:
+			p.next()
+			return p.parseStruct(name)
+		}
+	}
+	p.fail("unexpected %s", scanner.TokenString(p.tok))
+	panic("unreachable")
+}
+
+func (p *valueParser) parseNumber(sign float64) Value {
+	f, err := strconv.ParseFloat(p.s.TokenText(), 64)
+	if err != nil {
+		p.fail("%s", err)
+	}
+	p.next()
+	return Number(sign * f)
+}
+
+func (p *valueParser) parseList() Value {
+	p.next()
+	values := ValueSlice{}
+	for p.tok != ']' {
+		values = append(values, p.parseValue())
+		if p.tok != ',' {
+			break
+		}
+		p.next()
+	}
+	p.expect(']')
+	p.next()
+	return NewList(values...)
+}
+
+// parseBraces parses a Map, a Set or a Struct without a name, which all start with "{".
+func (p *valueParser) parseBraces() Value {
+	p.next()
+	if p.tok == '}' {
+		p.next()
+		return NewMap()
+	}
+
+	var first Value
+	if p.tok == scanner.Ident && !isValueKeyword(p.s.TokenText()) {
+		name := p.s.TokenText()
+		p.next()
+		if p.tok == ':' {
+			return p.parseStructFields("", name)
+		}
+		first = p.parseStruct(name)
+	} else {
+		first = p.parseValue()
+	}
+
+	if p.tok != ':' {
+		values := ValueSlice{first}
+		for p.tok == ',' {
+			p.next()
+			if p.tok == '}' {
+				break
+			}
+			values = append(values, p.parseValue())
+		}
+		p.expect('}')
+		p.next()
+		return NewSet(values...)
+	}
+
+	kvs := ValueSlice{first}
+	for {
+		p.expect(':')
+		p.next()
+		kvs = append(kvs, p.parseValue())
+		if p.tok != ',' {
+			break
+		}
+		p.next()
+		if p.tok == '}' {
+			break
+		}
+		kvs = append(kvs, p.parseValue())
+	}
+	p.expect('}')
+	p.next()
+	return NewMap(kvs...)
+}
+
+func isValueKeyword(ident string) bool {
+	return ident == "true" || ident == "false" || ident == "struct"
+}
+
+// parseStruct parses the fields of a Struct called name, starting with "{".
+func (p *valueParser) parseStruct(name string) Value {
+	if name != "" && !fieldNameRe.MatchString(name) {
+		p.fail("invalid struct name: %s", name)
+	}
+	p.expect('{')
+	p.next()
+	return p.parseStructFields(name, "")
+}
+
+// parseStructFields parses the fields of a Struct called name, whose opening "{" has been consumed. If field isn't empty, the name of the first field has been consumed too, and it's field.
+func (p *valueParser) parseStructFields(name, field string) Value {
+	data := StructData{}
+	for {
+		if field == "" {
+			if p.tok == '}' {
+				break
+			}
+			p.expect(scanner.Ident)
+			field = p.s.TokenText()
+			p.next()
+		}
+		if !fieldNameRe.MatchString(field) {
+			p.fail("invalid struct field name: %s", field)
+		}
+		if _, ok := data[field]; ok {
+			p.fail("duplicate struct field: %s", field)
+		}
+		p.expect(':')
+		p.next()
+		data[field] = p.parseValue()
+		field = ""
+		if p.tok != ',' {
+			break
+		}
+		p.next()
+	}
+	p.expect('}')
+	p.next()
+	return NewStruct(name, data)
+}
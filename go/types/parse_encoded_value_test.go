@@ -0,0 +1,62 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestParseEncodedValue(t *testing.T) {
+	assert := assert.New(t)
+
+	parses := func(exp Value, str string) {
+		act, err := ParseEncodedValue(str)
+		assert.NoError(err, str)
+		if err == nil {
+			assert.True(exp.Equals(act), "%s Expected %s Actual %s", str, EncodedValue(exp), EncodedValue(act))
+		}
+	}
+
+	parses(Number(42), "42")
+	parses(Number(-1.5e3), "-1.5e3")
+	parses(Bool(true), "true")
+	parses(String("a \"quoted\"\nline"), `"a \"quoted\"\nline"`)
+	parses(NewList(), "[]")
+	parses(NewList(Number(1), String("two")), `[1, "two",]`)
+	parses(NewMap(), "{}")
+	parses(NewMap(String("a"), NewList(Bool(false))), `{"a": [false]}`)
+	parses(NewSet(Number(1), Number(2)), "{1, 2}")
+	parses(NewStruct("", StructData{"a": Number(1)}), "{a: 1}")
+	parses(NewStruct("", StructData{"a": Number(1)}), "struct {a: 1}")
+	parses(NewStruct("Person", StructData{}), "Person {}")
+	parses(NewSet(NewStruct("P", StructData{"x": Number(1)})), "{P {x: 1}}")
+
+	// Whatever EncodedValue writes, other than Blobs and Refs, can be read back.
+	v := NewStruct("Person", StructData{
+		"name":   String("bob"),
+		"tags":   NewSet(String("a"), Number(2)),
+		"scores": NewMap(String("k"), NewList(Number(1), Bool(true))),
+		"list":   NewList(Number(0), Number(1), Number(2), Number(3), Number(4), Number(5), Number(6), Number(7), Number(8), Number(9), Number(10)),
+	})
+	parses(v, EncodedValue(v))
+
+	fails := func(str, msg string) {
+		v, err := ParseEncodedValue(str)
+		assert.Nil(v)
+		assert.Error(err, str)
+		if err != nil {
+			assert.Equal(msg, err.Error())
+		}
+	}
+	fails("", "Invalid value at 1:1: unexpected EOF")
+	fails("[1, 2", "Invalid value at 1:6: expected \"]\", found EOF")
+	fails("1 2", "Invalid value at 1:3: expected EOF, found Int")
+	fails("{a: 1, a: 2}", "Invalid value at 1:9: duplicate struct field: a")
+	fails("{1: 2, 3}", "Invalid value at 1:9: expected \":\", found \"}\"")
+	fails("Person", "Invalid value at 1:7: expected \"{\", found EOF")
+	fails("null", "Invalid value at 1:5: expected \"{\", found EOF")
+}
@@ -24,6 +24,10 @@ type Path []pathPart
 type pathPart interface {
 	Resolve(v Value) Value
 	String() string
+	// set returns a copy of v in which the value that the part resolves to is newValue.
+	set(v, newValue Value) (Value, error)
+	// remove returns a copy of v without the value that the part resolves to.
+	remove(v Value) (Value, error)
 }
 
 func NewPath() Path {
@@ -137,6 +141,44 @@ func (p Path) Resolve(v Value) (resolved Value) {
 	return
 }
 
+// Set returns a copy of v in which the value that p resolves to is newValue, and every value along the way is rebuilt to hold the new one. The last part of p needn't resolve: it can add a field to a Struct, a key to a Map, or an element to the end of a List. It returns an error if any other part doesn't resolve, or if a part can't be set in the value it applies to.
+func (p Path) Set(v, newValue Value) (Value, error) {
+	if len(p) == 0 {
+		return newValue, nil
+	}
+	if parent := p[:len(p)-1]; parent.Resolve(v) == nil {
+		return nil, fmt.Errorf("%s not found", parent)
+	}
+	return p.edit(v, func(parent Value, part pathPart) (Value, error) {
+		return part.set(parent, newValue)
+	})
+}
+
+// Remove returns a copy of v without the value that p resolves to, by removing it from the Struct, List, Map or Set that holds it. It returns an error if p is empty or doesn't resolve.
+func (p Path) Remove(v Value) (Value, error) {
+	if len(p) == 0 {
+		return nil, errors.New("Can't remove the value at an empty path")
+	}
+	if p.Resolve(v) == nil {
+		return nil, fmt.Errorf("%s not found", p)
+	}
+	return p.edit(v, func(parent Value, part pathPart) (Value, error) {
+		return part.remove(parent)
+	})
+}
+
+// edit rebuilds v with the result of calling f on the last part of p and the value that it applies to, which must resolve.
+func (p Path) edit(v Value, f func(parent Value, part pathPart) (Value, error)) (Value, error) {
+	if len(p) == 1 {
+		return f(v, p[0])
+	}
+	newChild, err := p[1:].edit(p[0].Resolve(v), f)
+	if err != nil {
+		return nil, err
+	}
+	return p[0].set(v, newChild)
+}
+
 func (p Path) String() string {
 	strs := make([]string, 0, len(p))
 	for _, part := range p {
@@ -167,6 +209,32 @@ func (fp fieldPart) String() string {
 	return fmt.Sprintf(".%s", fp.name)
 }
 
+func (fp fieldPart) set(v, newValue Value) (Value, error) {
+	s, ok := v.(Struct)
+	if !ok {
+		return nil, fmt.Errorf("Can't set field %s of a %s", fp.name, KindToString[v.Type().Kind()])
+	}
+	data := structData(s)
+	data[fp.name] = newValue
+	return NewStruct(s.desc().Name, data), nil
+}
+
+func (fp fieldPart) remove(v Value) (Value, error) {
+	s := v.(Struct)
+	data := structData(s)
+	delete(data, fp.name)
+	return NewStruct(s.desc().Name, data), nil
+}
+
+// structData returns the fields of s. Structs are rebuilt from it, rather than with Struct.Set, so that fields can be added and removed and change type.
+func structData(s Struct) StructData {
+	data := StructData{}
+	s.desc().IterFields(func(name string, t *Type) {
+		data[name] = s.Get(name)
+	})
+	return data
+}
+
 type indexPart struct {
 	idx Value
 	key bool
@@ -206,6 +274,58 @@ func (ip indexPart) Resolve(v Value) Value {
 	return nil
 }
 
+// listIndex returns the index in l that ip names, if it names one up to and including l.Len().
+func (ip indexPart) listIndex(l List) (uint64, bool) {
+	if n, ok := ip.idx.(Number); ok {
+		f := float64(n)
+		if f == math.Trunc(f) && f >= 0 && f <= float64(l.Len()) {
+			return uint64(f), true
+		}
+	}
+	return 0, false
+}
+
+func (ip indexPart) set(v, newValue Value) (Value, error) {
+	switch v := v.(type) {
+	case List:
+		if ip.key {
+			return nil, errors.New("Can't set the index of a List element")
+		}
+		if i, ok := ip.listIndex(v); ok {
+			if i == v.Len() {
+				return v.Append(newValue), nil
+			}
+			return v.Set(i, newValue), nil
+		}
+		return nil, fmt.Errorf("Index %s is out of range", EncodedIndexValue(ip.idx))
+	case Map:
+		if !ip.key {
+			return v.Set(ip.idx, newValue), nil
+		}
+		if v.Has(ip.idx) {
+			return replaceMapKey(v, ip.idx, newValue), nil
+		}
+		return nil, fmt.Errorf("%s not found", ip)
+	}
+	return nil, fmt.Errorf("Can't index into a %s", KindToString[v.Type().Kind()])
+}
+
+func (ip indexPart) remove(v Value) (Value, error) {
+	switch v := v.(type) {
+	case List:
+		i, _ := ip.listIndex(v)
+		return v.RemoveAt(i), nil
+	case Map:
+		return v.Remove(ip.idx), nil
+	}
+	panic("unreachable")
+}
+
+// replaceMapKey returns a copy of m in which the value of key is that of newKey instead.
+func replaceMapKey(m Map, key, newKey Value) Map {
+	return m.Remove(key).Set(newKey, m.Get(key))
+}
+
 func (ip indexPart) String() (str string) {
 	ann := ""
 	if ip.key {
@@ -255,6 +375,33 @@ func (hip hashIndexPart) Resolve(v Value) (res Value) {
 	return getCurrentValue(cur)
 }
 
+func (hip hashIndexPart) set(v, newValue Value) (Value, error) {
+	old := hip.Resolve(v)
+	if old == nil {
+		return nil, fmt.Errorf("%s not found", hip)
+	}
+	switch v := v.(type) {
+	case Set:
+		return v.Remove(old).Insert(newValue), nil
+	case Map:
+		if hip.key {
+			return replaceMapKey(v, old, newValue), nil
+		}
+		return v.Set(newHashIndexPart(hip.h, true).Resolve(v), newValue), nil
+	}
+	panic("unreachable")
+}
+
+func (hip hashIndexPart) remove(v Value) (Value, error) {
+	switch v := v.(type) {
+	case Set:
+		return v.Remove(hip.Resolve(v)), nil
+	case Map:
+		return v.Remove(newHashIndexPart(hip.h, true).Resolve(v)), nil
+	}
+	panic("unreachable")
+}
+
 func (hip hashIndexPart) String() string {
 	ann := ""
 	if hip.key {
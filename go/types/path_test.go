@@ -327,3 +327,86 @@ func TestPathParseErrors(t *testing.T) {
 	test("@key", "Invalid operator: @")
 	test(fmt.Sprintf(".foo[#%s]@soup", hash.FromData([]byte{42}).String()), "Unsupported annotation: @soup")
 }
+
+func TestPathSet(t *testing.T) {
+	assert := assert.New(t)
+
+	person := NewStruct("Person", StructData{
+		"name": String("bob"),
+		"tags": NewSet(NewList(String("a")), NewList(String("b"))),
+	})
+	v := NewMap(
+		String("bob"), person,
+		String("list"), NewList(Number(1), Number(2)),
+	)
+
+	sets := func(exp Value, str string, newValue Value) {
+		p, err := ParsePath(str)
+		assert.NoError(err)
+		act, err := p.Set(v, newValue)
+		if assert.NoError(err, str) {
+			assert.True(exp.Equals(act), "%s Expected %s Actual %s", str, EncodedValue(exp), EncodedValue(act))
+		}
+	}
+
+	sets(v.Set(String("bob"), person.Set("name", String("robert"))), `["bob"].name`, String("robert"))
+	// Fields can change type, and be added.
+	sets(v.Set(String("bob"), NewStruct("Person", StructData{"name": Number(1), "tags": person.Get("tags")})), `["bob"].name`, Number(1))
+	sets(v.Set(String("bob"), NewStruct("Person", StructData{"name": String("bob"), "tags": person.Get("tags"), "age": Number(42)})), `["bob"].age`, Number(42))
+	sets(v.Set(String("list"), NewList(Number(1), Number(3))), `["list"][1]`, Number(3))
+	sets(v.Set(String("list"), NewList(Number(1), Number(2), Number(3))), `["list"][2]`, Number(3))
+	sets(v.Set(String("carol"), String("new")), `["carol"]`, String("new"))
+	sets(v.Remove(String("list")).Set(String("numbers"), v.Get(String("list"))), `["list"]@key`, String("numbers"))
+	sets(v.Set(String("bob"), person.Set("tags", NewSet(NewList(String("a")), NewList(String("c"))))), fmt.Sprintf(`["bob"].tags[#%s]`, NewList(String("b")).Hash()), NewList(String("c")))
+
+	act, err := Path{}.Set(v, String("replaced"))
+	assert.NoError(err)
+	assert.Equal(String("replaced"), act)
+
+	setFails := func(str, msg string) {
+		p, err := ParsePath(str)
+		assert.NoError(err)
+		_, err = p.Set(v, Number(0))
+		assert.Error(err)
+		assert.Equal(msg, err.Error())
+	}
+	setFails(`["carol"].name`, `["carol"] not found`)
+	setFails(`["list"][3]`, "Index 3 is out of range")
+	setFails(`["list"].foo`, "Can't set field foo of a List")
+	setFails(`["bob"].name[0]`, "Can't index into a String")
+	setFails(`["list"][0]@key`, "Can't set the index of a List element")
+}
+
+func TestPathRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	person := NewStruct("Person", StructData{
+		"name": String("bob"),
+		"tags": NewSet(NewList(String("a")), NewList(String("b"))),
+	})
+	v := NewList(person, NewMap(String("k"), Number(1)))
+
+	removes := func(exp Value, str string) {
+		p, err := ParsePath(str)
+		assert.NoError(err)
+		act, err := p.Remove(v)
+		if assert.NoError(err, str) {
+			assert.True(exp.Equals(act), "%s Expected %s Actual %s", str, EncodedValue(exp), EncodedValue(act))
+		}
+	}
+
+	removes(v.Set(0, NewStruct("Person", StructData{"tags": person.Get("tags")})), `[0].name`)
+	removes(v.Set(0, person.Set("tags", NewSet(NewList(String("a"))))), fmt.Sprintf(`[0].tags[#%s]`, NewList(String("b")).Hash()))
+	removes(v.Set(1, NewMap()), `[1]["k"]`)
+	removes(v.Set(1, NewMap()), `[1]["k"]@key`)
+	removes(NewList(person), `[1]`)
+
+	p, err := ParsePath(`[0].age`)
+	assert.NoError(err)
+	_, err = p.Remove(v)
+	assert.Error(err)
+	assert.Equal("[0].age not found", err.Error())
+
+	_, err = Path{}.Remove(v)
+	assert.Error(err)
+}
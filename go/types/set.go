@@ -163,6 +163,14 @@ func (s Set) Iter(cb setIterCallback) {
 	})
 }
 
+// IterFrom is like Iter, but starts at the first value that is not less than start.
+func (s Set) IterFrom(start Value, cb setIterCallback) {
+	cur := newCursorAtValue(s.seq, start, true, false)
+	cur.iter(func(v interface{}) bool {
+		return cb(v.(Value))
+	})
+}
+
 type setIterAllCallback func(v Value)
 
 func (s Set) IterAll(cb setIterAllCallback) {
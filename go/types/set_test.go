@@ -926,3 +926,25 @@ func TestChunkedSetWithValuesOfEveryType(t *testing.T) {
 		assert.Equal(len(vs), int(s.Len()))
 	}
 }
+
+func TestSetIterFrom(t *testing.T) {
+	assert := assert.New(t)
+	vs := []Value{}
+	for i := 0; i < 5000; i++ {
+		vs = append(vs, Number(i*2))
+	}
+	s := NewSet(vs...)
+
+	collect := func(start Value, max int) (vals []Value) {
+		s.IterFrom(start, func(v Value) bool {
+			vals = append(vals, v)
+			return len(vals) == max
+		})
+		return
+	}
+
+	assert.Equal([]Value{Number(3000), Number(3002), Number(3004)}, collect(Number(3000), 3))
+	assert.Equal([]Value{Number(3002), Number(3004)}, collect(Number(3001), 2))
+	assert.Equal([]Value{Number(9998)}, collect(Number(9998), 10))
+	assert.Empty(collect(Number(10000), 10))
+}
@@ -146,7 +146,7 @@ func (s1 Struct) Diff(s2 Struct, changes chan<- ValueChanged, closeChan <-chan s
 			change = ValueChanged{ChangeType: DiffChangeRemoved, V: String(fn1)}
 			i1++
 		} else {
-			change = ValueChanged{ChangeType: DiffChangeAdded, V: String(fn1)}
+			change = ValueChanged{ChangeType: DiffChangeAdded, V: String(fn2)}
 			i2++
 		}
 
@@ -114,6 +114,9 @@ func TestStructDiff(t *testing.T) {
 	assertDiff([]ValueChanged{vc(DiffChangeRemoved, "b"), vc(DiffChangeAdded, "d")}, s1,
 		NewStruct("NewType", StructData{"a": Bool(true), "c": Number(4), "d": Number(5)}))
 
+	assertDiff([]ValueChanged{vc(DiffChangeAdded, "bb")}, s1,
+		NewStruct("NewType", StructData{"a": Bool(true), "b": String("hi"), "bb": Number(5), "c": Number(4)}))
+
 	s2 := NewStruct("", StructData{
 		"a": NewList(Number(0), Number(1)),
 		"b": NewMap(String("foo"), Bool(false), String("bar"), Bool(true)),
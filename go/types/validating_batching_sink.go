@@ -7,6 +7,7 @@ package types
 import (
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 )
 
 const batchSize = 100
@@ -17,10 +18,17 @@ type ValidatingBatchingSink struct {
 	batch [batchSize]chunks.Chunk
 	count int
 	tc    *TypeCache
+	// missingOK holds the hashes of Chunks that are allowed to refer to Chunks that aren't present.
+	missingOK hash.HashSet
 }
 
 func NewValidatingBatchingSink(cs chunks.ChunkStore, tc *TypeCache) *ValidatingBatchingSink {
-	return &ValidatingBatchingSink{vs: newLocalValueStore(cs), cs: cs, tc: tc}
+	return &ValidatingBatchingSink{vs: newLocalValueStore(cs), cs: cs, tc: tc, missingOK: hash.HashSet{}}
+}
+
+// AllowMissingRefs lets the Chunk with hash h be Enqueued even though some of the Chunks it refers to aren't present. Those Chunks can still be Enqueued later.
+func (vbs *ValidatingBatchingSink) AllowMissingRefs(h hash.Hash) {
+	vbs.missingOK.Insert(h)
 }
 
 // Prepare primes the type info cache used to validate Enqueued Chunks by reading the Chunks referenced by the provided hints.
@@ -38,7 +46,7 @@ func (vbs *ValidatingBatchingSink) Enqueue(c chunks.Chunk) chunks.BackpressureEr
 	}
 	v := DecodeFromBytes(c.Data(), vbs.vs, vbs.tc)
 	d.PanicIfTrue(getHash(v) != h, "Invalid hash found")
-	vbs.vs.ensureChunksInCache(v)
+	vbs.vs.checkChunksInCache(v, true, vbs.missingOK.Has(h))
 	vbs.vs.set(h, hintedChunk{v.Type(), h})
 
 	vbs.batch[vbs.count] = c
@@ -164,14 +164,15 @@ func (lvs *ValueStore) checkAndSet(r hash.Hash, entry chunkCacheEntry) {
 }
 
 func (lvs *ValueStore) chunkHintsFromCache(v Value) Hints {
-	return lvs.checkChunksInCache(v, false)
+	return lvs.checkChunksInCache(v, false, false)
 }
 
 func (lvs *ValueStore) ensureChunksInCache(v Value) {
-	lvs.checkChunksInCache(v, true)
+	lvs.checkChunksInCache(v, true, false)
 }
 
-func (lvs *ValueStore) checkChunksInCache(v Value, readValues bool) Hints {
+// checkChunksInCache fails if v refers to a Value that isn't present, unless missingOK is true, in which case such Refs are skipped.
+func (lvs *ValueStore) checkChunksInCache(v Value, readValues, missingOK bool) Hints {
 	hints := map[hash.Hash]struct{}{}
 	for _, reachable := range v.Chunks() {
 		// First, check the type cache to see if reachable is already known to be valid.
@@ -186,6 +187,9 @@ func (lvs *ValueStore) checkChunksInCache(v Value, readValues bool) Hints {
 				reachableV = lvs.ReadValue(targetHash)
 				entry = lvs.check(targetHash)
 			}
+			if reachableV == nil && missingOK {
+				continue
+			}
 			if reachableV == nil {
 				d.Chk.Fail("Attempted to write Value containing Ref to non-existent object.", "%s\n, contains ref %s, which points to a non-existent Value.", v.Hash(), reachable.TargetHash())
 			}
@@ -0,0 +1,674 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package jmespath evaluates JMESPath (http://jmespath.org) expressions against Noms values.
+//
+// Values are adapted as they're reached, rather than converted up front, so that an expression only reads the parts of a Value that it looks at:
+//   - Bool, Number and String become bool, float64 and string
+//   - Struct, and Map with String keys, are objects
+//   - List and Set are arrays, as is any other Map, of [key, value] pairs
+//   - Ref is followed, and is whatever its target is
+//
+// The result is a generic Go value of the kind produced by encoding/json, as nomstojson.NomsValueToDecodedJSON produces. Refs within the collections and structs in it aren't followed, and are written as {"@ref": "<target hash>"}.
+package jmespath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/nomstojson"
+	jp "github.com/jmespath/go-jmespath"
+)
+
+// Query is a compiled JMESPath expression.
+type Query struct {
+	ast node
+}
+
+// Compile parses expr as a JMESPath expression.
+func Compile(expr string) (*Query, error) {
+	ast, err := jp.NewParser().Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{readNode(reflect.ValueOf(ast))}, nil
+}
+
+// Search evaluates q against v, reading the targets of any Refs that it follows from vr.
+func (q *Query) Search(v types.Value, vr types.ValueReader) (interface{}, error) {
+	in := interpreter{vr}
+	res, err := in.eval(q.ast, in.adapt(v))
+	if err != nil {
+		return nil, err
+	}
+	return in.plain(res), nil
+}
+
+// Search compiles expr and evaluates it against v, reading the targets of any Refs that it follows from vr.
+func Search(expr string, v types.Value, vr types.ValueReader) (interface{}, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Search(v, vr)
+}
+
+// node is the syntax tree of a JMESPath expression. It's read by reflection from the jp.ASTNode that jp.Parser produces, because that doesn't export its fields; kind is the name of its node type, e.g. "ASTField".
+type node struct {
+	kind     string
+	value    interface{}
+	children []node
+}
+
+func readNode(v reflect.Value) node {
+	n := node{
+		kind:  enumName(v.FieldByName("nodeType")),
+		value: readNodeValue(v.FieldByName("value")),
+	}
+	children := v.FieldByName("children")
+	for i := 0; i < children.Len(); i++ {
+		n.children = append(n.children, readNode(children.Index(i)))
+	}
+	return n
+}
+
+// readNodeValue copies the value of a node, which can't be got with Interface() because it's in an unexported field. The value is a string, int, []*int, tokType, or a literal decoded from JSON.
+func readNodeValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return readNodeValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type().PkgPath() != "" {
+			return enumName(v)
+		}
+		return int(v.Int())
+	case reflect.Slice:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = readNodeValue(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = readNodeValue(v.MapIndex(k))
+		}
+		return m
+	}
+	panic(fmt.Sprintf("Unexpected JMESPath node value: %s", v.Type()))
+}
+
+// enumName returns the name of v, one of the enums that jp defines with String methods. v is copied first, because methods can't be called on values read from unexported fields.
+func enumName(v reflect.Value) string {
+	c := reflect.New(v.Type()).Elem()
+	c.SetInt(v.Int())
+	return c.Interface().(fmt.Stringer).String()
+}
+
+// expRef is the value of an expression reference, e.g. &age in sort_by(people, &age).
+type expRef struct {
+	n node
+}
+
+type interpreter struct {
+	vr types.ValueReader
+}
+
+func (in interpreter) eval(n node, v interface{}) (interface{}, error) {
+	switch n.kind {
+	case "ASTEmpty":
+		return nil, nil
+	case "ASTIdentity", "ASTCurrentNode":
+		return v, nil
+	case "ASTLiteral":
+		return n.value, nil
+	case "ASTField":
+		return in.field(v, n.value.(string)), nil
+	case "ASTIndex":
+		return in.index(v, n.value.(int)), nil
+	case "ASTSlice":
+		return in.slice(v, n.value.([]interface{}))
+	case "ASTSubexpression", "ASTIndexExpression":
+		left, err := in.eval(n.children[0], v)
+		if err != nil {
+			return nil, err
+		}
+		return in.eval(n.children[1], left)
+	case "ASTPipe":
+		var err error
+		for _, c := range n.children {
+			if v, err = in.eval(c, v); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case "ASTKeyValPair":
+		return in.eval(n.children[0], v)
+	case "ASTExpRef":
+		return expRef{n.children[0]}, nil
+	case "ASTComparator":
+		return in.compare(n, v)
+	case "ASTOrExpression", "ASTAndExpression":
+		left, err := in.eval(n.children[0], v)
+		if err != nil {
+			return nil, err
+		}
+		if isFalse(left) == (n.kind == "ASTOrExpression") {
+			return in.eval(n.children[1], v)
+		}
+		return left, nil
+	case "ASTNotExpression":
+		res, err := in.eval(n.children[0], v)
+		if err != nil {
+			return nil, err
+		}
+		return isFalse(res), nil
+	case "ASTMultiSelectList", "ASTMultiSelectHash":
+		if v == nil {
+			return nil, nil
+		}
+		list := make([]interface{}, len(n.children))
+		for i, c := range n.children {
+			res, err := in.eval(c, v)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = res
+		}
+		if n.kind == "ASTMultiSelectList" {
+			return list, nil
+		}
+		hash := make(map[string]interface{}, len(n.children))
+		for i, c := range n.children {
+			hash[c.value.(string)] = list[i]
+		}
+		return hash, nil
+	case "ASTProjection", "ASTFilterProjection", "ASTValueProjection":
+		return in.project(n, v)
+	case "ASTFlatten":
+		left, err := in.eval(n.children[0], v)
+		if err != nil {
+			return nil, err
+		}
+		flattened := []interface{}{}
+		isArray, err := in.iterArray(left, func(elem interface{}) error {
+			if isElemArray, _ := in.iterArray(elem, func(e interface{}) error {
+				flattened = append(flattened, e)
+				return nil
+			}); !isElemArray {
+				flattened = append(flattened, elem)
+			}
+			return nil
+		})
+		if !isArray {
+			return nil, err
+		}
+		return flattened, err
+	case "ASTFunctionExpression":
+		args := make([]interface{}, len(n.children))
+		for i, c := range n.children {
+			arg, err := in.eval(c, v)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+		}
+		return in.call(n.value.(string), args)
+	}
+	return nil, fmt.Errorf("Unknown JMESPath node: %s", n.kind)
+}
+
+// project evaluates the right hand side of a projection against each element of the array, or each value of the object, on its left, leaving out null results. A filter projection evaluates only those elements for which its condition holds.
+func (in interpreter) project(n node, v interface{}) (interface{}, error) {
+	left, err := in.eval(n.children[0], v)
+	if err != nil {
+		return nil, err
+	}
+	collected := []interface{}{}
+	cb := func(elem interface{}) error {
+		if n.kind == "ASTFilterProjection" {
+			cond, err := in.eval(n.children[2], elem)
+			if err != nil || isFalse(cond) {
+				return err
+			}
+		}
+		res, err := in.eval(n.children[1], elem)
+		if res != nil {
+			collected = append(collected, res)
+		}
+		return err
+	}
+
+	var ok bool
+	if n.kind == "ASTValueProjection" {
+		ok, err = in.iterObject(left, func(key string, val interface{}) error {
+			return cb(val)
+		})
+	} else {
+		ok, err = in.iterArray(left, cb)
+	}
+	if !ok || err != nil {
+		return nil, err
+	}
+	return collected, nil
+}
+
+func (in interpreter) compare(n node, v interface{}) (interface{}, error) {
+	left, err := in.eval(n.children[0], v)
+	if err != nil {
+		return nil, err
+	}
+	right, err := in.eval(n.children[1], v)
+	if err != nil {
+		return nil, err
+	}
+	switch n.value {
+	case "tEQ":
+		return reflect.DeepEqual(in.plain(left), in.plain(right)), nil
+	case "tNE":
+		return !reflect.DeepEqual(in.plain(left), in.plain(right)), nil
+	}
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, nil
+	}
+	switch n.value {
+	case "tLT":
+		return l < r, nil
+	case "tLTE":
+		return l <= r, nil
+	case "tGT":
+		return l > r, nil
+	case "tGTE":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("Unknown JMESPath comparator: %s", n.value)
+}
+
+// adapt returns the form that v takes in an expression, as described in the package documentation.
+func (in interpreter) adapt(v types.Value) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.Ref:
+		return in.adapt(v.TargetValue(in.vr))
+	case types.Struct, types.List, types.Set, types.Map:
+		return v
+	}
+	return nomstojson.NomsValueToDecodedJSON(v, 0)
+}
+
+// plain converts v, the result of evaluating an expression, into a generic Go value without any Noms values in it.
+func (in interpreter) plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case types.Value:
+		return nomstojson.NomsValueToDecodedJSON(v, 0)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, elem := range v {
+			res[i] = in.plain(elem)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			res[k] = in.plain(val)
+		}
+		return res
+	}
+	return v
+}
+
+// isObjectMap returns true if m is an object, rather than an array of [key, value] pairs, because all of its keys are Strings.
+func isObjectMap(m types.Map) bool {
+	t := m.Type().Desc.(types.CompoundDesc).ElemTypes[0]
+	return t.Kind() == types.StringKind || (t.Kind() == types.UnionKind && len(t.Desc.(types.CompoundDesc).ElemTypes) == 0)
+}
+
+func (in interpreter) field(v interface{}, name string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v[name]
+	case types.Struct:
+		if fv, ok := v.MaybeGet(name); ok {
+			return in.adapt(fv)
+		}
+	case types.Map:
+		if !isObjectMap(v) {
+			return nil
+		}
+		if val, ok := v.MaybeGet(types.String(name)); ok {
+			return in.adapt(val)
+		}
+	}
+	return nil
+}
+
+// iterArray calls cb with each element of v, until it returns an error. It returns false if v isn't an array.
+func (in interpreter) iterArray(v interface{}, cb func(elem interface{}) error) (isArray bool, err error) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			if err = cb(elem); err != nil {
+				break
+			}
+		}
+	case types.List:
+		v.Iter(func(elem types.Value, i uint64) bool {
+			err = cb(in.adapt(elem))
+			return err != nil
+		})
+	case types.Set:
+		v.Iter(func(elem types.Value) bool {
+			err = cb(in.adapt(elem))
+			return err != nil
+		})
+	case types.Map:
+		if isObjectMap(v) {
+			return false, nil
+		}
+		v.Iter(func(key, val types.Value) bool {
+			err = cb([]interface{}{in.adapt(key), in.adapt(val)})
+			return err != nil
+		})
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// iterObject calls cb with each key and value of v, in order of key, until it returns an error. It returns false if v isn't an object.
+func (in interpreter) iterObject(v interface{}, cb func(key string, val interface{}) error) (isObject bool, err error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err = cb(k, v[k]); err != nil {
+				break
+			}
+		}
+	case types.Struct:
+		v.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			if err == nil {
+				err = cb(name, in.adapt(v.Get(name)))
+			}
+		})
+	case types.Map:
+		if !isObjectMap(v) {
+			return false, nil
+		}
+		v.Iter(func(key, val types.Value) bool {
+			err = cb(string(key.(types.String)), in.adapt(val))
+			return err != nil
+		})
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// length returns the number of elements in v, if it's an array or object, without reading them if it's a Noms collection.
+func length(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v), true
+	case types.Struct:
+		return v.Type().Desc.(types.StructDesc).Len(), true
+	case types.Map:
+		return int(v.Len()), true
+	}
+	return arrayLen(v)
+}
+
+// arrayLen returns the number of elements in v, if it's an array.
+func arrayLen(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return len(v), true
+	case types.List:
+		return int(v.Len()), true
+	case types.Set:
+		return int(v.Len()), true
+	case types.Map:
+		if !isObjectMap(v) {
+			return int(v.Len()), true
+		}
+	}
+	return 0, false
+}
+
+func isFalse(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	}
+	n, ok := length(v)
+	return ok && n == 0
+}
+
+func (in interpreter) index(v interface{}, i int) interface{} {
+	n, ok := arrayLen(v)
+	if !ok {
+		return nil
+	}
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return nil
+	}
+	if l, ok := v.(types.List); ok {
+		return in.adapt(l.Get(uint64(i)))
+	}
+	var res interface{}
+	j := 0
+	in.iterArray(v, func(elem interface{}) error {
+		if j == i {
+			res = elem
+			return errStop
+		}
+		j++
+		return nil
+	})
+	return res
+}
+
+// errStop stops iterArray early, without being an error.
+var errStop = errors.New("stop")
+
+// slice evaluates a slice expression, e.g. [1:10:2], whose parts are the start, stop and step, any of which can be nil.
+func (in interpreter) slice(v interface{}, parts []interface{}) (interface{}, error) {
+	n, ok := arrayLen(v)
+	if !ok {
+		return nil, nil
+	}
+
+	step := 1
+	if parts[2] != nil {
+		if step = parts[2].(int); step == 0 {
+			return nil, errors.New("Invalid slice, step cannot be 0")
+		}
+	}
+	start, stop := 0, n
+	if step < 0 {
+		start, stop = n-1, -1
+	}
+	if parts[0] != nil {
+		start = capSlice(n, parts[0].(int), step)
+	}
+	if parts[1] != nil {
+		stop = capSlice(n, parts[1].(int), step)
+	}
+
+	sliced := []interface{}{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		sliced = append(sliced, in.index(v, i))
+	}
+	return sliced, nil
+}
+
+// capSlice limits i, one end of a slice of an array of length n, to the array, as Python does.
+func capSlice(n, i, step int) int {
+	if i < 0 {
+		i += n
+		if i < 0 {
+			if step < 0 {
+				return -1
+			}
+			return 0
+		}
+	} else if i >= n {
+		if step < 0 {
+			return n - 1
+		}
+		return n
+	}
+	return i
+}
+
+// call calls the function called name. Those that take an expression reference are implemented here; the rest are left to jp, after converting args to generic Go values, except that the length of a Noms collection is found without reading it.
+func (in interpreter) call(name string, args []interface{}) (interface{}, error) {
+	switch name {
+	case "length":
+		if len(args) == 1 {
+			if _, isValue := args[0].(types.Value); isValue {
+				n, _ := length(args[0])
+				return float64(n), nil
+			}
+		}
+	case "map":
+		if len(args) != 2 {
+			break
+		}
+		ref, ok := args[0].(expRef)
+		if !ok {
+			break
+		}
+		mapped := []interface{}{}
+		isArray, err := in.iterArray(args[1], func(elem interface{}) error {
+			res, err := in.eval(ref.n, elem)
+			mapped = append(mapped, res)
+			return err
+		})
+		if isArray {
+			return mapped, err
+		}
+	case "sort_by", "max_by", "min_by":
+		if len(args) != 2 {
+			break
+		}
+		ref, ok := args[1].(expRef)
+		if !ok {
+			break
+		}
+		return in.callBy(name, args[0], ref)
+	}
+
+	for _, arg := range args {
+		if _, ok := arg.(expRef); ok {
+			return nil, fmt.Errorf("Invalid arguments to %s", name)
+		}
+	}
+	params := make([]string, len(args))
+	for i := range args {
+		params[i] = fmt.Sprintf("@[%d]", i)
+	}
+	return jp.Search(fmt.Sprintf("%s(%s)", name, strings.Join(params, ", ")), in.plain(args))
+}
+
+// callBy calls sort_by, max_by or min_by, which order the elements of array by the result of evaluating ref against them, which must be all numbers or all strings.
+func (in interpreter) callBy(name string, array interface{}, ref expRef) (interface{}, error) {
+	elems, keys := []interface{}{}, []interface{}{}
+	isArray, err := in.iterArray(array, func(elem interface{}) error {
+		key, err := in.eval(ref.n, elem)
+		elems, keys = append(elems, elem), append(keys, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !isArray {
+		return nil, fmt.Errorf("Invalid arguments to %s: expected an array", name)
+	}
+	for _, k := range keys {
+		_, isNumber := k.(float64)
+		_, isString := k.(string)
+		if !isNumber && !isString || reflect.TypeOf(k) != reflect.TypeOf(keys[0]) {
+			return nil, fmt.Errorf("Invalid arguments to %s: expected all numbers or all strings", name)
+		}
+	}
+
+	less := func(i, j int) bool {
+		if a, ok := keys[i].(float64); ok {
+			return a < keys[j].(float64)
+		}
+		return keys[i].(string) < keys[j].(string)
+	}
+	switch name {
+	case "sort_by":
+		sort.Stable(byKey{elems, keys, less})
+		return elems, nil
+	case "max_by", "min_by":
+		if len(elems) == 0 {
+			return nil, nil
+		}
+		best := 0
+		for i := range elems {
+			if (name == "max_by" && less(best, i)) || (name == "min_by" && less(i, best)) {
+				best = i
+			}
+		}
+		return elems[best], nil
+	}
+	panic("unreachable")
+}
+
+// byKey sorts elems by keys, which are in the same order.
+type byKey struct {
+	elems, keys []interface{}
+	less        func(i, j int) bool
+}
+
+func (b byKey) Len() int {
+	return len(b.elems)
+}
+
+func (b byKey) Less(i, j int) bool {
+	return b.less(i, j)
+}
+
+func (b byKey) Swap(i, j int) {
+	b.elems[i], b.elems[j] = b.elems[j], b.elems[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
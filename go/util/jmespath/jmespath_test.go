@@ -0,0 +1,117 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package jmespath
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestJMESPathTestSuite(t *testing.T) {
+	suite.Run(t, &JMESPathTestSuite{})
+}
+
+type JMESPathTestSuite struct {
+	suite.Suite
+	vs    *types.ValueStore
+	reads []hash.Hash
+	root  types.Value
+}
+
+// ReadValue records the hashes that a query reads, so that tests can check that it reads only what it needs.
+func (suite *JMESPathTestSuite) ReadValue(h hash.Hash) types.Value {
+	suite.reads = append(suite.reads, h)
+	return suite.vs.ReadValue(h)
+}
+
+func person(name string, age float64, tags ...string) types.Struct {
+	ts := make([]types.Value, len(tags))
+	for i, t := range tags {
+		ts[i] = types.String(t)
+	}
+	return types.NewStruct("Person", types.StructData{
+		"name": types.String(name),
+		"age":  types.Number(age),
+		"tags": types.NewSet(ts...),
+	})
+}
+
+func (suite *JMESPathTestSuite) SetupTest() {
+	suite.vs = types.NewTestValueStore()
+	suite.reads = nil
+	people := types.NewList(person("alice", 42, "a", "b"), person("bob", 25), person("carol", 31, "c"))
+	suite.root = types.NewStruct("", types.StructData{
+		"people": suite.vs.WriteValue(people),
+		"byName": types.NewMap(types.String("alice"), people.Get(0), types.String("bob"), people.Get(1)),
+		"byAge":  types.NewMap(types.Number(42), types.String("alice")),
+		"other":  suite.vs.WriteValue(types.NewList(types.Number(1))),
+	})
+}
+
+func (suite *JMESPathTestSuite) search(expr string) interface{} {
+	res, err := Search(expr, suite.root, suite)
+	suite.NoError(err, expr)
+	return res
+}
+
+func (suite *JMESPathTestSuite) TestFields() {
+	suite.Equal("alice", suite.search("people[0].name"))
+	suite.Equal(float64(25), suite.search("byName.bob.age"))
+	suite.Nil(suite.search("byName.dave"))
+	suite.Nil(suite.search("people.name"))
+	suite.Equal([]interface{}{"a", "b"}, suite.search("people[0].tags"))
+	suite.Equal([]interface{}{[]interface{}{float64(42), "alice"}}, suite.search("byAge"))
+
+	// Refs are followed when a query steps through them, but not within its result.
+	suite.Equal([]interface{}{float64(1)}, suite.search("other"))
+	other := suite.root.(types.Struct).Get("other").(types.Ref).TargetHash().String()
+	suite.Equal(map[string]interface{}{"@ref": other}, suite.search("@").(map[string]interface{})["other"])
+}
+
+func (suite *JMESPathTestSuite) TestProjections() {
+	suite.Equal([]interface{}{"alice", "carol"}, suite.search("people[?age > `30`].name"))
+	suite.Equal([]interface{}{"bob"}, suite.search("people[?!tags].name"))
+	suite.Equal([]interface{}{"a", "b", "c"}, suite.search("people[].tags[]"))
+	suite.Equal([]interface{}{float64(42), float64(25)}, suite.search("byName.*.age"))
+	suite.Equal([]interface{}{"carol", "bob"}, suite.search("people[::-1] | [:2].name"))
+	suite.Equal([]interface{}{"bob"}, suite.search("people[-2:-1].name"))
+	suite.Equal(map[string]interface{}{"n": "alice", "a": float64(42)}, suite.search("people[0].{n: name, a: age}"))
+	suite.Equal(true, suite.search("people[0].name == 'alice' && people[1].age < `30`"))
+	suite.Equal("alice", suite.search("people[?name == 'dave'] || people[0].name"))
+}
+
+func (suite *JMESPathTestSuite) TestFunctions() {
+	suite.Equal(float64(3), suite.search("length(people)"))
+	suite.Equal([]interface{}{"bob", "carol", "alice"}, suite.search("sort_by(people, &age)[].name"))
+	suite.Equal("alice", suite.search("max_by(people, &age).name"))
+	suite.Equal("bob", suite.search("min_by(people, &age).name"))
+	suite.Equal([]interface{}{float64(2), float64(0), float64(1)}, suite.search("map(&length(tags), people)"))
+	suite.Equal(float64(98), suite.search("sum(people[].age)"))
+	suite.Equal([]interface{}{"alice", "bob"}, suite.search("keys(byName)"))
+	suite.Equal(true, suite.search("contains(people[0].tags, 'b')"))
+
+	_, err := Search("sort_by(people, &tags)", suite.root, suite)
+	suite.Error(err)
+	_, err = Search("abs('a')", suite.root, suite)
+	suite.Error(err)
+}
+
+func (suite *JMESPathTestSuite) TestReadsOnlyWhatItNeeds() {
+	suite.search("byName.alice.name")
+	suite.Empty(suite.reads)
+
+	suite.search("people[1].name")
+	suite.Equal([]hash.Hash{suite.root.(types.Struct).Get("people").(types.Ref).TargetHash()}, suite.reads)
+}
+
+func (suite *JMESPathTestSuite) TestInvalidExpression() {
+	_, err := Compile("people[?")
+	suite.Error(err)
+	_, err = Search("people[::0]", suite.root, suite)
+	suite.Error(err)
+}
@@ -0,0 +1,220 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/nomstojson"
+)
+
+// Change is one line of the newline-delimited JSON that HandleDiff responds with.
+type Change struct {
+	// Path is the path, relative to the values being diffed, of the value that changed. Elements of Sets, and Map entries whose keys aren't primitives, are given by hash.
+	Path string `json:"path"`
+	// Type is "added", "removed" or "modified".
+	Type string `json:"type"`
+	// Old is the value before the change, as produced by nomstojson.NomsValueToDecodedJSON, or null if Type is "added".
+	Old interface{} `json:"old"`
+	// New is the value after the change, as produced by nomstojson.NomsValueToDecodedJSON, or null if Type is "removed".
+	New interface{} `json:"new"`
+}
+
+// HandleDiff is meant to handle HTTP GET requests to the diff/ server endpoint. The "from" and "to" query params are absolute paths, as understood by spec.NewAbsolutePath, that are resolved against the Database served from cs. The server streams the changes that turn from into to as newline-delimited JSON, one Change per line, or responds 404 if either path doesn't resolve to a value.
+//
+// Lists, Maps, Sets and Structs that have the same kind on both sides are descended into, so that changes are reported at the deepest path possible. Maps and Sets are compared with their ordered-sequence diff, which reads only the chunks of subtrees that differ.
+var HandleDiff = handleErrors(handleDiff)
+
+func handleDiff(w http.ResponseWriter, req *http.Request, ps datas.URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	db := datas.NewDatabase(cs)
+	q := req.URL.Query()
+	resolve := func(param string) types.Value {
+		absPath, err := spec.NewAbsolutePath(q.Get(param))
+		d.PanicIfTrue(err != nil, "Invalid %s: %s", param, err)
+		v, err := absPath.Resolve(db)
+		d.PanicIfError(err)
+		if v == nil {
+			http.Error(w, fmt.Sprintf("Error: %s not found", absPath), http.StatusNotFound)
+		}
+		return v
+	}
+	from := resolve("from")
+	if from == nil {
+		return
+	}
+	to := resolve("to")
+	if to == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	diffValues(types.NewPath(), from, to, func(c Change) {
+		d.PanicIfError(enc.Encode(c))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
+
+func shouldDescend(v1, v2 types.Value) bool {
+	kind := v1.Type().Kind()
+	return !types.IsPrimitiveKind(kind) && kind == v2.Type().Kind() && kind != types.RefKind
+}
+
+// diffValues calls cb with each Change that turns v1 into v2, which are found at p.
+func diffValues(p types.Path, v1, v2 types.Value, cb func(Change)) {
+	if v1.Equals(v2) {
+		return
+	}
+	if !shouldDescend(v1, v2) {
+		cb(newChange(p, types.DiffChangeModified, v1, v2))
+		return
+	}
+
+	switch v1 := v1.(type) {
+	case types.List:
+		diffLists(p, v1, v2.(types.List), cb)
+	case types.Map:
+		diffMaps(p, v1, v2.(types.Map), cb)
+	case types.Set:
+		diffSets(p, v1, v2.(types.Set), cb)
+	case types.Struct:
+		diffStructs(p, v1, v2.(types.Struct), cb)
+	default:
+		// Blobs aren't descended into.
+		cb(newChange(p, types.DiffChangeModified, v1, v2))
+	}
+}
+
+func diffLists(p types.Path, v1, v2 types.List, cb func(Change)) {
+	spliceChan := make(chan types.Splice)
+	stopChan := make(chan struct{}, 1) // buffer size of 1, so this won't block if diff already finished
+
+	defer stop(stopChan)
+	go func() {
+		v2.Diff(v1, spliceChan, stopChan)
+		close(spliceChan)
+	}()
+
+	for splice := range spliceChan {
+		if splice.SpRemoved == splice.SpAdded {
+			for i := uint64(0); i < splice.SpRemoved; i++ {
+				diffValues(p.AddIndex(types.Number(splice.SpAt+i)), v1.Get(splice.SpAt+i), v2.Get(splice.SpFrom+i), cb)
+			}
+			continue
+		}
+		for i := uint64(0); i < splice.SpRemoved; i++ {
+			cb(newChange(p.AddIndex(types.Number(splice.SpAt+i)), types.DiffChangeRemoved, v1.Get(splice.SpAt+i), nil))
+		}
+		for i := uint64(0); i < splice.SpAdded; i++ {
+			cb(newChange(p.AddIndex(types.Number(splice.SpFrom+i)), types.DiffChangeAdded, nil, v2.Get(splice.SpFrom+i)))
+		}
+	}
+}
+
+func diffMaps(p types.Path, v1, v2 types.Map, cb func(Change)) {
+	changeChan := make(chan types.ValueChanged)
+	stopChan := make(chan struct{}, 1) // buffer size of 1, so this won't block if diff already finished
+
+	defer stop(stopChan)
+	go func() {
+		v2.Diff(v1, changeChan, stopChan)
+		close(changeChan)
+	}()
+
+	for change := range changeChan {
+		kp := p.AddHashIndex(change.V.Hash())
+		if types.IsPrimitiveKind(change.V.Type().Kind()) {
+			kp = p.AddIndex(change.V)
+		}
+		switch change.ChangeType {
+		case types.DiffChangeAdded:
+			cb(newChange(kp, change.ChangeType, nil, v2.Get(change.V)))
+		case types.DiffChangeRemoved:
+			cb(newChange(kp, change.ChangeType, v1.Get(change.V), nil))
+		case types.DiffChangeModified:
+			diffValues(kp, v1.Get(change.V), v2.Get(change.V), cb)
+		}
+	}
+}
+
+func diffSets(p types.Path, v1, v2 types.Set, cb func(Change)) {
+	changeChan := make(chan types.ValueChanged)
+	stopChan := make(chan struct{}, 1) // buffer size of 1, so this won't block if diff already finished
+
+	defer stop(stopChan)
+	go func() {
+		v2.Diff(v1, changeChan, stopChan)
+		close(changeChan)
+	}()
+
+	for change := range changeChan {
+		ep := p.AddHashIndex(change.V.Hash())
+		switch change.ChangeType {
+		case types.DiffChangeAdded:
+			cb(newChange(ep, change.ChangeType, nil, change.V))
+		case types.DiffChangeRemoved:
+			cb(newChange(ep, change.ChangeType, change.V, nil))
+		}
+	}
+}
+
+func diffStructs(p types.Path, v1, v2 types.Struct, cb func(Change)) {
+	changeChan := make(chan types.ValueChanged)
+	stopChan := make(chan struct{}, 1) // buffer size of 1, so this won't block if diff already finished
+
+	defer stop(stopChan)
+	go func() {
+		// Unlike Map.Diff, Struct.Diff reports the changes from its receiver to its argument.
+		v1.Diff(v2, changeChan, stopChan)
+		close(changeChan)
+	}()
+
+	for change := range changeChan {
+		fn := string(change.V.(types.String))
+		switch change.ChangeType {
+		case types.DiffChangeAdded:
+			cb(newChange(p.AddField(fn), change.ChangeType, nil, v2.Get(fn)))
+		case types.DiffChangeRemoved:
+			cb(newChange(p.AddField(fn), change.ChangeType, v1.Get(fn), nil))
+		case types.DiffChangeModified:
+			diffValues(p.AddField(fn), v1.Get(fn), v2.Get(fn), cb)
+		}
+	}
+}
+
+func newChange(p types.Path, ct types.DiffChangeType, v1, v2 types.Value) Change {
+	c := Change{Path: p.String()}
+	switch ct {
+	case types.DiffChangeAdded:
+		c.Type = "added"
+	case types.DiffChangeRemoved:
+		c.Type = "removed"
+	case types.DiffChangeModified:
+		c.Type = "modified"
+	}
+	if v1 != nil {
+		c.Old = nomstojson.NomsValueToDecodedJSON(v1, DefaultLimit)
+	}
+	if v2 != nil {
+		c.New = nomstojson.NomsValueToDecodedJSON(v2, DefaultLimit)
+	}
+	return c
+}
+
+func stop(ch chan<- struct{}) {
+	ch <- struct{}{}
+}
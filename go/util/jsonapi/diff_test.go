@@ -0,0 +1,96 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// getDiff requests the diff between the from and to paths and returns the response status and decoded Changes.
+func getDiff(cs chunks.ChunkStore, from, to string) (int, []Change) {
+	q := url.Values{"from": {from}, "to": {to}}
+	req, _ := http.NewRequest("GET", "/diff/?"+q.Encode(), nil)
+	w := httptest.NewRecorder()
+	HandleDiff(w, req, params{}, cs)
+	changes := []Change{}
+	if w.Code == http.StatusOK {
+		dec := json.NewDecoder(w.Body)
+		for {
+			c := Change{}
+			if err := dec.Decode(&c); err == io.EOF {
+				break
+			} else if err != nil {
+				panic(err)
+			}
+			changes = append(changes, c)
+		}
+	}
+	return w.Code, changes
+}
+
+func TestHandleDiff(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+
+	s1 := types.NewStruct("S", types.StructData{
+		"list": types.NewList(types.Number(1), types.Number(2)),
+		"map":  types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2)),
+		"set":  types.NewSet(types.Bool(false)),
+		"name": types.String("one"),
+	})
+	s2 := types.NewStruct("S", types.StructData{
+		"list": types.NewList(types.Number(1), types.Number(3)),
+		"map":  types.NewMap(types.String("a"), types.Number(1), types.String("c"), types.Number(3)),
+		"set":  types.NewSet(types.Bool(true)),
+		"size": types.Number(2),
+	})
+	h1 := commit(cs, "ds1", s1)
+	commit(cs, "ds2", s2)
+
+	code, changes := getDiff(cs, "#"+h1+".value", "ds2.value")
+	assert.Equal(http.StatusOK, code)
+	paths := map[string]Change{}
+	for _, c := range changes {
+		paths[c.Path] = c
+	}
+	assert.Len(changes, 7)
+	assert.Equal(Change{Path: ".list[1]", Type: "modified", Old: float64(2), New: float64(3)}, paths[".list[1]"])
+	assert.Equal(Change{Path: `.map["b"]`, Type: "removed", Old: float64(2)}, paths[`.map["b"]`])
+	assert.Equal(Change{Path: `.map["c"]`, Type: "added", New: float64(3)}, paths[`.map["c"]`])
+	assert.Equal(Change{Path: ".name", Type: "removed", Old: "one"}, paths[".name"])
+	assert.Equal(Change{Path: ".size", Type: "added", New: float64(2)}, paths[".size"])
+	tp, fp := ".set[#"+types.Bool(true).Hash().String()+"]", ".set[#"+types.Bool(false).Hash().String()+"]"
+	assert.Equal(Change{Path: tp, Type: "added", New: true}, paths[tp])
+	assert.Equal(Change{Path: fp, Type: "removed", Old: false}, paths[fp])
+
+	code, changes = getDiff(cs, "ds1", "ds1")
+	assert.Equal(http.StatusOK, code)
+	assert.Empty(changes)
+
+	code, _ = getDiff(cs, "ds1", "nope")
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = getDiff(cs, "ds1", "")
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestHandleDiffDifferentKinds(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	commit(cs, "ds1", types.NewList(types.Number(1)))
+	commit(cs, "ds2", types.String("x"))
+
+	code, changes := getDiff(cs, "ds1.value", "ds2.value")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]Change{{Path: "", Type: "modified", Old: []interface{}{float64(1)}, New: "x"}}, changes)
+}
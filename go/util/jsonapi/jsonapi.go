@@ -0,0 +1,233 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package jsonapi serves Noms values as plain JSON, so that clients which can't speak the Noms chunk protocol can still read from a Database.
+package jsonapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/jsontonoms"
+	"github.com/attic-labs/noms/go/util/nomstojson"
+)
+
+const (
+	// DefaultLimit is the number of elements returned per page of a List, Map or Set when the request doesn't give a limit.
+	DefaultLimit = 100
+	// MaxLimit is the largest number of elements that a page of a List, Map or Set may hold.
+	MaxLimit = 1000
+	// DefaultBlobLimit is the number of bytes returned per page of a Blob when the request doesn't give a limit.
+	DefaultBlobLimit = 1 << 16
+	// MaxBlobLimit is the largest number of bytes that a page of a Blob may hold.
+	MaxBlobLimit = 1 << 20
+)
+
+// Response is the JSON object that HandleGet responds with.
+type Response struct {
+	// Path is the absolute path that was resolved, e.g. "ds.value.field" or "#0123456789abcdefghijklmnopqrstuv.field".
+	Path string `json:"path"`
+	// Hash is the hash of the resolved value.
+	Hash string `json:"hash"`
+	// Type describes the type of the resolved value.
+	Type string `json:"type"`
+	// Length is the number of elements (or bytes, for a Blob) in the resolved value, if it's a collection.
+	Length uint64 `json:"length,omitempty"`
+	// Value is the resolved value, or one page of it if it's a collection, as produced by nomstojson.NomsValueToDecodedJSON. A page of a Blob is a base64-encoded string.
+	Value interface{} `json:"value"`
+	// Next is the URL of the following page, relative to the server, or empty if this is the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// HandleGet is meant to handle HTTP GET requests to the json/*path server endpoint. The path param is an absolute path, as understood by spec.NewAbsolutePath, that is resolved against the Database served from cs. The server responds with a Response, or 404 if the path doesn't resolve to a value.
+//
+// Collections are returned one page at a time:
+//  - Lists take "offset" (an index) and "limit" (a number of elements) query params.
+//  - Blobs take "offset" and "limit" too, both in bytes.
+//  - Maps and Sets take "start", the first key to include, and "limit". start is written as it would be inside [] in a path: a JSON string, number or boolean, or # followed by the hash of a key that isn't one of those.
+// Response.Next gives the query params for the following page. Collections nested inside a page that have more than limit elements are summarized rather than returned.
+var HandleGet = handleErrors(handleGet)
+
+func handleErrors(hndlr datas.Handler) datas.Handler {
+	return func(w http.ResponseWriter, req *http.Request, ps datas.URLParams, cs chunks.ChunkStore) {
+		err := d.Try(func() { hndlr(w, req, ps, cs) })
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+func handleGet(w http.ResponseWriter, req *http.Request, ps datas.URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	absPath, err := spec.NewAbsolutePath(strings.TrimPrefix(ps.ByName("path"), "/"))
+	d.PanicIfError(err)
+	// Closing the Database would close cs, which the server still needs.
+	v, err := absPath.Resolve(datas.NewDatabase(cs))
+	d.PanicIfError(err)
+	if v == nil {
+		http.Error(w, fmt.Sprintf("Error: %s not found", absPath), http.StatusNotFound)
+		return
+	}
+
+	resp := Response{Path: absPath.String(), Hash: v.Hash().String(), Type: v.Type().Describe()}
+	q := req.URL.Query()
+	next := func(param, val string) {
+		q.Set(param, val)
+		u := *req.URL
+		u.RawQuery = q.Encode()
+		resp.Next = u.RequestURI()
+	}
+
+	switch v := v.(type) {
+	case types.Blob:
+		offset, limit := parseUintParam(q, "offset", 0, v.Len()), parseLimitParam(q, DefaultBlobLimit, MaxBlobLimit)
+		resp.Length = v.Len()
+		r := v.Reader()
+		_, err := r.Seek(int64(offset), 0)
+		d.PanicIfError(err)
+		buf := make([]byte, min(limit, v.Len()-offset))
+		_, err = io.ReadFull(r, buf)
+		d.PanicIfError(err)
+		resp.Value = base64.StdEncoding.EncodeToString(buf)
+		if end := offset + uint64(len(buf)); end < v.Len() {
+			next("offset", strconv.FormatUint(end, 10))
+		}
+	case types.List:
+		offset, limit := parseUintParam(q, "offset", 0, v.Len()), parseLimitParam(q, DefaultLimit, MaxLimit)
+		resp.Length = v.Len()
+		items := []types.Value{}
+		if offset < v.Len() {
+			v.IterFrom(offset, func(item types.Value, idx uint64) bool {
+				items = append(items, item)
+				return uint64(len(items)) == limit
+			})
+		}
+		resp.Value = nomstojson.NomsValueToDecodedJSON(types.NewList(items...), limit)
+		if end := offset + uint64(len(items)); end < v.Len() {
+			next("offset", strconv.FormatUint(end, 10))
+		}
+	case types.Map:
+		start, limit := parseStartParam(q, v), parseLimitParam(q, DefaultLimit, MaxLimit)
+		resp.Length = v.Len()
+		kvs := []types.Value{}
+		var nextKey types.Value
+		v.IterFrom(start, func(key, val types.Value) bool {
+			if uint64(len(kvs)) == 2*limit {
+				nextKey = key
+				return true
+			}
+			kvs = append(kvs, key, val)
+			return false
+		})
+		resp.Value = nomstojson.NomsValueToDecodedJSON(types.NewMap(kvs...), limit)
+		if nextKey != nil {
+			next("start", encodeStart(nextKey))
+		}
+	case types.Set:
+		start, limit := parseStartParam(q, v), parseLimitParam(q, DefaultLimit, MaxLimit)
+		resp.Length = v.Len()
+		items := []types.Value{}
+		var nextItem types.Value
+		v.IterFrom(start, func(item types.Value) bool {
+			if uint64(len(items)) == limit {
+				nextItem = item
+				return true
+			}
+			items = append(items, item)
+			return false
+		})
+		resp.Value = nomstojson.NomsValueToDecodedJSON(types.NewSet(items...), limit)
+		if nextItem != nil {
+			next("start", encodeStart(nextItem))
+		}
+	default:
+		resp.Value = nomstojson.NomsValueToDecodedJSON(v, DefaultLimit)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	d.PanicIfError(json.NewEncoder(w).Encode(resp))
+}
+
+// parseUintParam returns the value of the query param named name, def if it's absent, or max if it's larger than max.
+func parseUintParam(q map[string][]string, name string, def, max uint64) uint64 {
+	str := ""
+	if vals := q[name]; len(vals) > 0 {
+		str = vals[0]
+	}
+	if str == "" {
+		return min(def, max)
+	}
+	n, err := strconv.ParseUint(str, 10, 64)
+	d.PanicIfTrue(err != nil, "Invalid %s: %s", name, str)
+	return min(n, max)
+}
+
+// parseLimitParam is like parseUintParam for the "limit" query param, which must not be zero.
+func parseLimitParam(q map[string][]string, def, max uint64) uint64 {
+	limit := parseUintParam(q, "limit", def, max)
+	d.PanicIfTrue(limit == 0, "Invalid limit: 0")
+	return limit
+}
+
+// parseStartParam returns the key named by the "start" query param, as described by HandleGet, or nil if it's absent.
+func parseStartParam(q map[string][]string, c types.Value) types.Value {
+	str := ""
+	if vals := q["start"]; len(vals) > 0 {
+		str = vals[0]
+	}
+	if str == "" {
+		return nil
+	}
+
+	if str[0] == '#' {
+		pathStr := "[" + str + "]"
+		if _, ok := c.(types.Map); ok {
+			pathStr += "@key"
+		}
+		p, err := types.ParsePath(pathStr)
+		d.PanicIfError(err)
+		key := p.Resolve(c)
+		d.PanicIfTrue(key == nil, "Invalid start: %s not found", str)
+		return key
+	}
+
+	var decoded interface{}
+	d.PanicIfError(json.Unmarshal([]byte(str), &decoded))
+	switch decoded.(type) {
+	case string, float64, bool:
+		return jsontonoms.NomsValueFromDecodedJSON(decoded, false)
+	}
+	d.PanicIfTrue(true, "Invalid start: %s", str)
+	return nil
+}
+
+// encodeStart returns the "start" query param that names key, as described by HandleGet.
+func encodeStart(key types.Value) string {
+	switch key := key.(type) {
+	case types.Bool, types.Number, types.String:
+		b, err := json.Marshal(nomstojson.NomsValueToDecodedJSON(key, 0))
+		d.PanicIfError(err)
+		return string(b)
+	}
+	return "#" + key.Hash().String()
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,180 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/nomstojson"
+	"github.com/attic-labs/testify/assert"
+)
+
+type params map[string]string
+
+func (p params) ByName(k string) string {
+	return p[k]
+}
+
+// commit commits v to datasetID and returns the hash of the new head.
+func commit(cs chunks.ChunkStore, datasetID string, v types.Value) string {
+	db, err := datas.NewDatabase(cs).Commit(datasetID, datas.NewCommit(v, types.NewSet(), types.EmptyStruct))
+	if err != nil {
+		panic(err)
+	}
+	return db.Head(datasetID).Hash().String()
+}
+
+// get requests the JSON for uri, which is the URL path after /json/ plus any query, and returns the response status and decoded body.
+func get(cs chunks.ChunkStore, uri string) (int, Response) {
+	u, err := url.Parse("/json/" + uri)
+	if err != nil {
+		panic(err)
+	}
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	w := httptest.NewRecorder()
+	HandleGet(w, req, params{"path": u.Path[len("/json"):]}, cs)
+	resp := Response{}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			panic(err)
+		}
+	}
+	return w.Code, resp
+}
+
+// getNext follows resp.Next, which is relative to the server.
+func getNext(cs chunks.ChunkStore, resp Response) (int, Response) {
+	return get(cs, resp.Next[len("/json/"):])
+}
+
+func TestHandleGetValue(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	st := types.NewStruct("S", types.StructData{"a": types.Number(1), "b": types.String("two")})
+	h := commit(cs, "ds", st)
+
+	code, resp := get(cs, "ds.value")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("ds.value", resp.Path)
+	assert.Equal(st.Hash().String(), resp.Hash)
+	assert.Equal(map[string]interface{}{"a": float64(1), "b": "two"}, resp.Value)
+	assert.Empty(resp.Next)
+
+	code, resp = get(cs, "%23"+h+".value.b")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("two", resp.Value)
+	assert.Equal("String", resp.Type)
+
+	code, _ = get(cs, "ds.value.c")
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = get(cs, "other")
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = get(cs, "ds.value[")
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestHandleGetListPages(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	commit(cs, "ds", types.NewList(types.Number(0), types.Number(1), types.Number(2), types.Number(3), types.Number(4)))
+
+	code, resp := get(cs, "ds.value?limit=2")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(uint64(5), resp.Length)
+	assert.Equal([]interface{}{float64(0), float64(1)}, resp.Value)
+
+	code, resp = getNext(cs, resp)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]interface{}{float64(2), float64(3)}, resp.Value)
+
+	code, resp = getNext(cs, resp)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]interface{}{float64(4)}, resp.Value)
+	assert.Empty(resp.Next)
+
+	code, _ = get(cs, "ds.value?limit=0")
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestHandleGetMapPages(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	commit(cs, "ds", types.NewMap(
+		types.String("a"), types.Number(1),
+		types.String("b"), types.Number(2),
+		types.String("c"), types.Number(3)))
+
+	code, resp := get(cs, "ds.value?limit=2")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(uint64(3), resp.Length)
+	assert.Equal(map[string]interface{}{"a": float64(1), "b": float64(2)}, resp.Value)
+
+	code, resp = getNext(cs, resp)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(map[string]interface{}{"c": float64(3)}, resp.Value)
+	assert.Empty(resp.Next)
+
+	code, resp = get(cs, `ds.value?start="b"`)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(map[string]interface{}{"b": float64(2), "c": float64(3)}, resp.Value)
+
+	code, _ = get(cs, "ds.value?start=[1]")
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestHandleGetSetPagesByHash(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	s := types.NewSet(
+		types.NewStruct("S", types.StructData{"n": types.Number(1)}),
+		types.NewStruct("S", types.StructData{"n": types.Number(2)}),
+		types.NewStruct("S", types.StructData{"n": types.Number(3)}))
+	commit(cs, "ds", s)
+
+	seen := []interface{}{}
+	code, resp := get(cs, "ds.value?limit=1")
+	for {
+		assert.Equal(http.StatusOK, code)
+		seen = append(seen, resp.Value.([]interface{})...)
+		if resp.Next == "" {
+			break
+		}
+		code, resp = getNext(cs, resp)
+	}
+	assert.Len(seen, 3)
+	assert.Equal(nomstojson.NomsValueToDecodedJSON(s, 0), seen)
+}
+
+func TestHandleGetBlobPages(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	data := []byte("0123456789")
+	commit(cs, "ds", types.NewBlob(bytes.NewReader(data)))
+
+	got := []byte{}
+	code, resp := get(cs, "ds.value?limit=4")
+	for {
+		assert.Equal(http.StatusOK, code)
+		assert.Equal(uint64(len(data)), resp.Length)
+		page, err := base64.StdEncoding.DecodeString(resp.Value.(string))
+		assert.NoError(err)
+		assert.True(len(page) <= 4)
+		got = append(got, page...)
+		if resp.Next == "" {
+			break
+		}
+		code, resp = getNext(cs, resp)
+	}
+	assert.Equal(data, got)
+}
@@ -0,0 +1,320 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nomstojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// NomsValueToDecodedJSON converts v into a generic Go value of the kind produced by encoding/json when decoding into an interface{}, so that it can be marshaled as JSON. It's the inverse of jsontonoms.NomsValueFromDecodedJSON, as far as JSON allows:
+//
+// Primitives:
+//  - Number becomes float64
+//  - Bool becomes bool
+//  - String becomes string
+//
+// Composites:
+//  - List and Set become []interface{}
+//  - Map with String keys becomes map[string]interface{}; any other Map becomes []interface{} of [key, value] pairs
+//  - Struct becomes map[string]interface{} of its fields
+//
+// Values that JSON can't represent directly become single-entry objects:
+//  - Ref becomes {"@ref": "<target hash>"}
+//  - Blob becomes {"@blob": <length in bytes>}
+//  - Type becomes {"@type": "<description>"}
+//
+// If maxElements is non-zero, any collection with more elements than that becomes {"@collection": "<type>", "@length": <length>} rather than being converted, so that the result stays a manageable size. WriteJSON shows the first elements of such a collection instead, and doesn't hold the result in memory.
+func NomsValueToDecodedJSON(v types.Value, maxElements uint64) interface{} {
+	if c, ok := v.(types.Collection); ok && maxElements > 0 && c.Len() > maxElements {
+		if _, isBlob := v.(types.Blob); !isBlob {
+			return map[string]interface{}{"@collection": v.Type().Describe(), "@length": c.Len()}
+		}
+	}
+
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.Blob:
+		return map[string]interface{}{"@blob": v.Len()}
+	case types.List:
+		items := make([]interface{}, 0, v.Len())
+		v.IterAll(func(item types.Value, idx uint64) {
+			items = append(items, NomsValueToDecodedJSON(item, maxElements))
+		})
+		return items
+	case types.Set:
+		items := make([]interface{}, 0, v.Len())
+		v.IterAll(func(item types.Value) {
+			items = append(items, NomsValueToDecodedJSON(item, maxElements))
+		})
+		return items
+	case types.Map:
+		if hasStringKeys(v) {
+			obj := make(map[string]interface{}, v.Len())
+			v.IterAll(func(key, val types.Value) {
+				obj[string(key.(types.String))] = NomsValueToDecodedJSON(val, maxElements)
+			})
+			return obj
+		}
+		pairs := make([]interface{}, 0, v.Len())
+		v.IterAll(func(key, val types.Value) {
+			pairs = append(pairs, []interface{}{NomsValueToDecodedJSON(key, maxElements), NomsValueToDecodedJSON(val, maxElements)})
+		})
+		return pairs
+	case types.Struct:
+		obj := map[string]interface{}{}
+		v.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			obj[name] = NomsValueToDecodedJSON(v.Get(name), maxElements)
+		})
+		return obj
+	case types.Ref:
+		return map[string]interface{}{"@ref": v.TargetHash().String()}
+	case *types.Type:
+		return map[string]interface{}{"@type": v.Describe()}
+	}
+	d.Chk.Fail("Unreachable", "Unknown Value of type %s", v.Type().Describe())
+	return nil
+}
+
+// hasStringKeys returns true if every key of m is a String, as JSON object keys must be.
+func hasStringKeys(m types.Map) bool {
+	t := m.Type().Desc.(types.CompoundDesc).ElemTypes[0]
+	if t.Kind() == types.StringKind {
+		return true
+	}
+	if t.Kind() != types.UnionKind {
+		return false
+	}
+	for _, et := range t.Desc.(types.CompoundDesc).ElemTypes {
+		if et.Kind() != types.StringKind {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteJSON writes v to w as indented JSON, in the form NomsValueToDecodedJSON gives it, without building the whole result in memory first. What opts says to leave out is replaced by a count of what's missing:
+//  - past MaxElements, the rest of the elements of a List, Set or Map become {"@more": <count>} at the end of its array, or a "@more" entry at the end of its object
+//  - beyond MaxDepth, the contents of a collection or struct become [{"@more": <count>}] or {"@more": <count>}
+func WriteJSON(w io.Writer, v types.Value, opts types.ElisionOptions) error {
+	jw := &jsonWriter{w: w, opts: opts}
+	jw.writeValue(v)
+	return jw.err
+}
+
+// jsonWriter writes the JSON for a Value as it goes, keeping track of how deeply the collections and structs being written are nested.
+type jsonWriter struct {
+	w     io.Writer
+	opts  types.ElisionOptions
+	ind   int
+	depth int
+	err   error
+}
+
+func (w *jsonWriter) write(s string) {
+	if w.err == nil {
+		_, w.err = io.WriteString(w.w, s)
+	}
+}
+
+func (w *jsonWriter) newLine() {
+	w.write("\n" + strings.Repeat("  ", w.ind))
+}
+
+func (w *jsonWriter) writeScalar(v interface{}) {
+	if w.err != nil {
+		return
+	}
+	buff := &bytes.Buffer{}
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	if w.err = enc.Encode(v); w.err == nil {
+		w.write(strings.TrimSuffix(buff.String(), "\n"))
+	}
+}
+
+// jsonContainer is a JSON array or object being written.
+type jsonContainer struct {
+	w     *jsonWriter
+	close string
+	n     int
+}
+
+func (w *jsonWriter) open(open, close string) *jsonContainer {
+	w.write(open)
+	w.ind++
+	return &jsonContainer{w, close, 0}
+}
+
+// elem starts the next element of an array.
+func (c *jsonContainer) elem() {
+	if c.n > 0 {
+		c.w.write(",")
+	}
+	c.n++
+	c.w.newLine()
+}
+
+// key starts the next entry of an object.
+func (c *jsonContainer) key(k string) {
+	c.elem()
+	c.w.writeScalar(k)
+	c.w.write(": ")
+}
+
+func (c *jsonContainer) end() {
+	c.w.ind--
+	if c.n > 0 {
+		c.w.newLine()
+	}
+	c.w.write(c.close)
+}
+
+// writeOpaque writes a single-entry object standing in for a Value JSON can't represent, or for elided elements.
+func (w *jsonWriter) writeOpaque(k string, v interface{}) {
+	c := w.open("{", "}")
+	c.key(k)
+	w.writeScalar(v)
+	c.end()
+}
+
+// enter opens the array or object holding the n elements or fields of a collection or struct. If it's nested too deeply for them to be written, it writes a count in their place and returns nil.
+func (w *jsonWriter) enter(open, close string, n uint64) *jsonContainer {
+	c := w.open(open, close)
+	if w.opts.MaxDepth != 0 && w.depth >= w.opts.MaxDepth && n > 0 {
+		if open == "[" {
+			c.elem()
+			w.writeOpaque("@more", n)
+		} else {
+			c.key("@more")
+			w.writeScalar(n)
+		}
+		c.end()
+		return nil
+	}
+	w.depth++
+	return c
+}
+
+func (w *jsonWriter) leave(c *jsonContainer) {
+	w.depth--
+	c.end()
+}
+
+// elide writes a count in place of the ith and later of the n elements of a collection, if i is MaxElements, and reports whether it did.
+func (w *jsonWriter) elide(c *jsonContainer, i, n uint64) bool {
+	if w.opts.MaxElements == 0 || i < w.opts.MaxElements {
+		return false
+	}
+	if c.close == "]" {
+		c.elem()
+		w.writeOpaque("@more", n-i)
+	} else {
+		c.key("@more")
+		w.writeScalar(n - i)
+	}
+	return true
+}
+
+func (w *jsonWriter) writeValue(v types.Value) {
+	switch v := v.(type) {
+	case types.Bool:
+		w.writeScalar(bool(v))
+	case types.Number:
+		w.writeScalar(float64(v))
+	case types.String:
+		w.writeScalar(string(v))
+	case types.Blob:
+		w.writeOpaque("@blob", v.Len())
+	case types.List:
+		c := w.enter("[", "]", v.Len())
+		if c == nil {
+			return
+		}
+		v.Iter(func(item types.Value, i uint64) bool {
+			if w.elide(c, i, v.Len()) {
+				return true
+			}
+			c.elem()
+			w.writeValue(item)
+			return w.err != nil
+		})
+		w.leave(c)
+	case types.Set:
+		c := w.enter("[", "]", v.Len())
+		if c == nil {
+			return
+		}
+		i := uint64(0)
+		v.Iter(func(item types.Value) bool {
+			if w.elide(c, i, v.Len()) {
+				return true
+			}
+			i++
+			c.elem()
+			w.writeValue(item)
+			return w.err != nil
+		})
+		w.leave(c)
+	case types.Map:
+		stringKeys := hasStringKeys(v)
+		open, close := "[", "]"
+		if stringKeys {
+			open, close = "{", "}"
+		}
+		c := w.enter(open, close, v.Len())
+		if c == nil {
+			return
+		}
+		i := uint64(0)
+		v.Iter(func(key, val types.Value) bool {
+			if w.elide(c, i, v.Len()) {
+				return true
+			}
+			i++
+			if stringKeys {
+				c.key(string(key.(types.String)))
+				w.writeValue(val)
+			} else {
+				c.elem()
+				pair := w.open("[", "]")
+				pair.elem()
+				w.writeValue(key)
+				pair.elem()
+				w.writeValue(val)
+				pair.end()
+			}
+			return w.err != nil
+		})
+		w.leave(c)
+	case types.Struct:
+		desc := v.Type().Desc.(types.StructDesc)
+		c := w.enter("{", "}", uint64(desc.Len()))
+		if c == nil {
+			return
+		}
+		desc.IterFields(func(name string, t *types.Type) {
+			c.key(name)
+			w.writeValue(v.Get(name))
+		})
+		w.leave(c)
+	case types.Ref:
+		w.writeOpaque("@ref", v.TargetHash().String())
+	case *types.Type:
+		w.writeOpaque("@type", v.Describe())
+	default:
+		d.Chk.Fail("Unreachable", "Unknown Value of type %s", v.Type().Describe())
+	}
+}
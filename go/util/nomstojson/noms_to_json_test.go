@@ -0,0 +1,101 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nomstojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestLibTestSuite(t *testing.T) {
+	suite.Run(t, &LibTestSuite{})
+}
+
+type LibTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LibTestSuite) TestPrimitiveTypes() {
+	suite.Equal("expected", NomsValueToDecodedJSON(types.String("expected"), 0))
+	suite.Equal(false, NomsValueToDecodedJSON(types.Bool(false), 0))
+	suite.Equal(1.7, NomsValueToDecodedJSON(types.Number(1.7), 0))
+}
+
+func (suite *LibTestSuite) TestCompositeTypes() {
+	l := types.NewList(types.Bool(false), types.Bool(true))
+	suite.Equal([]interface{}{false, true}, NomsValueToDecodedJSON(l, 0))
+
+	s := types.NewSet(types.Number(2), types.Number(1))
+	suite.Equal([]interface{}{float64(1), float64(2)}, NomsValueToDecodedJSON(s, 0))
+
+	m := types.NewMap(
+		types.String("string"), types.String("string"),
+		types.String("list"), l,
+		types.String("map"), types.NewMap(types.String("nested"), types.String("string")))
+	suite.Equal(map[string]interface{}{
+		"string": "string",
+		"list":   []interface{}{false, true},
+		"map":    map[string]interface{}{"nested": "string"},
+	}, NomsValueToDecodedJSON(m, 0))
+
+	nm := types.NewMap(types.Number(1), types.String("one"), types.Bool(true), types.String("yes"))
+	suite.Equal([]interface{}{
+		[]interface{}{true, "yes"},
+		[]interface{}{float64(1), "one"},
+	}, NomsValueToDecodedJSON(nm, 0))
+
+	st := types.NewStruct("S", types.StructData{"a": types.Number(1), "b": l})
+	suite.Equal(map[string]interface{}{"a": float64(1), "b": []interface{}{false, true}}, NomsValueToDecodedJSON(st, 0))
+}
+
+func (suite *LibTestSuite) TestOpaqueTypes() {
+	n := types.Number(42)
+	suite.Equal(map[string]interface{}{"@ref": n.Hash().String()}, NomsValueToDecodedJSON(types.NewRef(n), 0))
+	suite.Equal(map[string]interface{}{"@type": "Number"}, NomsValueToDecodedJSON(types.NumberType, 0))
+	suite.Equal(map[string]interface{}{"@blob": uint64(0)}, NomsValueToDecodedJSON(types.NewEmptyBlob(), 0))
+}
+
+func (suite *LibTestSuite) TestMaxElements() {
+	inner := types.NewList(types.Number(1), types.Number(2), types.Number(3))
+	outer := types.NewList(inner, types.NewList(types.Number(4)))
+	suite.Equal([]interface{}{
+		map[string]interface{}{"@collection": "List<Number>", "@length": uint64(3)},
+		[]interface{}{float64(4)},
+	}, NomsValueToDecodedJSON(outer, 2))
+}
+
+func (suite *LibTestSuite) TestWriteJSON() {
+	write := func(v types.Value, opts types.ElisionOptions) string {
+		buff := &bytes.Buffer{}
+		suite.NoError(WriteJSON(buff, v, opts))
+		return buff.String()
+	}
+
+	// Without options, the result is just what encoding/json would make of NomsValueToDecodedJSON.
+	l := types.NewList(types.Bool(false), types.String("<b>"))
+	v := types.NewStruct("S", types.StructData{
+		"list":  l,
+		"empty": types.NewSet(),
+		"map":   types.NewMap(types.String("b"), types.Number(2), types.String("a"), types.NewRef(l)),
+		"pairs": types.NewMap(types.Number(1), types.String("one")),
+		"blob":  types.NewEmptyBlob(),
+		"type":  types.NumberType,
+	})
+	expected := &bytes.Buffer{}
+	enc := json.NewEncoder(expected)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	suite.NoError(enc.Encode(NomsValueToDecodedJSON(v, 0)))
+	suite.Equal(strings.TrimSuffix(expected.String(), "\n"), write(v, types.ElisionOptions{}))
+
+	m := types.NewMap(types.String("a"), l, types.String("b"), types.Number(2), types.String("c"), types.Number(3))
+	suite.Equal("{\n  \"a\": [\n    false,\n    {\n      \"@more\": 1\n    }\n  ],\n  \"@more\": 2\n}", write(m, types.ElisionOptions{MaxElements: 1}))
+	suite.Equal("{\n  \"a\": [\n    {\n      \"@more\": 2\n    }\n  ],\n  \"b\": 2,\n  \"c\": 3\n}", write(m, types.ElisionOptions{MaxDepth: 1}))
+}
@@ -0,0 +1,469 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// The pseudo-columns of a row, which are there whatever its value is: the key of a Map entry, the index of a List element, and the entry's or element's value itself.
+const (
+	keyColumn   = "_key"
+	indexColumn = "_index"
+	valueColumn = "_value"
+)
+
+// expr is an expression in a query. A nil types.Value is NULL.
+type expr interface{}
+
+type literal struct {
+	v types.Value
+}
+
+// column names a column of the row, and then a field of it, and then a field of that, and so on.
+type column []string
+
+type unary struct {
+	op string
+	x  expr
+}
+
+type binary struct {
+	op   string
+	l, r expr
+}
+
+type isNull struct {
+	x   expr
+	not bool
+}
+
+type in struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+type between struct {
+	x, lo, hi expr
+	not       bool
+}
+
+type like struct {
+	x, pattern expr
+	not        bool
+}
+
+// call is a call of a function. If it's an aggregate, agg is its index in Query.aggs; otherwise it's -1.
+type call struct {
+	name string
+	args []expr
+	star bool
+	agg  int
+}
+
+// row is what a query reads from each element of the table. key is nil, and index is -1, if the table doesn't have them.
+type row struct {
+	key   types.Value
+	index int64
+	value types.Value
+}
+
+type evalError struct {
+	msg string
+}
+
+func (e evalError) Error() string {
+	return e.msg
+}
+
+func fail(format string, args ...interface{}) {
+	panic(d.Wrap(evalError{fmt.Sprintf(format, args...)}))
+}
+
+type evaluator struct {
+	vr types.ValueReader
+	// aggValues are the values of the aggregates over the group of rows being evaluated, if there is one.
+	aggValues []types.Value
+	patterns  map[string]*regexp.Regexp
+}
+
+// deref reads the targets of Refs, so that queries see through them.
+func (ev *evaluator) deref(v types.Value) types.Value {
+	for {
+		r, ok := v.(types.Ref)
+		if !ok {
+			return v
+		}
+		v = ev.vr.ReadValue(r.TargetHash())
+		if v == nil {
+			fail("Value not found: %s", r.TargetHash())
+		}
+	}
+}
+
+// eval evaluates e against r, which is nil if there are no rows in the group being evaluated.
+func (ev *evaluator) eval(e expr, r *row) types.Value {
+	switch e := e.(type) {
+	case literal:
+		return e.v
+	case column:
+		return ev.column(e, r)
+	case unary:
+		x := ev.eval(e.x, r)
+		if x == nil {
+			return nil
+		}
+		if e.op == "NOT" {
+			return types.Bool(!ev.bool(x))
+		}
+		return -ev.number(x)
+	case binary:
+		return ev.binary(e, r)
+	case isNull:
+		return types.Bool((ev.eval(e.x, r) == nil) != e.not)
+	case in:
+		x := ev.eval(e.x, r)
+		if x == nil {
+			return nil
+		}
+		var res types.Value = types.Bool(false)
+		for _, le := range e.list {
+			c, ok := compare(x, ev.eval(le, r))
+			if ok && c == 0 {
+				res = types.Bool(true)
+				break
+			} else if !ok {
+				res = nil
+			}
+		}
+		return not(res, e.not)
+	case between:
+		x := ev.eval(e.x, r)
+		lo, ok1 := compare(x, ev.eval(e.lo, r))
+		hi, ok2 := compare(x, ev.eval(e.hi, r))
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return not(types.Bool(lo >= 0 && hi <= 0), e.not)
+	case like:
+		x, pattern := ev.eval(e.x, r), ev.eval(e.pattern, r)
+		if x == nil || pattern == nil {
+			return nil
+		}
+		return not(types.Bool(ev.likePattern(ev.string(pattern)).MatchString(ev.string(x))), e.not)
+	case *call:
+		if e.agg >= 0 {
+			return ev.aggValues[e.agg]
+		}
+		args := make([]types.Value, len(e.args))
+		for i, a := range e.args {
+			args[i] = ev.eval(a, r)
+		}
+		return scalarFunctions[e.name](ev, args)
+	}
+	panic("unreachable")
+}
+
+func not(v types.Value, not bool) types.Value {
+	if v == nil || !not {
+		return v
+	}
+	return types.Bool(!bool(v.(types.Bool)))
+}
+
+// column looks up c in r. A field that isn't there, or that's looked for in something that isn't a struct, is NULL.
+func (ev *evaluator) column(c column, r *row) types.Value {
+	if r == nil {
+		return nil
+	}
+	var v types.Value
+	fields := c
+	switch c[0] {
+	case keyColumn:
+		v, fields = r.key, c[1:]
+	case indexColumn:
+		if r.index >= 0 {
+			v = types.Number(r.index)
+		}
+		fields = c[1:]
+	case valueColumn:
+		v, fields = r.value, c[1:]
+	default:
+		v = r.value
+	}
+	for _, f := range fields {
+		s, ok := ev.deref(v).(types.Struct)
+		if !ok {
+			return nil
+		}
+		if v, ok = s.MaybeGet(f); !ok {
+			return nil
+		}
+	}
+	if v == nil {
+		return nil
+	}
+	return ev.deref(v)
+}
+
+func (ev *evaluator) binary(e binary, r *row) types.Value {
+	l := ev.eval(e.l, r)
+	switch e.op {
+	case "AND", "OR":
+		// NULL AND false is false, and NULL OR true is true; otherwise, AND and OR of NULL are NULL.
+		short := e.op == "OR"
+		if l != nil && ev.bool(l) == short {
+			return types.Bool(short)
+		}
+		rv := ev.eval(e.r, r)
+		if rv != nil && ev.bool(rv) == short {
+			return types.Bool(short)
+		}
+		if l == nil || rv == nil {
+			return nil
+		}
+		return types.Bool(!short)
+	}
+
+	rv := ev.eval(e.r, r)
+	if l == nil || rv == nil {
+		return nil
+	}
+	switch e.op {
+	case "=", "<>", "<", "<=", ">", ">=":
+		c, ok := compare(l, rv)
+		if !ok {
+			return nil
+		}
+		return types.Bool(map[string]bool{"=": c == 0, "<>": c != 0, "<": c < 0, "<=": c <= 0, ">": c > 0, ">=": c >= 0}[e.op])
+	case "||":
+		return types.String(ev.string(l) + ev.string(rv))
+	}
+
+	a, b := ev.number(l), ev.number(rv)
+	switch e.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	}
+	if b == 0 {
+		return nil
+	}
+	if e.op == "/" {
+		return a / b
+	}
+	return types.Number(math.Mod(float64(a), float64(b)))
+}
+
+// compare orders a and b, if they're of the same kind and neither is NULL. Bools, Numbers and Strings are ordered by value; other values are only equal or not.
+func compare(a, b types.Value) (c int, ok bool) {
+	if a == nil || b == nil || a.Type().Kind() != b.Type().Kind() {
+		return 0, false
+	}
+	switch {
+	case a.Equals(b):
+		return 0, true
+	case a.Less(b):
+		return -1, true
+	default:
+		return 1, true
+	}
+}
+
+// less orders all values, for sorting: NULL is first, then values of different kinds are ordered as they are in a Map.
+func less(a, b types.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Less(b)
+}
+
+func (ev *evaluator) bool(v types.Value) bool {
+	b, ok := v.(types.Bool)
+	if !ok {
+		fail("Expected a Bool, found %s", types.EncodedValue(v))
+	}
+	return bool(b)
+}
+
+func (ev *evaluator) number(v types.Value) types.Number {
+	n, ok := v.(types.Number)
+	if !ok {
+		fail("Expected a Number, found %s", types.EncodedValue(v))
+	}
+	return n
+}
+
+func (ev *evaluator) string(v types.Value) string {
+	s, ok := v.(types.String)
+	if !ok {
+		fail("Expected a String, found %s", types.EncodedValue(v))
+	}
+	return string(s)
+}
+
+// likePattern compiles a LIKE pattern, in which % matches any run of characters and _ matches any one.
+func (ev *evaluator) likePattern(pattern string) *regexp.Regexp {
+	if re, ok := ev.patterns[pattern]; ok {
+		return re
+	}
+	re := "^"
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			re += ".*"
+		case '_':
+			re += "."
+		default:
+			re += regexp.QuoteMeta(string(c))
+		}
+	}
+	if ev.patterns == nil {
+		ev.patterns = map[string]*regexp.Regexp{}
+	}
+	ev.patterns[pattern] = regexp.MustCompile("(?s)" + re + "$")
+	return ev.patterns[pattern]
+}
+
+type scalarFunction func(ev *evaluator, args []types.Value) types.Value
+
+// scalarFunctions are those of one row. Unless they say otherwise, they take one argument, and are NULL if it is.
+var scalarFunctions = map[string]scalarFunction{
+	"abs": unaryFunction(func(ev *evaluator, v types.Value) types.Value {
+		return types.Number(math.Abs(float64(ev.number(v))))
+	}),
+	"length": unaryFunction(func(ev *evaluator, v types.Value) types.Value {
+		if s, ok := v.(types.String); ok {
+			return types.Number(len([]rune(string(s))))
+		}
+		if c, ok := v.(types.Collection); ok {
+			return types.Number(c.Len())
+		}
+		if b, ok := v.(types.Blob); ok {
+			return types.Number(b.Len())
+		}
+		fail("Expected a String, collection or Blob, found %s", types.EncodedValue(v))
+		return nil
+	}),
+	"lower": unaryFunction(func(ev *evaluator, v types.Value) types.Value {
+		return types.String(strings.ToLower(ev.string(v)))
+	}),
+	"upper": unaryFunction(func(ev *evaluator, v types.Value) types.Value {
+		return types.String(strings.ToUpper(ev.string(v)))
+	}),
+	// round rounds its first argument to the number of decimal places given by its second, if there is one, or else to a whole number.
+	"round": func(ev *evaluator, args []types.Value) types.Value {
+		if len(args) != 1 && len(args) != 2 {
+			fail("round takes one or two arguments")
+		}
+		places := types.Value(types.Number(0))
+		if len(args) == 2 {
+			places = args[1]
+		}
+		if args[0] == nil || places == nil {
+			return nil
+		}
+		scale := math.Pow(10, float64(ev.number(places)))
+		n := float64(ev.number(args[0])) * scale
+		if n < 0 {
+			return types.Number(-math.Floor(-n+0.5) / scale)
+		}
+		return types.Number(math.Floor(n+0.5) / scale)
+	},
+	// coalesce is the first of its arguments that isn't NULL.
+	"coalesce": func(ev *evaluator, args []types.Value) types.Value {
+		for _, a := range args {
+			if a != nil {
+				return a
+			}
+		}
+		return nil
+	},
+}
+
+func unaryFunction(f func(ev *evaluator, v types.Value) types.Value) scalarFunction {
+	return func(ev *evaluator, args []types.Value) types.Value {
+		if len(args) != 1 {
+			fail("Function takes one argument, but was given %d", len(args))
+		}
+		if args[0] == nil {
+			return nil
+		}
+		return f(ev, args[0])
+	}
+}
+
+// accumulator computes an aggregate over a group of rows, from the value of its argument for each, which is nil for count(*).
+type accumulator interface {
+	add(ev *evaluator, v types.Value)
+	result() types.Value
+}
+
+// aggregates make the accumulators of the aggregate functions. The aggregates skip NULLs; but for count, they're NULL over a group with no other values.
+var aggregates = map[string]func() accumulator{
+	"count": func() accumulator { return &countAcc{} },
+	"sum":   func() accumulator { return &sumAcc{} },
+	"avg":   func() accumulator { return &sumAcc{avg: true} },
+	"min":   func() accumulator { return &extremeAcc{} },
+	"max":   func() accumulator { return &extremeAcc{max: true} },
+}
+
+type countAcc struct {
+	n uint64
+}
+
+func (a *countAcc) add(ev *evaluator, v types.Value) {
+	a.n++
+}
+
+func (a *countAcc) result() types.Value {
+	return types.Number(a.n)
+}
+
+type sumAcc struct {
+	avg bool
+	n   uint64
+	sum types.Number
+}
+
+func (a *sumAcc) add(ev *evaluator, v types.Value) {
+	a.n++
+	a.sum += ev.number(v)
+}
+
+func (a *sumAcc) result() types.Value {
+	if a.n == 0 {
+		return nil
+	}
+	if a.avg {
+		return a.sum / types.Number(a.n)
+	}
+	return a.sum
+}
+
+type extremeAcc struct {
+	max bool
+	v   types.Value
+}
+
+func (a *extremeAcc) add(ev *evaluator, v types.Value) {
+	if a.v == nil || less(v, a.v) != a.max && !v.Equals(a.v) {
+		a.v = v
+	}
+}
+
+func (a *extremeAcc) result() types.Value {
+	return a.v
+}
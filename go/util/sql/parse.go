@@ -0,0 +1,493 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	quotedIdentToken
+	numberToken
+	stringToken
+	opToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var keywords = map[string]bool{
+	"AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true, "DESC": true, "FALSE": true, "FROM": true, "GROUP": true, "HAVING": true, "IN": true,
+	"IS": true, "LIKE": true, "LIMIT": true, "NOT": true, "NULL": true, "OFFSET": true, "OR": true, "ORDER": true, "SELECT": true, "TRUE": true, "WHERE": true,
+}
+
+// ops are the operators and punctuation that tokenize recognizes, longest first so that e.g. "<=" isn't read as "<" then "=".
+var ops = []string{"<=", ">=", "<>", "!=", "||", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", "."}
+
+func tokenize(s string) ([]token, error) {
+	toks := []token{}
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{identToken, s[i:j], i})
+			i = j
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])):
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				j++
+				if j < len(s) && (s[j] == '+' || s[j] == '-') {
+					j++
+				}
+				for j < len(s) && unicode.IsDigit(rune(s[j])) {
+					j++
+				}
+			}
+			toks = append(toks, token{numberToken, s[i:j], i})
+			i = j
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them, e.g. 'it''s'.
+			text := ""
+			j := i + 1
+			for {
+				k := strings.IndexByte(s[j:], s[i])
+				if k < 0 {
+					return nil, newSyntaxError(s, i, "unterminated %c", c)
+				}
+				text += s[j : j+k]
+				j += k + 1
+				if j == len(s) || s[j] != s[i] {
+					break
+				}
+				text += s[j : j+1]
+				j++
+			}
+			kind := stringToken
+			if c == '"' {
+				kind = quotedIdentToken
+			}
+			toks = append(toks, token{kind, text, i})
+			i = j
+		default:
+			op := ""
+			for _, o := range ops {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, newSyntaxError(s, i, "unexpected %q", c)
+			}
+			toks = append(toks, token{opToken, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{eofToken, "", len(s)}), nil
+}
+
+type syntaxError struct {
+	msg string
+}
+
+func (e syntaxError) Error() string {
+	return e.msg
+}
+
+func newSyntaxError(s string, pos int, format string, args ...interface{}) syntaxError {
+	return syntaxError{fmt.Sprintf("Syntax error at column %d of %q: %s", pos+1, s, fmt.Sprintf(format, args...))}
+}
+
+type parser struct {
+	s    string
+	toks []token
+	i    int
+	// aggs collects the aggregate calls in the query, in the order they're parsed. inAgg and allowAggs say whether one may be parsed where the parser is.
+	aggs      []*call
+	inAgg     bool
+	allowAggs bool
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != eofToken {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(d.Wrap(newSyntaxError(p.s, p.peek().pos, format, args...)))
+}
+
+func (p *parser) found() string {
+	t := p.peek()
+	if t.kind == eofToken {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func isKeyword(t token, kw string) bool {
+	return t.kind == identToken && strings.ToUpper(t.text) == kw
+}
+
+func (p *parser) acceptKeyword(kws ...string) bool {
+	for i, kw := range kws {
+		if !isKeyword(p.toks[p.i+i], kw) {
+			return false
+		}
+	}
+	p.i += len(kws)
+	return true
+}
+
+func (p *parser) expectKeyword(kws ...string) {
+	if !p.acceptKeyword(kws...) {
+		p.fail("expected %s, found %s", strings.Join(kws, " "), p.found())
+	}
+}
+
+func (p *parser) peekOp(op string) bool {
+	t := p.peek()
+	return t.kind == opToken && t.text == op
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.peekOp(op) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) {
+	if !p.acceptOp(op) {
+		p.fail("expected %q, found %s", op, p.found())
+	}
+}
+
+func (p *parser) ident() string {
+	t := p.peek()
+	if t.kind == quotedIdentToken || t.kind == identToken && !keywords[strings.ToUpper(t.text)] {
+		p.i++
+		return t.text
+	}
+	p.fail("expected a name, found %s", p.found())
+	return ""
+}
+
+func (p *parser) integer() int64 {
+	t := p.next()
+	n, err := strconv.ParseInt(t.text, 10, 64)
+	if t.kind != numberToken || err != nil || n < 0 {
+		p.i--
+		p.fail("expected a whole number, found %s", p.found())
+	}
+	return n
+}
+
+func parse(s string) (q *Query, err error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{s: s, toks: toks}
+	if err = d.Try(func() { q = p.query() }, syntaxError{}); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (p *parser) query() *Query {
+	q := &Query{limit: -1}
+	p.expectKeyword("SELECT")
+	p.allowAggs = true
+	for {
+		q.selects = append(q.selects, p.selectItem())
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+
+	if p.acceptKeyword("FROM") {
+		p.ident()
+	}
+	if p.acceptKeyword("WHERE") {
+		p.allowAggs = false
+		q.where = p.expr()
+		p.allowAggs = true
+	}
+	if p.acceptKeyword("GROUP", "BY") {
+		p.allowAggs = false
+		for {
+			q.groupBy = append(q.groupBy, p.expr())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		p.allowAggs = true
+	}
+	if p.acceptKeyword("HAVING") {
+		q.having = p.expr()
+	}
+	if p.acceptKeyword("ORDER", "BY") {
+		for {
+			item := orderItem{e: p.expr()}
+			if p.acceptKeyword("DESC") {
+				item.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, item)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		q.limit = p.integer()
+		if p.acceptKeyword("OFFSET") {
+			q.offset = p.integer()
+		}
+	}
+	if p.peek().kind != eofToken {
+		p.fail("unexpected %s", p.found())
+	}
+	q.aggs = p.aggs
+	return q
+}
+
+func (p *parser) selectItem() selectItem {
+	if p.acceptOp("*") {
+		return selectItem{star: true}
+	}
+	start := p.peek().pos
+	item := selectItem{e: p.expr()}
+	if p.acceptKeyword("AS") || p.peek().kind == quotedIdentToken || p.peek().kind == identToken && !keywords[strings.ToUpper(p.peek().text)] {
+		item.name = p.ident()
+	} else if c, ok := item.e.(column); ok {
+		item.name = c[len(c)-1]
+	} else {
+		item.name = strings.TrimSpace(p.s[start:p.peek().pos])
+	}
+	return item
+}
+
+// The precedence of operators, from loosest to tightest, is: OR; AND; NOT; comparisons, IS, IN, BETWEEN and LIKE; +, - and ||; *, / and %; unary -.
+func (p *parser) expr() expr {
+	e := p.and()
+	for p.acceptKeyword("OR") {
+		e = binary{"OR", e, p.and()}
+	}
+	return e
+}
+
+func (p *parser) and() expr {
+	e := p.not()
+	for p.acceptKeyword("AND") {
+		e = binary{"AND", e, p.not()}
+	}
+	return e
+}
+
+func (p *parser) not() expr {
+	if p.acceptKeyword("NOT") {
+		return unary{"NOT", p.not()}
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() expr {
+	e := p.additive()
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == opToken && (t.text == "=" || t.text == "<>" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+			p.next()
+			op := t.text
+			if op == "!=" {
+				op = "<>"
+			}
+			e = binary{op, e, p.additive()}
+		case p.acceptKeyword("IS"):
+			not := p.acceptKeyword("NOT")
+			p.expectKeyword("NULL")
+			e = isNull{e, not}
+		default:
+			not := p.acceptKeyword("NOT")
+			switch {
+			case p.acceptKeyword("IN"):
+				p.expectOp("(")
+				list := []expr{}
+				for {
+					list = append(list, p.expr())
+					if !p.acceptOp(",") {
+						break
+					}
+				}
+				p.expectOp(")")
+				e = in{e, list, not}
+			case p.acceptKeyword("BETWEEN"):
+				lo := p.additive()
+				p.expectKeyword("AND")
+				e = between{e, lo, p.additive(), not}
+			case p.acceptKeyword("LIKE"):
+				e = like{e, p.additive(), not}
+			default:
+				if not {
+					p.i--
+					p.fail("expected IN, BETWEEN or LIKE after NOT")
+				}
+				return e
+			}
+		}
+	}
+}
+
+func (p *parser) additive() expr {
+	e := p.multiplicative()
+	for {
+		switch {
+		case p.acceptOp("+"):
+			e = binary{"+", e, p.multiplicative()}
+		case p.acceptOp("-"):
+			e = binary{"-", e, p.multiplicative()}
+		case p.acceptOp("||"):
+			e = binary{"||", e, p.multiplicative()}
+		default:
+			return e
+		}
+	}
+}
+
+func (p *parser) multiplicative() expr {
+	e := p.unary()
+	for {
+		switch {
+		case p.acceptOp("*"):
+			e = binary{"*", e, p.unary()}
+		case p.acceptOp("/"):
+			e = binary{"/", e, p.unary()}
+		case p.acceptOp("%"):
+			e = binary{"%", e, p.unary()}
+		default:
+			return e
+		}
+	}
+}
+
+func (p *parser) unary() expr {
+	if p.acceptOp("-") {
+		return unary{"-", p.unary()}
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() expr {
+	t := p.peek()
+	switch {
+	case t.kind == numberToken:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			p.i--
+			p.fail("invalid number %s", p.found())
+		}
+		return literal{types.Number(f)}
+	case t.kind == stringToken:
+		p.next()
+		return literal{types.String(t.text)}
+	case p.acceptKeyword("TRUE"):
+		return literal{types.Bool(true)}
+	case p.acceptKeyword("FALSE"):
+		return literal{types.Bool(false)}
+	case p.acceptKeyword("NULL"):
+		return literal{nil}
+	case p.acceptOp("("):
+		e := p.expr()
+		p.expectOp(")")
+		return e
+	}
+
+	name := p.ident()
+	if t.kind == identToken && p.acceptOp("(") {
+		return p.call(t, strings.ToLower(name))
+	}
+	c := column{name}
+	for p.acceptOp(".") {
+		c = append(c, p.ident())
+	}
+	return c
+}
+
+func (p *parser) call(t token, name string) expr {
+	_, isAgg := aggregates[name]
+	if !isAgg && scalarFunctions[name] == nil {
+		p.i -= 2
+		p.fail("unknown function %s", name)
+	}
+	if isAgg {
+		if p.inAgg {
+			p.i -= 2
+			p.fail("aggregate functions can't be nested")
+		}
+		if !p.allowAggs {
+			p.i -= 2
+			p.fail("aggregate functions aren't allowed in WHERE or GROUP BY")
+		}
+		p.inAgg = true
+		defer func() { p.inAgg = false }()
+	}
+
+	c := &call{name: name, agg: -1}
+	if name == "count" && p.acceptOp("*") {
+		c.star = true
+	} else if !p.peekOp(")") {
+		for {
+			c.args = append(c.args, p.expr())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	p.expectOp(")")
+
+	if isAgg {
+		if !c.star && len(c.args) != 1 {
+			p.i--
+			p.fail("%s takes one argument", name)
+		}
+		c.agg = len(p.aggs)
+		p.aggs = append(p.aggs, c)
+	}
+	return c
+}
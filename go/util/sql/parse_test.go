@@ -0,0 +1,47 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	assert := assert.New(t)
+	q, err := parse("SELECT -a + b * 2 >= 3 OR NOT c AND d.e IS NOT NULL")
+	assert.NoError(err)
+	assert.Equal(binary{"OR",
+		binary{">=",
+			binary{"+", unary{"-", column{"a"}}, binary{"*", column{"b"}, literal{types.Number(2)}}},
+			literal{types.Number(3)}},
+		binary{"AND", unary{"NOT", column{"c"}}, isNull{column{"d", "e"}, true}},
+	}, q.selects[0].e)
+}
+
+func TestParseNames(t *testing.T) {
+	assert := assert.New(t)
+	q, err := parse(`select "select", a.b, 'it''s' as "x y", count( * ) n, sum(c) from t where "where" = 1 group by 1 order by 2 desc, n limit 3 offset 4`)
+	assert.NoError(err)
+	assert.Equal([]string{"select", "b", "x y", "n", "sum(c)"}, columnNames(q.selects))
+	assert.Equal(literal{types.String("it's")}, q.selects[2].e)
+	assert.Equal(binary{"=", column{"where"}, literal{types.Number(1)}}, q.where)
+	assert.Equal([]orderItem{{literal{types.Number(2)}, true}, {column{"n"}, false}}, q.orderBy)
+	assert.Equal(int64(3), q.limit)
+	assert.Equal(int64(4), q.offset)
+	assert.Len(q.aggs, 2)
+	assert.True(q.aggs[0].star)
+	assert.Equal(1, q.aggs[1].agg)
+}
+
+func TestParseErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, err := parse("SELECT a FROM t WHERE")
+	assert.EqualError(err, `Syntax error at column 22 of "SELECT a FROM t WHERE": expected a name, found end of query`)
+	_, err = parse("SELECT a, max(b FROM t")
+	assert.EqualError(err, `Syntax error at column 17 of "SELECT a, max(b FROM t": expected ")", found "FROM"`)
+}
@@ -0,0 +1,234 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sql
+
+import (
+	"math"
+	"sort"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// keyRange is the keys, elements or indexes of a table that a WHERE clause can be true of. lo and hi are nil if the range is unbounded below or above. If points isn't nil, the range is only those of its values that are between lo and hi.
+type keyRange struct {
+	lo, hi         types.Value
+	loIncl, hiIncl bool
+	points         []types.Value
+}
+
+func (kr keyRange) aboveLo(v types.Value) bool {
+	return kr.lo == nil || kr.lo.Less(v) || kr.loIncl && kr.lo.Equals(v)
+}
+
+func (kr keyRange) belowHi(v types.Value) bool {
+	return kr.hi == nil || v.Less(kr.hi) || kr.hiIncl && kr.hi.Equals(v)
+}
+
+func (kr *keyRange) restrictLo(v types.Value, incl bool) {
+	if kr.lo == nil || kr.lo.Less(v) {
+		kr.lo, kr.loIncl = v, incl
+	} else if kr.lo.Equals(v) {
+		kr.loIncl = kr.loIncl && incl
+	}
+}
+
+func (kr *keyRange) restrictHi(v types.Value, incl bool) {
+	if kr.hi == nil || v.Less(kr.hi) {
+		kr.hi, kr.hiIncl = v, incl
+	} else if kr.hi.Equals(v) {
+		kr.hiIncl = kr.hiIncl && incl
+	}
+}
+
+func (kr *keyRange) restrictPoints(vs []types.Value) {
+	if kr.points != nil {
+		both := []types.Value{}
+		for _, v := range vs {
+			for _, p := range kr.points {
+				if v.Equals(p) {
+					both = append(both, v)
+					break
+				}
+			}
+		}
+		vs = both
+	}
+	kr.points = vs
+}
+
+// whereRange finds the range of col, a pseudo-column that a table is ordered by, that where can be true of. Comparisons of col with literals, ANDed into where, restrict the range; where may be false of the rows in it too, so it still has to be evaluated.
+//
+// Values of different kinds are never equal, and are ordered within each kind as they are in a Map, so the range that a comparison restricts col to contains all the values it can be true of, even when they're not of the same kind as the literal.
+func whereRange(where expr, col string) keyRange {
+	kr := keyRange{}
+	isCol := func(e expr) bool {
+		c, ok := e.(column)
+		return ok && len(c) == 1 && c[0] == col
+	}
+	lit := func(e expr) types.Value {
+		if l, ok := e.(literal); ok {
+			return l.v
+		}
+		return nil
+	}
+
+	var restrict func(e expr)
+	restrict = func(e expr) {
+		switch e := e.(type) {
+		case binary:
+			if e.op == "AND" {
+				restrict(e.l)
+				restrict(e.r)
+				return
+			}
+			op, v := e.op, lit(e.r)
+			if !isCol(e.l) {
+				if !isCol(e.r) {
+					return
+				}
+				op, v = map[string]string{"=": "=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}[op], lit(e.l)
+			}
+			if v == nil {
+				return
+			}
+			switch op {
+			case "=":
+				kr.restrictPoints([]types.Value{v})
+			case "<", "<=":
+				kr.restrictHi(v, op == "<=")
+			case ">", ">=":
+				kr.restrictLo(v, op == ">=")
+			}
+		case between:
+			lo, hi := lit(e.lo), lit(e.hi)
+			if !e.not && isCol(e.x) && lo != nil && hi != nil {
+				kr.restrictLo(lo, true)
+				kr.restrictHi(hi, true)
+			}
+		case in:
+			if e.not || !isCol(e.x) {
+				return
+			}
+			vs := []types.Value{}
+			for _, le := range e.list {
+				v := lit(le)
+				if v == nil {
+					return
+				}
+				vs = append(vs, v)
+			}
+			kr.restrictPoints(vs)
+		}
+	}
+	if where != nil {
+		restrict(where)
+	}
+	return kr
+}
+
+// scan calls cb with the rows of table, in order, that where can be true of, until it returns true.
+func scan(ev *evaluator, table types.Value, where expr, cb func(r *row) (stop bool)) {
+	switch table := table.(type) {
+	case types.Map:
+		kr := whereRange(where, keyColumn)
+		if kr.points != nil {
+			for _, k := range sortedPoints(kr) {
+				if v, ok := table.MaybeGet(k); ok && cb(&row{k, -1, v}) {
+					return
+				}
+			}
+			return
+		}
+		iter := func(k, v types.Value) bool {
+			if !kr.belowHi(k) {
+				return true
+			}
+			return kr.aboveLo(k) && cb(&row{k, -1, v})
+		}
+		if kr.lo != nil {
+			table.IterFrom(kr.lo, iter)
+		} else {
+			table.Iter(iter)
+		}
+
+	case types.Set:
+		kr := whereRange(where, valueColumn)
+		if kr.points != nil {
+			for _, v := range sortedPoints(kr) {
+				if table.Has(v) && cb(&row{nil, -1, v}) {
+					return
+				}
+			}
+			return
+		}
+		iter := func(v types.Value) bool {
+			if !kr.belowHi(v) {
+				return true
+			}
+			return kr.aboveLo(v) && cb(&row{nil, -1, v})
+		}
+		if kr.lo != nil {
+			table.IterFrom(kr.lo, iter)
+		} else {
+			table.Iter(iter)
+		}
+
+	case types.List:
+		kr := whereRange(where, indexColumn)
+		// Indexes are Numbers, so bounds of other kinds can't be true of any row, and are left for where to find that out.
+		if _, ok := kr.lo.(types.Number); !ok {
+			kr.lo = nil
+		}
+		if _, ok := kr.hi.(types.Number); !ok {
+			kr.hi = nil
+		}
+		if kr.points != nil {
+			for _, p := range sortedPoints(kr) {
+				n, ok := p.(types.Number)
+				if ok && n >= 0 && float64(n) < float64(table.Len()) && float64(n) == math.Floor(float64(n)) {
+					if cb(&row{nil, int64(n), table.Get(uint64(n))}) {
+						return
+					}
+				}
+			}
+			return
+		}
+		start := float64(0)
+		if kr.lo != nil {
+			start = math.Max(0, math.Ceil(float64(kr.lo.(types.Number))))
+		}
+		if start >= float64(table.Len()) {
+			return
+		}
+		table.IterFrom(uint64(start), func(v types.Value, i uint64) bool {
+			idx := types.Number(i)
+			if !kr.belowHi(idx) {
+				return true
+			}
+			return kr.aboveLo(idx) && cb(&row{nil, int64(i), v})
+		})
+
+	default:
+		fail("Can't query %s; expected a List, Map or Set", types.EncodedValue(table.Type()))
+	}
+}
+
+// sortedPoints returns the points of kr that are between its bounds, in order and without duplicates.
+func sortedPoints(kr keyRange) []types.Value {
+	points := types.ValueSlice{}
+	for _, p := range kr.points {
+		if kr.aboveLo(p) && kr.belowHi(p) {
+			points = append(points, p)
+		}
+	}
+	sort.Sort(points)
+	res := []types.Value{}
+	for i, p := range points {
+		if i == 0 || !p.Equals(points[i-1]) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
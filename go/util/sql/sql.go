@@ -0,0 +1,351 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package sql runs SQL SELECT queries against Noms collections, treating them as tables, such as the List<struct Row> that csv-import makes, or a Map<String, struct>.
+//
+// Each element of a List, entry of a Map, or element of a Set is a row. The fields of a row's value are its columns, and a.b is field b of the struct in column a. A field that isn't there is NULL, so the rows of a table needn't all be the same type. Each row also has these pseudo-columns:
+//   - _key is the key of a Map entry
+//   - _index is the index of a List element
+//   - _value is the value of the row itself, which is useful when it isn't a struct
+//
+// Refs are followed wherever they're found, so e.g. a List<Ref<struct Row>> is a table like any other.
+//
+// Queries are of the form:
+//
+//	SELECT <expr> [[AS] <name>], ... [FROM <name>] [WHERE <expr>] [GROUP BY <expr>, ...] [HAVING <expr>] [ORDER BY <expr> [ASC|DESC], ...] [LIMIT <n> [OFFSET <m>]]
+//
+// The table is always the value a Query is run against, so the name after FROM is only for readability. Expressions have the usual SQL operators, including IS [NOT] NULL, [NOT] IN, [NOT] BETWEEN and [NOT] LIKE; the aggregates count, sum, avg, min and max; and the functions abs, coalesce, length, lower, round and upper. ORDER BY can name the columns of the result, or number them from 1.
+//
+// Where a query says which keys of a Map, elements of a Set, or indexes of a List it wants, with conditions on _key, _value or _index, respectively, ANDed into WHERE, only those are read. So are only as many rows as LIMIT says, if nothing has to be sorted or grouped.
+package sql
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Query is a compiled SQL query.
+type Query struct {
+	selects []selectItem
+	where   expr
+	groupBy []expr
+	having  expr
+	orderBy []orderItem
+	limit   int64
+	offset  int64
+	aggs    []*call
+}
+
+// selectItem is one of the things a query selects: either an expression and the name of its column, or *, all the columns of the table.
+type selectItem struct {
+	e    expr
+	name string
+	star bool
+}
+
+type orderItem struct {
+	e    expr
+	desc bool
+}
+
+// Result is the table that a query produces.
+type Result struct {
+	Columns []string
+	// Rows holds the values of each row's columns. NULL is nil.
+	Rows [][]types.Value
+}
+
+// Compile parses query as a SQL SELECT query.
+func Compile(query string) (*Query, error) {
+	return parse(query)
+}
+
+// Run compiles query and runs it against table, reading the targets of any Refs that it follows from vr.
+func Run(query string, table types.Value, vr types.ValueReader) (*Result, error) {
+	q, err := Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(table, vr)
+}
+
+// Run runs q against table, which must be a List, Map or Set, or a Ref to one, reading the targets of any Refs that it follows from vr.
+func (q *Query) Run(table types.Value, vr types.ValueReader) (res *Result, err error) {
+	err = d.Try(func() { res = q.run(table, vr) }, evalError{})
+	return
+}
+
+// outputRow is a row of the result, with the values it's to be sorted by.
+type outputRow struct {
+	values []types.Value
+	keys   []types.Value
+}
+
+// group is the rows with the same values of the GROUP BY expressions. Columns of a group that aren't aggregates are those of its first row.
+type group struct {
+	first *row
+	accs  []accumulator
+}
+
+func (q *Query) run(table types.Value, vr types.ValueReader) *Result {
+	ev := &evaluator{vr: vr}
+	table = ev.deref(table)
+	selects := q.expandStars(table)
+	res := &Result{Columns: columnNames(selects)}
+	order := q.resolveOrder(res.Columns)
+	grouped := len(q.groupBy) > 0 || len(q.aggs) > 0
+
+	rows := []outputRow{}
+	project := func(r *row) {
+		out := outputRow{make([]types.Value, len(selects)), make([]types.Value, len(order))}
+		for i, s := range selects {
+			out.values[i] = ev.eval(s.e, r)
+		}
+		for i, o := range order {
+			if o.column >= 0 {
+				out.keys[i] = out.values[o.column]
+			} else {
+				out.keys[i] = ev.eval(o.e, r)
+			}
+		}
+		rows = append(rows, out)
+	}
+
+	groups := map[string]*group{}
+	groupOrder := []*group{}
+	// Without sorting or grouping, only the rows up to LIMIT have to be read.
+	want := int64(-1)
+	if q.limit >= 0 && !grouped && len(order) == 0 {
+		want = q.offset + q.limit
+	}
+	scan(ev, table, q.where, func(r *row) bool {
+		if q.where != nil {
+			if w := ev.eval(q.where, r); w == nil || !ev.bool(w) {
+				return false
+			}
+		}
+		if !grouped {
+			project(r)
+			return want >= 0 && int64(len(rows)) >= want
+		}
+
+		key := groupKey(ev, q.groupBy, r)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{first: r}
+			for _, a := range q.aggs {
+				g.accs = append(g.accs, aggregates[a.name]())
+			}
+			groups[key] = g
+			groupOrder = append(groupOrder, g)
+		}
+		for i, a := range q.aggs {
+			if a.star {
+				g.accs[i].add(ev, nil)
+			} else if v := ev.eval(a.args[0], r); v != nil {
+				g.accs[i].add(ev, v)
+			}
+		}
+		return false
+	})
+
+	if grouped {
+		// Aggregates over a table with no groups are over all of its rows, even if there are none.
+		if len(q.groupBy) == 0 && len(groupOrder) == 0 {
+			g := &group{}
+			for _, a := range q.aggs {
+				g.accs = append(g.accs, aggregates[a.name]())
+			}
+			groupOrder = append(groupOrder, g)
+		}
+		for _, g := range groupOrder {
+			ev.aggValues = make([]types.Value, len(g.accs))
+			for i, acc := range g.accs {
+				ev.aggValues[i] = acc.result()
+			}
+			if q.having != nil {
+				if h := ev.eval(q.having, g.first); h == nil || !ev.bool(h) {
+					continue
+				}
+			}
+			project(g.first)
+		}
+	}
+
+	sort.Stable(byOrder{rows, order})
+
+	start, end := int64(len(rows)), int64(len(rows))
+	if q.offset < start {
+		start = q.offset
+	}
+	if q.limit >= 0 && start+q.limit < end {
+		end = start + q.limit
+	}
+	for _, r := range rows[start:end] {
+		res.Rows = append(res.Rows, r.values)
+	}
+	return res
+}
+
+type byOrder struct {
+	rows  []outputRow
+	order []resolvedOrderItem
+}
+
+func (b byOrder) Len() int      { return len(b.rows) }
+func (b byOrder) Swap(i, j int) { b.rows[i], b.rows[j] = b.rows[j], b.rows[i] }
+
+func (b byOrder) Less(i, j int) bool {
+	for k, o := range b.order {
+		x, y := b.rows[i].keys[k], b.rows[j].keys[k]
+		if o.desc {
+			x, y = y, x
+		}
+		if less(x, y) {
+			return true
+		}
+		if less(y, x) {
+			return false
+		}
+	}
+	return false
+}
+
+// expandStars replaces each * in the select list of q with the columns of table: its pseudo-columns, then the fields of the structs in it, in order of their names.
+func (q *Query) expandStars(table types.Value) []selectItem {
+	selects := []selectItem{}
+	for _, s := range q.selects {
+		if !s.star {
+			selects = append(selects, s)
+			continue
+		}
+		var rowType *types.Type
+		switch table := table.(type) {
+		case types.Map:
+			selects = append(selects, selectItem{e: column{keyColumn}, name: keyColumn})
+			rowType = table.Type().Desc.(types.CompoundDesc).ElemTypes[1]
+		case types.List, types.Set:
+			rowType = table.Type().Desc.(types.CompoundDesc).ElemTypes[0]
+		default:
+			fail("Can't query %s; expected a List, Map or Set", types.EncodedValue(table.Type()))
+		}
+		fields := structFields(rowType)
+		if len(fields) == 0 {
+			selects = append(selects, selectItem{e: column{valueColumn}, name: valueColumn})
+		}
+		for _, f := range fields {
+			selects = append(selects, selectItem{e: column{f}, name: f})
+		}
+	}
+	return selects
+}
+
+// structFields returns the names of the fields of the structs that values of type t can be, seeing through Refs and unions.
+func structFields(t *types.Type) []string {
+	names := map[string]bool{}
+	var add func(t *types.Type)
+	add = func(t *types.Type) {
+		switch t.Kind() {
+		case types.StructKind:
+			t.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+				names[name] = true
+			})
+		case types.RefKind, types.UnionKind:
+			for _, et := range t.Desc.(types.CompoundDesc).ElemTypes {
+				add(et)
+			}
+		}
+	}
+	add(t)
+	fields := []string{}
+	for n := range names {
+		fields = append(fields, n)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// columnNames returns the names of the columns that selects make. A name that's already taken has _2, _3 and so on added to it, so that the rows of the result can be structs.
+func columnNames(selects []selectItem) []string {
+	names := []string{}
+	taken := map[string]bool{}
+	for _, s := range selects {
+		name := s.name
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", s.name, i)
+		}
+		taken[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+type resolvedOrderItem struct {
+	orderItem
+	// column is the index of the result column to sort by, or -1 if it's by the value of e.
+	column int
+}
+
+func (q *Query) resolveOrder(columns []string) []resolvedOrderItem {
+	order := []resolvedOrderItem{}
+	for _, o := range q.orderBy {
+		ro := resolvedOrderItem{o, -1}
+		switch e := o.e.(type) {
+		case literal:
+			n, ok := e.v.(types.Number)
+			if !ok || n < 1 || int(n) > len(columns) || float64(n) != math.Floor(float64(n)) {
+				fail("ORDER BY %s isn't the number of a column", types.EncodedValue(e.v))
+			}
+			ro.column = int(n) - 1
+		case column:
+			if len(e) == 1 {
+				for i, c := range columns {
+					if c == e[0] {
+						ro.column = i
+						break
+					}
+				}
+			}
+		}
+		order = append(order, ro)
+	}
+	return order
+}
+
+// groupKey identifies the group that r is in, by the hashes of the values of the GROUP BY expressions.
+func groupKey(ev *evaluator, groupBy []expr, r *row) string {
+	parts := make([]string, len(groupBy))
+	for i, e := range groupBy {
+		if v := ev.eval(e, r); v != nil {
+			parts[i] = v.Hash().String()
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// List returns the rows of res as a List of structs named Row, with a field for each column, as csv-import makes, writing it to vrw as it's made. NULL columns are left out, and the names of the columns are escaped with types.EscapeStructField.
+func (res *Result) List(vrw types.ValueReadWriter) types.List {
+	fields := make([]string, len(res.Columns))
+	for i, c := range res.Columns {
+		fields[i] = types.EscapeStructField(c)
+	}
+	vals := make(chan types.Value, 1024)
+	lc := types.NewStreamingList(vrw, vals)
+	for _, r := range res.Rows {
+		data := types.StructData{}
+		for i, v := range r {
+			if v != nil {
+				data[fields[i]] = v
+			}
+		}
+		vals <- types.NewStruct("Row", data)
+	}
+	close(vals)
+	return <-lc
+}
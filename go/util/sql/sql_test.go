@@ -0,0 +1,247 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestSQLTestSuite(t *testing.T) {
+	suite.Run(t, &SQLTestSuite{})
+}
+
+type SQLTestSuite struct {
+	suite.Suite
+	vs     *types.ValueStore
+	reads  map[hash.Hash]bool
+	people types.List
+	byName types.Map
+}
+
+// ReadValue records the hashes that a query reads, so that tests can check that it reads only what it needs.
+func (suite *SQLTestSuite) ReadValue(h hash.Hash) types.Value {
+	suite.reads[h] = true
+	return suite.vs.ReadValue(h)
+}
+
+func person(name, city string, age float64) types.Struct {
+	data := types.StructData{
+		"name": types.String(name),
+		"age":  types.Number(age),
+	}
+	if city != "" {
+		data["city"] = types.String(city)
+	}
+	return types.NewStruct("Person", data)
+}
+
+func (suite *SQLTestSuite) SetupTest() {
+	suite.vs = types.NewTestValueStore()
+	suite.reads = map[hash.Hash]bool{}
+	people := []types.Struct{
+		person("alice", "sf", 42),
+		person("bob", "nyc", 25),
+		person("carol", "sf", 31),
+		person("dave", "", 58),
+		person("erin", "nyc", 19),
+	}
+	refs := []types.Value{}
+	kvs := []types.Value{}
+	for _, p := range people {
+		r := suite.vs.WriteValue(p)
+		refs = append(refs, r)
+		kvs = append(kvs, p.Get("name"), r)
+	}
+	suite.people = types.NewList(refs...)
+	suite.byName = types.NewMap(kvs...)
+}
+
+func (suite *SQLTestSuite) run(query string, table types.Value) *Result {
+	res, err := Run(query, table, suite)
+	suite.NoError(err, query)
+	return res
+}
+
+func (suite *SQLTestSuite) assertRows(query string, table types.Value, columns []string, rows ...[]types.Value) {
+	res := suite.run(query, table)
+	if res == nil {
+		return
+	}
+	suite.Equal(columns, res.Columns, query)
+	suite.Equal(len(rows), len(res.Rows), query)
+	for i := 0; i < len(rows) && i < len(res.Rows); i++ {
+		suite.Equal(len(rows[i]), len(res.Rows[i]), query)
+		for j, v := range rows[i] {
+			if v == nil {
+				suite.Nil(res.Rows[i][j], query)
+			} else {
+				suite.True(v.Equals(res.Rows[i][j]), "%s: row %d column %d", query, i, j)
+			}
+		}
+	}
+}
+
+func vals(vs ...interface{}) []types.Value {
+	res := make([]types.Value, len(vs))
+	for i, v := range vs {
+		switch v := v.(type) {
+		case string:
+			res[i] = types.String(v)
+		case int:
+			res[i] = types.Number(v)
+		case float64:
+			res[i] = types.Number(v)
+		case bool:
+			res[i] = types.Bool(v)
+		case nil:
+		default:
+			panic("unexpected value")
+		}
+	}
+	return res
+}
+
+func (suite *SQLTestSuite) TestSelectWhere() {
+	suite.assertRows("SELECT name, age FROM people WHERE age > 30 AND city = 'sf'", suite.people, []string{"name", "age"},
+		vals("alice", 42), vals("carol", 31))
+	suite.assertRows("select _index, upper(name) AS n, age * 2 from t where city is null or name like '_r%'", suite.people, []string{"_index", "n", "age * 2"},
+		vals(3, "DAVE", 116), vals(4, "ERIN", 38))
+	suite.assertRows("SELECT name WHERE city NOT IN ('sf', 'nyc') OR age BETWEEN 20 AND 25", suite.people, []string{"name"},
+		vals("bob"))
+	suite.assertRows("SELECT name, city FROM people WHERE NOT city = 'sf'", suite.people, []string{"name", "city"},
+		vals("bob", "nyc"), vals("erin", "nyc"))
+}
+
+func (suite *SQLTestSuite) TestSelectStar() {
+	suite.assertRows("SELECT * FROM people LIMIT 1", suite.people, []string{"age", "city", "name"},
+		vals(42, "sf", "alice"))
+	suite.assertRows("SELECT *, age FROM people WHERE _key = 'dave'", suite.byName, []string{"_key", "age", "city", "name", "age_2"},
+		vals("dave", 58, nil, "dave", 58))
+	suite.assertRows("SELECT * FROM t", types.NewSet(types.Number(2), types.Number(1)), []string{"_value"},
+		vals(1), vals(2))
+}
+
+func (suite *SQLTestSuite) TestOrderByLimit() {
+	suite.assertRows("SELECT name FROM people ORDER BY age DESC LIMIT 2", suite.people, []string{"name"},
+		vals("dave"), vals("alice"))
+	suite.assertRows("SELECT name, city AS c FROM people ORDER BY c, 1 DESC LIMIT 3 OFFSET 1", suite.people, []string{"name", "c"},
+		vals("erin", "nyc"), vals("bob", "nyc"), vals("carol", "sf"))
+	suite.assertRows("SELECT name FROM people LIMIT 0", suite.people, []string{"name"})
+	suite.assertRows("SELECT name FROM people LIMIT 2 OFFSET 4", suite.people, []string{"name"}, vals("erin"))
+}
+
+func (suite *SQLTestSuite) TestAggregates() {
+	suite.assertRows("SELECT count(*), count(city), sum(age), min(name), max(age), round(avg(age), 1) FROM people", suite.people,
+		[]string{"count(*)", "count(city)", "sum(age)", "min(name)", "max(age)", "round(avg(age), 1)"},
+		vals(5, 4, 175, "alice", 58, 35))
+	suite.assertRows("SELECT city, count(*) AS n, sum(age) FROM people GROUP BY city ORDER BY n DESC, city", suite.people, []string{"city", "n", "sum(age)"},
+		vals("nyc", 2, 44), vals("sf", 2, 73), vals(nil, 1, 58))
+	suite.assertRows("SELECT city, max(age) FROM people GROUP BY city HAVING count(*) > 1 AND max(age) > 40", suite.people, []string{"city", "max(age)"},
+		vals("sf", 42))
+	suite.assertRows("SELECT count(*), sum(age) FROM people WHERE age > 100", suite.people, []string{"count(*)", "sum(age)"},
+		vals(0, nil))
+	suite.assertRows("SELECT city FROM people WHERE age > 100 GROUP BY city", suite.people, []string{"city"})
+}
+
+func (suite *SQLTestSuite) TestNulls() {
+	suite.assertRows("SELECT 1 + NULL, NULL = NULL, NULL OR true, NULL AND false, coalesce(NULL, 'x'), 1 / 0 LIMIT 1", suite.people,
+		[]string{"1 + NULL", "NULL = NULL", "NULL OR true", "NULL AND false", "coalesce(NULL, 'x')", "1 / 0"},
+		vals(nil, nil, true, false, "x", nil))
+	suite.assertRows("SELECT nope.nope, _key, length(name), 'a' || 'b' AS ab FROM people WHERE _index = 0", suite.people, []string{"nope", "_key", "length(name)", "ab"},
+		vals(nil, nil, 5, "ab"))
+}
+
+func (suite *SQLTestSuite) TestNestedFields() {
+	table := types.NewList(
+		types.NewStruct("", types.StructData{"p": suite.people.Get(0), "n": types.Number(1)}),
+		types.NewStruct("", types.StructData{"p": suite.people.Get(1), "n": types.Number(2)}),
+	)
+	suite.assertRows("SELECT p.name, n FROM t WHERE p.age < 30", table, []string{"name", "n"}, vals("bob", 2))
+}
+
+func (suite *SQLTestSuite) TestKeyRanges() {
+	// The conditions on age are evaluated before those on _key, so every row the query looks at is read.
+	suite.assertRows("SELECT name FROM people WHERE age > 0 AND _key >= 'b' AND 'd' > _key", suite.byName, []string{"name"},
+		vals("bob"), vals("carol"))
+	suite.Len(suite.reads, 2)
+
+	suite.reads = map[hash.Hash]bool{}
+	suite.assertRows("SELECT name FROM people WHERE age > 0 AND _key IN ('erin', 'alice', 'zed')", suite.byName, []string{"name"},
+		vals("alice"), vals("erin"))
+	suite.Len(suite.reads, 2)
+
+	suite.reads = map[hash.Hash]bool{}
+	suite.assertRows("SELECT name FROM people WHERE age > 0 AND _key BETWEEN 'c' AND 'e' AND _key > 'carol'", suite.byName, []string{"name"},
+		vals("dave"))
+	suite.Len(suite.reads, 1)
+
+	suite.reads = map[hash.Hash]bool{}
+	suite.assertRows("SELECT name FROM people WHERE age > 0 AND _key > 1", suite.byName, []string{"name"})
+
+	suite.reads = map[hash.Hash]bool{}
+	suite.assertRows("SELECT name FROM people WHERE age > 0 AND _index > 1.5 AND _index <= 3", suite.people, []string{"name"},
+		vals("carol"), vals("dave"))
+	suite.Len(suite.reads, 2)
+
+	suite.reads = map[hash.Hash]bool{}
+	suite.assertRows("SELECT name FROM people WHERE age > 0 LIMIT 2", suite.people, []string{"name"},
+		vals("alice"), vals("bob"))
+	suite.Len(suite.reads, 2)
+
+	set := types.NewSet(types.Number(1), types.Number(2), types.Number(3), types.String("a"))
+	suite.assertRows("SELECT _value FROM t WHERE _value < 3", set, []string{"_value"}, vals(1), vals(2))
+	suite.assertRows("SELECT _value FROM t WHERE _value = 'a' OR _value = 1", set, []string{"_value"}, vals(1), vals("a"))
+}
+
+func (suite *SQLTestSuite) TestResultList() {
+	res := suite.run("SELECT name, city, age + 1 FROM people WHERE age > 40", suite.people)
+	l := res.List(suite.vs)
+	suite.True(types.NewList(
+		types.NewStruct("Row", types.StructData{"name": types.String("alice"), "city": types.String("sf"), types.EscapeStructField("age + 1"): types.Number(43)}),
+		types.NewStruct("Row", types.StructData{"name": types.String("dave"), types.EscapeStructField("age + 1"): types.Number(59)}),
+	).Equals(l))
+}
+
+func (suite *SQLTestSuite) TestErrors() {
+	for _, q := range []string{
+		"SELECT",
+		"SELECT name FROM",
+		"SELECT name WHERE",
+		"SELECT name, FROM people",
+		"SELECT 'name",
+		"SELECT name LIMIT -1",
+		"SELECT nope(name)",
+		"SELECT count(sum(age))",
+		"SELECT name WHERE count(*) > 1",
+		"SELECT name GROUP BY max(age)",
+		"SELECT sum(age, name)",
+		"SELECT name NOT 'a'",
+		"SELECT name ORDER BY",
+		"SELECT name # x",
+	} {
+		_, err := Compile(q)
+		suite.Error(err, q)
+	}
+
+	for _, q := range []string{
+		"SELECT name + 1",
+		"SELECT name WHERE age",
+		"SELECT sum(name)",
+		"SELECT upper(age)",
+		"SELECT name ORDER BY 3",
+	} {
+		_, err := Run(q, suite.people, suite)
+		suite.Error(err, q)
+	}
+
+	_, err := Run("SELECT *", types.Number(1), suite)
+	suite.Error(err)
+	_, err = Run("SELECT 1", types.String("a"), suite)
+	suite.Error(err)
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package walk
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// DatasetStats accounts for the storage used by a dataset: every chunk reachable from its head Commit, including its history.
+type DatasetStats struct {
+	ID     string
+	Chunks uint64
+	Bytes  uint64
+	// UniqueChunks and UniqueBytes count the chunks that no other dataset reaches.
+	UniqueChunks uint64
+	UniqueBytes  uint64
+	// CollectionDepth is the number of levels in the deepest tree of chunks that a collection in the dataset is split into: 1 for collections that fit in a single chunk, and 0 if no collection has a chunk of its own.
+	CollectionDepth int
+	// SizeHistogram counts chunks by size: SizeHistogram[i] is the number whose size is at least 2^i bytes and less than 2^(i+1).
+	SizeHistogram []uint64
+	// MissingChunks counts the chunks that the dataset refers to but that aren't in the store, such as the parents left out of a shallow pull. The walk stops at them, so nothing below them is counted.
+	MissingChunks uint64
+}
+
+// chunkStats is what DatasetsStats records about each chunk as it walks.
+type chunkStats struct {
+	size uint64
+	// datasets is how many datasets reach the chunk, and owner the index of the last of them.
+	datasets int
+	owner    int
+	// collection is true if the chunk holds a collection, in which case children are the chunks below it in the collection's tree, if any.
+	collection bool
+	children   []hash.Hash
+	depth      int
+}
+
+// DatasetsStats walks every chunk reachable from the heads of the datasets in datasets, a Map of dataset IDs to Refs of Commits like the one returned by datas.Database.Datasets, reading them from bs. It returns the stats of each dataset, in the order of the Map. Chunks that aren't in bs, as in a database made shallow by datas.PullWithDepth, are counted in MissingChunks rather than walked.
+func DatasetsStats(datasets types.Map, bs types.BatchStore, concurrency int) []DatasetStats {
+	all := map[hash.Hash]*chunkStats{}
+	collections := make([][]hash.Hash, datasets.Len())
+	stats := make([]DatasetStats, 0, datasets.Len())
+	mu := sync.Mutex{}
+
+	datasets.IterAll(func(k, v types.Value) {
+		i := len(stats)
+		ds := DatasetStats{ID: string(k.(types.String))}
+
+		someChunksP(v.(types.Ref), bs, func(r types.Ref) bool {
+			return false
+		}, func(r types.Ref, c chunks.Chunk) {
+			cs := &chunkStats{size: uint64(len(c.Data()))}
+			if isCollectionRef(r) {
+				coll := types.DecodeValue(c, nil).(types.Collection)
+				cs.collection = true
+				if types.IsMetaCollection(coll) {
+					for _, child := range coll.Chunks() {
+						cs.children = append(cs.children, child.TargetHash())
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if prev, ok := all[r.TargetHash()]; ok {
+				cs = prev
+			} else {
+				all[r.TargetHash()] = cs
+			}
+			cs.datasets++
+			cs.owner = i
+			if cs.collection {
+				collections[i] = append(collections[i], r.TargetHash())
+			}
+
+			ds.Chunks++
+			ds.Bytes += cs.size
+			bucket := sizeBucket(cs.size)
+			for len(ds.SizeHistogram) <= bucket {
+				ds.SizeHistogram = append(ds.SizeHistogram, 0)
+			}
+			ds.SizeHistogram[bucket]++
+		}, func(r types.Ref) {
+			mu.Lock()
+			defer mu.Unlock()
+			ds.MissingChunks++
+		}, concurrency)
+
+		stats = append(stats, ds)
+	})
+
+	for _, cs := range all {
+		if cs.datasets == 1 {
+			stats[cs.owner].UniqueChunks++
+			stats[cs.owner].UniqueBytes += cs.size
+		}
+	}
+	for i, hashes := range collections {
+		for _, h := range hashes {
+			if depth := collectionDepth(all, h); depth > stats[i].CollectionDepth {
+				stats[i].CollectionDepth = depth
+			}
+		}
+	}
+	return stats
+}
+
+// isCollectionRef returns true if r refers to a Blob, List, Map or Set.
+func isCollectionRef(r types.Ref) bool {
+	switch r.Type().Desc.(types.CompoundDesc).ElemTypes[0].Kind() {
+	case types.BlobKind, types.ListKind, types.MapKind, types.SetKind:
+		return true
+	}
+	return false
+}
+
+// collectionDepth returns the number of levels in the tree of collection chunks below, and including, the one with hash h.
+func collectionDepth(all map[hash.Hash]*chunkStats, h hash.Hash) int {
+	cs := all[h]
+	if cs.depth == 0 {
+		cs.depth = 1
+		for _, child := range cs.children {
+			if depth := collectionDepth(all, child) + 1; depth > cs.depth {
+				cs.depth = depth
+			}
+		}
+	}
+	return cs.depth
+}
+
+// sizeBucket returns the index of the bucket in DatasetStats.SizeHistogram that a chunk of size bytes is counted in.
+func sizeBucket(size uint64) int {
+	bucket := 0
+	for size > 1 {
+		size >>= 1
+		bucket++
+	}
+	return bucket
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package walk
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestDatasetsStats(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := datas.NewDatabase(cs)
+
+	nums := make([]types.Value, 10000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	big := types.NewList(nums...)
+	assert.True(types.IsMetaCollection(big))
+	assert.False(types.IsMetaCollection(types.NewList(types.Number(1))))
+
+	a := dataset.NewDataset(db, "a")
+	a, err := a.CommitValue(db.WriteValue(big))
+	assert.NoError(err)
+	a, err = a.CommitValue(types.String("inline"))
+	assert.NoError(err)
+	b := dataset.NewDataset(a.Database(), "b")
+	b, err = b.CommitValue(b.Database().WriteValue(big))
+	assert.NoError(err)
+	c := dataset.NewDataset(b.Database(), "c")
+	c, err = c.CommitValue(types.Number(42))
+	assert.NoError(err)
+	db = c.Database()
+
+	stats := DatasetsStats(db.Datasets(), types.NewBatchStoreAdaptor(cs), 4)
+	assert.Len(stats, 3)
+	sa, sb, sc := stats[0], stats[1], stats[2]
+	assert.Equal("a", sa.ID)
+	assert.Equal("b", sb.ID)
+	assert.Equal("c", sc.ID)
+
+	// b's only commit is the same as a's first, so all that's unique to a is its second commit.
+	assert.Equal(uint64(1), sa.UniqueChunks)
+	assert.Equal(uint64(0), sb.UniqueChunks)
+	assert.Equal(sa.Chunks-1, sb.Chunks)
+	assert.True(sa.Chunks > 3)
+	assert.Equal(uint64(1), sc.Chunks)
+	assert.Equal(sc.Chunks, sc.UniqueChunks)
+	assert.Equal(sc.Bytes, sc.UniqueBytes)
+
+	assert.True(sa.CollectionDepth > 1)
+	assert.Equal(sa.CollectionDepth, sb.CollectionDepth)
+	assert.Equal(0, sc.CollectionDepth)
+
+	for _, s := range stats {
+		total := uint64(0)
+		for _, n := range s.SizeHistogram {
+			total += n
+		}
+		assert.Equal(s.Chunks, total)
+	}
+}
+
+func TestDatasetsStatsShallow(t *testing.T) {
+	assert := assert.New(t)
+	sourceCS := chunks.NewTestStore()
+	source := dataset.NewDataset(datas.NewDatabase(sourceCS), "ds")
+	var err error
+	for i := 0; i < 4; i++ {
+		source, err = source.CommitValue(types.Number(i))
+		assert.NoError(err)
+	}
+	full := DatasetsStats(source.Database().Datasets(), types.NewBatchStoreAdaptor(sourceCS), 4)
+	assert.Equal(uint64(4), full[0].Chunks)
+	assert.Equal(uint64(0), full[0].MissingChunks)
+
+	// Only the two most recent commits are pulled, so the parent of the older one is missing.
+	cs := chunks.NewTestStore()
+	sink := datas.NewDatabase(cs)
+	head := source.HeadRef()
+	assert.NoError(datas.PullWithDepth(source.Database(), sink, head, types.Ref{}, 2, 1, nil))
+	sink, err = sink.Commit("ds", head.TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+	assert.Equal(uint64(1), sink.ShallowCommits().Len())
+
+	stats := DatasetsStats(sink.Datasets(), types.NewBatchStoreAdaptor(cs), 4)
+	assert.Len(stats, 1)
+	assert.Equal(uint64(2), stats[0].Chunks)
+	assert.Equal(uint64(1), stats[0].MissingChunks)
+}
+
+func TestSizeBucket(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, sizeBucket(1))
+	assert.Equal(1, sizeBucket(2))
+	assert.Equal(1, sizeBucket(3))
+	assert.Equal(10, sizeBucket(1024))
+	assert.Equal(10, sizeBucket(2047))
+}
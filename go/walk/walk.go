@@ -123,6 +123,11 @@ type SomeChunksChunkCallback func(r types.Ref, c chunks.Chunk)
 // |stopCb| is invoked for the types.Ref of every chunk. It can return true to stop SomeChunksP from descending any further.
 // |chunkCb| is optional, invoked with the chunks.Chunk referenced by |stopCb| if it didn't return true.
 func SomeChunksP(r types.Ref, bs types.BatchStore, stopCb SomeChunksStopCallback, chunkCb SomeChunksChunkCallback, concurrency int) {
+	someChunksP(r, bs, stopCb, chunkCb, nil, concurrency)
+}
+
+// someChunksP is SomeChunksP, except that if missingCb isn't nil, a chunk that isn't in bs is passed to it, and the walk doesn't descend below it, rather than being an error.
+func someChunksP(r types.Ref, bs types.BatchStore, stopCb SomeChunksStopCallback, chunkCb SomeChunksChunkCallback, missingCb func(r types.Ref), concurrency int) {
 	rq := newRefQueue()
 	wg := sync.WaitGroup{}
 	mu := sync.Mutex{}
@@ -147,6 +152,10 @@ func SomeChunksP(r types.Ref, bs types.BatchStore, stopCb SomeChunksStopCallback
 
 		if chunkCb != nil || r.Height() > 1 {
 			c = bs.Get(tr)
+			if c.IsEmpty() && missingCb != nil {
+				missingCb(r)
+				return
+			}
 			d.Chk.False(c.IsEmpty())
 
 			if chunkCb != nil {
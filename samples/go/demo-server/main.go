@@ -5,10 +5,12 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 	flag "github.com/tsuru/gnuflag"
 )
 
@@ -16,6 +18,9 @@ var (
 	portFlag    = flag.Int("port", 8000, "port to listen on")
 	ldbDir      = flag.String("ldb-dir", "", "directory for ldb database")
 	authKeyFlag = flag.String("authkey", "", "token to use for authenticating write operations")
+	tlsCertFlag = flag.String("tls-cert", "", "PEM file of the certificate to serve HTTPS with")
+	tlsKeyFlag  = flag.String("tls-key", "", "PEM file of the private key for -tls-cert")
+	tlsCAFlag   = flag.String("tls-client-ca", "", "PEM file of certificate authorities that client certificates must be signed by")
 )
 
 func usage() {
@@ -30,11 +35,18 @@ func main() {
 	flag.Usage = usage
 	flag.Parse(true)
 
-	if *portFlag == 0 || *authKeyFlag == "" {
+	if *portFlag == 0 || *authKeyFlag == "" || (*tlsCertFlag == "") != (*tlsKeyFlag == "") || (*tlsCAFlag != "" && *tlsCertFlag == "") {
 		usage()
 		return
 	}
 
+	var tlsConfig *tls.Config
+	if *tlsCertFlag != "" {
+		var err error
+		tlsConfig, err = datas.NewServerTLSConfig(*tlsCertFlag, *tlsKeyFlag, *tlsCAFlag)
+		d.CheckErrorNoUsage(err)
+	}
+
 	var factory chunks.Factory
 	if factory = dynFlags.CreateFactory(); factory != nil {
 		fmt.Printf("Using dynamo ...\n")
@@ -48,7 +60,7 @@ func main() {
 	factory = &cachingReadThroughStoreFactory{chunks.NewMemoryStore(), factory}
 	defer factory.Shutter()
 
-	startWebServer(factory, *authKeyFlag)
+	startWebServer(factory, *authKeyFlag, tlsConfig)
 }
 
 type cachingReadThroughStoreFactory struct {
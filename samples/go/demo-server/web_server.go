@@ -5,6 +5,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
@@ -62,14 +63,20 @@ func setupWebServer(factory chunks.Factory) *httprouter.Router {
 	return router
 }
 
-func startWebServer(factory chunks.Factory, key string) {
+// startWebServer serves databases from factory, requiring key for writes. If tlsConfig is non-nil, it serves HTTPS.
+func startWebServer(factory chunks.Factory, key string, tlsConfig *tls.Config) {
 	d.Chk.NotEmpty(key, "No auth key was provided to startWebServer")
 	authKey = key
 	router = setupWebServer(factory)
 
-	fmt.Printf("Listening on http://localhost:%d/...\n", *portFlag)
+	scheme := "http"
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", *portFlag))
 	d.Chk.NoError(err)
+	if tlsConfig != nil {
+		scheme = "https"
+		l = tls.NewListener(l, tlsConfig)
+	}
+	fmt.Printf("Listening on %s://localhost:%d/...\n", scheme, *portFlag)
 	srv := &http.Server{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			router.ServeHTTP(w, req)